@@ -16,6 +16,8 @@ limitations under the License.
 
 package api
 
+import "sort"
+
 func getAddressOfType(t NetworkAddressType, a []NetworkAddress) string {
 	for i := 0; i < len(a); i++ {
 		if a[i].Type == t {
@@ -25,6 +27,40 @@ func getAddressOfType(t NetworkAddressType, a []NetworkAddress) string {
 	return ""
 }
 
+// GetAddress returns the first address of the given type, or the
+// empty string when there is none.
+func GetAddress(addrs []NetworkAddress, t NetworkAddressType) string {
+	return getAddressOfType(t, addrs)
+}
+
+// addressSortOrder is the canonical ordering of address types within
+// an address list; see SortAddresses.
+var addressSortOrder = map[NetworkAddressType]int{
+	PodIP:      0,
+	PrivateIP:  1,
+	PublicIP:   2,
+	PrivateDNS: 3,
+	PublicDNS:  4,
+}
+
+// SortAddresses puts an address list into a deterministic order by
+// address type, so clients that read "the first IP" see consistent
+// results no matter in which order the addresses were discovered.
+// Unknown types sort last; addresses of the same type keep their
+// relative order. The helpers that modify address lists sort them,
+// so lists built through them are always ordered.
+func SortAddresses(a []NetworkAddress) []NetworkAddress {
+	sort.SliceStable(a, func(i, j int) bool {
+		oi, iKnown := addressSortOrder[a[i].Type]
+		oj, jKnown := addressSortOrder[a[j].Type]
+		if !iKnown || !jKnown {
+			return iKnown
+		}
+		return oi < oj
+	})
+	return a
+}
+
 func GetPublicIP(a []NetworkAddress) string {
 	return getAddressOfType(PublicIP, a)
 }
@@ -72,7 +108,7 @@ func SetPodIP(ip string, a []NetworkAddress) []NetworkAddress {
 		Type:    PodIP,
 		Address: ip,
 	})
-	return a
+	return SortAddresses(a)
 }
 
 func SetPrivateDNS(dns string, a []NetworkAddress) []NetworkAddress {
@@ -86,7 +122,7 @@ func SetPrivateDNS(dns string, a []NetworkAddress) []NetworkAddress {
 		Type:    PrivateDNS,
 		Address: dns,
 	})
-	return a
+	return SortAddresses(a)
 }
 
 func SetPublicAddresses(ip, dns string, a []NetworkAddress) []NetworkAddress {
@@ -113,7 +149,7 @@ func SetPublicAddresses(ip, dns string, a []NetworkAddress) []NetworkAddress {
 			Address: dns,
 		})
 	}
-	return a
+	return SortAddresses(a)
 }
 
 func DeletePublicAddresses(a []NetworkAddress) []NetworkAddress {
@@ -0,0 +1,55 @@
+/*
+Copyright 2020 Elotl Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSortAddresses(t *testing.T) {
+	a := []NetworkAddress{
+		{Type: PublicDNS, Address: "ec2.example.com"},
+		{Type: PrivateIP, Address: "10.0.0.2"},
+		{Type: PublicIP, Address: "54.0.0.2"},
+		{Type: PodIP, Address: "172.16.0.2"},
+		{Type: PrivateDNS, Address: "ip-10-0-0-2.internal"},
+	}
+	sorted := SortAddresses(a)
+	expected := []NetworkAddressType{
+		PodIP, PrivateIP, PublicIP, PrivateDNS, PublicDNS,
+	}
+	for i, at := range expected {
+		assert.Equal(t, at, sorted[i].Type)
+	}
+}
+
+func TestSetAddressesKeepsOrder(t *testing.T) {
+	a := NewNetworkAddresses("10.0.0.2", "ip-10-0-0-2.internal")
+	a = SetPublicAddresses("54.0.0.2", "ec2.example.com", a)
+	a = SetPodIP("172.16.0.2", a)
+	expected := []NetworkAddressType{
+		PodIP, PrivateIP, PublicIP, PrivateDNS, PublicDNS,
+	}
+	assert.Len(t, a, len(expected))
+	for i, at := range expected {
+		assert.Equal(t, at, a[i].Type)
+	}
+	assert.Equal(t, "172.16.0.2", GetAddress(a, PodIP))
+	assert.Equal(t, "", GetAddress(a, NetworkAddressType("bogus")))
+}
@@ -43,6 +43,11 @@ const PodVolumeSize = "pod.elotl.co/volume-size"
 // value to false will not override the cloud subnet settings.
 const PodResourcesPrivateIPOnly = "pod.elotl.co/private-ip-only"
 
+// PodAvailabilityZone is an annotation users can put on their
+// kubernetes pods to pin the pod to a specific availability zone.
+// The zone must exist in the cloud region kip runs in.
+const PodAvailabilityZone = "pod.elotl.co/availability-zone"
+
 // PodSecurityGroups is an annotation users can put on their
 // kubernetes pods to tell kip to add additional security groups
 // to the instance backing their pod.
@@ -71,8 +76,51 @@ const PodTaskRole = "pod.elotl.co/task-role"
 // will not be terminated due to failing healthchecks.
 const PodHealthcheckHealthyTimeout = "pod.elotl.co/healthcheck-healthy-timeout"
 
+// PodSecondaryInterfaceSubnet is an annotation users can put on their
+// kubernetes pods to tell kip to attach a second network interface to
+// the instance backing their pod, created in the given subnet. The
+// subnet must be in the availability zone the pod runs in. Only
+// supported on AWS.
+const PodSecondaryInterfaceSubnet = "pod.elotl.co/secondary-interface-subnet"
+
+// PodSecondaryInterfaceSecurityGroups sets the security groups of the
+// secondary network interface requested via the
+// pod.elotl.co/secondary-interface-subnet annotation. The value must
+// be one or more security group IDs separated by commas. If unset,
+// kip's boot security groups are used for the interface.
+const PodSecondaryInterfaceSecurityGroups = "pod.elotl.co/secondary-interface-security-groups"
+
+// PodSkipCABundle is an annotation users can put on their kubernetes
+// pods to opt out of the trusted CA bundle that kip mounts into every
+// pod when cells.trustedCAFile is configured.
+const PodSkipCABundle = "pod.elotl.co/skip-ca-bundle"
+
+// PodMaxStartFailures is an annotation users can put on their
+// kubernetes pods to override the number of times kip will retry
+// starting the pod on a fresh cell after a start failure. Once the
+// pod has failed to start more than max-start-failures times it is
+// marked as failed instead of being retried. The counter is reset
+// whenever all of the pod's units start successfully.
+const PodMaxStartFailures = "pod.elotl.co/max-start-failures"
+
+// PodSuspend is an annotation users can put on their kubernetes pods
+// to tell kip to stop the cell backing the pod without deleting the
+// pod itself. The pod object and any persistent volumes are kept, but
+// anything stored on the cell (emptyDir contents, container
+// filesystems, process state) is lost. Setting the annotation to
+// "false" or removing it resumes the pod on a fresh cell.
+const PodSuspend = "pod.elotl.co/suspend"
+
 // PodCloudRoute can be used to add one or more routes to the cloud subnet
 // route table. The value must be one or more CIDRs separated by whitespace,
 // e.g. "10.20.30.40/24 192.168.1.0/28". Route to these CIDRs, using the
 // instance as the next hop, will be added to the route table of the subnet.
 const PodCloudRoute = "pod.elotl.co/cloud-route"
+
+// PodReplaceOnImageUpdate is an annotation users can put on their
+// kubernetes pods to opt in (or, with "false", out) of the boot image
+// rollout: when the cells.bootImageRollout config is enabled and the
+// pod's cell runs an outdated boot image, the cell is replaced and the
+// pod restarted on the new image. When present the annotation
+// overrides the namespace list in the rollout config.
+const PodReplaceOnImageUpdate = "pod.elotl.co/replace-on-image-update"
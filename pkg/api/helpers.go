@@ -19,9 +19,11 @@ package api
 import (
 	"fmt"
 	"reflect"
+	"strings"
 
 	"github.com/elotl/kip/pkg/labels"
 	"github.com/elotl/kip/pkg/selection"
+	"k8s.io/apimachinery/pkg/api/resource"
 )
 
 // LabelSelectorAsSelector converts the LabelSelector api type into a struct that implements
@@ -185,7 +187,14 @@ func ToObjectReference(resource interface{}) ObjectReference {
 	}
 }
 
-func IsHostNetwork(securityContext *PodSecurityContext) bool {
+func IsHostNetwork(podSpec *PodSpec) bool {
+	if podSpec == nil {
+		return false
+	}
+	if podSpec.HostNetwork {
+		return true
+	}
+	securityContext := podSpec.SecurityContext
 	if securityContext == nil {
 		return false
 	}
@@ -195,3 +204,80 @@ func IsHostNetwork(securityContext *PodSecurityContext) bool {
 	}
 	return true
 }
+
+// normalizeQuantity rewrites a quantity string into
+// resource.Quantity's canonical form (e.g. "0.5" becomes "500m",
+// "2.0" becomes "2"). Empty and malformed values are returned as-is;
+// validation rejects the malformed ones with a proper field error.
+func normalizeQuantity(s string) string {
+	if s == "" {
+		return s
+	}
+	q, err := resource.ParseQuantity(s)
+	if err != nil {
+		return s
+	}
+	return q.String()
+}
+
+// unitRequest parses a single unit's request for the named resource.
+// Malformed values count as zero; validation rejects them with a
+// proper field error.
+func unitRequest(u *Unit, name string) resource.Quantity {
+	if s, ok := u.Resources.Requests[name]; ok {
+		if q, err := resource.ParseQuantity(s); err == nil {
+			return q
+		}
+	}
+	return resource.Quantity{}
+}
+
+// AggregateUnitResources computes the pod-level resource footprint
+// implied by per-unit requests. Regular units run concurrently so
+// their requests are summed; init units run one at a time before the
+// regular units start, so each init unit only raises a total to its
+// own request when that is larger. Fractional CPU requests like "0.5"
+// are summed as quantities.
+func AggregateUnitResources(spec *PodSpec) ResourceSpec {
+	var out ResourceSpec
+	for _, name := range []string{"cpu", "memory", "gpu"} {
+		total := resource.Quantity{}
+		for i := range spec.Units {
+			q := unitRequest(&spec.Units[i], name)
+			total.Add(q)
+		}
+		for i := range spec.InitUnits {
+			if q := unitRequest(&spec.InitUnits[i], name); q.Cmp(total) > 0 {
+				total = q
+			}
+		}
+		if total.IsZero() {
+			continue
+		}
+		switch name {
+		case "cpu":
+			out.CPU = total.String()
+		case "memory":
+			out.Memory = total.String()
+		case "gpu":
+			out.GPU = total.String()
+		}
+	}
+	return out
+}
+
+// NormalizeResourceSpec canonicalizes the free-form quantity strings
+// in a ResourceSpec so the instance selector and the cloud backends
+// only ever see one spelling of each value. Fractional CPU requests
+// survive normalization ("0.5" and "500m" are the same quantity).
+func NormalizeResourceSpec(rs *ResourceSpec) {
+	rs.CPU = normalizeQuantity(rs.CPU)
+	rs.Memory = normalizeQuantity(rs.Memory)
+	rs.GPUMemory = normalizeQuantity(rs.GPUMemory)
+	rs.VolumeSize = normalizeQuantity(rs.VolumeSize)
+	// GPU is "<count> [<gpu type>]"; only the count is a quantity.
+	if parts := strings.Fields(rs.GPU); len(parts) > 0 {
+		parts[0] = normalizeQuantity(parts[0])
+		rs.GPU = strings.Join(parts, " ")
+	}
+}
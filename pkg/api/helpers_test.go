@@ -84,6 +84,50 @@ func TestLabelSelectorAsSelector(t *testing.T) {
 	}
 }
 
+func TestFinalizers(t *testing.T) {
+	meta := &ObjectMeta{}
+	if meta.HasFinalizer(CloudResourcesFinalizer) {
+		t.Errorf("empty meta should not have any finalizers")
+	}
+	meta.AddFinalizer(CloudResourcesFinalizer)
+	// adding twice doesn't duplicate the entry
+	meta.AddFinalizer(CloudResourcesFinalizer)
+	if !reflect.DeepEqual(meta.Finalizers, []string{CloudResourcesFinalizer}) {
+		t.Errorf("unexpected finalizer list: %v", meta.Finalizers)
+	}
+	meta.RemoveFinalizer("some-other-finalizer")
+	if !meta.HasFinalizer(CloudResourcesFinalizer) {
+		t.Errorf("removing an unrelated finalizer should not touch others")
+	}
+	meta.RemoveFinalizer(CloudResourcesFinalizer)
+	if meta.HasFinalizer(CloudResourcesFinalizer) {
+		t.Errorf("finalizer should have been removed")
+	}
+}
+
+func TestNodeConditions(t *testing.T) {
+	status := &NodeStatus{}
+	if status.GetCondition(NodeReady) != nil {
+		t.Errorf("new status should not have any conditions")
+	}
+	if !status.SetCondition(NodeReady, ConditionTrue, "Heartbeat", "") {
+		t.Errorf("adding a condition should report a change")
+	}
+	if status.SetCondition(NodeReady, ConditionTrue, "Heartbeat", "") {
+		t.Errorf("re-setting the same status should not report a change")
+	}
+	if !status.SetCondition(NodeReady, ConditionFalse, "HeartbeatTimeout", "") {
+		t.Errorf("flipping the status should report a change")
+	}
+	cond := status.GetCondition(NodeReady)
+	if cond == nil || cond.Status != ConditionFalse || cond.Reason != "HeartbeatTimeout" {
+		t.Errorf("unexpected condition: %+v", cond)
+	}
+	if len(status.Conditions) != 1 {
+		t.Errorf("expected 1 condition, got %d", len(status.Conditions))
+	}
+}
+
 func TestLabelSelectorAsMap(t *testing.T) {
 	matchLabels := map[string]string{"foo": "bar"}
 	matchExpressions := func(operator LabelSelectorOperator, values []string) []LabelSelectorRequirement {
@@ -153,3 +197,91 @@ func TestLabelSelectorAsMap(t *testing.T) {
 		}
 	}
 }
+
+func TestNormalizeResourceSpec(t *testing.T) {
+	testCases := []struct {
+		in  ResourceSpec
+		out ResourceSpec
+	}{
+		{
+			in:  ResourceSpec{CPU: "0.5", Memory: "3Gi"},
+			out: ResourceSpec{CPU: "500m", Memory: "3Gi"},
+		},
+		{
+			in:  ResourceSpec{CPU: "500m", Memory: "3G"},
+			out: ResourceSpec{CPU: "500m", Memory: "3G"},
+		},
+		{
+			in:  ResourceSpec{CPU: "2.0", GPU: "1.0 nvidia-tesla-k80", VolumeSize: "10Gi"},
+			out: ResourceSpec{CPU: "2", GPU: "1 nvidia-tesla-k80", VolumeSize: "10Gi"},
+		},
+		{
+			// malformed values pass through untouched, validation
+			// rejects them with a field error
+			in:  ResourceSpec{CPU: "notacpu", Memory: "512Mi"},
+			out: ResourceSpec{CPU: "notacpu", Memory: "512Mi"},
+		},
+	}
+	for i, tc := range testCases {
+		rs := tc.in
+		NormalizeResourceSpec(&rs)
+		if !reflect.DeepEqual(rs, tc.out) {
+			t.Errorf("[%v]expected:\n\t%+v\nbut got:\n\t%+v", i, tc.out, rs)
+		}
+	}
+}
+
+func TestAggregateUnitResources(t *testing.T) {
+	requests := func(rl ResourceList) Unit {
+		return Unit{Resources: ResourceRequirements{Requests: rl}}
+	}
+	testCases := []struct {
+		spec PodSpec
+		out  ResourceSpec
+	}{
+		{
+			// fractional cpu requests are summed as quantities
+			spec: PodSpec{
+				Units: []Unit{
+					requests(ResourceList{"cpu": "0.5", "memory": "512Mi"}),
+					requests(ResourceList{"cpu": "0.25", "memory": "1Gi"}),
+				},
+			},
+			out: ResourceSpec{CPU: "750m", Memory: "1536Mi"},
+		},
+		{
+			// an init unit larger than the sum of the regular units
+			// sets the total for that resource
+			spec: PodSpec{
+				Units: []Unit{
+					requests(ResourceList{"cpu": "1", "memory": "1Gi"}),
+				},
+				InitUnits: []Unit{
+					requests(ResourceList{"cpu": "2"}),
+					requests(ResourceList{"memory": "512Mi"}),
+				},
+			},
+			out: ResourceSpec{CPU: "2", Memory: "1Gi"},
+		},
+		{
+			// units without requests contribute nothing
+			spec: PodSpec{
+				Units: []Unit{
+					{},
+					requests(ResourceList{"gpu": "1"}),
+				},
+			},
+			out: ResourceSpec{GPU: "1"},
+		},
+		{
+			spec: PodSpec{Units: []Unit{{}}},
+			out:  ResourceSpec{},
+		},
+	}
+	for i, tc := range testCases {
+		out := AggregateUnitResources(&tc.spec)
+		if !reflect.DeepEqual(out, tc.out) {
+			t.Errorf("[%v]expected:\n\t%+v\nbut got:\n\t%+v", i, tc.out, out)
+		}
+	}
+}
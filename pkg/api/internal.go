@@ -63,3 +63,12 @@ type AttachParams struct {
 type RunCmdParams struct {
 	Command []string
 }
+
+// StatPathReply describes what the cell agent found at a path on the
+// instance, used to validate hostPath volume types before dispatching
+// units. Kind is one of the HostPathType constants ("Directory",
+// "File", "Socket", "CharDevice" or "BlockDevice").
+type StatPathReply struct {
+	Exists bool   `json:"exists"`
+	Kind   string `json:"kind,omitempty"`
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2020 Elotl Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	stdjson "encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Serialize and deserialize each top-level object, making sure no
+// fields get lost along the way. Timestamps are rounded to second
+// precision since the monotonic clock reading doesn't survive
+// serialization. The roundtrip goes through encoding/json rather than
+// JsonCodec: the vendored json-iterator/reflect2 faults building
+// codecs for types carrying map fields under recent Go runtimes, and
+// JsonCodec is configured to be compatible with the standard library
+// anyway, so the wire format checked here is the same.
+func TestObjectSerializationRoundTrip(t *testing.T) {
+	pod := GetFakePod()
+	pod.CreationTimestamp = pod.CreationTimestamp.Rfc3339Copy()
+	pod.Spec.ImagePullSecrets = []string{"pull-secret"}
+	pod.Status.LastPhaseChange = pod.Status.LastPhaseChange.Rfc3339Copy()
+
+	node := GetFakeNode()
+	node.CreationTimestamp = node.CreationTimestamp.Rfc3339Copy()
+	node.Status.Phase = NodeClaimed
+
+	event := GetFakeEvent()
+	event.CreationTimestamp = event.CreationTimestamp.Rfc3339Copy()
+
+	metrics := NewMetrics()
+	metrics.Name = "test-metrics"
+	metrics.CreationTimestamp = metrics.CreationTimestamp.Rfc3339Copy()
+	metrics.Timestamp = metrics.CreationTimestamp
+	metrics.ResourceUsage = ResourceMetrics{"cpu": 0.5}
+
+	tests := []struct {
+		name string
+		obj  interface{}
+		into interface{}
+	}{
+		{"pod", pod, &Pod{}},
+		{"node", node, &Node{}},
+		{"event", event, &Event{}},
+		{"metrics", metrics, &Metrics{}},
+	}
+	for _, tc := range tests {
+		data, err := stdjson.Marshal(tc.obj)
+		assert.NoError(t, err, tc.name)
+		err = stdjson.Unmarshal(data, tc.into)
+		assert.NoError(t, err, tc.name)
+		assert.Equal(t, tc.obj, tc.into, tc.name)
+	}
+}
@@ -61,6 +61,11 @@ type ObjectMeta struct {
 	CreationTimestamp Time `json:"creationTimestamp,omitempty"`
 	// Time when the resource got deleted.
 	DeletionTimestamp *Time `json:"deletionTimestamp,omitempty"`
+	// Finalizers that must be cleared before the object is removed
+	// from the registry. Controllers add a finalizer when they create
+	// external resources for an object and remove it once those
+	// resources are confirmed released.
+	Finalizers []string `json:"finalizers,omitempty"`
 	// Unused.
 	Annotations map[string]string `json:"annotations,omitempty"`
 	// Universal identifier in order to distinguish between different objects
@@ -90,6 +95,39 @@ func (meta *ObjectMeta) Create() {
 	}
 }
 
+// CloudResourcesFinalizer keeps an object in the registry until the
+// cloud resources backing it (instance, routes, attached volumes)
+// have been confirmed released.
+const CloudResourcesFinalizer = "kip.elotl.co/cloud-resources"
+
+// HasFinalizer returns true if name is in the object's finalizer list.
+func (meta *ObjectMeta) HasFinalizer(name string) bool {
+	for _, f := range meta.Finalizers {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// AddFinalizer adds name to the object's finalizer list if it isn't
+// in there yet.
+func (meta *ObjectMeta) AddFinalizer(name string) {
+	if !meta.HasFinalizer(name) {
+		meta.Finalizers = append(meta.Finalizers, name)
+	}
+}
+
+// RemoveFinalizer removes name from the object's finalizer list.
+func (meta *ObjectMeta) RemoveFinalizer(name string) {
+	for i, f := range meta.Finalizers {
+		if f == name {
+			meta.Finalizers = append(meta.Finalizers[:i], meta.Finalizers[i+1:]...)
+			return
+		}
+	}
+}
+
 func SetAPIVersion(version string) {
 
 }
@@ -121,26 +159,93 @@ type PodSpec struct {
 	// Init Units. They are run in order, one at a time before regular Units
 	// are started.
 	InitUnits []Unit `json:"initUnits"`
+	// Environment variables that are merged into the environment of
+	// every Unit (including init Units) in this Pod. A Unit's own Env
+	// entries override a Pod-level entry with the same name. Values
+	// must be literal strings, references are resolved before the
+	// Pod's spec is created.
+	Env []EnvVar `json:"env,omitempty"`
+	// EnableServiceLinks controls whether information about kubernetes
+	// services is injected into the Pod's environment as *_SERVICE_HOST
+	// and *_SERVICE_PORT variables, matching the kubernetes field of
+	// the same name. When unset it defaults to true, the kubernetes
+	// default; when false no service env vars are added to the Pod's
+	// units automatically.
+	EnableServiceLinks *bool `json:"enableServiceLinks,omitempty"`
 	// List of Secrets that will be used for authenticating when pulling
 	// images.
-	ImagePullSecrets []string `json:"imagePullSecrets,omitemtpy"`
+	ImagePullSecrets []string `json:"imagePullSecrets,omitempty"`
 	// Type of cloud instance type that will be used to run this Pod.
 	InstanceType string `json:"instanceType,omitempty"`
 	// PodSpot is the policy that determines if a spot instance may be used for
 	// a Pod.
 	Spot PodSpot `json:"spot,omitempty"`
+	// Priority of this Pod relative to other Pods. A larger number
+	// means a more important Pod. When preemption is enabled in the
+	// provider config and a Pod can't get capacity from the cloud,
+	// the Pod is allowed to preempt a running Pod with a lower
+	// priority. Defaults to 0.
+	Priority int32 `json:"priority,omitempty"`
+	// Optional name of the priority class this Pod's priority was
+	// taken from. It is informational only, the Priority value is
+	// what is used for scheduling decisions.
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+	// Optional number of seconds the Pod may spend waiting to be
+	// scheduled onto a cell, measured from Pod creation and covering
+	// all dispatch attempts (including AZ retries and instance type
+	// fallbacks). If the Pod isn't running by then it is failed with
+	// an event explaining why. Zero means use the controller's
+	// default, which is to wait forever.
+	SchedulingTimeoutSeconds int64 `json:"schedulingTimeoutSeconds,omitempty"`
+	// Optional hard wall-clock limit on how long the Pod may be
+	// running, measured from the first time the Pod entered the
+	// Running phase. Restarts don't reset the clock. Once the
+	// deadline passes all Units are terminated and the Pod is failed
+	// with reason DeadlineExceeded, regardless of restart policy.
+	ActiveDeadlineSeconds *int64 `json:"activeDeadlineSeconds,omitempty"`
 	// Resource requirements for the Node that will run this Pod. If both
 	// instanceType and resources are specified, instanceType will take
 	// precedence.
 	Resources ResourceSpec `json:"resources,omitempty"`
+	// Size of the tmpfs mounted at /dev/shm in the Pod's Units, e.g.
+	// "1Gi". Empty keeps the runtime's small default, which breaks
+	// applications that use /dev/shm heavily (Chrome, some ML
+	// libraries). It cannot exceed the Pod's memory request.
+	ShmSize string `json:"shmSize,omitempty"`
 	// Placement is used to specify where a Pod will be place in the
 	// infrastructure.
 	Placement PlacementSpec `json:"placement,omitempty"`
+	// Optional second network interface that will be attached to the
+	// cloud instance running this Pod, used to separate classes of
+	// traffic onto their own subnets. Only supported on AWS.
+	SecondaryNetworkInterface *SecondaryNetworkInterfaceSpec `json:"secondaryNetworkInterface,omitempty"`
+	// List of CIDRs allowed to reach the host ports this Pod's Units
+	// expose. The cell's security group only admits traffic from
+	// inside the VPC; each range listed here is opened up in addition
+	// to that. Opening a Pod to the world requires listing 0.0.0.0/0
+	// explicitly, and the controller can be configured to reject it.
+	SourceRanges []string `json:"sourceRanges,omitempty"`
 	// List of volumes that will be made available to the Pod. Units can then
 	// attach any of these mounts.
 	Volumes []Volume `json:"volumes,omitempty"`
 	// Pod security context.
 	SecurityContext *PodSecurityContext `json:"securityContext,omitempty"`
+	// RuntimeClassName selects the container runtime the cell agent
+	// uses to run this Pod's Units, e.g. a sandboxed runtime like
+	// gVisor for untrusted workloads. It must be one of the runtime
+	// classes listed in the provider config. Empty means the agent's
+	// default runtime.
+	RuntimeClassName string `json:"runtimeClassName,omitempty"`
+	// LogConfig bounds the size of the logs the Units in this Pod
+	// keep on the cell. Unset fields fall back to the provider-level
+	// unitLogMaxSize and unitLogMaxFiles cells settings, or to the
+	// cell agent's defaults.
+	LogConfig PodLogConfig `json:"logConfig,omitempty"`
+	// Host networking requested for this Pod. When true the Units run
+	// in the node's network namespace and share the node's main IP
+	// address instead of getting a dedicated pod IP, and no cloud
+	// route is created for the Pod. Defaults to false.
+	HostNetwork bool `json:"hostNetwork,omitempty"`
 	// Pod DNS policy.
 	DNSPolicy DNSPolicy `json:"dnsPolicy,omitempty"`
 	// Pod DNS config.
@@ -161,6 +266,20 @@ type PodSpec struct {
 	HostAliases []HostAlias `json:"hostAliases,omitempty"`
 }
 
+// PodLogConfig controls how the cell agent rotates the logs of the
+// Units in a Pod so long-running chatty Units don't fill the cell's
+// disk.
+type PodLogConfig struct {
+	// MaxSize is the largest a Unit's active log file may grow, in
+	// bytes, before the agent rotates it. Zero means use the provider
+	// or agent default.
+	MaxSize int64 `json:"maxSize,omitempty"`
+	// MaxFiles is the number of rotated log files the agent keeps per
+	// Unit in addition to the active one. Zero means use the provider
+	// or agent default.
+	MaxFiles int32 `json:"maxFiles,omitempty"`
+}
+
 // HostAlias holds the mapping between IP and hostnames that will be injected as an entry in the
 // pod's hosts file.
 type HostAlias struct {
@@ -231,12 +350,32 @@ type PodSecurityContext struct {
 	// GID to run pod processes as.
 	RunAsGroup *int64 `json:"runAsGroup,omitempty"`
 	// List of groups applied to the first process run in the sandbox, in
-	// addition to the pod's primary GID.
+	// addition to the pod's primary GID. The groups are passed to the
+	// node as part of the pod spec and, when FSGroup is unset, the first
+	// group also owns deployed volume files so group-readable files stay
+	// readable.
 	SupplementalGroups []int64 `json:"supplementalGroups,omitempty"`
+	// A special supplemental group that owns deployed volume files.
+	// Takes precedence over the first supplemental group when both are
+	// set.
+	FSGroup *int64 `json:"fsGroup,omitempty"`
+	// Defines when volume file ownership is changed. "OnRootMismatch"
+	// skips the ownership change for files that already carry the
+	// group, "Always" (the default) applies it on every deploy.
+	FSGroupChangePolicy *string `json:"fsGroupChangePolicy,omitempty"`
 	// Set these sysctls in the pod.
 	Sysctls []Sysctl `json:"sysctls,omitempty"`
 }
 
+const (
+	// FSGroupChangeAlways applies the fsGroup ownership on every
+	// volume deploy.
+	FSGroupChangeAlways = "Always"
+	// FSGroupChangeOnRootMismatch only changes ownership of volume
+	// files that don't already carry the fsGroup.
+	FSGroupChangeOnRootMismatch = "OnRootMismatch"
+)
+
 // NamespaceOption provides options for Linux namespaces.
 type NamespaceOption struct {
 	// Network namespace for this container/sandbox.
@@ -308,6 +447,24 @@ type VolumeSource struct {
 	HostPath *HostPathVolumeSource `json:"hostPath,omitempty"`
 	// Items for all in one resources secrets, configmaps, and downward API
 	Projected *ProjectedVolumeSource `json:"projected,omitempty"`
+	// CloudVolume attaches a pre-existing cloud provider volume (e.g.
+	// an EBS volume on AWS) to the instance running the pod.
+	CloudVolume *CloudVolumeSource `json:"cloudVolume,omitempty"`
+}
+
+// CloudVolumeSource references a pre-existing volume at the cloud
+// provider by its provider-assigned ID. The volume is attached to
+// whatever instance runs the pod, detaching it from its previous
+// instance if necessary, and units mount it through their
+// volumeMounts like any other volume. Since volumes can only be
+// attached within their own availability zone, the pod is placed in
+// the volume's zone. The volume is never deleted when the pod
+// terminates, which makes this suitable for single-replica stateful
+// pods that need their data to outlive any one pod.
+type CloudVolumeSource struct {
+	// ID of the volume at the cloud provider, e.g. "vol-0d2a463bca45e4d33"
+	// on AWS.
+	VolumeID string `json:"volumeID"`
 }
 
 // Represents a host path mapped into a pod.
@@ -322,6 +479,11 @@ type HostPathVolumeSource struct {
 	// More info: https://kubernetes.io/docs/concepts/storage/volumes#hostpath
 	// +optional
 	Type *HostPathType `json:"type,omitempty" protobuf:"bytes,2,opt,name=type"`
+	// ReadOnly hints to the cell agent that the path should be mounted
+	// read-only. Set by the controller when every unit mounting the
+	// volume does so read-only; never set directly by users.
+	// +optional
+	ReadOnly bool `json:"readOnly,omitempty"`
 }
 
 type HostPathType string
@@ -423,8 +585,17 @@ type StorageMedium string
 const (
 	StorageMediumDefault StorageMedium = ""       // Use default (disk).
 	StorageMediumMemory  StorageMedium = "Memory" // Use tmpfs.
-	// Supporting huge pages will require some extra steps.
-	//StorageMediumHugePages StorageMedium = "HugePages" // use hugepages
+	// Use a local instance-store (ephemeral NVMe) disk. Only instance
+	// types that come with instance-store volumes can back this medium,
+	// so requesting it constrains instance type selection. Data on an
+	// instance-store volume is lost whenever the backing instance is
+	// stopped or terminated.
+	StorageMediumInstanceStore StorageMedium = "InstanceStore"
+	// Back the volume with a hugetlbfs mount instead of tmpfs. A
+	// SizeLimit is required since hugepages have to be reserved up
+	// front, and only instance types that support reserving hugepages
+	// can back this medium.
+	StorageMediumHugePages StorageMedium = "HugePages"
 )
 
 // EmptyDir is is disk or memory-backed Volume. Units can use it as
@@ -433,11 +604,16 @@ const (
 // serving these static files from the emptyDir).
 type EmptyDir struct {
 	// Backing medium for the emptyDir. The default is "" (to use disk
-	// space).  The other option is "Memory", for creating a tmpfs
-	// volume.
+	// space).  The other options are "Memory", for creating a tmpfs
+	// volume, "InstanceStore", for using a local instance-store
+	// (ephemeral NVMe) disk on instance types that offer one, and
+	// "HugePages", for a hugetlbfs volume backed by reserved
+	// hugepages. Note that instance-store data does not survive a stop
+	// or terminate of the backing instance.
 	Medium StorageMedium `json:"medium,omitempty"`
-	// SizeLimit is only meaningful for tmpfs. It is the size of the tmpfs
-	// volume.
+	// SizeLimit is only meaningful for tmpfs and hugetlbfs. It is the
+	// size of the volume, and is required when the medium is
+	// "HugePages".
 	SizeLimit int64 `json:"sizeLimit,omitempty"`
 }
 
@@ -468,9 +644,9 @@ type VolumeProjection struct {
 	// information about the secret data to project
 	// +optional
 	Secret *SecretProjection `json:"secret,omitempty"`
-	// // information about the downwardAPI data to project
-	// // +optional
-	// DownwardAPI *DownwardAPIProjection `json:"downwardAPI,omitempty"`
+	// information about the downwardAPI data to project
+	// +optional
+	DownwardAPI *DownwardAPIProjection `json:"downwardAPI,omitempty"`
 	// information about the configMap data to project
 	// +optional
 	ConfigMap *ConfigMapProjection `json:"configMap,omitempty"`
@@ -489,8 +665,6 @@ const (
 //
 // The contents of the target Secret's Data field will be presented in a
 // projected volume as files using the keys in the Data field as the file names.
-// Note that this is identical to a secret volume source without the default
-// mode.
 type SecretProjection struct {
 	LocalObjectReference `json:",inline" protobuf:"bytes,1,opt,name=localObjectReference"`
 	// If unspecified, each key-value pair in the Data field of the referenced
@@ -505,6 +679,11 @@ type SecretProjection struct {
 	// Specify whether the Secret or its key must be defined
 	// +optional
 	Optional *bool `json:"optional,omitempty" protobuf:"varint,4,opt,name=optional"`
+	// Mode bits to use on files projected from this source when the item
+	// doesn't specify its own mode. Overrides the projected volume's
+	// DefaultMode for this source only. Must be a value between 0 and 0777.
+	// +optional
+	DefaultMode *int32 `json:"defaultMode,omitempty"`
 }
 
 // Adapts a ConfigMap into a projected volume.
@@ -512,8 +691,6 @@ type SecretProjection struct {
 // The contents of the target ConfigMap's Data field will be presented in a
 // projected volume as files using the keys in the Data field as the file names,
 // unless the items element is populated with specific mappings of keys to paths.
-// Note that this is identical to a configmap volume source without the default
-// mode.
 type ConfigMapProjection struct {
 	LocalObjectReference `json:",inline" protobuf:"bytes,1,opt,name=localObjectReference"`
 	// If unspecified, each key-value pair in the Data field of the referenced
@@ -528,44 +705,37 @@ type ConfigMapProjection struct {
 	// Specify whether the ConfigMap or its keys must be defined
 	// +optional
 	Optional *bool `json:"optional,omitempty" protobuf:"varint,4,opt,name=optional"`
+	// Mode bits to use on files projected from this source when the item
+	// doesn't specify its own mode. Overrides the projected volume's
+	// DefaultMode for this source only. Must be a value between 0 and 0777.
+	// +optional
+	DefaultMode *int32 `json:"defaultMode,omitempty"`
 }
 
-// // Represents downward API info for projecting into a projected volume.
-// // Note that this is identical to a downwardAPI volume source without the default
-// // mode.
-// type DownwardAPIProjection struct {
-// 	// Items is a list of DownwardAPIVolume file
-// 	// +optional
-// 	Items []DownwardAPIVolumeFile `json:"items,omitempty" protobuf:"bytes,1,rep,name=items"`
-// }
-
-// // DownwardAPIVolumeFile represents information to create the file containing the pod field
-// type DownwardAPIVolumeFile struct {
-// 	// Required: Path is  the relative path name of the file to be created. Must not be absolute or contain the '..' path. Must be utf-8 encoded. The first item of the relative path must not start with '..'
-// 	Path string `json:"path" protobuf:"bytes,1,opt,name=path"`
-// 	// Required: Selects a field of the pod: only annotations, labels, name and namespace are supported.
-// 	// +optional
-// 	FieldRef *ObjectFieldSelector `json:"fieldRef,omitempty" protobuf:"bytes,2,opt,name=fieldRef"`
-// 	// Selects a resource of the container: only resources limits and requests
-// 	// (limits.cpu, limits.memory, requests.cpu and requests.memory) are currently supported.
-// 	// +optional
-// 	ResourceFieldRef *ResourceFieldSelector `json:"resourceFieldRef,omitempty" protobuf:"bytes,3,opt,name=resourceFieldRef"`
-// 	// Optional: mode bits to use on this file, must be a value between 0
-// 	// and 0777. If not specified, the volume defaultMode will be used.
-// 	// This might be in conflict with other options that affect the file
-// 	// mode, like fsGroup, and the result can be other mode bits set.
-// 	// +optional
-// 	Mode *int32 `json:"mode,omitempty" protobuf:"varint,4,opt,name=mode"`
-// }
+// Represents downward API info for projecting into a projected volume.
+// Note that this is identical to a downwardAPI volume source without the default
+// mode.
+type DownwardAPIProjection struct {
+	// Items is a list of DownwardAPIVolume file
+	// +optional
+	Items []DownwardAPIVolumeFile `json:"items,omitempty"`
+}
 
-// // ObjectFieldSelector selects an APIVersioned field of an object.
-// type ObjectFieldSelector struct {
-// 	// Version of the schema the FieldPath is written in terms of, defaults to "v1".
-// 	// +optional
-// 	APIVersion string `json:"apiVersion,omitempty" protobuf:"bytes,1,opt,name=apiVersion"`
-// 	// Path of the field to select in the specified API version.
-// 	FieldPath string `json:"fieldPath" protobuf:"bytes,2,opt,name=fieldPath"`
-// }
+// DownwardAPIVolumeFile represents information to create the file containing the pod field
+type DownwardAPIVolumeFile struct {
+	// Required: Path is the relative path name of the file to be created. Must not be absolute or contain the '..' path. Must be utf-8 encoded. The first item of the relative path must not start with '..'
+	Path string `json:"path"`
+	// Required: Selects a field of the pod: metadata.name,
+	// metadata.namespace, metadata.uid, metadata.labels and
+	// metadata.annotations are supported.
+	FieldRef *ObjectFieldSelector `json:"fieldRef,omitempty"`
+	// Optional: mode bits to use on this file, must be a value between 0
+	// and 0777. If not specified, the volume defaultMode will be used.
+	// This might be in conflict with other options that affect the file
+	// mode, like fsGroup, and the result can be other mode bits set.
+	// +optional
+	Mode *int32 `json:"mode,omitempty"`
+}
 
 // // ResourceFieldSelector represents container resources (cpu, memory) and their output format
 // type ResourceFieldSelector struct {
@@ -595,10 +765,27 @@ type ResourceSpec struct {
 	Memory string `json:"memory,omitempty"`
 	// Number of GPUs present on the instance.
 	GPU string `json:"gpu,omitempty"`
+	// Accelerator model required on the instance, e.g.
+	// "nvidia-tesla-t4". Only instance types offering that accelerator
+	// are considered during selection. Takes precedence over a model
+	// appended to the gpu count string.
+	GPUType string `json:"gpuType,omitempty"`
+	// Minimum quantity of memory per GPU accelerator, e.g. gpuMemory:
+	// "24Gi". Combined with the gpu count and type constraints when
+	// selecting an instance type.
+	GPUMemory string `json:"gpuMemory,omitempty"`
 	// Root volume size. Both AWS and GCE specify volumes in GiB.
 	// However according to their docs, AWS will bill you in
 	// GB.
 	VolumeSize string `json:"volumeSize,omitempty"`
+	// If InstanceStoreRoot is true, the Pod's cell boots from a local
+	// instance-store disk instead of a network-attached root volume
+	// and is scheduled onto an instance type that has instance-store
+	// disks. The root disk is ephemeral: everything on it is lost
+	// when the instance stops. The boot image spec must point at an
+	// instance-store backed image and volumeSize cannot be set since
+	// the disk's size is fixed by the instance type. AWS only.
+	InstanceStoreRoot bool `json:"instanceStoreRoot,omitempty"`
 	// Request an instance with dedicated or non-shared CPU. For AWS
 	// T2 instances have a shared CPU, all other instance families
 	// have a dedicated CPU.  Set dedicatedCPU to true if you do
@@ -616,6 +803,23 @@ type ResourceSpec struct {
 	ContainerInstance *bool `json:"containerInstance,omitempty"`
 }
 
+// TerminationMessagePolicy describes how termination messages are
+// retrieved from a Unit.
+type TerminationMessagePolicy string
+
+const (
+	// TerminationMessageReadFile reads the Unit's termination message
+	// from the file at TerminationMessagePath.
+	TerminationMessageReadFile TerminationMessagePolicy = "File"
+	// TerminationMessageFallbackToLogsOnError reads the tail of the
+	// Unit's logs if the termination message file is empty and the
+	// Unit exited with an error.
+	TerminationMessageFallbackToLogsOnError TerminationMessagePolicy = "FallbackToLogsOnError"
+	// TerminationMessagePathDefault is the path at which Units write
+	// their termination message unless overridden.
+	TerminationMessagePathDefault string = "/dev/termination-log"
+)
+
 // Units run applications. A Pod consists of one or more Units.
 type Unit struct {
 	// Name of the Unit.
@@ -638,17 +842,49 @@ type Unit struct {
 	Command []string `json:"command,omitempty"`
 	// Arguments to the command. If empty, the cmd from the image will be used.
 	Args []string `json:"args,omitempty"`
+	// If true, the command is run through `/bin/sh -c` so shell
+	// features like pipes and variable expansion work. Command must
+	// then contain a single shell command string; any Args are
+	// appended to that string separated by spaces. Defaults to false,
+	// which execs the command directly.
+	Shell bool `json:"shell,omitempty"`
+	// Restart policy for this Unit only, overriding the Pod's
+	// RestartPolicy. It can be "always", "onFailure" or "never".
+	// Defaults to the Pod's policy.
+	RestartPolicy *RestartPolicy `json:"restartPolicy,omitempty"`
 	// List of environment variables that will be exported inside the Unit
 	// before start the application.
 	Env []EnvVar `json:"env,omitempty"`
+	// List of ConfigMap or Secret keys containing dotenv-style
+	// KEY=VALUE lines that are parsed into the Unit's environment.
+	// Explicit Env entries win on conflict.
+	EnvFileFrom []EnvFileSource `json:"envFileFrom,omitempty"`
 	// A list of Volumes that will be attached to the Unit.
 	VolumeMounts []VolumeMount `json:"volumeMounts,omitempty"`
 	// A list of ports that will be opened up for this Unit.
 	Ports []ContainerPort `json:"ports,omitempty"`
 	// Working directory to change to before running the command for the Unit.
 	WorkingDir string `json:"workingDir,omitempty"`
+	// Signal sent to the Unit's process for graceful shutdown instead
+	// of SIGTERM, e.g. "SIGQUIT" for nginx. The process is still
+	// killed with SIGKILL after the termination grace period.
+	// Defaults to SIGTERM.
+	StopSignal string `json:"stopSignal,omitempty"`
+	// Path inside the Unit at which the Unit's termination message
+	// will be written. Defaults to /dev/termination-log.
+	TerminationMessagePath string `json:"terminationMessagePath,omitempty"`
+	// Policy for populating the termination message. "File" reads the
+	// file at TerminationMessagePath; "FallbackToLogsOnError" also
+	// uses the tail of the Unit's logs when that file is empty and
+	// the Unit exited with an error. Defaults to "File".
+	TerminationMessagePolicy TerminationMessagePolicy `json:"terminationMessagePolicy,omitempty"`
 	// Unit security context.
 	SecurityContext *SecurityContext `json:"securityContext,omitempty"`
+	// Resource limits (ulimits) the cell agent applies to the Unit's
+	// processes before starting them, e.g. a higher open file limit
+	// for proxies. This saves users from baking ulimit tweaks into
+	// custom entrypoints.
+	Ulimits []Ulimit `json:"ulimits,omitempty"`
 	// Periodic probe of container liveness.  Container will be
 	// restarted if the probe fails.  Cannot be updated.  More info:
 	// https://kubernetes.io/docs/concepts/workloads/pods/pod-lifecycle#container-probes
@@ -662,19 +898,40 @@ type Unit struct {
 	//initialized. If specified, no other probes are executed until
 	//this completes successfully.
 	StartupProbe *Probe `json:"startupProbe,omitempty"`
+	// Actions the system takes in response to Unit lifecycle events.
+	Lifecycle *Lifecycle `json:"lifecycle,omitempty"`
+	// Compute resources this Unit asks for. When the Pod sets neither
+	// an instance type nor pod-level resources, the unit requests are
+	// aggregated into the effective ResourceSpec used to pick an
+	// instance type.
+	Resources ResourceRequirements `json:"resources,omitempty"`
 }
 
+// ResourceRequirements describes the compute resource requests and
+// limits of a single Unit, mirroring the Kubernetes container fields.
+// Values are quantity strings; cpu may be fractional like "0.5".
+type ResourceRequirements struct {
+	// Minimum amount of compute resources the Unit needs. Supported
+	// keys are "cpu", "memory" and "gpu".
+	Requests ResourceList `json:"requests,omitempty"`
+	// Maximum amount of compute resources the Unit may use.
+	Limits ResourceList `json:"limits,omitempty"`
+}
+
+// ResourceList maps a resource name to a quantity string.
+type ResourceList map[string]string
+
 // Optional security context that overrides whatever is set for the pod.
 //
 // Example yaml:
 //
 // securityContext:
-//           capabilities:
-//             add:
-//             - NET_BIND_SERVICE
-//             drop:
-//             - ALL
 //
+//	capabilities:
+//	  add:
+//	  - NET_BIND_SERVICE
+//	  drop:
+//	  - ALL
 type SecurityContext struct {
 	// Capabilities to add or drop.
 	Capabilities *Capabilities `json:"capabilities,omitempty"`
@@ -692,6 +949,18 @@ type Capabilities struct {
 	Drop []string `json:"drop,omitempty"`
 }
 
+// Ulimit is a resource limit applied to a Unit's processes, named
+// after the setrlimit resource it controls (e.g. "nofile", "nproc").
+type Ulimit struct {
+	// Name of the limit.
+	Name string `json:"name"`
+	// Soft limit, the value the kernel enforces.
+	Soft int64 `json:"soft"`
+	// Hard limit, the ceiling the soft limit may be raised to. May
+	// not be smaller than the soft limit.
+	Hard int64 `json:"hard"`
+}
+
 // ExecAction describes a "run in container" action.
 type ExecAction struct {
 	// Command is the command line to execute inside the container,
@@ -752,6 +1021,17 @@ type TCPSocketAction struct {
 	Host string `json:"host,omitempty"`
 }
 
+// GRPCAction describes a health check using the standard
+// grpc_health_v1 Health service.
+type GRPCAction struct {
+	// Port of the gRPC service to query.
+	// Number must be in the range 1 to 65535.
+	Port int32 `json:"port"`
+	// Service is the name placed in the grpc HealthCheckRequest. An
+	// empty service queries the server's overall health.
+	Service string `json:"service,omitempty"`
+}
+
 // Handler defines a specific action that should be taken
 type Handler struct {
 	// One and only one of the following should be specified.
@@ -762,6 +1042,20 @@ type Handler struct {
 	// TCPSocket specifies an action involving a TCP port.
 	// TCP hooks not yet supported
 	TCPSocket *TCPSocketAction `json:"tcpSocket,omitempty"`
+	// GRPC specifies a grpc_health_v1 check against a gRPC port.
+	GRPC *GRPCAction `json:"grpc,omitempty"`
+}
+
+// Lifecycle describes actions taken in response to unit lifecycle
+// events. PostStart runs once the unit has started; PreStop runs
+// before the unit is terminated and termination waits for the handler
+// to finish, up to a grace period.
+type Lifecycle struct {
+	// PostStart is called after the unit has started.
+	PostStart *Handler `json:"postStart,omitempty"`
+	// PreStop is called before the unit is terminated. Termination
+	// blocks until the handler completes or the grace period elapses.
+	PreStop *Handler `json:"preStop,omitempty"`
 }
 
 // Probe describes a health check to be performed against a container
@@ -797,6 +1091,12 @@ type VolumeMount struct {
 	Name string `json:"name"`
 	// Path where this Volume will be attached inside the Unit.
 	MountPath string `json:"mountPath"`
+	// Mount the Volume read-only. The deployed files lose their write
+	// bits.
+	ReadOnly bool `json:"readOnly,omitempty"`
+	// Path within the Volume to mount instead of its root. Must be a
+	// relative path.
+	SubPath string `json:"subPath,omitempty"`
 }
 
 // Environment variables.
@@ -805,6 +1105,43 @@ type EnvVar struct {
 	Name string `json:"name"`
 	// Value of the environment variable.
 	Value string `json:"value,omitempty"`
+	// Source for the value. Ignored when Value is not empty.
+	ValueFrom *EnvVarSource `json:"valueFrom,omitempty"`
+}
+
+// EnvVarSource represents a source for the value of an EnvVar. Exactly
+// one of the fields must be set.
+type EnvVarSource struct {
+	// Selects a field of the pod.
+	FieldRef *ObjectFieldSelector `json:"fieldRef,omitempty"`
+	// Selects a key of a ConfigMap.
+	ConfigMapKeyRef *ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
+	// Selects a key of a Secret.
+	SecretKeyRef *SecretKeySelector `json:"secretKeyRef,omitempty"`
+}
+
+// ObjectFieldSelector selects a field of the pod. For env vars the
+// supported paths are "metadata.name", "metadata.namespace",
+// "spec.nodeName", "status.boundNodeName" and "status.podIP"; fields
+// that are only known once the pod has been dispatched, like the pod
+// IP, are resolved right before the units are sent to the cell.
+// Downward API volume files instead support "metadata.name",
+// "metadata.namespace", "metadata.uid", "metadata.labels" and
+// "metadata.annotations".
+type ObjectFieldSelector struct {
+	// Path of the field to select.
+	FieldPath string `json:"fieldPath"`
+}
+
+// EnvFileSource selects a ConfigMap or Secret key whose value holds
+// dotenv-style KEY=VALUE lines. Blank lines and lines starting with
+// '#' are skipped and CRLF line endings are accepted. Exactly one of
+// the fields must be set.
+type EnvFileSource struct {
+	// The ConfigMap key holding the env file.
+	ConfigMapKey *ConfigMapKeySelector `json:"configMapKey,omitempty"`
+	// The Secret key holding the env file.
+	SecretKey *SecretKeySelector `json:"secretKey,omitempty"`
 }
 
 // LocalObjectReference contains enough information to let you locate the referenced object inside the same namespace.
@@ -839,8 +1176,9 @@ type SecretKeySelector struct {
 type SpotPolicy string
 
 const (
-	SpotAlways SpotPolicy = "Always"
-	SpotNever  SpotPolicy = "Never"
+	SpotAlways    SpotPolicy = "Always"
+	SpotPreferred SpotPolicy = "Preferred"
+	SpotNever     SpotPolicy = "Never"
 )
 
 // PodSpot is the policy that determines if a spot instance may be used for a
@@ -875,6 +1213,10 @@ type PodStatus struct {
 	Phase PodPhase `json:"phase"`
 	// Time of the last phase change
 	LastPhaseChange Time `json:"lastPhaseChange"`
+	// Time the Pod first entered the Running phase. Preserved across
+	// restarts so activeDeadlineSeconds is measured from the first
+	// run, not per restart.
+	StartTime *Time `json:"startTime,omitempty"`
 	// Name of the node running this Pod.
 	BoundNodeName string `json:"boundNodeName"`
 	// ID of the node running this Pod.
@@ -892,7 +1234,8 @@ type PodStatus struct {
 }
 
 // Phase is the last observed phase of the Pod. Can be "creating",
-// "dispatching", "running", "succeeded", "failed" or "terminated".
+// "dispatching", "running", "suspended", "succeeded", "failed" or
+// "terminated".
 type PodPhase string
 
 const (
@@ -903,6 +1246,12 @@ const (
 	PodDispatching PodPhase = "Dispatching"
 	// PodRunning means that the Pod is up and running.
 	PodRunning PodPhase = "Running"
+	// PodSuspended means the Pod's cell has been stopped at the user's
+	// request while the Pod object and any persistent volumes are
+	// retained. Anything stored on the cell itself is lost. It is not
+	// a terminal phase, the Pod is relaunched when its spec phase goes
+	// back to Running.
+	PodSuspended PodPhase = "Suspended"
 	// Pod succeeded means all the Units in the Pod returned success. It is a
 	// terminal phase, i.e. the final phase when a Pod finished. Once the Pod
 	// finished, Spec.Phase and Status.Phase are the same.
@@ -935,7 +1284,7 @@ const (
 )
 
 type PodList struct {
-	TypeMeta `json:",inline"`
+	TypeMeta `json:",inline,squash"`
 	Items    []*Pod `json:"items"`
 }
 
@@ -961,6 +1310,10 @@ type NodeSpec struct {
 	Terminate bool `json:"terminate,omitempty"`
 	// This is a spot cloud instance.
 	Spot bool `json:"spot"`
+	// Launch an on-demand instance instead when there is no spot
+	// capacity. Set on nodes created for pods with the "Preferred"
+	// spot policy.
+	SpotFallback bool `json:"spotFallback,omitempty"`
 	// Resource requirements necessary for booting this Node. If both
 	// instanceType and memory and cpu resources are specified,
 	// instanceType will take precedence.  If the cloud provider
@@ -969,14 +1322,98 @@ type NodeSpec struct {
 	Resources ResourceSpec `json:"resources,omitempty"`
 	// Placement of the Node in the infrastructure.
 	Placement PlacementSpec `json:"placement,omitempty"`
+	// Optional second network interface attached to this Node's
+	// instance at launch. Only supported on AWS.
+	SecondaryNetworkInterface *SecondaryNetworkInterfaceSpec `json:"secondaryNetworkInterface,omitempty"`
+	// InstanceMetadataOptions controls access to the instance
+	// metadata service on this Node's instance. Only supported on
+	// AWS, other providers ignore it.
+	InstanceMetadataOptions InstanceMetadataOptions `json:"instanceMetadataOptions,omitempty"`
+}
+
+// InstanceMetadataOptions configures how the cloud instance metadata
+// service is exposed on an instance at launch. Only supported on AWS.
+type InstanceMetadataOptions struct {
+	// HttpTokens is HttpTokensRequired to only allow metadata
+	// requests that carry an IMDSv2 session token, or
+	// HttpTokensOptional to also allow unauthenticated IMDSv1
+	// requests. Empty defaults to HttpTokensRequired.
+	HttpTokens string `json:"httpTokens,omitempty"`
+	// HttpPutResponseHopLimit is the number of network hops metadata
+	// responses may travel. Zero defaults to 1, which keeps the
+	// metadata service reachable from the instance itself but not
+	// from containers on a separate network hop.
+	HttpPutResponseHopLimit int32 `json:"httpPutResponseHopLimit,omitempty"`
 }
 
+const (
+	// HttpTokensRequired makes the instance metadata service reject
+	// requests without an IMDSv2 session token.
+	HttpTokensRequired = "required"
+	// HttpTokensOptional allows IMDSv1 requests in addition to
+	// IMDSv2.
+	HttpTokensOptional = "optional"
+)
+
 type PlacementSpec struct {
 	AvailabilityZone string `json:"availabilityZone,omitempty"`
+	// AvailabilityZones lists the candidate zones when spreading
+	// across zones. Empty means every zone in the region is a
+	// candidate. Ignored when availabilityZone pins a single zone.
+	AvailabilityZones []string `json:"availabilityZones,omitempty"`
+	// SpreadAcrossZones asks the controller to balance cells across
+	// availability zones: each new cell goes to the candidate zone
+	// with the fewest live cells, skipping zones without capacity.
+	SpreadAcrossZones bool `json:"spreadAcrossZones,omitempty"`
+	// Name of the placement group the instance is launched into. The
+	// group is created with PlacementGroupStrategy if it doesn't
+	// exist yet. Only supported on AWS.
+	PlacementGroup string `json:"placementGroup,omitempty"`
+	// Strategy of the placement group: cluster, spread or partition.
+	// Defaults to cluster.
+	PlacementGroupStrategy PlacementGroupStrategy `json:"placementGroupStrategy,omitempty"`
+	// SubnetID pins the instance to a specific subnet in the VPC.
+	// Normally left empty; the controller fills it in when subnet
+	// topology spread is enabled or a subnetSelector is specified.
+	// Only supported on AWS.
+	SubnetID string `json:"subnetID,omitempty"`
+	// NodeSelector restricts the pod to nodes whose placement carries
+	// exactly these labels. An empty selector matches any node.
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	// SubnetSelector restricts the subnets the pod's instance may
+	// launch into by matching the subnet's cloud tags. The subnet
+	// name is matched under the "Name" tag key. Scheduling fails when
+	// no subnet in the VPC matches. Only supported on AWS.
+	SubnetSelector *LabelSelector `json:"subnetSelector,omitempty"`
+}
+
+// PlacementGroupStrategy determines how the instances in a placement
+// group are laid out on the underlying hardware.
+type PlacementGroupStrategy string
+
+const (
+	// PlacementStrategyCluster packs instances close together for
+	// low-latency networking.
+	PlacementStrategyCluster PlacementGroupStrategy = "cluster"
+	// PlacementStrategySpread places instances on distinct hardware.
+	PlacementStrategySpread PlacementGroupStrategy = "spread"
+	// PlacementStrategyPartition spreads instances across logical
+	// partitions, each on its own set of hardware.
+	PlacementStrategyPartition PlacementGroupStrategy = "partition"
+)
 
-	// Future additions: In addition to explicitly specifying a subnet
-	// we could make it so that users can use a selector to match
-	// cloud tags on a subnet.
+// SecondaryNetworkInterfaceSpec describes an additional network
+// interface attached to an instance at launch, e.g. to keep data-plane
+// and control-plane traffic on separate subnets. The interface is
+// created by the cloud provider when the instance starts and deleted
+// when the instance terminates.
+type SecondaryNetworkInterfaceSpec struct {
+	// ID of the subnet the interface will be created in. The subnet
+	// must be in the availability zone the instance runs in.
+	SubnetID string `json:"subnetID"`
+	// Security groups attached to the interface. If empty, the
+	// controller's boot security groups are used.
+	SecurityGroupIDs []string `json:"securityGroupIDs,omitempty"`
 }
 
 // NodeStatus is the last observed status of a Node.
@@ -985,10 +1422,87 @@ type NodeStatus struct {
 	Phase NodePhase `json:"phase"`
 	// Cloud instance ID of this Node.
 	InstanceID string `json:"instanceID"`
+	// ID of the subnet this Node's instance was launched into, when
+	// the provider reports one.
+	SubnetID string `json:"subnetID,omitempty"`
 	// IP addresses and DNS names of this Node.
 	Addresses []NetworkAddress `json:"addresses"`
 	// If a Pod is bound to this Node, this is the name of that Pod.
 	BoundPodName string `json:"boundPodName"`
+	// LaunchLatencySeconds is how long this Node's instance took to
+	// go from dispatch to a healthy cell agent, recorded once when
+	// the Node first became available.
+	LaunchLatencySeconds float64 `json:"launchLatencySeconds,omitempty"`
+	// Conditions reflect the health of the running Node: whether it
+	// is still heartbeating and any resource pressure the cell agent
+	// reported.
+	Conditions []NodeCondition `json:"conditions,omitempty"`
+}
+
+// ConditionStatus is the observed state of a condition, "True",
+// "False" or "Unknown".
+type ConditionStatus string
+
+const (
+	ConditionTrue    ConditionStatus = "True"
+	ConditionFalse   ConditionStatus = "False"
+	ConditionUnknown ConditionStatus = "Unknown"
+)
+
+// NodeConditionType names an aspect of node health tracked in
+// NodeStatus.Conditions.
+type NodeConditionType string
+
+const (
+	NodeReady          NodeConditionType = "Ready"
+	NodeMemoryPressure NodeConditionType = "MemoryPressure"
+	NodeDiskPressure   NodeConditionType = "DiskPressure"
+	NodePIDPressure    NodeConditionType = "PIDPressure"
+)
+
+// NodeCondition records the last observed state of one aspect of a
+// running Node's health.
+type NodeCondition struct {
+	Type   NodeConditionType `json:"type"`
+	Status ConditionStatus   `json:"status"`
+	// Last time the condition was refreshed from a node report.
+	LastHeartbeatTime Time   `json:"lastHeartbeatTime,omitempty"`
+	Reason            string `json:"reason,omitempty"`
+	Message           string `json:"message,omitempty"`
+}
+
+// GetCondition returns the condition of the given type or nil if the
+// node doesn't have one.
+func (ns *NodeStatus) GetCondition(conditionType NodeConditionType) *NodeCondition {
+	for i := range ns.Conditions {
+		if ns.Conditions[i].Type == conditionType {
+			return &ns.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// SetCondition updates or adds a condition, refreshing its heartbeat
+// time. It reports whether the condition's status changed.
+func (ns *NodeStatus) SetCondition(conditionType NodeConditionType, status ConditionStatus, reason, message string) bool {
+	now := Now()
+	cond := ns.GetCondition(conditionType)
+	if cond == nil {
+		ns.Conditions = append(ns.Conditions, NodeCondition{
+			Type:              conditionType,
+			Status:            status,
+			LastHeartbeatTime: now,
+			Reason:            reason,
+			Message:           message,
+		})
+		return true
+	}
+	changed := cond.Status != status
+	cond.Status = status
+	cond.LastHeartbeatTime = now
+	cond.Reason = reason
+	cond.Message = message
+	return changed
 }
 
 // NodePhase is the last observed phase of the Node. Can be "creating",
@@ -1007,7 +1521,7 @@ const (
 )
 
 type NodeList struct {
-	TypeMeta `json:",inline"`
+	TypeMeta `json:",inline,squash"`
 	Items    []*Node `json:"items"`
 }
 
@@ -1184,7 +1698,7 @@ type Event struct {
 
 // A list of Events.
 type EventList struct {
-	TypeMeta `json:",inline"`
+	TypeMeta `json:",inline,squash"`
 	Items    []*Event `json:"items"`
 }
 
@@ -1204,7 +1718,7 @@ type LogFile struct {
 
 // A list of logfiles.
 type LogFileList struct {
-	TypeMeta `json:",inline"`
+	TypeMeta `json:",inline,squash"`
 	Items    []*LogFile `json:"items"`
 }
 
@@ -1240,8 +1754,16 @@ type UnitStatus struct {
 	LastTerminationState UnitState `json:"lastState,omitempty"`
 	RestartCount         int32     `json:"restartCount"`
 	Image                string    `json:"image"`
-	Ready                bool      `json:"ready"`
-	Started              *bool     `json:"started"`
+	// ImageID records the image the unit is actually running as
+	// resolved at pull time, including the `@sha256:` digest. It lets
+	// users audit exactly what ran when Image uses a mutable tag.
+	ImageID string `json:"imageID,omitempty"`
+	// LogSize is the total size in bytes of the unit's log files on
+	// the cell (the active file plus any rotated ones), as reported
+	// by the agent.
+	LogSize int64 `json:"logSize,omitempty"`
+	Ready   bool  `json:"ready"`
+	Started *bool `json:"started"`
 }
 
 type Metrics struct {
@@ -1262,6 +1784,6 @@ type Metrics struct {
 type ResourceMetrics map[string]float64
 
 type MetricsList struct {
-	TypeMeta `json:",inline"`
+	TypeMeta `json:",inline,squash"`
 	Items    []*Metrics
 }
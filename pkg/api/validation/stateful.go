@@ -33,16 +33,20 @@ import (
 // system.
 
 type StatefulValidator struct {
-	cloudStatus   cloud.StatusKeeper
-	cloudProvider string
-	vpcCIDRs      []string
+	cloudStatus              cloud.StatusKeeper
+	cloudProvider            string
+	vpcCIDRs                 []string
+	supportedRuntimeClasses  []string
+	forbidPublicSourceRanges bool
 }
 
-func NewStatefulValidator(status cloud.StatusKeeper, cloudProvider string, vpcCIDRs []string) *StatefulValidator {
+func NewStatefulValidator(status cloud.StatusKeeper, cloudProvider string, vpcCIDRs []string, supportedRuntimeClasses []string, forbidPublicSourceRanges bool) *StatefulValidator {
 	return &StatefulValidator{
-		cloudStatus:   status,
-		cloudProvider: cloudProvider,
-		vpcCIDRs:      vpcCIDRs,
+		cloudStatus:              status,
+		cloudProvider:            cloudProvider,
+		vpcCIDRs:                 vpcCIDRs,
+		supportedRuntimeClasses:  supportedRuntimeClasses,
+		forbidPublicSourceRanges: forbidPublicSourceRanges,
 	}
 }
 
@@ -67,6 +71,22 @@ func (v *StatefulValidator) ValidatePodSpec(spec *api.PodSpec, fldPath *field.Pa
 			}
 		}
 	}
+	if spec.RuntimeClassName != "" &&
+		!util.StringInSlice(spec.RuntimeClassName, v.supportedRuntimeClasses) {
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("runtimeClassName"), spec.RuntimeClassName, v.supportedRuntimeClasses))
+	}
+	if v.forbidPublicSourceRanges {
+		for i, sr := range spec.SourceRanges {
+			if sr == cloud.PublicCIDR {
+				msg := "the controller is configured to forbid fully-open ingress, list the specific CIDRs that should reach this pod"
+				allErrs = append(allErrs, field.Forbidden(fldPath.Child("sourceRanges").Index(i), msg))
+			}
+		}
+	}
+	if spec.Resources.InstanceStoreRoot && v.cloudProvider != cloud.ProviderAWS {
+		msg := "instance-store backed root disks are only supported on AWS"
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("resources.instanceStoreRoot"), spec.Resources.InstanceStoreRoot, msg))
+	}
 	if v.cloudProvider == cloud.ProviderAzure {
 		if spec.Resources.SustainedCPU != nil && *spec.Resources.SustainedCPU {
 			msg := "Azure does not support burstable instances with sustained CPUs"
@@ -67,6 +67,31 @@ func TestValidateStatefulPodSpecPlacement(t *testing.T) {
 	}
 }
 
+func TestValidateStatefulPodSpecRuntimeClass(t *testing.T) {
+	sv := StatefulValidator{supportedRuntimeClasses: []string{"gvisor", "kata"}}
+	testCases := []struct {
+		runtimeClassName string
+		numErrs          int
+	}{
+		{"", 0},
+		{"gvisor", 0},
+		{"kata", 0},
+		{"runc2", 1},
+	}
+	for i, testCase := range testCases {
+		ps := api.NewPod().Spec
+		ps.RuntimeClassName = testCase.runtimeClassName
+		errs := sv.ValidatePodSpec(&ps, field.NewPath("field"))
+		assert.Len(t, errs, testCase.numErrs, "test %d failed", i)
+	}
+	// with no runtime classes configured, setting one is an error
+	sv = StatefulValidator{}
+	ps := api.NewPod().Spec
+	ps.RuntimeClassName = "gvisor"
+	errs := sv.ValidatePodSpec(&ps, field.NewPath("field"))
+	assert.Len(t, errs, 1)
+}
+
 func TestValidateStatefulPodSpecSustainedCPU(t *testing.T) {
 	sv := StatefulValidator{}
 	truth := true
@@ -106,3 +131,24 @@ func TestValidateStatefulPodSpecSustainedCPU(t *testing.T) {
 		assert.Len(t, errs, testCase.numErrs, "test %d failed", i)
 	}
 }
+
+func TestValidateStatefulPodSpecSourceRanges(t *testing.T) {
+	testCases := []struct {
+		forbidPublic bool
+		sourceRanges []string
+		numErrs      int
+	}{
+		{false, nil, 0},
+		{false, []string{"10.0.0.0/8", cloud.PublicCIDR}, 0},
+		{true, []string{"10.0.0.0/8"}, 0},
+		{true, []string{cloud.PublicCIDR}, 1},
+		{true, []string{"10.0.0.0/8", cloud.PublicCIDR}, 1},
+	}
+	for i, testCase := range testCases {
+		sv := StatefulValidator{forbidPublicSourceRanges: testCase.forbidPublic}
+		ps := api.NewPod().Spec
+		ps.SourceRanges = testCase.sourceRanges
+		errs := sv.ValidatePodSpec(&ps, field.NewPath("field"))
+		assert.Len(t, errs, testCase.numErrs, "test %d failed", i)
+	}
+}
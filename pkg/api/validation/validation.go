@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"path"
 	"strconv"
 	"strings"
 
@@ -228,6 +229,16 @@ func ValidateObjectMeta(meta *api.ObjectMeta, requiresNamespace bool, nameFn Val
 	return allErrs
 }
 
+// validFieldRefPaths are the pod fields an env var may take its value
+// from via a fieldRef.
+var validFieldRefPaths = []string{
+	"metadata.name",
+	"metadata.namespace",
+	"spec.nodeName",
+	"status.boundNodeName",
+	"status.podIP",
+}
+
 func validateEnv(vars []api.EnvVar, fldPath *field.Path) field.ErrorList {
 	allErrs := field.ErrorList{}
 
@@ -240,6 +251,45 @@ func validateEnv(vars []api.EnvVar, fldPath *field.Path) field.ErrorList {
 				allErrs = append(allErrs, field.Invalid(idxPath.Child("name"), ev.Name, msg))
 			}
 		}
+		if ev.ValueFrom != nil {
+			if len(ev.Value) != 0 {
+				allErrs = append(allErrs, field.Invalid(idxPath.Child("valueFrom"), "", "may not be specified when `value` is not empty"))
+			}
+			numSources := 0
+			if ev.ValueFrom.FieldRef != nil {
+				numSources++
+				fieldRefPath := idxPath.Child("valueFrom", "fieldRef")
+				if !util.StringInSlice(ev.ValueFrom.FieldRef.FieldPath, validFieldRefPaths) {
+					allErrs = append(allErrs, field.NotSupported(fieldRefPath.Child("fieldPath"), ev.ValueFrom.FieldRef.FieldPath, validFieldRefPaths))
+				}
+			}
+			if ev.ValueFrom.ConfigMapKeyRef != nil {
+				numSources++
+				keyRefPath := idxPath.Child("valueFrom", "configMapKeyRef")
+				if len(ev.ValueFrom.ConfigMapKeyRef.Name) == 0 {
+					allErrs = append(allErrs, field.Required(keyRefPath.Child("name"), ""))
+				}
+				if len(ev.ValueFrom.ConfigMapKeyRef.Key) == 0 {
+					allErrs = append(allErrs, field.Required(keyRefPath.Child("key"), ""))
+				}
+			}
+			if ev.ValueFrom.SecretKeyRef != nil {
+				numSources++
+				keyRefPath := idxPath.Child("valueFrom", "secretKeyRef")
+				if len(ev.ValueFrom.SecretKeyRef.Name) == 0 {
+					allErrs = append(allErrs, field.Required(keyRefPath.Child("name"), ""))
+				}
+				if len(ev.ValueFrom.SecretKeyRef.Key) == 0 {
+					allErrs = append(allErrs, field.Required(keyRefPath.Child("key"), ""))
+				}
+			}
+			if numSources == 0 {
+				allErrs = append(allErrs, field.Required(idxPath.Child("valueFrom"), "must specify a fieldRef, configMapKeyRef or secretKeyRef"))
+			}
+			if numSources > 1 {
+				allErrs = append(allErrs, field.Forbidden(idxPath.Child("valueFrom"), "may not specify more than 1 source"))
+			}
+		}
 	}
 	return allErrs
 }
@@ -262,12 +312,12 @@ func validateRestartPolicy(restartPolicy *api.RestartPolicy, fldPath *field.Path
 func validateSpotPolicy(spotPolicy *api.SpotPolicy, fldPath *field.Path) field.ErrorList {
 	allErrors := field.ErrorList{}
 	switch *spotPolicy {
-	case api.SpotAlways, api.SpotNever:
+	case api.SpotAlways, api.SpotPreferred, api.SpotNever:
 		break
 	case "":
 		allErrors = append(allErrors, field.Required(fldPath, ""))
 	default:
-		validValues := []string{string(api.SpotAlways), string(api.SpotNever)}
+		validValues := []string{string(api.SpotAlways), string(api.SpotPreferred), string(api.SpotNever)}
 		allErrors = append(allErrors, field.NotSupported(fldPath, *spotPolicy, validValues))
 	}
 
@@ -313,7 +363,12 @@ func validateResourceSpec(rs *api.ResourceSpec, fldPath *field.Path) field.Error
 	allErrs = append(allErrs, ValidateResourceParses(rs.Memory, fldPath.Child("Memory"))...)
 	allErrs = append(allErrs, ValidateResourceParses(rs.CPU, fldPath.Child("CPU"))...)
 	allErrs = append(allErrs, ValidateGPUSpec(rs.GPU, fldPath.Child("GPU"))...)
+	allErrs = append(allErrs, ValidateResourceParses(rs.GPUMemory, fldPath.Child("GPUMemory"))...)
 	allErrs = append(allErrs, ValidateResourceParses(rs.VolumeSize, fldPath.Child("VolumeSize"))...)
+	if rs.InstanceStoreRoot && rs.VolumeSize != "" {
+		msg := "volumeSize cannot be set with instanceStoreRoot, the size of an instance-store disk is fixed by the instance type"
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("VolumeSize"), rs.VolumeSize, msg))
+	}
 
 	return allErrs
 }
@@ -342,11 +397,194 @@ func ValidatePodSpec(spec *api.PodSpec, fldPath *field.Path) field.ErrorList {
 
 	allErrs = append(allErrs, validateResourceSpec(&spec.Resources, fldPath.Child("Resources"))...)
 	allErrs = append(allErrs, validateRestartPolicy(&spec.RestartPolicy, fldPath.Child("restartPolicy"))...)
+	allErrs = append(allErrs, validateUnitRestartPolicies(spec, fldPath)...)
+	allErrs = append(allErrs, validateEnv(spec.Env, fldPath.Child("env"))...)
 	allErrs = append(allErrs, validateUnits(spec.Units, allVolumes, fldPath.Child("units"))...)
 	allErrs = append(allErrs, validateInitUnits(spec.InitUnits, spec.Units, allVolumes, fldPath.Child("initUnits"))...)
 	allErrs = append(allErrs, validatePodSpot(spec.Spot, fldPath.Child("spot"))...)
 	allErrs = append(allErrs, ValidateInstanceType(spec.InstanceType, fldPath.Child("instanceType"))...)
 	allErrs = append(allErrs, validatePodSecurityContext(spec.SecurityContext, fldPath.Child("SecurityContext"))...)
+	allErrs = append(allErrs, validateSecondaryNetworkInterface(spec.SecondaryNetworkInterface, fldPath.Child("secondaryNetworkInterface"))...)
+	allErrs = append(allErrs, validatePlacementGroup(spec, fldPath.Child("placement"))...)
+	allErrs = append(allErrs, validateHostNetwork(spec, fldPath)...)
+	allErrs = append(allErrs, validateLogConfig(spec.LogConfig, fldPath.Child("logConfig"))...)
+	allErrs = append(allErrs, validateSourceRanges(spec.SourceRanges, fldPath.Child("sourceRanges"))...)
+	allErrs = append(allErrs, validateShmSize(spec, fldPath.Child("shmSize"))...)
+	allErrs = append(allErrs, validatePortNames(spec, fldPath)...)
+	if spec.ActiveDeadlineSeconds != nil && *spec.ActiveDeadlineSeconds <= 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("activeDeadlineSeconds"), *spec.ActiveDeadlineSeconds, "must be greater than 0"))
+	}
+	return allErrs
+}
+
+// validatePortNames checks that named container ports are unique
+// across every unit in the pod, init units included. Services and
+// probes resolve ports by name pod-wide, a duplicate would make that
+// lookup ambiguous. Unnamed ports may repeat freely.
+func validatePortNames(spec *api.PodSpec, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	allNames := sets.String{}
+	checkUnits := func(units []api.Unit, unitsPath *field.Path) {
+		for i, unit := range units {
+			for j, port := range unit.Ports {
+				if port.Name == "" {
+					continue
+				}
+				namePath := unitsPath.Index(i).Child("ports").Index(j).Child("name")
+				if allNames.Has(port.Name) {
+					allErrs = append(allErrs, field.Duplicate(namePath, port.Name))
+				} else {
+					allNames.Insert(port.Name)
+				}
+			}
+		}
+	}
+	checkUnits(spec.InitUnits, fldPath.Child("initUnits"))
+	checkUnits(spec.Units, fldPath.Child("units"))
+	return allErrs
+}
+
+func validateShmSize(spec *api.PodSpec, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if spec.ShmSize == "" {
+		return allErrs
+	}
+	shm, err := resource.ParseQuantity(spec.ShmSize)
+	if err != nil {
+		msg := fmt.Sprintf("Invalid quantity format specified: %v", err)
+		return append(allErrs, field.Invalid(fldPath, spec.ShmSize, msg))
+	}
+	if spec.Resources.Memory != "" {
+		mem, err := resource.ParseQuantity(spec.Resources.Memory)
+		if err == nil && shm.Cmp(mem) > 0 {
+			msg := fmt.Sprintf("shmSize cannot exceed the pod's memory request of %s", spec.Resources.Memory)
+			allErrs = append(allErrs, field.Invalid(fldPath, spec.ShmSize, msg))
+		}
+	}
+	return allErrs
+}
+
+func validateSourceRanges(sourceRanges []string, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	for i, sr := range sourceRanges {
+		if _, _, err := net.ParseCIDR(sr); err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Index(i), sr, "Could not parse source range as CIDR"))
+		}
+	}
+	return allErrs
+}
+
+func validateLogConfig(logConfig api.PodLogConfig, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	allErrs = append(allErrs, ValidateNonnegativeField(logConfig.MaxSize, fldPath.Child("maxSize"))...)
+	allErrs = append(allErrs, ValidateNonnegativeField(int64(logConfig.MaxFiles), fldPath.Child("maxFiles"))...)
+	return allErrs
+}
+
+func validatePlacementGroup(spec *api.PodSpec, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	placement := spec.Placement
+	strategy := placement.PlacementGroupStrategy
+	switch strategy {
+	case "", api.PlacementStrategyCluster, api.PlacementStrategySpread, api.PlacementStrategyPartition:
+		break
+	default:
+		validValues := []string{
+			string(api.PlacementStrategyCluster),
+			string(api.PlacementStrategySpread),
+			string(api.PlacementStrategyPartition),
+		}
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("placementGroupStrategy"), strategy, validValues))
+	}
+	if strategy != "" && placement.PlacementGroup == "" {
+		allErrs = append(allErrs, field.Required(fldPath.Child("placementGroup"), "placementGroupStrategy requires a placementGroup"))
+	}
+	if placement.PlacementGroup != "" &&
+		(strategy == "" || strategy == api.PlacementStrategyCluster) &&
+		!supportsClusterPlacement(spec.InstanceType) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("placementGroup"), placement.PlacementGroup,
+			fmt.Sprintf("instance type %s cannot be launched into a cluster placement group", spec.InstanceType)))
+	}
+	return allErrs
+}
+
+// burstable instance families cannot join cluster placement groups
+func supportsClusterPlacement(instanceType string) bool {
+	family := strings.SplitN(instanceType, ".", 2)[0]
+	switch family {
+	case "t1", "t2", "t3", "t3a", "t4g":
+		return false
+	}
+	return true
+}
+
+func validateSecondaryNetworkInterface(iface *api.SecondaryNetworkInterfaceSpec, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if iface == nil {
+		return allErrs
+	}
+	if len(iface.SubnetID) == 0 {
+		allErrs = append(allErrs, field.Required(fldPath.Child("subnetID"), ""))
+	}
+	for i, sg := range iface.SecurityGroupIDs {
+		if len(sg) == 0 {
+			allErrs = append(allErrs, field.Required(fldPath.Child("securityGroupIDs").Index(i), ""))
+		}
+	}
+	return allErrs
+}
+
+func validateUnitRestartPolicies(spec *api.PodSpec, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	for i, unit := range spec.Units {
+		if unit.RestartPolicy == nil {
+			continue
+		}
+		idxPath := fldPath.Child("units").Index(i).Child("restartPolicy")
+		allErrs = append(allErrs, validateRestartPolicy(unit.RestartPolicy, idxPath)...)
+		if spec.RestartPolicy == api.RestartPolicyNever &&
+			*unit.RestartPolicy == api.RestartPolicyAlways {
+			allErrs = append(allErrs, field.Forbidden(idxPath, "may not be Always when the pod restartPolicy is Never, the pod could never complete"))
+		}
+	}
+	for i, unit := range spec.InitUnits {
+		if unit.RestartPolicy == nil {
+			continue
+		}
+		idxPath := fldPath.Child("initUnits").Index(i).Child("restartPolicy")
+		allErrs = append(allErrs, validateRestartPolicy(unit.RestartPolicy, idxPath)...)
+		if *unit.RestartPolicy == api.RestartPolicyAlways {
+			allErrs = append(allErrs, field.Forbidden(idxPath, "init units must run to completion and may not be Always"))
+		}
+	}
+	return allErrs
+}
+
+func validateHostNetwork(spec *api.PodSpec, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if !api.IsHostNetwork(spec) {
+		return allErrs
+	}
+	if len(spec.HostAliases) > 0 {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("hostAliases"), "may not be set for pods using host networking"))
+	}
+	allErrs = append(allErrs, validateHostNetworkPorts(spec.Units, fldPath.Child("units"))...)
+	allErrs = append(allErrs, validateHostNetworkPorts(spec.InitUnits, fldPath.Child("initUnits"))...)
+	return allErrs
+}
+
+func validateHostNetworkPorts(units []api.Unit, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	for i, unit := range units {
+		for j, port := range unit.Ports {
+			if port.HostPort != 0 && port.HostPort != port.ContainerPort {
+				allErrs = append(allErrs, field.Invalid(
+					fldPath.Index(i).Child("ports").Index(j).Child("hostPort"),
+					port.HostPort,
+					"must match containerPort for pods using host networking"))
+			}
+		}
+	}
 	return allErrs
 }
 
@@ -363,9 +601,86 @@ func validatePodSecurityContext(context *api.PodSecurityContext, fldPath *field.
 			allErrs = append(allErrs, field.Invalid(idxPath, sysctl, msg))
 		}
 	}
+	if context.FSGroupChangePolicy != nil {
+		policy := *context.FSGroupChangePolicy
+		if policy != api.FSGroupChangeAlways && policy != api.FSGroupChangeOnRootMismatch {
+			validValues := []string{api.FSGroupChangeAlways, api.FSGroupChangeOnRootMismatch}
+			allErrs = append(allErrs, field.NotSupported(fldPath.Child("fsGroupChangePolicy"), policy, validValues))
+		}
+	}
 	return allErrs
 }
 
+func validateEnvFileFrom(sources []api.EnvFileSource, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	for i, src := range sources {
+		idxPath := fldPath.Index(i)
+		if src.ConfigMapKey != nil && src.SecretKey != nil {
+			allErrs = append(allErrs, field.Invalid(idxPath, src, "may not specify both configMapKey and secretKey"))
+			continue
+		}
+		if src.ConfigMapKey == nil && src.SecretKey == nil {
+			allErrs = append(allErrs, field.Required(idxPath, "one of configMapKey or secretKey is required"))
+			continue
+		}
+		if src.ConfigMapKey != nil {
+			if len(src.ConfigMapKey.Name) == 0 {
+				allErrs = append(allErrs, field.Required(idxPath.Child("configMapKey", "name"), ""))
+			}
+			if len(src.ConfigMapKey.Key) == 0 {
+				allErrs = append(allErrs, field.Required(idxPath.Child("configMapKey", "key"), ""))
+			}
+		}
+		if src.SecretKey != nil {
+			if len(src.SecretKey.Name) == 0 {
+				allErrs = append(allErrs, field.Required(idxPath.Child("secretKey", "name"), ""))
+			}
+			if len(src.SecretKey.Key) == 0 {
+				allErrs = append(allErrs, field.Required(idxPath.Child("secretKey", "key"), ""))
+			}
+		}
+	}
+	return allErrs
+}
+
+// validateImageDigest checks that an image pinned by digest
+// (repo@sha256:...) uses a wellformed sha256 digest.
+func validateImageDigest(image string, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	idx := strings.Index(image, "@")
+	if idx < 0 {
+		return allErrs
+	}
+	digest := image[idx+1:]
+	if !strings.HasPrefix(digest, "sha256:") {
+		allErrs = append(allErrs, field.Invalid(fldPath, image, "image digest must use the sha256: algorithm"))
+		return allErrs
+	}
+	hexPart := strings.TrimPrefix(digest, "sha256:")
+	if len(hexPart) != 64 {
+		allErrs = append(allErrs, field.Invalid(fldPath, image, "sha256 image digest must be 64 hex characters"))
+		return allErrs
+	}
+	for _, c := range hexPart {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			allErrs = append(allErrs, field.Invalid(fldPath, image, "sha256 image digest must be lowercase hex"))
+			break
+		}
+	}
+	return allErrs
+}
+
+// Signal names the cell agent can deliver for a unit's graceful
+// shutdown.
+var unitStopSignals = sets.NewString(
+	"SIGABRT", "SIGALRM", "SIGBUS", "SIGCHLD", "SIGCONT", "SIGFPE",
+	"SIGHUP", "SIGILL", "SIGINT", "SIGIO", "SIGKILL", "SIGPIPE",
+	"SIGPROF", "SIGPWR", "SIGQUIT", "SIGSEGV", "SIGSTKFLT", "SIGSTOP",
+	"SIGSYS", "SIGTERM", "SIGTRAP", "SIGTSTP", "SIGTTIN", "SIGTTOU",
+	"SIGURG", "SIGUSR1", "SIGUSR2", "SIGVTALRM", "SIGWINCH", "SIGXCPU",
+	"SIGXFSZ",
+)
+
 func validateUnits(units []api.Unit, volumes sets.String, fldPath *field.Path) field.ErrorList {
 	allErrs := field.ErrorList{}
 
@@ -392,11 +707,171 @@ func validateUnits(units []api.Unit, volumes sets.String, fldPath *field.Path) f
 			msg := "Invalid image format: must be one of ACCOUNT.dkr.ecr.REGION.amazonaws.com/reponame, url/namespace/reponame, namespace/reponame or reponame"
 			allErrs = append(allErrs, field.Invalid(idxPath.Child("image"), unit.Image, msg))
 		}
+		allErrs = append(allErrs, validateImageDigest(unit.Image, idxPath.Child("image"))...)
+		if unit.Shell && len(unit.Command) != 1 {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("command"), unit.Command, "shell units require a single shell command string in command"))
+		}
+		// The cell agent chdirs into the working directory before
+		// starting the unit, a relative path only produces a cryptic
+		// start failure there.
+		if unit.WorkingDir != "" && !path.IsAbs(unit.WorkingDir) {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("workingDir"), unit.WorkingDir, "workingDir must be an absolute path"))
+		}
+		if unit.StopSignal != "" && !unitStopSignals.Has(unit.StopSignal) {
+			allErrs = append(allErrs, field.NotSupported(idxPath.Child("stopSignal"), unit.StopSignal, unitStopSignals.List()))
+		}
 		allErrs = append(allErrs, validateEnv(unit.Env, idxPath.Child("env"))...)
+		allErrs = append(allErrs, validateEnvFileFrom(unit.EnvFileFrom, idxPath.Child("envFileFrom"))...)
 		allErrs = append(allErrs, validateVolumeMounts(unit.VolumeMounts, volumes, idxPath.Child("volumeMounts"))...)
-		//
-		// todo: validate probes when we get probes
-		//
+		allErrs = append(allErrs, validateUlimits(unit.Ulimits, idxPath.Child("ulimits"))...)
+		allErrs = append(allErrs, validateProbe(unit.StartupProbe, idxPath.Child("startupProbe"))...)
+		allErrs = append(allErrs, validateProbe(unit.ReadinessProbe, idxPath.Child("readinessProbe"))...)
+		allErrs = append(allErrs, validateProbe(unit.LivenessProbe, idxPath.Child("livenessProbe"))...)
+		allErrs = append(allErrs, validateLifecycle(unit.Lifecycle, idxPath.Child("lifecycle"))...)
+		allErrs = append(allErrs, validateResourceRequirements(unit.Resources, idxPath.Child("resources"))...)
+	}
+	return allErrs
+}
+
+// unitResourceNames are the resource names a unit may request.
+var unitResourceNames = sets.NewString("cpu", "memory", "gpu")
+
+func validateResourceRequirements(req api.ResourceRequirements, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	validateList := func(rl api.ResourceList, child string) {
+		for name, value := range rl {
+			keyPath := fldPath.Child(child).Key(name)
+			if !unitResourceNames.Has(name) {
+				allErrs = append(allErrs, field.NotSupported(keyPath, name, unitResourceNames.List()))
+				continue
+			}
+			if _, err := resource.ParseQuantity(value); err != nil {
+				allErrs = append(allErrs, field.Invalid(keyPath, value, "must be a valid quantity"))
+			}
+		}
+	}
+	validateList(req.Requests, "requests")
+	validateList(req.Limits, "limits")
+	return allErrs
+}
+
+func validateProbe(probe *api.Probe, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if probe == nil {
+		return allErrs
+	}
+	if probe.HTTPGet != nil {
+		allErrs = append(allErrs, validateHTTPGetAction(probe.HTTPGet, fldPath.Child("httpGet"))...)
+	}
+	if probe.GRPC != nil {
+		allErrs = append(allErrs, validateGRPCAction(probe.GRPC, fldPath.Child("grpc"))...)
+	}
+	return allErrs
+}
+
+func validateGRPCAction(action *api.GRPCAction, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	for _, msg := range validation.IsValidPortNum(int(action.Port)) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("port"), action.Port, msg))
+	}
+	return allErrs
+}
+
+func validateHTTPGetAction(action *api.HTTPGetAction, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	// an empty scheme defaults to HTTP
+	switch action.Scheme {
+	case "", api.URISchemeHTTP, api.URISchemeHTTPS:
+	default:
+		validValues := []string{string(api.URISchemeHTTP), string(api.URISchemeHTTPS)}
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("scheme"), action.Scheme, validValues))
+	}
+	hostHeader := false
+	for i, header := range action.HTTPHeaders {
+		idxPath := fldPath.Child("httpHeaders").Index(i)
+		for _, msg := range validation.IsHTTPHeaderName(header.Name) {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("name"), header.Name, msg))
+		}
+		if strings.EqualFold(header.Name, "Host") {
+			hostHeader = true
+		}
+	}
+	// the host field defaults to the pod IP; setting it alongside a
+	// "Host" header leaves it ambiguous which one the probe connects
+	// to and which one ends up on the wire
+	if action.Host != "" && hostHeader {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("host"), action.Host, "host may not be combined with a \"Host\" entry in httpHeaders"))
+	}
+	return allErrs
+}
+
+func validateLifecycle(lifecycle *api.Lifecycle, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if lifecycle == nil {
+		return allErrs
+	}
+	if lifecycle.PostStart != nil {
+		allErrs = append(allErrs, validateHandler(lifecycle.PostStart, fldPath.Child("postStart"))...)
+	}
+	if lifecycle.PreStop != nil {
+		allErrs = append(allErrs, validateHandler(lifecycle.PreStop, fldPath.Child("preStop"))...)
+	}
+	return allErrs
+}
+
+func validateHandler(handler *api.Handler, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	numActions := 0
+	if handler.Exec != nil {
+		numActions++
+		if len(handler.Exec.Command) == 0 {
+			allErrs = append(allErrs, field.Required(fldPath.Child("exec", "command"), ""))
+		}
+	}
+	if handler.HTTPGet != nil {
+		numActions++
+		allErrs = append(allErrs, validateHTTPGetAction(handler.HTTPGet, fldPath.Child("httpGet"))...)
+	}
+	if handler.TCPSocket != nil {
+		numActions++
+	}
+	if handler.GRPC != nil {
+		numActions++
+		allErrs = append(allErrs, validateGRPCAction(handler.GRPC, fldPath.Child("grpc"))...)
+	}
+	if numActions == 0 {
+		allErrs = append(allErrs, field.Required(fldPath, "must specify an exec, httpGet, tcpSocket or grpc action"))
+	} else if numActions > 1 {
+		allErrs = append(allErrs, field.Forbidden(fldPath, "may not specify more than 1 action"))
+	}
+	return allErrs
+}
+
+// setrlimit resources the cell agent knows how to apply per unit.
+var ulimitNames = sets.NewString(
+	"core", "cpu", "data", "fsize", "locks", "memlock", "msgqueue",
+	"nice", "nofile", "nproc", "rss", "rtprio", "rttime", "sigpending",
+	"stack",
+)
+
+func validateUlimits(ulimits []api.Ulimit, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	allNames := sets.String{}
+	for i, ulimit := range ulimits {
+		idxPath := fldPath.Index(i)
+		if !ulimitNames.Has(ulimit.Name) {
+			allErrs = append(allErrs, field.NotSupported(idxPath.Child("name"), ulimit.Name, ulimitNames.List()))
+		}
+		if allNames.Has(ulimit.Name) {
+			allErrs = append(allErrs, field.Duplicate(idxPath.Child("name"), ulimit.Name))
+		} else {
+			allNames.Insert(ulimit.Name)
+		}
+		allErrs = append(allErrs, ValidateNonnegativeField(ulimit.Soft, idxPath.Child("soft"))...)
+		allErrs = append(allErrs, ValidateNonnegativeField(ulimit.Hard, idxPath.Child("hard"))...)
+		if ulimit.Hard < ulimit.Soft {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("hard"), ulimit.Hard, "hard limit may not be smaller than the soft limit"))
+		}
 	}
 	return allErrs
 }
@@ -495,7 +970,7 @@ func validateVolumeSource(source *api.VolumeSource, fldPath *field.Path) field.E
 	allErrs := field.ErrorList{}
 	if source.EmptyDir != nil {
 		numVolumes++
-		// EmptyDirs have nothing to validate
+		allErrs = append(allErrs, validateEmptyDirVolumeSource(source.EmptyDir, fldPath.Child("emptyDir"))...)
 	}
 
 	if source.PackagePath != nil {
@@ -508,22 +983,28 @@ func validateVolumeSource(source *api.VolumeSource, fldPath *field.Path) field.E
 		allErrs = append(allErrs, validateHostPathVolumeSource(source.HostPath, fldPath)...)
 	}
 
-	if source.ConfigMap != nil ||
-		source.Secret != nil ||
-		source.Projected != nil {
+	if source.ConfigMap != nil {
 		numVolumes++
+		allErrs = append(allErrs, validateConfigMapVolumeSource(source.ConfigMap, fldPath.Child("configMap"))...)
 	}
 
-	// we will likely implement secret volumes at some point
+	if source.Secret != nil {
+		numVolumes++
+		allErrs = append(allErrs, validateSecretVolumeSource(source.Secret, fldPath.Child("secret"))...)
+	}
+
+	if source.Projected != nil {
+		numVolumes++
+		allErrs = append(allErrs, validateProjectedVolumeSource(source.Projected, fldPath.Child("projected"))...)
+	}
+
+	if source.CloudVolume != nil {
+		numVolumes++
+		if len(source.CloudVolume.VolumeID) == 0 {
+			allErrs = append(allErrs, field.Required(fldPath.Child("cloudVolume", "volumeID"), ""))
+		}
+	}
 
-	// if source.Secret != nil {
-	// 	if numVolumes > 0 {
-	// 		allErrs = append(allErrs, field.Forbidden(fldPath.Child("secret"), "may not specify more than 1 volume type"))
-	// 	} else {
-	// 		numVolumes++
-	// 		allErrs = append(allErrs, validateSecretVolumeSource(source.Secret, fldPath.Child("secret"))...)
-	// 	}
-	// }
 	if numVolumes == 0 {
 		allErrs = append(allErrs, field.Required(fldPath, "must specify a valid volume type"))
 	}
@@ -534,6 +1015,140 @@ func validateVolumeSource(source *api.VolumeSource, fldPath *field.Path) field.E
 	return allErrs
 }
 
+// validateKeyToPaths checks the key-to-path items of a configmap or
+// secret volume. Paths are laid down relative to the volume, so they
+// must stay inside it.
+func validateKeyToPaths(items []api.KeyToPath, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	for i, item := range items {
+		idxPath := fldPath.Index(i)
+		if len(item.Key) == 0 {
+			allErrs = append(allErrs, field.Required(idxPath.Child("key"), ""))
+		}
+		if path.IsAbs(item.Path) {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("path"), item.Path, "must be a relative path"))
+		}
+		if strings.Contains(item.Path, "..") {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("path"), item.Path, "must not contain '..'"))
+		}
+	}
+	return allErrs
+}
+
+// The existence of the referenced secret is checked at deploy time,
+// here we only make sure the reference can be resolved at all.
+func validateSecretVolumeSource(source *api.SecretVolumeSource, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if len(source.SecretName) == 0 {
+		allErrs = append(allErrs, field.Required(fldPath.Child("secretName"), ""))
+	}
+	allErrs = append(allErrs, validateKeyToPaths(source.Items, fldPath.Child("items"))...)
+	return allErrs
+}
+
+// The existence of the referenced configmap is checked at deploy
+// time, here we only make sure the reference can be resolved at all.
+func validateConfigMapVolumeSource(source *api.ConfigMapVolumeSource, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if len(source.Name) == 0 {
+		allErrs = append(allErrs, field.Required(fldPath.Child("name"), ""))
+	}
+	allErrs = append(allErrs, validateKeyToPaths(source.Items, fldPath.Child("items"))...)
+	return allErrs
+}
+
+func validateProjectedVolumeSource(source *api.ProjectedVolumeSource, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	for i, proj := range source.Sources {
+		idxPath := fldPath.Child("sources").Index(i)
+		numSources := 0
+		if proj.Secret != nil {
+			numSources++
+			if len(proj.Secret.Name) == 0 {
+				allErrs = append(allErrs, field.Required(idxPath.Child("secret", "name"), ""))
+			}
+			allErrs = append(allErrs, validateKeyToPaths(proj.Secret.Items, idxPath.Child("secret", "items"))...)
+		}
+		if proj.ConfigMap != nil {
+			numSources++
+			if len(proj.ConfigMap.Name) == 0 {
+				allErrs = append(allErrs, field.Required(idxPath.Child("configMap", "name"), ""))
+			}
+			allErrs = append(allErrs, validateKeyToPaths(proj.ConfigMap.Items, idxPath.Child("configMap", "items"))...)
+		}
+		if proj.DownwardAPI != nil {
+			numSources++
+			allErrs = append(allErrs, validateDownwardAPIProjection(proj.DownwardAPI, idxPath.Child("downwardAPI"))...)
+		}
+		if numSources == 0 {
+			allErrs = append(allErrs, field.Required(idxPath, "must specify a secret, configMap or downwardAPI projection"))
+		}
+		if numSources > 1 {
+			allErrs = append(allErrs, field.Forbidden(idxPath, "may not specify more than 1 projection type"))
+		}
+	}
+	return allErrs
+}
+
+// validDownwardAPIFieldPaths are the field paths a downwardAPI volume
+// file may reference. This is a different set than the env var field
+// refs: volume files can carry the whole label or annotation maps.
+var validDownwardAPIFieldPaths = []string{
+	"metadata.annotations",
+	"metadata.labels",
+	"metadata.name",
+	"metadata.namespace",
+	"metadata.uid",
+}
+
+func validateDownwardAPIProjection(proj *api.DownwardAPIProjection, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	for i, item := range proj.Items {
+		idxPath := fldPath.Child("items").Index(i)
+		if len(item.Path) == 0 {
+			allErrs = append(allErrs, field.Required(idxPath.Child("path"), ""))
+		}
+		if path.IsAbs(item.Path) {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("path"), item.Path, "must be a relative path"))
+		}
+		if strings.Contains(item.Path, "..") {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("path"), item.Path, "must not contain '..'"))
+		}
+		if item.FieldRef == nil {
+			allErrs = append(allErrs, field.Required(idxPath.Child("fieldRef"), ""))
+			continue
+		}
+		valid := false
+		for _, fp := range validDownwardAPIFieldPaths {
+			if item.FieldRef.FieldPath == fp {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			allErrs = append(allErrs, field.NotSupported(idxPath.Child("fieldRef", "fieldPath"), item.FieldRef.FieldPath, validDownwardAPIFieldPaths))
+		}
+	}
+	return allErrs
+}
+
+func validateEmptyDirVolumeSource(emptyDir *api.EmptyDir, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	switch emptyDir.Medium {
+	case api.StorageMediumDefault, api.StorageMediumMemory, api.StorageMediumInstanceStore, api.StorageMediumHugePages:
+		break
+	default:
+		validValues := []string{string(api.StorageMediumDefault), string(api.StorageMediumMemory), string(api.StorageMediumInstanceStore), string(api.StorageMediumHugePages)}
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("medium"), emptyDir.Medium, validValues))
+	}
+	// Hugepages are reserved up front, the cell agent can't size the
+	// mount without a limit.
+	if emptyDir.Medium == api.StorageMediumHugePages && emptyDir.SizeLimit <= 0 {
+		allErrs = append(allErrs, field.Required(fldPath.Child("sizeLimit"), "sizeLimit must be set when medium is HugePages"))
+	}
+	return allErrs
+}
+
 func validatePackagePathVolumeSource(packagePath *api.PackagePath, fldPath *field.Path) field.ErrorList {
 	allErrs := field.ErrorList{}
 	if len(packagePath.Path) == 0 {
@@ -550,14 +1165,6 @@ func validateHostPathVolumeSource(hostPath *api.HostPathVolumeSource, fldPath *f
 	return allErrs
 }
 
-// func validateSecretVolumeSource(secretSource *api.SecretVolumeSource, fldPath *field.Path) field.ErrorList {
-// 	allErrs := field.ErrorList{}
-// 	if len(secretSource.SecretName) == 0 {
-// 		allErrs = append(allErrs, field.Required(fldPath.Child("secretName"), ""))
-// 	}
-// 	return allErrs
-// }
-
 func validateVolumeMounts(mounts []api.VolumeMount, volumes sets.String, fldPath *field.Path) field.ErrorList {
 	allErrs := field.ErrorList{}
 	mountpoints := sets.NewString()
@@ -578,6 +1185,12 @@ func validateVolumeMounts(mounts []api.VolumeMount, volumes sets.String, fldPath
 			allErrs = append(allErrs, field.Invalid(idxPath.Child("mountPath"), mnt.MountPath, "must be unique"))
 		}
 		mountpoints.Insert(mnt.MountPath)
+		if path.IsAbs(mnt.SubPath) {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("subPath"), mnt.SubPath, "must be a relative path"))
+		}
+		if strings.Contains(mnt.SubPath, "..") {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("subPath"), mnt.SubPath, "must not contain '..'"))
+		}
 	}
 	return allErrs
 }
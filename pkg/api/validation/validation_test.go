@@ -23,6 +23,7 @@ import (
 
 	"github.com/elotl/kip/pkg/api"
 	"github.com/elotl/kip/pkg/util/validation/field"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/apimachinery/pkg/util/sets"
 )
 
@@ -106,6 +107,21 @@ func TestValidateEnv(t *testing.T) {
 		{Name: "ABC", Value: "value"},
 		{Name: "AbC_123", Value: "value"},
 		{Name: "abc", Value: ""},
+		{Name: "POD_IP", ValueFrom: &api.EnvVarSource{
+			FieldRef: &api.ObjectFieldSelector{FieldPath: "status.podIP"},
+		}},
+		{Name: "LOG_LEVEL", ValueFrom: &api.EnvVarSource{
+			ConfigMapKeyRef: &api.ConfigMapKeySelector{
+				LocalObjectReference: api.LocalObjectReference{Name: "settings"},
+				Key:                  "loglevel",
+			},
+		}},
+		{Name: "PASSWORD", ValueFrom: &api.EnvVarSource{
+			SecretKeyRef: &api.SecretKeySelector{
+				LocalObjectReference: api.LocalObjectReference{Name: "creds"},
+				Key:                  "password",
+			},
+		}},
 	}
 	if errs := validateEnv(successCase, field.NewPath("field")); len(errs) != 0 {
 		t.Errorf("expected success: %v", errs)
@@ -126,6 +142,52 @@ func TestValidateEnv(t *testing.T) {
 			envs:          []api.EnvVar{{Name: "a.b.c"}},
 			expectedError: `[0].name: Invalid value: "a.b.c": must match the regex`,
 		},
+		{
+			name: "both value and valueFrom",
+			envs: []api.EnvVar{{Name: "abc", Value: "value", ValueFrom: &api.EnvVarSource{
+				FieldRef: &api.ObjectFieldSelector{FieldPath: "status.podIP"},
+			}}},
+			expectedError: "[0].valueFrom: Invalid value",
+		},
+		{
+			name:          "valueFrom without a source",
+			envs:          []api.EnvVar{{Name: "abc", ValueFrom: &api.EnvVarSource{}}},
+			expectedError: "[0].valueFrom: Required value",
+		},
+		{
+			name: "valueFrom with two sources",
+			envs: []api.EnvVar{{Name: "abc", ValueFrom: &api.EnvVarSource{
+				FieldRef: &api.ObjectFieldSelector{FieldPath: "status.podIP"},
+				ConfigMapKeyRef: &api.ConfigMapKeySelector{
+					LocalObjectReference: api.LocalObjectReference{Name: "settings"},
+					Key:                  "loglevel",
+				},
+			}}},
+			expectedError: "[0].valueFrom: Forbidden",
+		},
+		{
+			name: "configMapKeyRef without a key",
+			envs: []api.EnvVar{{Name: "abc", ValueFrom: &api.EnvVarSource{
+				ConfigMapKeyRef: &api.ConfigMapKeySelector{
+					LocalObjectReference: api.LocalObjectReference{Name: "settings"},
+				},
+			}}},
+			expectedError: "[0].valueFrom.configMapKeyRef.key: Required value",
+		},
+		{
+			name: "secretKeyRef without a name",
+			envs: []api.EnvVar{{Name: "abc", ValueFrom: &api.EnvVarSource{
+				SecretKeyRef: &api.SecretKeySelector{Key: "password"},
+			}}},
+			expectedError: "[0].valueFrom.secretKeyRef.name: Required value",
+		},
+		{
+			name: "unsupported field path",
+			envs: []api.EnvVar{{Name: "abc", ValueFrom: &api.EnvVarSource{
+				FieldRef: &api.ObjectFieldSelector{FieldPath: "status.hostIP"},
+			}}},
+			expectedError: `[0].valueFrom.fieldRef.fieldPath: Unsupported value: "status.hostIP"`,
+		},
 	}
 	for _, tc := range errorCases {
 		if errs := validateEnv(tc.envs, field.NewPath("field")); len(errs) == 0 {
@@ -147,6 +209,7 @@ func TestValidateResourceParses(t *testing.T) {
 		{VolumeSize: "200Gi", Memory: "", CPU: "500m"},
 		{CPU: "1.5", GPU: "1", Memory: "512Mi"},
 		{CPU: "2.0", GPU: "8 nvidia-tesla-k80", Memory: "4Gi"},
+		{CPU: "0.5", Memory: "3G"},
 	}
 	for _, spec := range successCases {
 		if errs := validateResourceSpec(&spec, field.NewPath("field")); len(errs) != 0 {
@@ -205,11 +268,82 @@ func TestValidateSpotPolicy(t *testing.T) {
 	}
 }
 
+func TestValidateLogConfig(t *testing.T) {
+	successCases := []api.PodLogConfig{
+		{},
+		{MaxSize: 10485760},
+		{MaxSize: 10485760, MaxFiles: 5},
+	}
+	for _, logConfig := range successCases {
+		if errs := validateLogConfig(logConfig, field.NewPath("field")); len(errs) != 0 {
+			t.Errorf("expected success: %v", errs)
+		}
+	}
+
+	errorCases := []api.PodLogConfig{
+		{MaxSize: -1},
+		{MaxFiles: -2},
+	}
+	for k, logConfig := range errorCases {
+		if errs := validateLogConfig(logConfig, field.NewPath("field")); len(errs) == 0 {
+			t.Errorf("expected failure for %d", k)
+		}
+	}
+}
+
+func TestValidatePlacementGroup(t *testing.T) {
+	successCases := []api.PodSpec{
+		{},
+		{Placement: api.PlacementSpec{PlacementGroup: "hpc"}},
+		{Placement: api.PlacementSpec{
+			PlacementGroup:         "hpc",
+			PlacementGroupStrategy: api.PlacementStrategyCluster,
+		}},
+		{
+			InstanceType: "t3.micro",
+			Placement: api.PlacementSpec{
+				PlacementGroup:         "ha",
+				PlacementGroupStrategy: api.PlacementStrategySpread,
+			},
+		},
+	}
+	for i, spec := range successCases {
+		if errs := validatePlacementGroup(&spec, field.NewPath("field")); len(errs) != 0 {
+			t.Errorf("case %d, expected success: %v", i, errs)
+		}
+	}
+
+	errorCases := []api.PodSpec{
+		// unknown strategy
+		{Placement: api.PlacementSpec{
+			PlacementGroup:         "hpc",
+			PlacementGroupStrategy: "clustered",
+		}},
+		// strategy without a group name
+		{Placement: api.PlacementSpec{
+			PlacementGroupStrategy: api.PlacementStrategyCluster,
+		}},
+		// burstable instances can't join cluster placement groups
+		{
+			InstanceType: "t3.micro",
+			Placement:    api.PlacementSpec{PlacementGroup: "hpc"},
+		},
+	}
+	for i, spec := range errorCases {
+		if errs := validatePlacementGroup(&spec, field.NewPath("field")); len(errs) == 0 {
+			t.Errorf("expected failure for case %d", i)
+		}
+	}
+}
+
 func TestValidateUnits(t *testing.T) {
 	successCase := []api.Unit{
 		{Name: "abc", Image: "image"},
 		{Name: "123", Image: "image"},
 		{Name: "abc-123", Image: "image"},
+		{Name: "digest", Image: "image@sha256:" + strings.Repeat("a", 64)},
+		{Name: "workdir", Image: "image", WorkingDir: "/does/not/have/to/exist"},
+		{Name: "stopsig", Image: "image", StopSignal: "SIGQUIT"},
 	}
 	if errs := validateUnits(successCase, sets.NewString(), field.NewPath("field")); len(errs) != 0 {
 		t.Errorf("expected success: %v", errs)
@@ -224,6 +358,21 @@ func TestValidateUnits(t *testing.T) {
 			{Name: "abc", Image: "image"},
 		},
 		"zero-length image": {{Name: "abc", Image: ""}},
+		"bad digest algorithm": {
+			{Name: "abc", Image: "image@md5:abcdef"},
+		},
+		"bad digest length": {
+			{Name: "abc", Image: "image@sha256:abcdef"},
+		},
+		"digest not lowercase hex": {
+			{Name: "abc", Image: "image@sha256:" + strings.Repeat("Z", 64)},
+		},
+		"relative working dir": {
+			{Name: "abc", Image: "image", WorkingDir: "relative/path"},
+		},
+		"unknown stop signal": {
+			{Name: "abc", Image: "image", StopSignal: "SIGBOGUS"},
+		},
 	}
 
 	for k, v := range errorCases {
@@ -320,12 +469,16 @@ func TestValidateLabels(t *testing.T) {
 func TestValidateVolumes(t *testing.T) {
 	successCase := []api.Volume{
 		{Name: "empty", VolumeSource: api.VolumeSource{EmptyDir: &api.EmptyDir{}}},
+		{Name: "hugetlb", VolumeSource: api.VolumeSource{EmptyDir: &api.EmptyDir{
+			Medium:    api.StorageMediumHugePages,
+			SizeLimit: 1 << 30,
+		}}},
 	}
 	names, errs := validateVolumes(successCase, field.NewPath("field"))
 	if len(errs) != 0 {
 		t.Errorf("expected success: %v", errs)
 	}
-	if len(names) != len(successCase) || !names.HasAll("empty") {
+	if len(names) != len(successCase) || !names.HasAll("empty", "hugetlb") {
 		t.Errorf("wrong names result: %v", names)
 	}
 
@@ -361,6 +514,16 @@ func TestValidateVolumes(t *testing.T) {
 			field.ErrorTypeRequired,
 			"[0].packagePath.path", "",
 		},
+		"hugepages without a size limit": {
+			[]api.Volume{{Name: "hugetlb", VolumeSource: api.VolumeSource{EmptyDir: &api.EmptyDir{Medium: api.StorageMediumHugePages}}}},
+			field.ErrorTypeRequired,
+			"[0].emptyDir.sizeLimit", "sizeLimit must be set when medium is HugePages",
+		},
+		"unknown emptyDir medium": {
+			[]api.Volume{{Name: "scratch", VolumeSource: api.VolumeSource{EmptyDir: &api.EmptyDir{Medium: "Floppy"}}}},
+			field.ErrorTypeNotSupported,
+			"[0].emptyDir.medium", "",
+		},
 	}
 	for k, v := range errorCases {
 		_, errs := validateVolumes(v.V, field.NewPath("field"))
@@ -382,6 +545,167 @@ func TestValidateVolumes(t *testing.T) {
 	}
 }
 
+func TestValidateShmSize(t *testing.T) {
+	testCases := []struct {
+		shmSize string
+		memory  string
+		numErrs int
+	}{
+		{"", "", 0},
+		{"1Gi", "", 0},
+		{"1Gi", "4Gi", 0},
+		{"4Gi", "4Gi", 0},
+		{"8Gi", "4Gi", 1},
+		{"notasize", "", 1},
+	}
+	for i, tc := range testCases {
+		spec := api.PodSpec{ShmSize: tc.shmSize}
+		spec.Resources.Memory = tc.memory
+		errs := validateShmSize(&spec, field.NewPath("field"))
+		if len(errs) != tc.numErrs {
+			t.Errorf("test %d: expected %d errors, got %v", i, tc.numErrs, errs)
+		}
+	}
+}
+
+func TestValidateUlimits(t *testing.T) {
+	successCases := [][]api.Ulimit{
+		{},
+		{{Name: "nofile", Soft: 1024, Hard: 65536}},
+		{{Name: "nofile", Soft: 65536, Hard: 65536}, {Name: "nproc", Soft: 512, Hard: 1024}},
+	}
+	for i, ulimits := range successCases {
+		if errs := validateUlimits(ulimits, field.NewPath("field")); len(errs) != 0 {
+			t.Errorf("case %d: expected success: %v", i, errs)
+		}
+	}
+	errorCases := map[string][]api.Ulimit{
+		"unknown name":        {{Name: "openfiles", Soft: 1024, Hard: 1024}},
+		"duplicate name":      {{Name: "nofile", Soft: 1, Hard: 1}, {Name: "nofile", Soft: 2, Hard: 2}},
+		"hard below soft":     {{Name: "nofile", Soft: 65536, Hard: 1024}},
+		"negative soft limit": {{Name: "nproc", Soft: -1, Hard: 1024}},
+	}
+	for k, v := range errorCases {
+		if errs := validateUlimits(v, field.NewPath("field")); len(errs) == 0 {
+			t.Errorf("expected failure for %s", k)
+		}
+	}
+}
+
+func TestValidateVolumeSourceReferences(t *testing.T) {
+	successCases := []api.VolumeSource{
+		{Secret: &api.SecretVolumeSource{SecretName: "creds"}},
+		{ConfigMap: &api.ConfigMapVolumeSource{
+			LocalObjectReference: api.LocalObjectReference{Name: "settings"},
+			Items:                []api.KeyToPath{{Key: "foo", Path: "sub/foo"}},
+		}},
+		{Projected: &api.ProjectedVolumeSource{
+			Sources: []api.VolumeProjection{
+				{Secret: &api.SecretProjection{
+					LocalObjectReference: api.LocalObjectReference{Name: "creds"},
+				}},
+				{ConfigMap: &api.ConfigMapProjection{
+					LocalObjectReference: api.LocalObjectReference{Name: "settings"},
+				}},
+				{DownwardAPI: &api.DownwardAPIProjection{
+					Items: []api.DownwardAPIVolumeFile{
+						{Path: "podname", FieldRef: &api.ObjectFieldSelector{FieldPath: "metadata.name"}},
+						{Path: "labels", FieldRef: &api.ObjectFieldSelector{FieldPath: "metadata.labels"}},
+					},
+				}},
+			},
+		}},
+	}
+	for i, source := range successCases {
+		if errs := validateVolumeSource(&source, field.NewPath("field")); len(errs) != 0 {
+			t.Errorf("case %d: expected success: %v", i, errs)
+		}
+	}
+
+	errorCases := map[string]api.VolumeSource{
+		"secret without a name": {
+			Secret: &api.SecretVolumeSource{},
+		},
+		"configmap without a name": {
+			ConfigMap: &api.ConfigMapVolumeSource{},
+		},
+		"absolute item path": {
+			ConfigMap: &api.ConfigMapVolumeSource{
+				LocalObjectReference: api.LocalObjectReference{Name: "settings"},
+				Items:                []api.KeyToPath{{Key: "foo", Path: "/abs"}},
+			},
+		},
+		"item path escaping the volume": {
+			Secret: &api.SecretVolumeSource{
+				SecretName: "creds",
+				Items:      []api.KeyToPath{{Key: "foo", Path: "../escape"}},
+			},
+		},
+		"empty projection": {
+			Projected: &api.ProjectedVolumeSource{
+				Sources: []api.VolumeProjection{{}},
+			},
+		},
+		"projection with two sources": {
+			Projected: &api.ProjectedVolumeSource{
+				Sources: []api.VolumeProjection{
+					{
+						Secret: &api.SecretProjection{
+							LocalObjectReference: api.LocalObjectReference{Name: "creds"},
+						},
+						ConfigMap: &api.ConfigMapProjection{
+							LocalObjectReference: api.LocalObjectReference{Name: "settings"},
+						},
+					},
+				},
+			},
+		},
+		"projected secret without a name": {
+			Projected: &api.ProjectedVolumeSource{
+				Sources: []api.VolumeProjection{
+					{Secret: &api.SecretProjection{}},
+				},
+			},
+		},
+		"downwardAPI file without a fieldRef": {
+			Projected: &api.ProjectedVolumeSource{
+				Sources: []api.VolumeProjection{
+					{DownwardAPI: &api.DownwardAPIProjection{
+						Items: []api.DownwardAPIVolumeFile{{Path: "podname"}},
+					}},
+				},
+			},
+		},
+		"downwardAPI file with an unsupported field path": {
+			Projected: &api.ProjectedVolumeSource{
+				Sources: []api.VolumeProjection{
+					{DownwardAPI: &api.DownwardAPIProjection{
+						Items: []api.DownwardAPIVolumeFile{
+							{Path: "ip", FieldRef: &api.ObjectFieldSelector{FieldPath: "status.podIP"}},
+						},
+					}},
+				},
+			},
+		},
+		"downwardAPI file with an absolute path": {
+			Projected: &api.ProjectedVolumeSource{
+				Sources: []api.VolumeProjection{
+					{DownwardAPI: &api.DownwardAPIProjection{
+						Items: []api.DownwardAPIVolumeFile{
+							{Path: "/abs", FieldRef: &api.ObjectFieldSelector{FieldPath: "metadata.name"}},
+						},
+					}},
+				},
+			},
+		},
+	}
+	for k, v := range errorCases {
+		if errs := validateVolumeSource(&v, field.NewPath("field")); len(errs) == 0 {
+			t.Errorf("expected failure for %s", k)
+		}
+	}
+}
+
 func TestValidateVolumeMounts(t *testing.T) {
 	volumes := sets.NewString("abc", "123", "abc-123")
 
@@ -400,6 +724,10 @@ func TestValidateVolumeMounts(t *testing.T) {
 		"empty mountpath":     {{Name: "abc", MountPath: ""}},
 		"colon mountpath":     {{Name: "abc", MountPath: "foo:bar"}},
 		"mountpath collision": {{Name: "foo", MountPath: "/path/a"}, {Name: "bar", MountPath: "/path/a"}},
+		"absolute subpath":    {{Name: "abc", MountPath: "/foo", SubPath: "/bar"}},
+		"subpath escaping the volume": {
+			{Name: "abc", MountPath: "/foo", SubPath: "../escape"},
+		},
 	}
 	for k, v := range errorCases {
 		if errs := validateVolumeMounts(v, volumes, field.NewPath("field")); len(errs) == 0 {
@@ -482,3 +810,254 @@ func TestValidatePodSecurityContext(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateHostNetwork(t *testing.T) {
+	tests := []struct {
+		spec   api.PodSpec
+		errlen int
+	}{
+		{
+			// host networking off, mismatched host ports are fine
+			api.PodSpec{
+				Units: []api.Unit{
+					{
+						Ports: []api.ContainerPort{
+							{ContainerPort: 8080, HostPort: 80},
+						},
+					},
+				},
+			},
+			0,
+		},
+		{
+			api.PodSpec{
+				HostNetwork: true,
+				Units: []api.Unit{
+					{
+						Ports: []api.ContainerPort{
+							{ContainerPort: 8080, HostPort: 8080},
+							{ContainerPort: 9090},
+						},
+					},
+				},
+			},
+			0,
+		},
+		{
+			api.PodSpec{
+				HostNetwork: true,
+				Units: []api.Unit{
+					{
+						Ports: []api.ContainerPort{
+							{ContainerPort: 8080, HostPort: 80},
+						},
+					},
+				},
+			},
+			1,
+		},
+		{
+			api.PodSpec{
+				HostNetwork: true,
+				HostAliases: []api.HostAlias{
+					{IP: "1.2.3.4", Hostnames: []string{"foo"}},
+				},
+			},
+			1,
+		},
+	}
+	for i, test := range tests {
+		errs := validateHostNetwork(&test.spec, field.NewPath("spec"))
+		if len(errs) != test.errlen {
+			t.Errorf("test case %d: expected %d errors, got %d: %v",
+				i, test.errlen, len(errs), errs)
+		}
+	}
+}
+
+func TestValidatePortNames(t *testing.T) {
+	tests := []struct {
+		spec   api.PodSpec
+		errlen int
+	}{
+		{
+			// unique names across units are fine
+			api.PodSpec{
+				Units: []api.Unit{
+					{Ports: []api.ContainerPort{{Name: "http", ContainerPort: 80}}},
+					{Ports: []api.ContainerPort{{Name: "metrics", ContainerPort: 9090}}},
+				},
+			},
+			0,
+		},
+		{
+			// multiple unnamed ports are fine
+			api.PodSpec{
+				Units: []api.Unit{
+					{Ports: []api.ContainerPort{{ContainerPort: 80}, {ContainerPort: 81}}},
+					{Ports: []api.ContainerPort{{ContainerPort: 82}}},
+				},
+			},
+			0,
+		},
+		{
+			// two units both naming a port "http" collide
+			api.PodSpec{
+				Units: []api.Unit{
+					{Ports: []api.ContainerPort{{Name: "http", ContainerPort: 80}}},
+					{Ports: []api.ContainerPort{{Name: "http", ContainerPort: 8080}}},
+				},
+			},
+			1,
+		},
+		{
+			// duplicates within a single unit collide too
+			api.PodSpec{
+				Units: []api.Unit{
+					{Ports: []api.ContainerPort{
+						{Name: "http", ContainerPort: 80},
+						{Name: "http", ContainerPort: 8080},
+					}},
+				},
+			},
+			1,
+		},
+		{
+			// init units share the pod-wide namespace
+			api.PodSpec{
+				InitUnits: []api.Unit{
+					{Ports: []api.ContainerPort{{Name: "http", ContainerPort: 80}}},
+				},
+				Units: []api.Unit{
+					{Ports: []api.ContainerPort{{Name: "http", ContainerPort: 8080}}},
+				},
+			},
+			1,
+		},
+	}
+	for i, test := range tests {
+		errs := validatePortNames(&test.spec, field.NewPath("spec"))
+		if len(errs) != test.errlen {
+			t.Errorf("test case %d: expected %d errors, got %d: %v",
+				i, test.errlen, len(errs), errs)
+		}
+	}
+}
+
+func TestValidateHTTPGetAction(t *testing.T) {
+	tests := []struct {
+		action api.HTTPGetAction
+		errlen int
+	}{
+		{
+			// HTTPS with custom headers, including Host
+			api.HTTPGetAction{
+				Path:   "/healthz",
+				Port:   intstr.FromInt(443),
+				Scheme: api.URISchemeHTTPS,
+				HTTPHeaders: []api.HTTPHeader{
+					{Name: "Host", Value: "svc.example.com"},
+					{Name: "X-Probe-Token", Value: "sekret"},
+				},
+			},
+			0,
+		},
+		{
+			// an empty scheme defaults to HTTP
+			api.HTTPGetAction{Port: intstr.FromInt(80)},
+			0,
+		},
+		{
+			// unknown scheme
+			api.HTTPGetAction{
+				Port:   intstr.FromInt(80),
+				Scheme: api.URIScheme("GOPHER"),
+			},
+			1,
+		},
+		{
+			// illegal characters in a header name
+			api.HTTPGetAction{
+				Port: intstr.FromInt(80),
+				HTTPHeaders: []api.HTTPHeader{
+					{Name: "X Probe Token", Value: "sekret"},
+				},
+			},
+			1,
+		},
+		{
+			// host field and Host header are ambiguous together
+			api.HTTPGetAction{
+				Port: intstr.FromInt(80),
+				Host: "svc.example.com",
+				HTTPHeaders: []api.HTTPHeader{
+					{Name: "host", Value: "other.example.com"},
+				},
+			},
+			1,
+		},
+	}
+	for i, test := range tests {
+		errs := validateHTTPGetAction(&test.action, field.NewPath("httpGet"))
+		if len(errs) != test.errlen {
+			t.Errorf("test case %d: expected %d errors, got %d: %v",
+				i, test.errlen, len(errs), errs)
+		}
+	}
+}
+
+func TestValidateUnitRestartPolicies(t *testing.T) {
+	always := api.RestartPolicyAlways
+	never := api.RestartPolicyNever
+	bogus := api.RestartPolicy("Sometimes")
+	tests := []struct {
+		spec   api.PodSpec
+		errlen int
+	}{
+		{
+			// restarting main with a run-once helper
+			api.PodSpec{
+				RestartPolicy: api.RestartPolicyAlways,
+				Units: []api.Unit{
+					{Name: "main"},
+					{Name: "helper", RestartPolicy: &never},
+				},
+			},
+			0,
+		},
+		{
+			api.PodSpec{
+				RestartPolicy: api.RestartPolicyNever,
+				Units: []api.Unit{
+					{Name: "main", RestartPolicy: &always},
+				},
+			},
+			1,
+		},
+		{
+			api.PodSpec{
+				RestartPolicy: api.RestartPolicyAlways,
+				Units: []api.Unit{
+					{Name: "main", RestartPolicy: &bogus},
+				},
+			},
+			1,
+		},
+		{
+			api.PodSpec{
+				RestartPolicy: api.RestartPolicyAlways,
+				InitUnits: []api.Unit{
+					{Name: "init", RestartPolicy: &always},
+				},
+			},
+			1,
+		},
+	}
+	for i, test := range tests {
+		errs := validateUnitRestartPolicies(&test.spec, field.NewPath("spec"))
+		if len(errs) != test.errlen {
+			t.Errorf("test case %d: expected %d errors, got %d: %v",
+				i, test.errlen, len(errs), errs)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2020 Elotl Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Hand-maintained companion to clientapi.pb.go holding the KipWatch
+// service.  The service lives in its own ServiceDesc so it can be
+// added without regenerating the protobuf code; the corresponding
+// (commented out) definitions are kept in clientapi.proto so the next
+// regeneration can absorb it and this file can be deleted.
+package clientapi
+
+import (
+	context "context"
+	proto "github.com/golang/protobuf/proto"
+	grpc "google.golang.org/grpc"
+)
+
+type WatchEventsRequest struct {
+	// Replay retained events created after this RFC3339 timestamp
+	// before streaming new ones; empty means only new events.
+	Since                []byte   `protobuf:"bytes,1,opt,name=since,proto3" json:"since,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *WatchEventsRequest) Reset()         { *m = WatchEventsRequest{} }
+func (m *WatchEventsRequest) String() string { return proto.CompactTextString(m) }
+func (*WatchEventsRequest) ProtoMessage()    {}
+
+func (m *WatchEventsRequest) GetSince() []byte {
+	if m != nil {
+		return m.Since
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*WatchEventsRequest)(nil), "WatchEventsRequest")
+}
+
+// KipWatchClient is the client API for the KipWatch service.
+type KipWatchClient interface {
+	// Watch events as they are created.
+	WatchEvents(ctx context.Context, in *WatchEventsRequest, opts ...grpc.CallOption) (KipWatch_WatchEventsClient, error)
+}
+
+type kipWatchClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewKipWatchClient(cc *grpc.ClientConn) KipWatchClient {
+	return &kipWatchClient{cc}
+}
+
+func (c *kipWatchClient) WatchEvents(ctx context.Context, in *WatchEventsRequest, opts ...grpc.CallOption) (KipWatch_WatchEventsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_KipWatch_serviceDesc.Streams[0], "/KipWatch/WatchEvents", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &kipWatchEventsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type KipWatch_WatchEventsClient interface {
+	Recv() (*StreamMsg, error)
+	grpc.ClientStream
+}
+
+type kipWatchEventsClient struct {
+	grpc.ClientStream
+}
+
+func (x *kipWatchEventsClient) Recv() (*StreamMsg, error) {
+	m := new(StreamMsg)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// KipWatchServer is the server API for the KipWatch service.
+type KipWatchServer interface {
+	// Watch events as they are created.
+	WatchEvents(*WatchEventsRequest, KipWatch_WatchEventsServer) error
+}
+
+func RegisterKipWatchServer(s *grpc.Server, srv KipWatchServer) {
+	s.RegisterService(&_KipWatch_serviceDesc, srv)
+}
+
+func _KipWatch_WatchEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchEventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(KipWatchServer).WatchEvents(m, &kipWatchEventsServer{stream})
+}
+
+type KipWatch_WatchEventsServer interface {
+	Send(*StreamMsg) error
+	grpc.ServerStream
+}
+
+type kipWatchEventsServer struct {
+	grpc.ServerStream
+}
+
+func (x *kipWatchEventsServer) Send(m *StreamMsg) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+var _KipWatch_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "KipWatch",
+	HandlerType: (*KipWatchServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchEvents",
+			Handler:       _KipWatch_WatchEvents_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "clientapi.proto",
+}
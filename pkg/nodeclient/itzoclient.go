@@ -18,6 +18,7 @@ package nodeclient
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/json"
@@ -351,6 +352,31 @@ func (c *ItzoClient) GetFile(path string, lines, bytes int) ([]byte, error) {
 	return body, nil
 }
 
+func (c *ItzoClient) StatPath(path string) (*api.StatPathReply, error) {
+	v := neturl.Values{}
+	v.Set("path", path)
+	url := c.baseURL + "rest/v1/stat/?" + v.Encode()
+	resp, err := c.httpClient.Get(url)
+	if err != nil {
+		return nil, util.WrapError(err, "Error statting path on %s", c.instanceIp)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, util.WrapError(err, "Error reading stat reply from %s", c.instanceIp)
+	}
+	if resp.StatusCode/200 != 1 {
+		return nil, fmt.Errorf("Failed to stat path: %s (%d); %s",
+			resp.Status, resp.StatusCode, string(body))
+	}
+	reply := api.StatPathReply{}
+	err = json.Unmarshal(body, &reply)
+	if err != nil {
+		return nil, util.WrapError(err, "Error deserializing stat reply from %s", c.instanceIp)
+	}
+	return &reply, nil
+}
+
 func (c *ItzoClient) UpdateUnits(pp api.PodParameters) error {
 	url := c.baseURL + "rest/v1/updatepod"
 	b, err := json.Marshal(pp)
@@ -368,7 +394,7 @@ func (c *ItzoClient) UpdateUnits(pp api.PodParameters) error {
 	return nil
 }
 
-func (c *ItzoClient) Deploy(pod, name string, data io.Reader) error {
+func (c *ItzoClient) Deploy(ctx context.Context, pod, name string, data io.Reader) error {
 	pr, pw := io.Pipe()
 	writer := multipart.NewWriter(pw)
 	defer writer.Close()
@@ -385,6 +411,7 @@ func (c *ItzoClient) Deploy(pod, name string, data io.Reader) error {
 			ch <- err
 			return
 		}
+		req = req.WithContext(ctx)
 		req.Header.Add("Content-Type", writer.FormDataContentType())
 		resp, err := c.httpClient.Do(req)
 		if err != nil {
@@ -21,6 +21,7 @@ package nodeclient
 //
 
 import (
+	"context"
 	"io"
 
 	"github.com/elotl/kip/pkg/api"
@@ -38,6 +39,9 @@ func NewMockItzoClientFactory() *MockItzoClientFactory {
 		File: func(unit string, lines, bytes int) ([]byte, error) {
 			return []byte("file"), nil
 		},
+		Stat: func(path string) (*api.StatPathReply, error) {
+			return &api.StatPathReply{Exists: true, Kind: "Directory"}, nil
+		},
 		Resize: func() error {
 			return nil
 		},
@@ -47,9 +51,12 @@ func NewMockItzoClientFactory() *MockItzoClientFactory {
 		Update: func(pp api.PodParameters) error {
 			return nil
 		},
-		DeployPackage: func(pod, name string, data io.Reader) error {
+		DeployPackage: func(ctx context.Context, pod, name string, data io.Reader) error {
 			return nil
 		},
+		Run: func(cmd api.RunCmdParams) (string, error) {
+			return "", nil
+		},
 	}
 }
 
@@ -57,10 +64,12 @@ type MockItzoClientFactory struct {
 	Health        func() error
 	Logs          func(unit string, lines, bytes int) ([]byte, error)
 	File          func(unit string, lines, bytes int) ([]byte, error)
+	Stat          func(path string) (*api.StatPathReply, error)
 	Resize        func() error
 	Status        func() (*api.PodStatusReply, error)
 	Update        func(pp api.PodParameters) error
-	DeployPackage func(pod, name string, data io.Reader) error
+	DeployPackage func(ctx context.Context, pod, name string, data io.Reader) error
+	Run           func(cmd api.RunCmdParams) (string, error)
 }
 
 // screw it, make the factory implement the interface as well...
@@ -85,7 +94,11 @@ func (a *MockItzoClientFactory) GetLogs(unit string, lines, bytes int) ([]byte,
 }
 
 func (a *MockItzoClientFactory) GetFile(unit string, lines, bytes int) ([]byte, error) {
-	return a.Logs(unit, lines, bytes)
+	return a.File(unit, lines, bytes)
+}
+
+func (a *MockItzoClientFactory) StatPath(path string) (*api.StatPathReply, error) {
+	return a.Stat(path)
 }
 
 func (a *MockItzoClientFactory) ResizeVolume() error {
@@ -101,9 +114,9 @@ func (a *MockItzoClientFactory) UpdateUnits(pp api.PodParameters) error {
 }
 
 func (a *MockItzoClientFactory) RunCmd(cmdParams api.RunCmdParams) (string, error) {
-	return "", nil
+	return a.Run(cmdParams)
 }
 
-func (a *MockItzoClientFactory) Deploy(pod, name string, data io.Reader) error {
-	return a.DeployPackage(pod, name, data)
+func (a *MockItzoClientFactory) Deploy(ctx context.Context, pod, name string, data io.Reader) error {
+	return a.DeployPackage(ctx, pod, name, data)
 }
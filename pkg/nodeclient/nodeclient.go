@@ -17,6 +17,7 @@ limitations under the License.
 package nodeclient
 
 import (
+	"context"
 	"io"
 
 	"github.com/elotl/kip/pkg/api"
@@ -26,9 +27,10 @@ type NodeClient interface {
 	Healthcheck() error
 	GetLogs(unit string, lines, bytes int) ([]byte, error)
 	GetFile(filepath string, lines, bytes int) ([]byte, error)
+	StatPath(path string) (*api.StatPathReply, error)
 	ResizeVolume() error
 	GetStatus() (*api.PodStatusReply, error)
 	UpdateUnits(api.PodParameters) error
-	Deploy(pod, name string, data io.Reader) error
+	Deploy(ctx context.Context, pod, name string, data io.Reader) error
 	RunCmd(cmd api.RunCmdParams) (string, error)
 }
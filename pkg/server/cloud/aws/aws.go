@@ -99,6 +99,36 @@ func getECSClient(endpointURL string, insecureSkipSSLVerify bool) (*ecs.ECS, err
 	return client, nil
 }
 
+// ResolveEndpoint returns the endpoint URL an AWS service client
+// should use: the service's entry in serviceEndpoints when present,
+// otherwise the catch-all defaultEndpoint. An empty result lets the
+// SDK pick the public endpoint for the region.
+func ResolveEndpoint(service string, serviceEndpoints map[string]string, defaultEndpoint string) string {
+	if endpoint := serviceEndpoints[service]; endpoint != "" {
+		return endpoint
+	}
+	return defaultEndpoint
+}
+
+// CheckEndpointsReachable verifies each configured service endpoint
+// answers HTTP at startup, so a mistyped or misrouted VPC endpoint
+// shows up as a clear configuration error instead of as timeouts
+// when the first instance is launched. Any HTTP response counts, the
+// services reject unsigned requests but a reply proves the endpoint
+// resolves and accepts connections.
+func CheckEndpointsReachable(serviceEndpoints map[string]string, insecureSkipSSLVerify bool) error {
+	httpClient := getAWSConfig("", insecureSkipSSLVerify).HTTPClient
+	for service, endpoint := range serviceEndpoints {
+		resp, err := httpClient.Head(endpoint)
+		if err != nil {
+			return util.WrapError(err, "%s service endpoint %s is not reachable", service, endpoint)
+		}
+		resp.Body.Close()
+		klog.V(2).Infof("%s service endpoint %s is reachable", service, endpoint)
+	}
+	return nil
+}
+
 func AutoDetectRegion() string {
 	session, err := session.NewSession()
 	if err != nil {
@@ -145,6 +175,9 @@ type EC2ClientConfig struct {
 	PrivateIPOnly         bool
 	EndpointURL           string
 	InsecureTLSSkipVerify bool
+	// ServiceEndpoints overrides EndpointURL per service client,
+	// keyed by service name ("ec2", "ecs").
+	ServiceEndpoints map[string]string
 }
 
 // Parsing our server.json configuration should have put all confg
@@ -156,13 +189,17 @@ func NewEC2Client(config EC2ClientConfig) (*AwsEC2, error) {
 	if config.Nametag == "" {
 		return nil, fmt.Errorf("Nametag is a required parameter")
 	}
-	ec2Client, err := getEC2Client(config.EndpointURL, config.InsecureTLSSkipVerify)
+	ec2Client, err := getEC2Client(
+		ResolveEndpoint("ec2", config.ServiceEndpoints, config.EndpointURL),
+		config.InsecureTLSSkipVerify)
 	if err != nil {
 		return nil, util.WrapError(err, "Error creating EC2 client")
 	}
 	var ecsClient *ecs.ECS
 	if config.ECSClusterName != "" {
-		ecsClient, err = getECSClient(config.EndpointURL, config.InsecureTLSSkipVerify)
+		ecsClient, err = getECSClient(
+			ResolveEndpoint("ecs", config.ServiceEndpoints, config.EndpointURL),
+			config.InsecureTLSSkipVerify)
 		if err != nil {
 			return nil, util.WrapError(err, "Error creating ECS client")
 		}
@@ -234,11 +271,17 @@ func (m *AwsEC2) GetAttributes() cloud.CloudAttributes {
 	return cloud.CloudAttributes{
 		DiskProductName: api.StorageGP2,
 		FixedSizeVolume: false,
+		// EC2 limits user-data to 16KB after base64 decoding
+		MaxUserDataSize: 16384,
 		Provider:        cloud.ProviderAWS,
 		Region:          m.region,
 	}
 }
 
+func (m *AwsEC2) GetInstanceTypes() ([]cloud.InstanceTypeSpec, error) {
+	return cloud.InstanceTypesFromSelector()
+}
+
 func filterLabelsForTags(resource string, labels map[string]string) (map[string]string, error) {
 	illegalKeys := []string{"Node", cloud.ControllerTagKey}
 	ignoredPrefixes := []string{"aws:"}
@@ -56,3 +56,18 @@ func TestAWSTagsFromLabels(t *testing.T) {
 }
 
 // eventually test for getting VPC resolver address
+
+func TestResolveEndpoint(t *testing.T) {
+	endpoints := map[string]string{
+		"ec2": "https://vpce-123.ec2.us-east-1.vpce.amazonaws.com",
+	}
+	assert.Equal(t,
+		"https://vpce-123.ec2.us-east-1.vpce.amazonaws.com",
+		ResolveEndpoint("ec2", endpoints, "https://proxy.internal"))
+	// services without an override fall back to the catch-all endpoint
+	assert.Equal(t,
+		"https://proxy.internal",
+		ResolveEndpoint("ecs", endpoints, "https://proxy.internal"))
+	// and to the SDK default when there's no catch-all either
+	assert.Equal(t, "", ResolveEndpoint("ecs", nil, ""))
+}
@@ -38,6 +38,10 @@ const (
 	awsCreationDateFormat = "2006-01-02T15:04:05.000Z"
 	elotlOwnerID          = "689494258501"
 	elotlImageNameFilter  = "elotl-kip-*"
+	// Device name pre-existing volumes get attached under. Nitro
+	// instance types expose the volume as an NVMe device no matter
+	// what name is used here.
+	attachVolumeDeviceName = "/dev/xvdba"
 )
 
 func (e *AwsEC2) StopInstance(instanceID string) error {
@@ -78,6 +82,22 @@ func (e *AwsEC2) getNodeTags(node *api.Node) []*ec2.Tag {
 	return tags
 }
 
+// getNodeBlockDeviceMapping sizes the EBS root volume for a node, or
+// checks that the boot image really boots from an instance-store
+// disk when the node asks for an instance-store root. Instance-store
+// images bring their own ephemeral mapping so no EBS device is added
+// for them.
+func (e *AwsEC2) getNodeBlockDeviceMapping(node *api.Node, image cloud.Image) ([]*ec2.BlockDeviceMapping, error) {
+	if node.Spec.Resources.InstanceStoreRoot {
+		if image.RootDeviceType != ec2.DeviceTypeInstanceStore {
+			return nil, fmt.Errorf("the pod requests an instance-store backed root disk but boot image %s is %q backed; point bootImageSpec at an instance-store image", image.ID, image.RootDeviceType)
+		}
+		return nil, nil
+	}
+	volSizeGiB := cloud.ToSaneVolumeSize(node.Spec.Resources.VolumeSize)
+	return e.getBlockDeviceMapping(image, volSizeGiB), nil
+}
+
 func (e *AwsEC2) getBlockDeviceMapping(image cloud.Image, volSizeGiB int32) []*ec2.BlockDeviceMapping {
 	awsVolSize := aws.Int64(int64(volSizeGiB))
 	devices := []*ec2.BlockDeviceMapping{
@@ -92,9 +112,30 @@ func (e *AwsEC2) getBlockDeviceMapping(image cloud.Image, volSizeGiB int32) []*e
 	return devices
 }
 
-func (e *AwsEC2) getInstanceNetworkSpec(privateIPOnly bool) []*ec2.InstanceNetworkInterfaceSpecification {
+// launchSubnet returns the subnet and availability zone an instance
+// for node launches into: the subnet pinned on the node's placement
+// when set, the client's configured subnet otherwise.
+func (e *AwsEC2) launchSubnet(node *api.Node) (string, string) {
+	subnetID := node.Spec.Placement.SubnetID
+	if subnetID == "" || subnetID == e.subnetID {
+		return e.subnetID, e.availabilityZone
+	}
+	for _, sn := range e.cloudStatus.GetAllSubnets() {
+		if sn.ID == subnetID {
+			return subnetID, sn.AZ
+		}
+	}
+	return subnetID, ""
+}
+
+func (e *AwsEC2) getInstanceNetworkSpec(node *api.Node, subnetID string) []*ec2.InstanceNetworkInterfaceSpecification {
 	associatePublicIPAddress := true
-	if privateIPOnly || !e.usePublicIPs {
+	if node.Spec.Resources.PrivateIPOnly || !e.usePublicIPs {
+		associatePublicIPAddress = false
+	}
+	// AWS won't auto-assign a public address to an instance launched
+	// with more than one network interface.
+	if node.Spec.SecondaryNetworkInterface != nil {
 		associatePublicIPAddress = false
 	}
 	networkSpec := []*ec2.InstanceNetworkInterfaceSpecification{
@@ -106,7 +147,21 @@ func (e *AwsEC2) getInstanceNetworkSpec(privateIPOnly bool) []*ec2.InstanceNetwo
 		},
 	}
 	// Let AWS figure out the subnet/AZ if we didn't specify a subnet
-	networkSpec[0].SubnetId = aws.String(e.subnetID)
+	networkSpec[0].SubnetId = aws.String(subnetID)
+	if sni := node.Spec.SecondaryNetworkInterface; sni != nil {
+		groups := sni.SecurityGroupIDs
+		if len(groups) == 0 {
+			groups = e.bootSecurityGroupIDs
+		}
+		networkSpec = append(networkSpec, &ec2.InstanceNetworkInterfaceSpecification{
+			// DeleteOnTermination makes AWS clean up the extra ENI for
+			// us when the instance is terminated.
+			DeleteOnTermination: aws.Bool(true),
+			DeviceIndex:         aws.Int64(1),
+			Groups:              aws.StringSlice(groups),
+			SubnetId:            aws.String(sni.SubnetID),
+		})
+	}
 	return networkSpec
 }
 
@@ -189,6 +244,103 @@ func (e *AwsEC2) ResizeVolume(node *api.Node, size int64) (error, bool) {
 		"Volume resize request timeout on node %s", node.Name), false
 }
 
+func (e *AwsEC2) getVolume(volumeID string) (*ec2.Volume, error) {
+	out, err := e.client.DescribeVolumes(&ec2.DescribeVolumesInput{
+		VolumeIds: aws.StringSlice([]string{volumeID}),
+	})
+	if err != nil {
+		return nil, util.WrapErrorWithCategory(
+			util.CategoryTransient, err, "describing volume %s", volumeID)
+	}
+	if len(out.Volumes) != 1 || out.Volumes[0] == nil {
+		return nil, util.WrapErrorWithCategory(
+			util.CategoryNotFound,
+			fmt.Errorf("volume %s not found", volumeID), "")
+	}
+	return out.Volumes[0], nil
+}
+
+func (e *AwsEC2) GetVolumeAvailabilityZone(volumeID string) (string, error) {
+	vol, err := e.getVolume(volumeID)
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(vol.AvailabilityZone), nil
+}
+
+func (e *AwsEC2) AttachVolume(node *api.Node, volumeID string) error {
+	vol, err := e.getVolume(volumeID)
+	if err != nil {
+		return err
+	}
+	instanceID := node.Status.InstanceID
+	for _, attachment := range vol.Attachments {
+		if aws.StringValue(attachment.InstanceId) == instanceID {
+			klog.V(2).Infof("volume %s is already attached to instance %s",
+				volumeID, instanceID)
+			return nil
+		}
+	}
+	// Validate the attach target before touching existing attachments,
+	// otherwise an AZ mismatch would leave the volume detached from
+	// its previous instance without being attachable to the new one.
+	volumeAZ := aws.StringValue(vol.AvailabilityZone)
+	nodeAZ := node.Spec.Placement.AvailabilityZone
+	if volumeAZ != nodeAZ {
+		return util.WrapErrorWithCategory(
+			util.CategoryInvalid,
+			fmt.Errorf("volume %s is in availability zone %s but node %s is in %s",
+				volumeID, volumeAZ, node.Name, nodeAZ), "")
+	}
+	detached := false
+	for _, attachment := range vol.Attachments {
+		attachedTo := aws.StringValue(attachment.InstanceId)
+		klog.V(2).Infof("detaching volume %s from previous instance %s",
+			volumeID, attachedTo)
+		_, err = e.client.DetachVolume(&ec2.DetachVolumeInput{
+			InstanceId: attachment.InstanceId,
+			VolumeId:   aws.String(volumeID),
+		})
+		if err != nil {
+			return util.WrapErrorWithCategory(
+				util.CategoryTransient, err,
+				"detaching volume %s from instance %s", volumeID, attachedTo)
+		}
+		detached = true
+	}
+	if detached {
+		err = e.client.WaitUntilVolumeAvailable(&ec2.DescribeVolumesInput{
+			VolumeIds: aws.StringSlice([]string{volumeID}),
+		})
+		if err != nil {
+			return util.WrapErrorWithCategory(
+				util.CategoryTransient, err,
+				"waiting for volume %s to become available", volumeID)
+		}
+	}
+	_, err = e.client.AttachVolume(&ec2.AttachVolumeInput{
+		Device:     aws.String(attachVolumeDeviceName),
+		InstanceId: aws.String(instanceID),
+		VolumeId:   aws.String(volumeID),
+	})
+	if err != nil {
+		return util.WrapErrorWithCategory(
+			util.CategoryTransient, err,
+			"attaching volume %s to instance %s", volumeID, instanceID)
+	}
+	err = e.client.WaitUntilVolumeInUse(&ec2.DescribeVolumesInput{
+		VolumeIds: aws.StringSlice([]string{volumeID}),
+	})
+	if err != nil {
+		return util.WrapErrorWithCategory(
+			util.CategoryTransient, err,
+			"waiting for volume %s to attach to instance %s", volumeID, instanceID)
+	}
+	klog.V(2).Infof("attached volume %s to instance %s as %s",
+		volumeID, instanceID, attachVolumeDeviceName)
+	return nil
+}
+
 func bootImageSpecToDescribeImagesInput(spec cloud.BootImageSpec) *ec2.DescribeImagesInput {
 	input := &ec2.DescribeImagesInput{}
 	if len(spec) < 1 {
@@ -232,53 +384,130 @@ func bootImageSpecToDescribeImagesInput(spec cloud.BootImageSpec) *ec2.DescribeI
 	return input
 }
 
-func (e *AwsEC2) GetImage(spec cloud.BootImageSpec) (cloud.Image, error) {
+func (e *AwsEC2) ListImages(spec cloud.BootImageSpec) ([]cloud.Image, error) {
 	input := bootImageSpecToDescribeImagesInput(spec)
 	resp, err := e.client.DescribeImages(input)
 	if err != nil {
 		klog.Errorf("getting image list for spec %+v: %v", spec, err)
-		return cloud.Image{}, err
-	}
-	if len(resp.Images) < 1 {
-		msg := fmt.Sprintf("no images found for spec %+v", spec)
-		klog.Errorf("%s", msg)
-		return cloud.Image{}, fmt.Errorf("%s", msg)
+		return nil, err
 	}
 	images := make([]cloud.Image, len(resp.Images))
 	for i, img := range resp.Images {
 		var creationTime *time.Time
+		parseError := ""
 		if img.CreationDate != nil {
 			ts, err := time.Parse(awsCreationDateFormat, *img.CreationDate)
 			if err != nil {
-				klog.Warningf(
+				parseError = fmt.Sprintf(
 					"invalid image creation date %s", *img.CreationDate)
+				klog.Warningf("%s", parseError)
 			} else {
 				creationTime = &ts
 			}
 		}
 		images[i] = cloud.Image{
-			Name:         aws.StringValue(img.Name),
-			RootDevice:   aws.StringValue(img.RootDeviceName),
-			ID:           aws.StringValue(img.ImageId),
-			CreationTime: creationTime,
+			Name:           aws.StringValue(img.Name),
+			RootDevice:     aws.StringValue(img.RootDeviceName),
+			RootDeviceType: aws.StringValue(img.RootDeviceType),
+			ID:             aws.StringValue(img.ImageId),
+			CreationTime:   creationTime,
+			ParseError:     parseError,
 		}
 	}
 	cloud.SortImagesByCreationTime(images)
+	return images, nil
+}
+
+func (e *AwsEC2) GetImage(spec cloud.BootImageSpec) (cloud.Image, error) {
+	images, err := e.ListImages(spec)
+	if err != nil {
+		return cloud.Image{}, err
+	}
+	if len(images) < 1 {
+		msg := fmt.Sprintf("no images found for spec %+v", spec)
+		klog.Errorf("%s", msg)
+		return cloud.Image{}, fmt.Errorf("%s", msg)
+	}
 	return images[len(images)-1], nil
 }
 
+// getInstancePlacement returns the placement parameters for
+// RunInstances, nil when the node doesn't request a placement group.
+func (e *AwsEC2) getInstancePlacement(node *api.Node) *ec2.Placement {
+	if node.Spec.Placement.PlacementGroup == "" {
+		return nil
+	}
+	return &ec2.Placement{
+		GroupName: aws.String(node.Spec.Placement.PlacementGroup),
+	}
+}
+
+// ensurePlacementGroup creates the node's placement group if it
+// doesn't exist yet. Creating an existing group fails with a
+// duplicate error which we treat as success so concurrent launches
+// into the same group don't race with each other.
+func (e *AwsEC2) ensurePlacementGroup(node *api.Node) error {
+	groupName := node.Spec.Placement.PlacementGroup
+	if groupName == "" {
+		return nil
+	}
+	strategy := node.Spec.Placement.PlacementGroupStrategy
+	if strategy == "" {
+		strategy = api.PlacementStrategyCluster
+	}
+	_, err := e.client.CreatePlacementGroup(&ec2.CreatePlacementGroupInput{
+		GroupName: aws.String(groupName),
+		Strategy:  aws.String(string(strategy)),
+	})
+	if err != nil && !isDuplicatePlacementGroupError(err) {
+		return util.WrapError(err, "Could not create placement group %s", groupName)
+	}
+	return nil
+}
+
+func isDuplicatePlacementGroupError(err error) bool {
+	if awsErr, ok := err.(awserr.Error); ok {
+		return awsErr.Code() == "InvalidPlacementGroup.Duplicate"
+	}
+	return false
+}
+
+// getInstanceMetadataOptions converts the node's metadata service
+// settings into the EC2 launch parameter. Unset values default to
+// IMDSv2-only access with a hop limit of 1.
+func getInstanceMetadataOptions(node *api.Node) *ec2.InstanceMetadataOptionsRequest {
+	httpTokens := node.Spec.InstanceMetadataOptions.HttpTokens
+	if httpTokens == "" {
+		httpTokens = api.HttpTokensRequired
+	}
+	hopLimit := int64(node.Spec.InstanceMetadataOptions.HttpPutResponseHopLimit)
+	if hopLimit == 0 {
+		hopLimit = 1
+	}
+	return &ec2.InstanceMetadataOptionsRequest{
+		HttpTokens:              aws.String(httpTokens),
+		HttpPutResponseHopLimit: aws.Int64(hopLimit),
+	}
+}
+
 func (e *AwsEC2) StartNode(node *api.Node, image cloud.Image, metadata string) (*cloud.StartNodeResult, error) {
 	klog.V(2).Infof("Starting instance for node: %v", node)
+	if err := e.ensurePlacementGroup(node); err != nil {
+		return nil, err
+	}
 	tags := e.getNodeTags(node)
 	tagSpec := ec2.TagSpecification{
 		ResourceType: aws.String("instance"),
 		Tags:         tags,
 	}
-	volSizeGiB := cloud.ToSaneVolumeSize(node.Spec.Resources.VolumeSize)
-	devices := e.getBlockDeviceMapping(image, volSizeGiB)
-	networkSpec := e.getInstanceNetworkSpec(node.Spec.Resources.PrivateIPOnly)
+	devices, err := e.getNodeBlockDeviceMapping(node, image)
+	if err != nil {
+		return nil, err
+	}
+	subnetID, availabilityZone := e.launchSubnet(node)
+	networkSpec := e.getInstanceNetworkSpec(node, subnetID)
 	klog.V(2).Infof("Starting node with security groups: %v subnet: '%s'",
-		e.bootSecurityGroupIDs, e.subnetID)
+		e.bootSecurityGroupIDs, subnetID)
 	result, err := e.client.RunInstances(&ec2.RunInstancesInput{
 		ImageId:             aws.String(node.Spec.BootImage),
 		InstanceType:        aws.String(node.Spec.InstanceType),
@@ -288,12 +517,14 @@ func (e *AwsEC2) StartNode(node *api.Node, image cloud.Image, metadata string) (
 		NetworkInterfaces:   networkSpec,
 		BlockDeviceMappings: devices,
 		UserData:            aws.String(metadata),
+		Placement:           e.getInstancePlacement(node),
+		MetadataOptions:     getInstanceMetadataOptions(node),
 	})
 	if err != nil {
 		if isSubnetConstrainedError(err) {
 			return nil, &cloud.NoCapacityError{
 				OriginalError: err.Error(),
-				SubnetID:      e.subnetID,
+				SubnetID:      subnetID,
 			}
 		} else if isAZConstrainedError(err) || isInstanceConstrainedError(err) {
 			return nil, &cloud.NoCapacityError{
@@ -309,7 +540,8 @@ func (e *AwsEC2) StartNode(node *api.Node, image cloud.Image, metadata string) (
 	klog.V(2).Infof("Started instance: %s", cloudID)
 	startResult := &cloud.StartNodeResult{
 		InstanceID:       cloudID,
-		AvailabilityZone: e.availabilityZone,
+		AvailabilityZone: availabilityZone,
+		SubnetID:         subnetID,
 	}
 	return startResult, nil
 }
@@ -318,19 +550,24 @@ func (e *AwsEC2) StartNode(node *api.Node, image cloud.Image, metadata string) (
 // some minor differences.  We'll capture errors correctly here and there
 func (e *AwsEC2) StartSpotNode(node *api.Node, image cloud.Image, metadata string) (*cloud.StartNodeResult, error) {
 	klog.V(2).Infof("Starting instance for node: %v", node)
+	if err := e.ensurePlacementGroup(node); err != nil {
+		return nil, err
+	}
 	tags := e.getNodeTags(node)
 	tagSpec := ec2.TagSpecification{
 		ResourceType: aws.String("instance"),
 		Tags:         tags,
 	}
 	var err error
-	//var subnet *cloud.SubnetAttributes
-	klog.V(2).Infof("Starting spot node in: %s", e.subnetID)
-	volSizeGiB := cloud.ToSaneVolumeSize(node.Spec.Resources.VolumeSize)
-	devices := e.getBlockDeviceMapping(image, volSizeGiB)
-	networkSpec := e.getInstanceNetworkSpec(node.Spec.Resources.PrivateIPOnly)
+	subnetID, availabilityZone := e.launchSubnet(node)
+	klog.V(2).Infof("Starting spot node in: %s", subnetID)
+	devices, err := e.getNodeBlockDeviceMapping(node, image)
+	if err != nil {
+		return nil, err
+	}
+	networkSpec := e.getInstanceNetworkSpec(node, subnetID)
 	klog.V(2).Infof("Starting node with security groups: %v subnet: '%s'",
-		e.bootSecurityGroupIDs, e.subnetID)
+		e.bootSecurityGroupIDs, subnetID)
 	result, err := e.client.RunInstances(&ec2.RunInstancesInput{
 		ImageId:             aws.String(node.Spec.BootImage),
 		InstanceType:        aws.String(node.Spec.InstanceType),
@@ -340,6 +577,8 @@ func (e *AwsEC2) StartSpotNode(node *api.Node, image cloud.Image, metadata strin
 		NetworkInterfaces:   networkSpec,
 		BlockDeviceMappings: devices,
 		UserData:            aws.String(metadata),
+		Placement:           e.getInstancePlacement(node),
+		MetadataOptions:     getInstanceMetadataOptions(node),
 		InstanceMarketOptions: &ec2.InstanceMarketOptionsRequest{
 			MarketType: aws.String("spot"),
 			SpotOptions: &ec2.SpotMarketOptions{
@@ -353,12 +592,18 @@ func (e *AwsEC2) StartSpotNode(node *api.Node, image cloud.Image, metadata strin
 		if isSubnetConstrainedError(err) {
 			return nil, &cloud.NoCapacityError{
 				OriginalError: err.Error(),
-				SubnetID:      e.subnetID,
+				SubnetID:      subnetID,
 			}
 		} else if isAZConstrainedError(err) || isInstanceConstrainedError(err) {
 			return nil, &cloud.NoCapacityError{
 				OriginalError: err.Error(),
 			}
+		} else if isSpotPriceTooLowError(err) {
+			// No spot capacity at the price we're willing to pay,
+			// treat it like any other capacity problem.
+			return nil, &cloud.NoCapacityError{
+				OriginalError: err.Error(),
+			}
 		} else if isUnsupportedInstanceError(err) {
 			return nil, &cloud.UnsupportedInstanceError{err.Error()}
 		}
@@ -371,7 +616,8 @@ func (e *AwsEC2) StartSpotNode(node *api.Node, image cloud.Image, metadata strin
 	klog.V(2).Infof("Started instance: %s", cloudID)
 	startResult := &cloud.StartNodeResult{
 		InstanceID:       cloudID,
-		AvailabilityZone: e.availabilityZone,
+		AvailabilityZone: availabilityZone,
+		SubnetID:         subnetID,
 	}
 	return startResult, nil
 }
@@ -591,6 +837,13 @@ func isInstanceConstrainedError(err error) bool {
 	return false
 }
 
+func isSpotPriceTooLowError(err error) bool {
+	if awsErr, ok := err.(awserr.Error); ok {
+		return awsErr.Code() == "SpotMaxPriceTooLow"
+	}
+	return false
+}
+
 func isUnsupportedInstanceError(err error) bool {
 	if awsErr, ok := err.(awserr.Error); ok {
 		if strings.Contains(awsErr.Error(), "unsupported instance type") {
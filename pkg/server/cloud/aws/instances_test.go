@@ -5,6 +5,7 @@ import (
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/elotl/kip/pkg/api"
 	"github.com/elotl/kip/pkg/server/cloud"
 	"github.com/stretchr/testify/assert"
 )
@@ -67,3 +68,35 @@ func TestBootImageSpecToDescribeImagesInput(t *testing.T) {
 		assert.Equal(t, tc.Input, *input)
 	}
 }
+
+// func getInstanceMetadataOptions(node *api.Node) *ec2.InstanceMetadataOptionsRequest
+func TestGetInstanceMetadataOptions(t *testing.T) {
+	testCases := []struct {
+		Spec    api.InstanceMetadataOptions
+		Request ec2.InstanceMetadataOptionsRequest
+	}{
+		{
+			Spec: api.InstanceMetadataOptions{},
+			Request: ec2.InstanceMetadataOptionsRequest{
+				HttpTokens:              aws.String(api.HttpTokensRequired),
+				HttpPutResponseHopLimit: aws.Int64(1),
+			},
+		},
+		{
+			Spec: api.InstanceMetadataOptions{
+				HttpTokens:              api.HttpTokensOptional,
+				HttpPutResponseHopLimit: 2,
+			},
+			Request: ec2.InstanceMetadataOptionsRequest{
+				HttpTokens:              aws.String(api.HttpTokensOptional),
+				HttpPutResponseHopLimit: aws.Int64(2),
+			},
+		},
+	}
+	for _, tc := range testCases {
+		node := api.GetFakeNode()
+		node.Spec.InstanceMetadataOptions = tc.Spec
+		request := getInstanceMetadataOptions(node)
+		assert.Equal(t, tc.Request, *request)
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/elotl/kip/pkg/server/cloud"
 	"github.com/elotl/kip/pkg/util"
@@ -219,12 +220,18 @@ func makeMilpaSubnets(awsSubnets []*ec2.Subnet, rts []*ec2.RouteTable) ([]cloud.
 		if isPublic {
 			addressType = cloud.PublicAddress
 		}
+		tags := make(map[string]string, len(subnet.Tags))
+		for _, tag := range subnet.Tags {
+			tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+		}
 		subnetInfo := cloud.SubnetAttributes{
+			Name:               tags[cloud.NameTagKey],
 			ID:                 subnetID,
 			CIDR:               aws.StringValue(subnet.CidrBlock),
 			AZ:                 aws.StringValue(subnet.AvailabilityZone),
 			AddressAffinity:    addressType,
 			AvailableAddresses: int(aws.Int64Value(subnet.AvailableIpAddressCount)),
+			Tags:               tags,
 		}
 		subnets[i] = subnetInfo
 	}
@@ -348,7 +355,7 @@ func (e *AwsEC2) RemoveRoute(destinationCIDR, instanceID string) error {
 		},
 	})
 	if err != nil {
-		return util.WrapError(err, "listing vpc route tables")
+		return util.WrapErrorWithCategory(util.CategoryTransient, err, "listing vpc route tables")
 	}
 	for _, table := range out.RouteTables {
 		if len(table.Associations) == 0 {
@@ -382,6 +389,11 @@ func (e *AwsEC2) RemoveRoute(destinationCIDR, instanceID string) error {
 				DestinationCidrBlock: route.DestinationCidrBlock,
 				RouteTableId:         table.RouteTableId,
 			})
+			if isRouteNotFoundError(err) {
+				// Somebody else (e.g. the garbage collector) removed
+				// the route already, that's fine.
+				continue
+			}
 			if err != nil {
 				return util.WrapError(
 					err,
@@ -397,6 +409,60 @@ func (e *AwsEC2) RemoveRoute(destinationCIDR, instanceID string) error {
 	return nil
 }
 
+func isRouteNotFoundError(err error) bool {
+	if awsErr, ok := err.(awserr.Error); ok {
+		return awsErr.Code() == "InvalidRoute.NotFound"
+	}
+	return false
+}
+
+func findRoute(table *ec2.RouteTable, destinationCIDR string) *ec2.Route {
+	for _, route := range table.Routes {
+		if aws.StringValue(route.DestinationCidrBlock) == destinationCIDR {
+			return route
+		}
+	}
+	return nil
+}
+
+// Default maximum number of routes per route table in EC2. The limit
+// can be raised up to 1000 but we only know about the default.
+const awsRouteTableEntryLimit = 50
+
+func (e *AwsEC2) EnsureRouteTableCapacity(numRoutes int) error {
+	out, err := e.client.DescribeRouteTables(&ec2.DescribeRouteTablesInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("vpc-id"),
+				Values: aws.StringSlice([]string{e.vpcID}),
+			},
+			{
+				Name:   aws.String("association.main"),
+				Values: aws.StringSlice([]string{"false"}),
+			},
+			{
+				Name:   aws.String("association.subnet-id"),
+				Values: aws.StringSlice([]string{e.subnetID}),
+			},
+		},
+	})
+	if err != nil {
+		return util.WrapErrorWithCategory(util.CategoryTransient, err, "listing vpc route tables")
+	}
+	for _, table := range out.RouteTables {
+		if len(table.Associations) == 0 {
+			continue
+		}
+		if len(table.Routes)+numRoutes > awsRouteTableEntryLimit {
+			return &cloud.ErrRouteTableFull{
+				RouteTableID: aws.StringValue(table.RouteTableId),
+				Limit:        awsRouteTableEntryLimit,
+			}
+		}
+	}
+	return nil
+}
+
 func (e *AwsEC2) AddRoute(destinationCIDR, instanceID string) error {
 	if destinationCIDR == "" || instanceID == "" {
 		return fmt.Errorf(
@@ -420,19 +486,43 @@ func (e *AwsEC2) AddRoute(destinationCIDR, instanceID string) error {
 		},
 	})
 	if err != nil {
-		return util.WrapError(err, "listing vpc route tables")
+		return util.WrapErrorWithCategory(util.CategoryTransient, err, "listing vpc route tables")
 	}
 	for _, table := range out.RouteTables {
 		if len(table.Associations) == 0 {
 			continue
 		}
+		// Adding a route that already exists is an error in EC2, so
+		// check for an existing route first. If the CIDR already
+		// routes to our instance there's nothing to do, if it routes
+		// somewhere else let the caller decide how to resolve the
+		// conflict.
+		existingRoute := findRoute(table, destinationCIDR)
+		if existingRoute != nil {
+			routeIID := aws.StringValue(existingRoute.InstanceId)
+			if routeIID == instanceID {
+				klog.V(5).Infof("route %s via %s already exists in table %s",
+					destinationCIDR, instanceID,
+					aws.StringValue(table.RouteTableId))
+				continue
+			}
+			target := routeIID
+			if target == "" {
+				target = aws.StringValue(existingRoute.NetworkInterfaceId)
+			}
+			return &cloud.RouteConflictError{
+				CIDR:           destinationCIDR,
+				ExistingTarget: target,
+			}
+		}
 		_, err = e.client.CreateRoute(&ec2.CreateRouteInput{
 			DestinationCidrBlock: aws.String(destinationCIDR),
 			InstanceId:           aws.String(instanceID),
 			RouteTableId:         table.RouteTableId,
 		})
 		if err != nil {
-			return util.WrapError(
+			return util.WrapErrorWithCategory(
+				util.CategoryTransient,
 				err,
 				"creating route %s via %s in table %s",
 				destinationCIDR, instanceID, aws.StringValue(table.RouteTableId))
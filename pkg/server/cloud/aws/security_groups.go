@@ -316,12 +316,51 @@ func makeIPPermissions(rules []cloud.IngressRule) []*ec2.IpPermission {
 	return ipPermissions
 }
 
+func (e *AwsEC2) getInstanceSecurityGroups(instanceID string) ([]string, error) {
+	output, err := e.client.DescribeInstanceAttribute(
+		&ec2.DescribeInstanceAttributeInput{
+			Attribute:  aws.String(ec2.InstanceAttributeNameGroupSet),
+			InstanceId: aws.String(instanceID),
+		})
+	if err != nil {
+		return nil, err
+	}
+	groups := make([]string, 0, len(output.Groups))
+	for _, group := range output.Groups {
+		groups = append(groups, aws.StringValue(group.GroupId))
+	}
+	return groups, nil
+}
+
+// AttachSecurityGroups reconciles the security groups on the node's
+// instance with the desired set. We diff against the groups currently
+// attached so unchanged instances are left alone, and apply the full
+// desired set in a single ModifyInstanceAttribute call so the instance
+// is never caught between two calls with fewer groups (and thus less
+// connectivity) than it should have.
 func (e *AwsEC2) AttachSecurityGroups(node *api.Node, groups []string) error {
 	allGroups := append(groups, e.bootSecurityGroupIDs...)
 	for i := range allGroups {
 		allGroups[i] = strings.TrimSpace(allGroups[i])
 	}
-	_, err := e.client.ModifyInstanceAttribute(
+	if len(allGroups) == 0 {
+		return fmt.Errorf("refusing to remove all security groups from instance %s", node.Status.InstanceID)
+	}
+	currentGroups, err := e.getInstanceSecurityGroups(node.Status.InstanceID)
+	if err != nil {
+		klog.Warningf("Could not list current security groups of instance %s, applying the full desired set: %v",
+			node.Status.InstanceID, err)
+	} else {
+		add, remove := cloud.SecurityGroupsDiff(currentGroups, allGroups)
+		if len(add) == 0 && len(remove) == 0 {
+			klog.V(4).Infof("Security groups of instance %s are up to date",
+				node.Status.InstanceID)
+			return nil
+		}
+		klog.V(2).Infof("Updating security groups of instance %s: attaching %v, detaching %v",
+			node.Status.InstanceID, add, remove)
+	}
+	_, err = e.client.ModifyInstanceAttribute(
 		&ec2.ModifyInstanceAttributeInput{
 			InstanceId: aws.String(node.Status.InstanceID),
 			Groups:     aws.StringSlice(allGroups),
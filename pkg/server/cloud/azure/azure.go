@@ -172,11 +172,17 @@ func (az *AzureClient) GetAttributes() cloud.CloudAttributes {
 	return cloud.CloudAttributes{
 		DiskProductName: api.StorageStandardSSD,
 		FixedSizeVolume: true,
+		// Azure customData is limited to 64KB of base64, 48KB decoded
+		MaxUserDataSize: 49152,
 		Provider:        cloud.ProviderAzure,
 		Region:          az.region,
 	}
 }
 
+func (az *AzureClient) GetInstanceTypes() ([]cloud.InstanceTypeSpec, error) {
+	return cloud.InstanceTypesFromSelector()
+}
+
 func (az *AzureClient) IsAvailable() (bool, error) {
 	// TODO
 	return true, nil
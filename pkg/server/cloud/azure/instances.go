@@ -152,6 +152,12 @@ func (az *AzureClient) createNIC(instanceID string, ipID string) (string, error)
 
 func (az *AzureClient) StartNode(node *api.Node, image cloud.Image, metadata string) (*cloud.StartNodeResult, error) {
 	klog.V(2).Infof("Starting instance for node: %v", node)
+	if node.Spec.SecondaryNetworkInterface != nil {
+		return nil, fmt.Errorf("secondary network interfaces are not implemented for azure")
+	}
+	if node.Spec.Placement.PlacementGroup != "" {
+		return nil, fmt.Errorf("placement groups are not implemented for azure")
+	}
 	instanceID := makeInstanceID(az.controllerID, node.Name)
 	err := az.createResourceGroup(instanceID)
 	if err != nil {
@@ -259,6 +265,7 @@ func (az *AzureClient) StartNode(node *api.Node, image cloud.Image, metadata str
 	startResult := &cloud.StartNodeResult{
 		InstanceID:       instanceID,
 		AvailabilityZone: zone,
+		SubnetID:         az.subnet.ID,
 	}
 	return startResult, nil
 }
@@ -344,14 +351,14 @@ func matchSpec(properties map[string]string, spec cloud.BootImageSpec) bool {
 	return true
 }
 
-func (az *AzureClient) GetImage(spec cloud.BootImageSpec) (cloud.Image, error) {
+func (az *AzureClient) ListImages(spec cloud.BootImageSpec) ([]cloud.Image, error) {
 	ctx := context.Background()
 	timeoutCtx, cancel := context.WithTimeout(ctx, azureDefaultTimeout)
 	defer cancel()
 	rgName := regionalResourceGroupName(az.region)
 	resultPage, err := az.images.ListByResourceGroup(timeoutCtx, rgName)
 	if err != nil {
-		return cloud.Image{}, err
+		return nil, err
 	}
 	images := make(map[string]compute.Image)
 	imageNames := make([]string, 0)
@@ -379,23 +386,34 @@ func (az *AzureClient) GetImage(spec cloud.BootImageSpec) (cloud.Image, error) {
 		defer cancel()
 		err := resultPage.NextWithContext(timeoutCtx)
 		if err != nil {
-			return cloud.Image{}, err
+			return nil, err
 		}
 	}
+	// compute.Image has no creation timestamp, so we rely on naming convention
+	// here: the name has a timestamp in it, so we can get the latest one via
+	// lexicographical sorting.
+	sort.Strings(imageNames)
+	result := make([]cloud.Image, len(imageNames))
+	for i, name := range imageNames {
+		result[i] = cloud.Image{
+			ID:   to.String(images[name].ID),
+			Name: name,
+		}
+	}
+	return result, nil
+}
+
+func (az *AzureClient) GetImage(spec cloud.BootImageSpec) (cloud.Image, error) {
+	images, err := az.ListImages(spec)
+	if err != nil {
+		return cloud.Image{}, err
+	}
 	if len(images) == 0 {
 		msg := fmt.Sprintf("no images found for spec %+v", spec)
 		klog.Errorf("%s", msg)
 		return cloud.Image{}, fmt.Errorf("%s", msg)
 	}
-	// compute.Image has no creation timestamp, so we rely on naming convention
-	// here: the name has a timestamp in it, so we can get the latest one via
-	// lexicographical sorting.
-	sort.Strings(imageNames)
-	latestImage := imageNames[len(imageNames)-1]
-	return cloud.Image{
-		ID:   to.String(images[latestImage].ID),
-		Name: to.String(images[latestImage].Name),
-	}, nil
+	return images[len(images)-1], nil
 }
 
 func (az *AzureClient) ListInstancesFilterID(ids []string) ([]cloud.CloudInstance, error) {
@@ -514,6 +532,14 @@ func (az *AzureClient) ResizeVolume(node *api.Node, size int64) (error, bool) {
 	return nil, true
 }
 
+func (az *AzureClient) GetVolumeAvailabilityZone(volumeID string) (string, error) {
+	return "", fmt.Errorf("GetVolumeAvailabilityZone is not implemented for azure")
+}
+
+func (az *AzureClient) AttachVolume(node *api.Node, volumeID string) error {
+	return fmt.Errorf("AttachVolume is not implemented for azure")
+}
+
 func getStartVMError(err error, subnetID, az string) error {
 	if isSubnetConstrainedError(err) {
 		return &cloud.NoCapacityError{
@@ -363,6 +363,52 @@ func (az *AzureClient) RemoveRoute(destinationCIDR, instanceID string) error {
 	return nil
 }
 
+// Maximum number of routes per route table in Azure.
+const azureRouteTableEntryLimit = 400
+
+func (az *AzureClient) EnsureRouteTableCapacity(numRoutes int) error {
+	ctx := context.Background()
+	timeoutCtx, cancel := context.WithTimeout(ctx, azureDefaultTimeout)
+	defer cancel()
+	vnet, err := az.vnets.Get(
+		timeoutCtx, az.virtualNetwork.ResourceGroup, az.virtualNetwork.Name, "")
+	if err != nil {
+		return util.WrapError(err, "getting virtual network")
+	}
+	if vnet.VirtualNetworkPropertiesFormat == nil ||
+		vnet.VirtualNetworkPropertiesFormat.Subnets == nil {
+		return nil
+	}
+	for _, subnet := range *vnet.VirtualNetworkPropertiesFormat.Subnets {
+		if subnet.SubnetPropertiesFormat == nil ||
+			subnet.SubnetPropertiesFormat.RouteTable == nil {
+			continue
+		}
+		details, err := azure.ParseResourceID(
+			to.String(subnet.SubnetPropertiesFormat.RouteTable.ID))
+		if err != nil {
+			return util.WrapError(err, "parsing route table ID")
+		}
+		timeoutCtx, cancel = context.WithTimeout(ctx, azureDefaultTimeout)
+		defer cancel()
+		rt, err := az.routetables.Get(
+			timeoutCtx, details.ResourceGroup, details.ResourceName, "")
+		if err != nil {
+			return util.WrapError(err, "getting route table")
+		}
+		if rt.Routes == nil {
+			continue
+		}
+		if len(*rt.Routes)+numRoutes > azureRouteTableEntryLimit {
+			return &cloud.ErrRouteTableFull{
+				RouteTableID: to.String(rt.Name),
+				Limit:        azureRouteTableEntryLimit,
+			}
+		}
+	}
+	return nil
+}
+
 func (az *AzureClient) AddRoute(destinationCIDR, instanceID string) error {
 	if destinationCIDR == "" || instanceID == "" {
 		return fmt.Errorf(
@@ -399,6 +445,35 @@ func (az *AzureClient) AddRoute(destinationCIDR, instanceID string) error {
 	if err != nil {
 		return util.WrapError(err, "parsing route table ID")
 	}
+	timeoutCtx, cancel = context.WithTimeout(ctx, azureDefaultTimeout)
+	defer cancel()
+	rt, err := az.routetables.Get(
+		timeoutCtx, details.ResourceGroup, details.ResourceName, "")
+	if err != nil {
+		return util.WrapError(err, "getting route table for adding route")
+	}
+	if rt.Routes != nil {
+		for _, existing := range *rt.Routes {
+			if existing.RoutePropertiesFormat == nil {
+				continue
+			}
+			cidr := to.String(existing.RoutePropertiesFormat.AddressPrefix)
+			if cidr != destinationCIDR {
+				continue
+			}
+			nextHop := to.String(
+				existing.RoutePropertiesFormat.NextHopIPAddress)
+			if nextHop == to.String(ipconfig.PrivateIPAddress) {
+				klog.V(2).Infof("route for %q via %q already exists",
+					destinationCIDR, nextHop)
+				return nil
+			}
+			return &cloud.RouteConflictError{
+				CIDR:           destinationCIDR,
+				ExistingTarget: nextHop,
+			}
+		}
+	}
 	route := network.Route{
 		Name: to.StringPtr(instanceID),
 		RoutePropertiesFormat: &network.RoutePropertiesFormat{
@@ -22,6 +22,7 @@ import (
 	"time"
 
 	"github.com/elotl/kip/pkg/api"
+	"github.com/elotl/kip/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/sets"
 )
 
@@ -48,31 +49,78 @@ type CloudClient interface {
 	StopInstance(instanceID string) error
 	WaitForRunning(node *api.Node) ([]api.NetworkAddress, error)
 	EnsureMilpaSecurityGroups([]string, []string) error
+	// EnsureSecurityGroup creates the named security group opening
+	// ports to sourceRanges, or updates it in place if it already
+	// exists with different rules.
+	EnsureSecurityGroup(sgName string, ports []InstancePort, sourceRanges []string) (*SecurityGroup, error)
 	AttachSecurityGroups(node *api.Node, groups []string) error
 	AddIAMPermissions(node *api.Node, permissions string) error
 	ListInstancesFilterID([]string) ([]CloudInstance, error)
 	ListInstances() ([]CloudInstance, error)
 	ResizeVolume(node *api.Node, size int64) (error, bool)
+	// GetVolumeAvailabilityZone returns the availability zone a
+	// pre-existing cloud volume lives in. Volumes can only be
+	// attached within their own zone, so pods referencing one are
+	// placed there.
+	GetVolumeAvailabilityZone(volumeID string) (string, error)
+	// AttachVolume attaches a pre-existing cloud volume to the node's
+	// instance, detaching it from its previous instance first if
+	// necessary. The volume is left in place when the instance
+	// terminates.
+	AttachVolume(node *api.Node, volumeID string) error
 	GetRegistryAuth() (string, string, error)
 	GetImage(spec BootImageSpec) (Image, error)
+	// ListImages returns every image matching spec, sorted the same
+	// way GetImage ranks them (oldest first, the last entry wins
+	// selection), so operators can see the candidate list behind a
+	// boot image choice.
+	ListImages(spec BootImageSpec) ([]Image, error)
 	SetSustainedCPU(*api.Node, bool) error
 	AddInstanceTags(string, map[string]string) error
 	ConnectWithPublicIPs() bool
 	ModifySourceDestinationCheck(string, bool) error
 	RemoveRoute(string, string) error
 	AddRoute(string, string) error
+	// EnsureRouteTableCapacity checks that the route tables AddRoute
+	// writes to have room for numRoutes more entries, returning an
+	// ErrRouteTableFull if one of them doesn't.
+	EnsureRouteTableCapacity(numRoutes int) error
 	GetVPCCIDRs() []string
 	GetDNSInfo() ([]string, []string, error)
 	CloudStatusKeeper() StatusKeeper
 	GetSubnets() ([]SubnetAttributes, error)
 	GetAvailabilityZones() ([]string, error)
 	GetAttributes() CloudAttributes
+	GetInstanceTypes() ([]InstanceTypeSpec, error)
 	IsAvailable() (bool, error)
 }
 
+// InstanceTypeSpec describes an instance type a cloud provider offers
+// in the controller's region.
+type InstanceTypeSpec struct {
+	Name string
+	// Number of vCPUs. Fractional values cover shared-core types.
+	CPU float32
+	// Memory in the provider's native unit (GiB on AWS and Azure, GB
+	// on GCE).
+	Memory float32
+	// Number of GPUs that come with the instance type and the GPU
+	// types that can be attached to it.
+	GPU               int
+	SupportedGPUTypes map[string]int
+	// Network performance class as reported by the provider, e.g.
+	// "Up to 10 Gigabit". Empty when the provider doesn't report one.
+	NetworkPerformance string
+	// Whether the instance type has local instance-store disks.
+	InstanceStore bool
+}
+
 type CloudAttributes struct {
 	DiskProductName api.StorageType
 	FixedSizeVolume bool
+	// MaxUserDataSize is the largest raw (base64-decoded) user-data
+	// document, in bytes, the provider's launch API will accept.
+	MaxUserDataSize int
 	Provider        string
 	Region          string
 	Zone            string
@@ -81,6 +129,9 @@ type CloudAttributes struct {
 type StartNodeResult struct {
 	InstanceID       string
 	AvailabilityZone string
+	// ID of the subnet the instance was launched into, used to keep
+	// the cached available-address counts current.
+	SubnetID string
 }
 
 type SubnetAddressAffinity string
@@ -110,13 +161,46 @@ type SubnetAttributes struct {
 	// instances and bucket them.
 	AvailableAddresses int
 	//Capacity            int
+
+	// Cloud tags on the subnet, matched by placement subnet
+	// selectors. The subnet name, when present, is stored under the
+	// "Name" key.
+	Tags map[string]string
+}
+
+// SubnetMatchesSelector reports whether the subnet's cloud tags
+// satisfy the label selector. The subnet name counts as the "Name"
+// tag. A nil selector matches nothing, selectors with no requirements
+// match everything.
+func SubnetMatchesSelector(subnet SubnetAttributes, sel *api.LabelSelector) (bool, error) {
+	selector, err := api.LabelSelectorAsSelector(sel)
+	if err != nil {
+		return false, err
+	}
+	set := make(labels.Set, len(subnet.Tags)+1)
+	for k, v := range subnet.Tags {
+		set[k] = v
+	}
+	if subnet.Name != "" {
+		set[NameTagKey] = subnet.Name
+	}
+	return selector.Matches(set), nil
 }
 
 type Image struct {
-	ID           string
-	Name         string
-	RootDevice   string
-	CreationTime *time.Time
+	ID         string
+	Name       string
+	RootDevice string
+	// RootDeviceType is the kind of root disk the image boots from,
+	// e.g. "ebs" or "instance-store" on AWS. Empty when the provider
+	// doesn't report one.
+	RootDeviceType string
+	CreationTime   *time.Time
+	// ParseError records why part of the image's metadata (e.g. its
+	// creation timestamp) couldn't be parsed. Images with no creation
+	// time sort as oldest, so a candidate with a parse error never
+	// wins selection; the field surfaces the reason in image listings.
+	ParseError string
 }
 
 func SortImagesByCreationTime(images []Image) {
@@ -188,3 +272,39 @@ type LoadBalancer struct {
 	DNSName          string
 	IPAddress        string
 }
+
+// PodReadyForTraffic reports whether a pod may be registered as a
+// load balancer target: it must be running and every one of its
+// units must have passed its readiness probe.
+func PodReadyForTraffic(pod *api.Pod) bool {
+	if pod.Status.Phase != api.PodRunning {
+		return false
+	}
+	for _, us := range pod.Status.UnitStatuses {
+		if !us.Ready {
+			return false
+		}
+	}
+	return true
+}
+
+// SetInstanceReadiness gates an instance in or out of the load
+// balancer's target set based on the readiness of the pod it runs,
+// so unready pods don't receive traffic and pods whose readiness
+// recovers are registered again. It returns true when membership
+// changed and the target set needs to be pushed to the cloud
+// provider.
+func (lb *LoadBalancer) SetInstanceReadiness(instanceID string, ready bool) bool {
+	if lb.Instances == nil {
+		lb.Instances = sets.NewString()
+	}
+	if ready == lb.Instances.Has(instanceID) {
+		return false
+	}
+	if ready {
+		lb.Instances.Insert(instanceID)
+	} else {
+		lb.Instances.Delete(instanceID)
+	}
+	return true
+}
@@ -156,7 +156,7 @@ func TestComparePorts(t *testing.T) {
 	}
 }
 
-//func SortImagesByCreationTime(images []Image)
+// func SortImagesByCreationTime(images []Image)
 func TestSortImagesByCreationTime(t *testing.T) {
 	images := []Image{}
 	SortImagesByCreationTime(images)
@@ -187,3 +187,198 @@ func TestSortImagesByCreationTime(t *testing.T) {
 		prev = images[i]
 	}
 }
+
+func TestGetInstanceTypes(t *testing.T) {
+	catalog := []InstanceTypeSpec{
+		{
+			Name:               "fake.large",
+			CPU:                2,
+			Memory:             8.0,
+			NetworkPerformance: "Up to 10 Gigabit",
+		},
+		{
+			Name:              "fake.gpu",
+			CPU:               8,
+			Memory:            61.0,
+			GPU:               1,
+			SupportedGPUTypes: map[string]int{"nvidia-tesla-v100": 1},
+			InstanceStore:     true,
+		},
+	}
+	client := NewMockClient()
+	client.InstanceTypesGetter = func() ([]InstanceTypeSpec, error) {
+		return catalog, nil
+	}
+	got, err := client.GetInstanceTypes()
+	assert.NoError(t, err)
+	assert.Equal(t, catalog, got)
+}
+
+func TestSetInstanceReadiness(t *testing.T) {
+	lb := &LoadBalancer{}
+	// an unready pod's instance is never registered
+	assert.False(t, lb.SetInstanceReadiness("i-123", false))
+	assert.False(t, lb.Instances.Has("i-123"))
+	// readiness registers the instance, once
+	assert.True(t, lb.SetInstanceReadiness("i-123", true))
+	assert.False(t, lb.SetInstanceReadiness("i-123", true))
+	assert.True(t, lb.Instances.Has("i-123"))
+	// a failed readiness probe deregisters it
+	assert.True(t, lb.SetInstanceReadiness("i-123", false))
+	assert.False(t, lb.Instances.Has("i-123"))
+	// recovery registers it again
+	assert.True(t, lb.SetInstanceReadiness("i-123", true))
+	assert.True(t, lb.Instances.Has("i-123"))
+}
+
+func TestPodReadyForTraffic(t *testing.T) {
+	pod := api.GetFakePod()
+	pod.Status.Phase = api.PodRunning
+	pod.Status.UnitStatuses = []api.UnitStatus{{Name: "unit-name", Ready: true}}
+	assert.True(t, PodReadyForTraffic(pod))
+	pod.Status.UnitStatuses[0].Ready = false
+	assert.False(t, PodReadyForTraffic(pod))
+	pod.Status.UnitStatuses[0].Ready = true
+	pod.Status.Phase = api.PodDispatching
+	assert.False(t, PodReadyForTraffic(pod))
+}
+
+func TestSecurityGroupsDiff(t *testing.T) {
+	testCases := []struct {
+		current []string
+		desired []string
+		add     []string
+		remove  []string
+	}{
+		{
+			current: []string{"sg-1", "sg-2"},
+			desired: []string{"sg-1", "sg-2"},
+			add:     []string{},
+			remove:  []string{},
+		},
+		{
+			current: []string{"sg-1"},
+			desired: []string{"sg-1", "sg-2"},
+			add:     []string{"sg-2"},
+			remove:  []string{},
+		},
+		{
+			current: []string{"sg-1", "sg-2"},
+			desired: []string{"sg-1"},
+			add:     []string{},
+			remove:  []string{"sg-2"},
+		},
+		{
+			current: []string{"sg-1", "sg-2"},
+			desired: []string{"sg-1", "sg-3"},
+			add:     []string{"sg-3"},
+			remove:  []string{"sg-2"},
+		},
+		{
+			// Order and duplicates don't create spurious changes.
+			current: []string{"sg-2", "sg-1"},
+			desired: []string{"sg-1", "sg-2", "sg-1"},
+			add:     []string{},
+			remove:  []string{},
+		},
+		{
+			current: []string{},
+			desired: []string{"sg-1"},
+			add:     []string{"sg-1"},
+			remove:  []string{},
+		},
+	}
+	for i, tc := range testCases {
+		add, remove := SecurityGroupsDiff(tc.current, tc.desired)
+		assert.Equal(t, tc.add, add, "test case %d", i)
+		assert.Equal(t, tc.remove, remove, "test case %d", i)
+	}
+}
+
+func TestSubnetMatchesSelector(t *testing.T) {
+	subnets := []SubnetAttributes{
+		{
+			Name: "public-a",
+			ID:   "subnet-1",
+			AZ:   "us-east-1a",
+			Tags: map[string]string{"Name": "public-a", "tier": "public"},
+		},
+		{
+			Name: "private-a",
+			ID:   "subnet-2",
+			AZ:   "us-east-1a",
+			Tags: map[string]string{"Name": "private-a", "tier": "private"},
+		},
+		{
+			ID: "subnet-3",
+			AZ: "us-east-1b",
+		},
+	}
+	testCases := []struct {
+		selector *api.LabelSelector
+		matches  []string
+	}{
+		{
+			// matchLabels on a cloud tag.
+			selector: &api.LabelSelector{
+				MatchLabels: map[string]string{"tier": "private"},
+			},
+			matches: []string{"subnet-2"},
+		},
+		{
+			// matchLabels on the subnet name.
+			selector: &api.LabelSelector{
+				MatchLabels: map[string]string{"Name": "public-a"},
+			},
+			matches: []string{"subnet-1"},
+		},
+		{
+			// matchExpressions with In.
+			selector: &api.LabelSelector{
+				MatchExpressions: []api.LabelSelectorRequirement{
+					{
+						Key:      "tier",
+						Operator: api.LabelSelectorOpIn,
+						Values:   []string{"public", "private"},
+					},
+				},
+			},
+			matches: []string{"subnet-1", "subnet-2"},
+		},
+		{
+			// matchExpressions with DoesNotExist.
+			selector: &api.LabelSelector{
+				MatchExpressions: []api.LabelSelectorRequirement{
+					{
+						Key:      "tier",
+						Operator: api.LabelSelectorOpDoesNotExist,
+					},
+				},
+			},
+			matches: []string{"subnet-3"},
+		},
+		{
+			// No subnet carries the tag.
+			selector: &api.LabelSelector{
+				MatchLabels: map[string]string{"tier": "isolated"},
+			},
+			matches: []string{},
+		},
+		{
+			// A nil selector matches nothing.
+			selector: nil,
+			matches:  []string{},
+		},
+	}
+	for i, tc := range testCases {
+		matched := []string{}
+		for _, sn := range subnets {
+			ok, err := SubnetMatchesSelector(sn, tc.selector)
+			assert.NoError(t, err, "test case %d", i)
+			if ok {
+				matched = append(matched, sn.ID)
+			}
+		}
+		assert.Equal(t, tc.matches, matched, "test case %d", i)
+	}
+}
@@ -19,6 +19,7 @@ package cloud
 import (
 	"github.com/elotl/kip/pkg/api"
 	"github.com/elotl/kip/pkg/util"
+	"github.com/elotl/kip/pkg/util/instanceselector"
 	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/klog"
 )
@@ -39,7 +40,7 @@ type InstancePort struct {
 	PortRangeSize int `json:"portRangeSize,omitempty"`
 }
 
-//Allow ports to be sorted
+// Allow ports to be sorted
 type SortableSliceOfPorts []InstancePort
 
 func (p SortableSliceOfPorts) Len() int           { return len(p) }
@@ -117,6 +118,37 @@ func NewIngressRule(port InstancePort, source string) IngressRule {
 	}
 }
 
+// SecurityGroupsDiff does a set difference between the security
+// groups currently attached to an instance and the desired set,
+// returning the groups that need to be attached and detached.
+// Callers use this to skip no-op updates and to apply only the
+// delta when reconciling groups on a running instance.
+func SecurityGroupsDiff(current, desired []string) ([]string, []string) {
+	currentSet := make(map[string]struct{}, len(current))
+	for _, g := range current {
+		currentSet[g] = struct{}{}
+	}
+	desiredSet := make(map[string]struct{}, len(desired))
+	for _, g := range desired {
+		desiredSet[g] = struct{}{}
+	}
+	add := make([]string, 0)
+	for _, g := range desired {
+		if _, exists := currentSet[g]; !exists {
+			add = append(add, g)
+			currentSet[g] = struct{}{}
+		}
+	}
+	remove := make([]string, 0)
+	for _, g := range current {
+		if _, exists := desiredSet[g]; !exists {
+			remove = append(remove, g)
+			desiredSet[g] = struct{}{}
+		}
+	}
+	return add, remove
+}
+
 func MergeSecurityGroups(cloudSG SecurityGroup, specPorts []InstancePort, specSourceRanges []string) ([]IngressRule, []IngressRule) {
 	// Explode the cross of ports and sources into IngressRules
 	// Do a diff of those and use that for updating rules
@@ -126,6 +158,30 @@ func MergeSecurityGroups(cloudSG SecurityGroup, specPorts []InstancePort, specSo
 	return add, delete
 }
 
+// InstanceTypesFromSelector builds the provider-agnostic instance type
+// catalog from the instance selector's data for the current region.
+// Providers use this for GetInstanceTypes since the selector already
+// holds their per-region type data.
+func InstanceTypesFromSelector() ([]InstanceTypeSpec, error) {
+	data, err := instanceselector.Catalog()
+	if err != nil {
+		return nil, util.WrapError(err, "getting instance type catalog")
+	}
+	specs := make([]InstanceTypeSpec, len(data))
+	for i, d := range data {
+		specs[i] = InstanceTypeSpec{
+			Name:               d.InstanceType,
+			CPU:                d.CPU,
+			Memory:             d.Memory,
+			GPU:                d.GPU,
+			SupportedGPUTypes:  d.SupportedGPUTypes,
+			NetworkPerformance: d.NetworkPerformance,
+			InstanceStore:      instanceselector.SupportsInstanceStore(d.InstanceType),
+		}
+	}
+	return specs, nil
+}
+
 func ToSaneVolumeSize(volSizeSpec string) int32 {
 	size, _ := resource.ParseQuantity(volSizeSpec)
 	volSizeGiB := util.ToGiBRoundUp(&size)
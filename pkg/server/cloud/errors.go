@@ -36,6 +36,29 @@ func (e *NoCapacityError) Error() string {
 	}
 }
 
+type RouteConflictError struct {
+	// CIDR is the destination CIDR of the conflicting route.
+	CIDR string
+	// ExistingTarget is the instance or next hop the CIDR currently
+	// routes to.
+	ExistingTarget string
+}
+
+func (e *RouteConflictError) Error() string {
+	return fmt.Sprintf("route for %s already exists with a different target %s", e.CIDR, e.ExistingTarget)
+}
+
+type ErrRouteTableFull struct {
+	// RouteTableID identifies the route table that is out of space.
+	RouteTableID string
+	// Limit is the maximum number of routes the table can hold.
+	Limit int
+}
+
+func (e *ErrRouteTableFull) Error() string {
+	return fmt.Sprintf("route table %s is full (limit of %d routes)", e.RouteTableID, e.Limit)
+}
+
 type UnsupportedInstanceError struct {
 	OriginalError string
 }
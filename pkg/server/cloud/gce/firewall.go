@@ -282,10 +282,18 @@ func (c *gceClient) AttachSecurityGroups(node *api.Node, groups []string) error
 		return util.WrapError(err, "error retrieving instance's network tags fingerprint from GKE")
 	}
 	fp := ""
+	var currentTags []string
 	if inst.Tags != nil {
 		fp = inst.Tags.Fingerprint
+		currentTags = inst.Tags.Items
 	}
 	allTags := append(groups, c.bootSecurityGroupIDs...)
+	// SetTags replaces the full set atomically; we only diff here to
+	// skip the update when nothing changed.
+	add, remove := cloud.SecurityGroupsDiff(currentTags, allTags)
+	if len(add) == 0 && len(remove) == 0 {
+		return nil
+	}
 	rb := &compute.Tags{
 		Fingerprint: fp,
 		Items:       allTags,
@@ -155,12 +155,18 @@ func (c *gceClient) GetAttributes() cloud.CloudAttributes {
 	return cloud.CloudAttributes{
 		DiskProductName: api.StandardPersistentDisk,
 		FixedSizeVolume: false,
+		// GCE stores user-data in a metadata value, capped at 256KB
+		MaxUserDataSize: 262144,
 		Provider:        cloud.ProviderGCE,
 		Region:          c.region,
 		Zone:            c.zone,
 	}
 }
 
+func (c *gceClient) GetInstanceTypes() ([]cloud.InstanceTypeSpec, error) {
+	return cloud.InstanceTypesFromSelector()
+}
+
 func (c *gceClient) CloudStatusKeeper() cloud.StatusKeeper {
 	return c.cloudStatus
 }
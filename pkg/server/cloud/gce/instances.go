@@ -262,6 +262,12 @@ EOF
 // it is called in the exported StartNode and StartSpotNode functions
 func (c *gceClient) startNode(node *api.Node, image cloud.Image, metadata string) (*cloud.StartNodeResult, error) {
 	klog.V(2).Infof("Starting instance for node: %v", node)
+	if node.Spec.SecondaryNetworkInterface != nil {
+		return nil, fmt.Errorf("secondary network interfaces are not implemented for gce")
+	}
+	if node.Spec.Placement.PlacementGroup != "" {
+		return nil, fmt.Errorf("placement groups are not implemented for gce")
+	}
 	spec, err := c.createInstanceSpec(node, image, metadata)
 	if err != nil {
 		return nil, err
@@ -283,6 +289,7 @@ func (c *gceClient) startNode(node *api.Node, image cloud.Image, metadata string
 	startResult := &cloud.StartNodeResult{
 		InstanceID:       spec.Name,
 		AvailabilityZone: c.zone,
+		SubnetID:         c.subnetName,
 	}
 	return startResult, nil
 }
@@ -425,6 +432,14 @@ func (c *gceClient) ResizeVolume(node *api.Node, size int64) (error, bool) {
 	return nil, true
 }
 
+func (c *gceClient) GetVolumeAvailabilityZone(volumeID string) (string, error) {
+	return "", fmt.Errorf("GetVolumeAvailabilityZone is not implemented for gce")
+}
+
+func (c *gceClient) AttachVolume(node *api.Node, volumeID string) error {
+	return fmt.Errorf("AttachVolume is not implemented for gce")
+}
+
 func (c *gceClient) SetSustainedCPU(node *api.Node, enabled bool) error {
 	// Not supported in GCE return nil
 	return nil
@@ -523,11 +538,13 @@ func (c *gceClient) GetImage(spec cloud.BootImageSpec) (cloud.Image, error) {
 		return cloud.Image{}, nilResponseError("Images.Get")
 	}
 	var creationTime *time.Time
+	parseError := ""
 	if resp.CreationTimestamp != "" {
 		ts, err := time.Parse(time.RFC3339, resp.CreationTimestamp)
 		if err != nil {
-			klog.Warningf(
+			parseError = fmt.Sprintf(
 				"invalid image creation date %s", resp.CreationTimestamp)
+			klog.Warningf("%s", parseError)
 		} else {
 			creationTime = &ts
 		}
@@ -537,9 +554,21 @@ func (c *gceClient) GetImage(spec cloud.BootImageSpec) (cloud.Image, error) {
 		Name:         resp.SelfLink,
 		RootDevice:   "",
 		CreationTime: creationTime,
+		ParseError:   parseError,
 	}, nil
 }
 
+// ListImages returns the candidate list behind a boot image choice.
+// A GCE boot image spec names a single project/image pair, so the
+// list has at most one entry.
+func (c *gceClient) ListImages(spec cloud.BootImageSpec) ([]cloud.Image, error) {
+	image, err := c.GetImage(spec)
+	if err != nil {
+		return nil, err
+	}
+	return []cloud.Image{image}, nil
+}
+
 func (c *gceClient) AddIAMPermissions(node *api.Node, permissions string) error {
 	klog.Errorf("In GCE Instances must be stopped to assign service account")
 	return nil
@@ -215,6 +215,12 @@ func (c *gceClient) AddRoute(destinationCIDR, instanceID string) error {
 	return nil
 }
 
+func (c *gceClient) EnsureRouteTableCapacity(numRoutes int) error {
+	// Routes in GCE are limited by a per-project quota, not a route
+	// table, and AddRoute isn't implemented yet anyway.
+	return nil
+}
+
 func (c *gceClient) RemoveRoute(destinationCIDR, instanceID string) error {
 	// TODO
 	return nil
@@ -40,20 +40,28 @@ type MockCloudClient struct {
 	Waiter              func(node *api.Node) ([]api.NetworkAddress, error)
 	Lister              func() ([]CloudInstance, error)
 	Resizer             func(node *api.Node, size int64) (error, bool)
+	VolumeAZGetter      func(volumeID string) (string, error)
+	VolumeAttacher      func(node *api.Node, volumeID string) error
 	ContainerAuthorizer func() (string, string, error)
 	ImageGetter         func(BootImageSpec) (Image, error)
+	ImageLister         func(BootImageSpec) ([]Image, error)
 
 	InstanceListerFilter func([]string) ([]CloudInstance, error)
 	InstanceLister       func() ([]CloudInstance, error)
+	InstanceTagAdder     func(string, map[string]string) error
 
 	DNSInfoGetter func() ([]string, []string, error)
 
-	RouteRemover func(string, string) error
-	RouteAdder   func(string, string) error
+	RouteRemover              func(string, string) error
+	RouteAdder                func(string, string) error
+	RouteTableCapacityEnsurer func(int) error
 
-	StatusKeeperGetter func() StatusKeeper
-	SubnetGetter       func() ([]SubnetAttributes, error)
-	AZGetter           func() ([]string, error)
+	SecurityGroupEnsurer func(string, []InstancePort, []string) (*SecurityGroup, error)
+
+	StatusKeeperGetter  func() StatusKeeper
+	SubnetGetter        func() ([]SubnetAttributes, error)
+	AZGetter            func() ([]string, error)
+	InstanceTypesGetter func() ([]InstanceTypeSpec, error)
 
 	AvailabilityChecker func() (bool, error)
 
@@ -94,6 +102,14 @@ func (m *MockCloudClient) ResizeVolume(node *api.Node, size int64) (error, bool)
 	return m.Resizer(node, size)
 }
 
+func (m *MockCloudClient) GetVolumeAvailabilityZone(volumeID string) (string, error) {
+	return m.VolumeAZGetter(volumeID)
+}
+
+func (m *MockCloudClient) AttachVolume(node *api.Node, volumeID string) error {
+	return m.VolumeAttacher(node, volumeID)
+}
+
 func (m *MockCloudClient) GetRegistryAuth() (string, string, error) {
 	return m.ContainerAuthorizer()
 }
@@ -102,12 +118,16 @@ func (m *MockCloudClient) GetImage(spec BootImageSpec) (Image, error) {
 	return m.ImageGetter(spec)
 }
 
+func (m *MockCloudClient) ListImages(spec BootImageSpec) ([]Image, error) {
+	return m.ImageLister(spec)
+}
+
 func (m *MockCloudClient) SetSustainedCPU(n *api.Node, enabled bool) error {
 	return nil
 }
 
-func (m *MockCloudClient) AddInstanceTags(string, map[string]string) error {
-	return nil
+func (m *MockCloudClient) AddInstanceTags(iid string, tags map[string]string) error {
+	return m.InstanceTagAdder(iid, tags)
 }
 
 func (c *MockCloudClient) CloudStatusKeeper() StatusKeeper {
@@ -118,6 +138,10 @@ func (c *MockCloudClient) GetSubnets() ([]SubnetAttributes, error) {
 	return c.SubnetGetter()
 }
 
+func (c *MockCloudClient) GetInstanceTypes() ([]InstanceTypeSpec, error) {
+	return c.InstanceTypesGetter()
+}
+
 func (c *MockCloudClient) GetAvailabilityZones() ([]string, error) {
 	return c.AZGetter()
 }
@@ -130,6 +154,10 @@ func (c *MockCloudClient) EnsureMilpaSecurityGroups([]string, []string) error {
 	return nil
 }
 
+func (c *MockCloudClient) EnsureSecurityGroup(sgName string, ports []InstancePort, sourceRanges []string) (*SecurityGroup, error) {
+	return c.SecurityGroupEnsurer(sgName, ports, sourceRanges)
+}
+
 func (c *MockCloudClient) ListInstancesFilterID(iid []string) ([]CloudInstance, error) {
 	return c.InstanceListerFilter(iid)
 }
@@ -162,6 +190,10 @@ func (e *MockCloudClient) AddRoute(destinationCIDR, instanceID string) error {
 	return e.RouteAdder(destinationCIDR, instanceID)
 }
 
+func (e *MockCloudClient) EnsureRouteTableCapacity(numRoutes int) error {
+	return e.RouteTableCapacityEnsurer(numRoutes)
+}
+
 func (e *MockCloudClient) GetVPCCIDRs() []string {
 	return e.VPCCIDRs
 }
@@ -173,12 +205,17 @@ func (e *MockCloudClient) AddInstances(insts ...CloudInstance) {
 }
 
 func (m *MockCloudClient) GetAttributes() CloudAttributes {
+	zone := ""
+	if len(m.Subnets) > 0 {
+		zone = m.Subnets[0].AZ
+	}
 	return CloudAttributes{
 		DiskProductName: api.StorageGP2,
 		FixedSizeVolume: false,
+		MaxUserDataSize: 16384,
 		Provider:        ProviderAWS,
 		Region:          "us-east-1",
-		Zone:            m.Subnets[0].AZ,
+		Zone:            zone,
 	}
 }
 
@@ -269,6 +306,11 @@ func NewMockClient() *MockCloudClient {
 		return insts, nil
 	}
 
+	net.SecurityGroupEnsurer = func(sgName string, ports []InstancePort, sourceRanges []string) (*SecurityGroup, error) {
+		sg := NewSecurityGroup("sg-"+sgName, sgName, ports, sourceRanges)
+		return &sg, nil
+	}
+
 	net.ContainerInstanceRunner = func(p *api.Pod) (string, error) {
 		id := p.Status.BoundInstanceID
 		inst := ContainerInstance{ID: id}
@@ -315,10 +357,50 @@ func NewMockClient() *MockCloudClient {
 		return nil
 	}
 
+	net.RouteTableCapacityEnsurer = func(numRoutes int) error {
+		return nil
+	}
+
+	net.VolumeAZGetter = func(volumeID string) (string, error) {
+		return net.Subnets[0].AZ, nil
+	}
+
+	net.VolumeAttacher = func(node *api.Node, volumeID string) error {
+		return nil
+	}
+
+	net.InstanceTagAdder = func(iid string, tags map[string]string) error {
+		return nil
+	}
+
 	net.AvailabilityChecker = func() (bool, error) {
 		return true, nil
 	}
 
+	net.InstanceTypesGetter = func() ([]InstanceTypeSpec, error) {
+		return []InstanceTypeSpec{
+			{
+				Name:   "t3.nano",
+				CPU:    2,
+				Memory: 0.5,
+			},
+			{
+				Name:               "p2.xlarge",
+				CPU:                4,
+				Memory:             61.0,
+				GPU:                1,
+				SupportedGPUTypes:  map[string]int{"nvidia-tesla-k80": 1},
+				NetworkPerformance: "High",
+			},
+			{
+				Name:          "i3.large",
+				CPU:           2,
+				Memory:        15.25,
+				InstanceStore: true,
+			},
+		}, nil
+	}
+
 	net.ImageGetter = func(BootImageSpec) (Image, error) {
 		t := time.Now()
 		img := Image{
@@ -330,6 +412,14 @@ func NewMockClient() *MockCloudClient {
 		return img, nil
 	}
 
+	net.ImageLister = func(spec BootImageSpec) ([]Image, error) {
+		img, err := net.ImageGetter(spec)
+		if err != nil {
+			return nil, err
+		}
+		return []Image{img}, nil
+	}
+
 	net.DNSInfoGetter = func() ([]string, []string, error) {
 		return []string{"cloud.internal"}, []string{"1.1.1.1"}, nil
 	}
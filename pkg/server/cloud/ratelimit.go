@@ -0,0 +1,93 @@
+/*
+Copyright 2020 Elotl Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/elotl/kip/pkg/api"
+	"golang.org/x/time/rate"
+)
+
+// RateLimitedClient is implemented by clients wrapped with
+// NewRateLimitedClient. QueueDepth is the number of goroutines
+// currently waiting for a token, useful for spotting when the
+// controller is being throttled.
+type RateLimitedClient interface {
+	QueueDepth() int
+}
+
+// rateLimitedClient wraps a CloudClient so that the cloud API calls
+// driving instance launches share a token bucket. When a flood of
+// pods comes in, launches are smoothed out instead of hitting
+// account-wide API throttling; callers simply block until a token is
+// available so pods queue rather than fail.
+type rateLimitedClient struct {
+	CloudClient
+	limiter *rate.Limiter
+	waiting int32
+}
+
+// NewRateLimitedClient wraps client so StartNode, StartSpotNode,
+// AddInstanceTags and the instance listing calls are limited to
+// requestsPerSecond, allowing bursts of burst calls. The limiter is
+// shared across all goroutines using the client.
+func NewRateLimitedClient(client CloudClient, requestsPerSecond float64, burst int) CloudClient {
+	return &rateLimitedClient{
+		CloudClient: client,
+		limiter:     rate.NewLimiter(rate.Limit(requestsPerSecond), burst),
+	}
+}
+
+func (c *rateLimitedClient) wait() {
+	atomic.AddInt32(&c.waiting, 1)
+	defer atomic.AddInt32(&c.waiting, -1)
+	// Wait only fails if the context is canceled or the wait would
+	// exceed the context deadline, neither can happen with the
+	// background context.
+	_ = c.limiter.Wait(context.Background())
+}
+
+func (c *rateLimitedClient) QueueDepth() int {
+	return int(atomic.LoadInt32(&c.waiting))
+}
+
+func (c *rateLimitedClient) StartNode(node *api.Node, image Image, metadata string) (*StartNodeResult, error) {
+	c.wait()
+	return c.CloudClient.StartNode(node, image, metadata)
+}
+
+func (c *rateLimitedClient) StartSpotNode(node *api.Node, image Image, metadata string) (*StartNodeResult, error) {
+	c.wait()
+	return c.CloudClient.StartSpotNode(node, image, metadata)
+}
+
+func (c *rateLimitedClient) AddInstanceTags(instanceID string, tags map[string]string) error {
+	c.wait()
+	return c.CloudClient.AddInstanceTags(instanceID, tags)
+}
+
+func (c *rateLimitedClient) ListInstances() ([]CloudInstance, error) {
+	c.wait()
+	return c.CloudClient.ListInstances()
+}
+
+func (c *rateLimitedClient) ListInstancesFilterID(ids []string) ([]CloudInstance, error) {
+	c.wait()
+	return c.CloudClient.ListInstancesFilterID(ids)
+}
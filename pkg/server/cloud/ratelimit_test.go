@@ -0,0 +1,60 @@
+/*
+Copyright 2020 Elotl Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/elotl/kip/pkg/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitedClientDelegates(t *testing.T) {
+	started := 0
+	mock := NewMockClient()
+	mock.Starter = func(node *api.Node, image Image, metadata string) (*StartNodeResult, error) {
+		started++
+		return &StartNodeResult{InstanceID: "i-123"}, nil
+	}
+	client := NewRateLimitedClient(mock, 1000.0, 1)
+	result, err := client.StartNode(api.GetFakeNode(), Image{}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "i-123", result.InstanceID)
+	assert.Equal(t, 1, started)
+	rl, ok := client.(RateLimitedClient)
+	assert.True(t, ok)
+	assert.Equal(t, 0, rl.QueueDepth())
+}
+
+func TestRateLimitedClientSmoothsCalls(t *testing.T) {
+	mock := NewMockClient()
+	client := NewRateLimitedClient(mock, 100.0, 1)
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = client.ListInstances()
+		}()
+	}
+	wg.Wait()
+	// the first call gets the burst token, the other 3 wait 10ms each
+	assert.True(t, time.Since(start) >= 30*time.Millisecond)
+}
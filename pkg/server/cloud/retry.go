@@ -0,0 +1,183 @@
+/*
+Copyright 2020 Elotl Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"math/rand"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/elotl/kip/pkg/api"
+	"github.com/elotl/kip/pkg/util"
+)
+
+// RetryStats is implemented by clients wrapped with
+// NewRetryingClient. RetryCount is the total number of retried cloud
+// API calls since startup, useful for spotting an account that is
+// constantly being throttled.
+type RetryStats interface {
+	RetryCount() int64
+}
+
+// throttleMessageParts are substrings of the error codes the cloud
+// SDKs return for throttling and transient server-side failures.
+// They are matched against error messages because the SDK error
+// types never make it out of the provider packages.
+var throttleMessageParts = []string{
+	"RequestLimitExceeded",
+	"Throttling",
+	"TooManyRequests",
+	"RateLimitExceeded",
+	"ServiceUnavailable",
+	"InternalError",
+	"InternalServerError",
+	"RequestTimeout",
+}
+
+// retriableError reports whether a failed cloud API call is worth
+// trying again. Errors the providers have classified as transient
+// are retried, throttling and 5xx errors are recognized by message,
+// everything else — including validation errors and the typed
+// capacity errors — fails fast.
+func retriableError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if util.IsInvalidError(err) || util.IsNotFoundError(err) {
+		return false
+	}
+	if util.IsTransientError(err) {
+		return true
+	}
+	switch err.(type) {
+	case *NoCapacityError, *UnsupportedInstanceError, *RouteConflictError, *ErrRouteTableFull:
+		return false
+	}
+	msg := err.Error()
+	for _, part := range throttleMessageParts {
+		if strings.Contains(msg, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// retryingClient wraps a CloudClient so the calls driving the pool
+// loop survive throttling in noisy shared accounts. Failed calls are
+// retried with exponential backoff when retriableError says a retry
+// can help; a throttled StartNode never launched anything, so
+// retrying mutate calls is safe for the errors we retry on.
+type retryingClient struct {
+	CloudClient
+	maxAttempts int
+	baseDelay   time.Duration
+	jitter      float64
+	retries     int64
+}
+
+// NewRetryingClient wraps client so StartNode, StartSpotNode,
+// StopInstance, AddInstanceTags and the instance listing calls are
+// retried up to maxAttempts times total. The delay before the first
+// retry is baseDelay and doubles after every attempt; jitter is the
+// fraction of the delay added at random on top, spreading out
+// retries from concurrent callers. When combined with the rate
+// limiter, wrap the rate limited client so every retry waits for a
+// token too.
+func NewRetryingClient(client CloudClient, maxAttempts int, baseDelay time.Duration, jitter float64) CloudClient {
+	return &retryingClient{
+		CloudClient: client,
+		maxAttempts: maxAttempts,
+		baseDelay:   baseDelay,
+		jitter:      jitter,
+	}
+}
+
+func (c *retryingClient) do(call func() error) error {
+	delay := c.baseDelay
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = call()
+		if err == nil || attempt >= c.maxAttempts || !retriableError(err) {
+			return err
+		}
+		atomic.AddInt64(&c.retries, 1)
+		d := delay
+		if c.jitter > 0 {
+			d += time.Duration(rand.Float64() * c.jitter * float64(delay))
+		}
+		time.Sleep(d)
+		delay *= 2
+	}
+}
+
+func (c *retryingClient) RetryCount() int64 {
+	return atomic.LoadInt64(&c.retries)
+}
+
+// QueueDepth makes the rate limiter's queue depth visible through
+// the retry wrapper when both are configured.
+func (c *retryingClient) QueueDepth() int {
+	if rl, ok := c.CloudClient.(RateLimitedClient); ok {
+		return rl.QueueDepth()
+	}
+	return 0
+}
+
+func (c *retryingClient) StartNode(node *api.Node, image Image, metadata string) (result *StartNodeResult, err error) {
+	err = c.do(func() error {
+		result, err = c.CloudClient.StartNode(node, image, metadata)
+		return err
+	})
+	return result, err
+}
+
+func (c *retryingClient) StartSpotNode(node *api.Node, image Image, metadata string) (result *StartNodeResult, err error) {
+	err = c.do(func() error {
+		result, err = c.CloudClient.StartSpotNode(node, image, metadata)
+		return err
+	})
+	return result, err
+}
+
+func (c *retryingClient) StopInstance(instanceID string) error {
+	return c.do(func() error {
+		return c.CloudClient.StopInstance(instanceID)
+	})
+}
+
+func (c *retryingClient) AddInstanceTags(instanceID string, tags map[string]string) error {
+	return c.do(func() error {
+		return c.CloudClient.AddInstanceTags(instanceID, tags)
+	})
+}
+
+func (c *retryingClient) ListInstances() (instances []CloudInstance, err error) {
+	err = c.do(func() error {
+		instances, err = c.CloudClient.ListInstances()
+		return err
+	})
+	return instances, err
+}
+
+func (c *retryingClient) ListInstancesFilterID(ids []string) (instances []CloudInstance, err error) {
+	err = c.do(func() error {
+		instances, err = c.CloudClient.ListInstancesFilterID(ids)
+		return err
+	})
+	return instances, err
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2020 Elotl Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/elotl/kip/pkg/api"
+	"github.com/elotl/kip/pkg/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetriableError(t *testing.T) {
+	tests := []struct {
+		err       error
+		retriable bool
+	}{
+		{nil, false},
+		{errors.New("RequestLimitExceeded: Request limit exceeded"), true},
+		{errors.New("Throttling: Rate exceeded"), true},
+		{errors.New("InternalError: service failure"), true},
+		{util.WrapErrorWithCategory(util.CategoryTransient, errors.New("boom"), "listing instances"), true},
+		{util.WrapErrorWithCategory(util.CategoryInvalid, errors.New("Throttling"), "bad input"), false},
+		{&NoCapacityError{OriginalError: "InsufficientInstanceCapacity"}, false},
+		{errors.New("InvalidParameterValue: bad ami"), false},
+	}
+	for i, tc := range tests {
+		assert.Equal(t, tc.retriable, retriableError(tc.err), "case %d: %v", i, tc.err)
+	}
+}
+
+func TestRetryingClientRetriesThrottling(t *testing.T) {
+	calls := 0
+	mock := NewMockClient()
+	mock.Starter = func(node *api.Node, image Image, metadata string) (*StartNodeResult, error) {
+		calls++
+		if calls < 3 {
+			return nil, errors.New("RequestLimitExceeded: Request limit exceeded")
+		}
+		return &StartNodeResult{InstanceID: "i-123"}, nil
+	}
+	client := NewRetryingClient(mock, 3, time.Millisecond, 0.0)
+	result, err := client.StartNode(api.GetFakeNode(), Image{}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "i-123", result.InstanceID)
+	assert.Equal(t, 3, calls)
+	rs, ok := client.(RetryStats)
+	assert.True(t, ok)
+	assert.Equal(t, int64(2), rs.RetryCount())
+}
+
+func TestRetryingClientFailsFast(t *testing.T) {
+	calls := 0
+	mock := NewMockClient()
+	mock.Starter = func(node *api.Node, image Image, metadata string) (*StartNodeResult, error) {
+		calls++
+		return nil, &NoCapacityError{OriginalError: "InsufficientInstanceCapacity"}
+	}
+	client := NewRetryingClient(mock, 5, time.Millisecond, 0.0)
+	_, err := client.StartNode(api.GetFakeNode(), Image{}, "")
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestRetryingClientGivesUp(t *testing.T) {
+	calls := 0
+	mock := NewMockClient()
+	mock.InstanceLister = func() ([]CloudInstance, error) {
+		calls++
+		return nil, errors.New("Throttling: Rate exceeded")
+	}
+	client := NewRetryingClient(mock, 3, time.Millisecond, 0.5)
+	_, err := client.ListInstances()
+	assert.Error(t, err)
+	assert.Equal(t, 3, calls)
+}
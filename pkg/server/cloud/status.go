@@ -55,6 +55,12 @@ type StatusKeeper interface {
 	AddUnavailableZone(instanceType string, spot bool, zone string)
 	AddUnavailableSubnet(instanceType string, spot bool, subnetID string)
 	IsUnavailableZone(instanceType string, spot, privateIP bool, az string) bool
+	// ClaimSubnetAddress decrements the cached available-address
+	// count of a subnet after an instance was launched into it, so
+	// scheduling decisions don't have to wait for the next subnet
+	// refresh to see the subnet fill up. The count is reconciled
+	// against the live value when the subnets are refreshed.
+	ClaimSubnetAddress(subnetID string)
 }
 
 type subnetPoller struct {
@@ -63,6 +69,10 @@ type subnetPoller struct {
 	stopchan          chan struct{} // mostly (only?) used from tests
 	subnets           []SubnetAttributes
 	availabilityZones []string
+	// Number of times each subnet has run out of available addresses
+	// since the controller started, keyed by subnet ID. Shows up in
+	// Dump() output.
+	subnetExhaustedCounts map[string]int64
 }
 
 type LinkedAZSubnetStatus struct {
@@ -119,6 +129,39 @@ func (s *subnetPoller) runRefreshLoop() {
 	}
 }
 
+func (s *subnetPoller) ClaimSubnetAddress(subnetID string) {
+	s.Lock()
+	defer s.Unlock()
+	for i := range s.subnets {
+		if s.subnets[i].ID != subnetID {
+			continue
+		}
+		if s.subnets[i].AvailableAddresses > 0 {
+			s.subnets[i].AvailableAddresses--
+			if s.subnets[i].AvailableAddresses == 0 {
+				klog.Warningf("subnet %s is out of available addresses", subnetID)
+				if s.subnetExhaustedCounts == nil {
+					s.subnetExhaustedCounts = make(map[string]int64)
+				}
+				s.subnetExhaustedCounts[subnetID]++
+			}
+		}
+		return
+	}
+}
+
+// SubnetExhaustedCounts returns how many times each subnet has run
+// out of available addresses since the controller started.
+func (s *subnetPoller) SubnetExhaustedCounts() map[string]int64 {
+	s.RLock()
+	defer s.RUnlock()
+	counts := make(map[string]int64, len(s.subnetExhaustedCounts))
+	for id, n := range s.subnetExhaustedCounts {
+		counts[id] = n
+	}
+	return counts
+}
+
 func (s *subnetPoller) start() {
 	s.stopchan = make(chan struct{})
 }
@@ -167,11 +210,13 @@ func (s *LinkedAZSubnetStatus) Dump() []byte {
 	defer s.RUnlock()
 
 	dumpStruct := struct {
-		Subnets     []SubnetAttributes
-		Unavailable []string
+		Subnets          []SubnetAttributes
+		Unavailable      []string
+		SubnetsExhausted map[string]int64
 	}{
-		Subnets:     s.GetAllSubnets(),
-		Unavailable: s.unavailableInstances.Keys(),
+		Subnets:          s.GetAllSubnets(),
+		Unavailable:      s.unavailableInstances.Keys(),
+		SubnetsExhausted: s.subnetExhaustedCounts,
 	}
 	b, err := json.MarshalIndent(dumpStruct, "", "    ")
 	if err != nil {
@@ -352,11 +397,13 @@ func (s *AZSubnetStatus) Dump() []byte {
 		AvailabilityZones  []string
 		UnavailableSubnets []string
 		UnavailableZones   []string
+		SubnetsExhausted   map[string]int64
 	}{
 		Subnets:            s.GetAllSubnets(),
 		AvailabilityZones:  s.availabilityZones,
 		UnavailableSubnets: s.unavailableSubnets.Keys(),
 		UnavailableZones:   s.unavailableZones.Keys(),
+		SubnetsExhausted:   s.subnetExhaustedCounts,
 	}
 	b, err := json.MarshalIndent(dumpStruct, "", "    ")
 	if err != nil {
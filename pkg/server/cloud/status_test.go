@@ -92,6 +92,48 @@ func TestLinkedStatusGetAllAZSubnets(t *testing.T) {
 	assert.Len(t, sns, 0)
 }
 
+func TestClaimSubnetAddress(t *testing.T) {
+	c := NewMockClient()
+	c.Subnets = []SubnetAttributes{
+		{
+			ID:                 "sub-1111",
+			CIDR:               "172.16.0.0/16",
+			AZ:                 "us-east-1a",
+			AddressAffinity:    PublicAddress,
+			AvailableAddresses: 2,
+		},
+		{
+			ID:                 "sub-2222",
+			CIDR:               "172.17.0.0/16",
+			AZ:                 "us-east-1b",
+			AddressAffinity:    PublicAddress,
+			AvailableAddresses: 250,
+		},
+	}
+	s, err := NewLinkedAZSubnetStatus(c)
+	assert.NoError(t, err)
+	itype := "t2.nano"
+	assert.Len(t, s.GetAvailableSubnets(itype, false, false), 2)
+	s.ClaimSubnetAddress("sub-1111")
+	sns := s.GetAllSubnets()
+	assert.Equal(t, 1, sns[0].AvailableAddresses)
+	assert.Empty(t, s.SubnetExhaustedCounts())
+	// Exhausting the subnet takes it out of scheduling decisions and
+	// gets recorded.
+	s.ClaimSubnetAddress("sub-1111")
+	sns = s.GetAvailableSubnets(itype, false, false)
+	assert.Len(t, sns, 1)
+	assert.Equal(t, "sub-2222", sns[0].ID)
+	assert.Equal(t, map[string]int64{"sub-1111": 1}, s.SubnetExhaustedCounts())
+	// Claiming addresses in an exhausted subnet doesn't drive the
+	// count negative.
+	s.ClaimSubnetAddress("sub-1111")
+	sns = s.GetAllSubnets()
+	assert.Equal(t, 0, sns[0].AvailableAddresses)
+	// An unknown subnet ID is ignored.
+	s.ClaimSubnetAddress("sub-9999")
+}
+
 func TestLinkedStatusGetAvailableZones(t *testing.T) {
 	c := GetMockMultiSubnetNetworker()
 	s, err := NewLinkedAZSubnetStatus(c)
@@ -20,9 +20,12 @@ import (
 	"bytes"
 	"fmt"
 	"io/ioutil"
+	"net/url"
 	"os"
 	"regexp"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/elotl/kip/pkg/api"
 	"github.com/elotl/kip/pkg/api/validation"
@@ -32,6 +35,7 @@ import (
 	"github.com/elotl/kip/pkg/server/cloud/gce"
 	"github.com/elotl/kip/pkg/server/nodemanager"
 	"github.com/elotl/kip/pkg/util"
+	"github.com/elotl/kip/pkg/util/cloudinitfile"
 	vutil "github.com/elotl/kip/pkg/util/validation"
 	"github.com/elotl/kip/pkg/util/validation/field"
 	"github.com/elotl/kip/pkg/util/yaml"
@@ -74,6 +78,11 @@ type KubeletConfig struct {
 	Pods     *resource.Quantity `json:"pods"`
 	Capacity v1.ResourceList    `json:"capacity"`
 	Labels   map[string]string  `json:"labels"`
+	// Taints are added to the virtual node so that cluster-level
+	// scheduling can steer pods away from (or onto) kip. Pods that
+	// do not tolerate these taints are also rejected by kip itself
+	// in case they bypass the scheduler with a hardcoded nodeName.
+	Taints []v1.Taint `json:"taints"`
 }
 
 type MultiCloudConfig struct {
@@ -91,6 +100,14 @@ type AWSConfig struct {
 	EcsClusterName        string `json:"ecsClusterName"`
 	EndpointURL           string `json:"endpointURL"`
 	InsecureTLSSkipVerify bool   `json:"insecureTLSSkipVerify"`
+	// ServiceEndpoints overrides the endpoint URL for individual AWS
+	// service clients, keyed by service name ("ec2", "ecs"). Use it
+	// to point the controller at VPC interface endpoints so a
+	// controller with no internet egress can still reach the cloud
+	// APIs. A service without an entry falls back to endpointURL and
+	// then to the SDK's default endpoint for the region. Endpoints
+	// are checked for reachability at startup.
+	ServiceEndpoints map[string]string `json:"serviceEndpoints"`
 }
 
 // See https://github.com/Azure/azure-sdk-for-go/blob/master/README.md
@@ -152,6 +169,228 @@ type CellsConfig struct {
 	PrivateIPOnly         *bool                         `json:"privateIPOnly"`
 	CellConfig            map[string]string             `json:"cellConfig"`
 	DefaultIAMPermissions string                        `json:"defaultIAMPermissions"`
+	// TrustedCAFile points at a PEM CA bundle on the controller that
+	// is mounted into every pod, e.g. for corporate proxies with
+	// internal CAs. By default the bundle is added next to the one the
+	// image ships; see ReplaceImageCABundle. Pods can opt out with the
+	// pod.elotl.co/skip-ca-bundle annotation.
+	TrustedCAFile string `json:"trustedCAFile"`
+	// ReplaceImageCABundle mounts the trusted CA bundle over the
+	// image's /etc/ssl/certs/ca-certificates.crt instead of adding it
+	// alongside, clobbering the bundle the image ships.
+	ReplaceImageCABundle bool `json:"replaceImageCABundle"`
+	// DefaultDNSNdots lowers the ndots resolver option in pod
+	// resolv.conf files. Cluster DNS typically uses ndots:5 which
+	// causes extra lookups for external names; internet-facing
+	// workloads can run with a lower value. Pods that set ndots
+	// explicitly in their dnsConfig options keep their value. Zero
+	// (the default) leaves the cluster value alone.
+	DefaultDNSNdots int `json:"defaultDNSNdots"`
+	// MaxDNSSearchDomains trims the cluster search domains in pod
+	// resolv.conf files to at most this many entries. Pods that
+	// specify searches in their dnsConfig are not trimmed. Zero (the
+	// default) keeps all search domains.
+	MaxDNSSearchDomains int `json:"maxDNSSearchDomains"`
+	// DefaultDNSPolicy is the DNS policy applied to pods that don't
+	// specify one, e.g. "Default" to use the node resolver instead
+	// of cluster DNS. It can be "Default", "ClusterFirst" or
+	// "ClusterFirstWithHostNet". Empty keeps the current behavior of
+	// treating pods without a policy as ClusterFirst.
+	DefaultDNSPolicy string `json:"defaultDNSPolicy"`
+	// DefaultImagePullSecret names a kubernetes docker-registry
+	// secret, as "namespace/name", whose .dockerconfigjson is applied
+	// to every pod in addition to the pod's own imagePullSecrets.
+	// When both cover the same registry the pod-level credentials
+	// win.
+	DefaultImagePullSecret string `json:"defaultImagePullSecret"`
+	// RegistryMirrors maps a container registry host to a mirror (or
+	// pull-through cache) host images should be pulled from instead.
+	// Use "docker.io" to match references without an explicit
+	// registry. References are rewritten only in the spec sent to the
+	// cell; the pod keeps the original reference and unit statuses
+	// report it. Credentials for a mirror come from whichever pull
+	// secret covers the mirror's host, never from the credentials of
+	// the registry being mirrored.
+	RegistryMirrors map[string]string `json:"registryMirrors"`
+	// SupportedRuntimeClasses lists the runtime class names pods may
+	// request via spec.runtimeClassName, e.g. sandboxed runtimes the
+	// boot image ships alongside the default one. Pods asking for a
+	// class not in this list are rejected at validation time. Empty
+	// (the default) rejects any pod that sets a runtime class.
+	SupportedRuntimeClasses []string `json:"supportedRuntimeClasses"`
+	// ForbidPublicSourceRanges rejects pods that list 0.0.0.0/0 in
+	// spec.sourceRanges, for installations that never want a pod's
+	// ports opened to the whole internet. The default allows it,
+	// since a fully-open range still has to be listed explicitly in
+	// the pod spec.
+	ForbidPublicSourceRanges bool `json:"forbidPublicSourceRanges"`
+	// ImagePullConcurrency bounds how many container images a cell
+	// pulls in parallel. Pods with many units can exhaust bandwidth
+	// or hit registry rate limits when every image is pulled at once.
+	// The value is passed to the cell agent through its config file.
+	// Zero (the default) leaves the agent's pull behavior unchanged.
+	ImagePullConcurrency int `json:"imagePullConcurrency"`
+	// UnitLogMaxSize is the largest a unit's active log file may
+	// grow, in bytes, before the cell agent rotates it. It is passed
+	// to the agent through its config file and can be overridden per
+	// pod via the pod spec's logConfig. Zero (the default) leaves the
+	// agent's rotation behavior unchanged.
+	UnitLogMaxSize int `json:"unitLogMaxSize"`
+	// UnitLogMaxFiles is the number of rotated log files the cell
+	// agent keeps per unit in addition to the active one. It is
+	// passed to the agent through its config file and can be
+	// overridden per pod via the pod spec's logConfig. Zero (the
+	// default) leaves the agent's rotation behavior unchanged.
+	UnitLogMaxFiles int `json:"unitLogMaxFiles"`
+	// InstanceTagLabels lists the pod label keys that are propagated
+	// to the pod's cloud instance as tags, e.g. a team or cost-center
+	// label used by cost allocation tooling. Empty (the default)
+	// propagates every pod label that isn't kubernetes-internal.
+	InstanceTagLabels []string `json:"instanceTagLabels"`
+	// ResyncInstanceTags re-applies label-derived tags to running
+	// pods' instances during the periodic full sync, so labels edited
+	// after a pod launches are reflected in the cloud. Off by
+	// default; every sync costs a tagging API call per running pod.
+	ResyncInstanceTags bool `json:"resyncInstanceTags"`
+	// LaunchRateLimit smooths the cloud API calls that launch and tag
+	// instances with a token bucket shared across the controller, so
+	// a thundering herd of pod creations doesn't run into
+	// account-wide API throttling. Pods queue for a token instead of
+	// failing. A zero requestsPerSecond (the default) disables the
+	// limiter.
+	LaunchRateLimit LaunchRateLimitConfig `json:"launchRateLimit"`
+	// InstanceMetadataOptions controls access to the cloud instance
+	// metadata service on cells. HttpTokens defaults to "required"
+	// so new cells only answer IMDSv2 requests; set it to "optional"
+	// to also allow IMDSv1. HttpPutResponseHopLimit defaults to 1.
+	// Only applied on AWS, other providers ignore it.
+	InstanceMetadataOptions api.InstanceMetadataOptions `json:"instanceMetadataOptions"`
+	// EnablePodPreemption allows high priority pods to preempt lower
+	// priority running pods when the cloud doesn't have capacity for
+	// them. It is off by default.
+	EnablePodPreemption bool `json:"enablePodPreemption"`
+	// SchedulingTimeoutSeconds is the default number of seconds a pod
+	// may wait to be scheduled onto a cell before it is failed. Pods
+	// can override it with spec.schedulingTimeoutSeconds. Zero (the
+	// default) means pods wait forever.
+	SchedulingTimeoutSeconds int64 `json:"schedulingTimeoutSeconds"`
+	// InstanceSizeBounds floors and caps the instance types chosen
+	// when Kip sizes an instance from a pod's resources, a cost
+	// guardrail against mis-specified pods landing on huge
+	// instances. Pods with an explicit instanceType are unaffected.
+	InstanceSizeBounds InstanceSizeBoundsConfig `json:"instanceSizeBounds"`
+	// RecycleNodes reuses a cell for the next pod with the same
+	// shape after its pod terminates, instead of terminating the
+	// instance, skipping an instance boot for back-to-back short
+	// pods. The previous pod's units, volumes and deployed files are
+	// wiped before the cell goes back into the pool. Off by default.
+	RecycleNodes bool `json:"recycleNodes"`
+	// RecycleNodeMaxAgeSeconds bounds how old an instance may be and
+	// still be reused for another pod. Zero (the default) allows
+	// reuse for up to an hour.
+	RecycleNodeMaxAgeSeconds int64 `json:"recycleNodeMaxAgeSeconds"`
+	// NamespaceDefaultResources maps a kubernetes namespace to the
+	// ResourceSpec applied to its pods that specify neither resources
+	// nor an instance type, so resource-less pods land on a sensibly
+	// sized instance instead of the global default. Anything the pod
+	// sets itself always wins over the namespace default.
+	NamespaceDefaultResources map[string]api.ResourceSpec `json:"namespaceDefaultResources"`
+	// SubnetSpread spreads new cells across the VPC's eligible
+	// subnets instead of launching everything into the configured
+	// subnet, so one subnet's addresses aren't exhausted while the
+	// rest of the VPC sits idle. Only supported on AWS.
+	SubnetSpread SubnetSpreadConfig `json:"subnetSpread"`
+	// NamespaceDefaultInstanceType maps a kubernetes namespace to the
+	// instance type used for its pods that specify neither resources
+	// nor an instance type, overriding defaultInstanceType for that
+	// namespace. A namespace default ResourceSpec, if one is also
+	// configured, takes precedence since it sizes the pod first.
+	NamespaceDefaultInstanceType map[string]string `json:"namespaceDefaultInstanceType"`
+	// BootTimeoutSeconds is how long a booted instance may take to
+	// become healthy before it is stopped and replaced. The timeout
+	// covers the window after the instance is running; waiting for
+	// the cloud to start the instance is not counted against it.
+	// Zero (the default) keeps the built-in 300 second timeout.
+	BootTimeoutSeconds int64 `json:"bootTimeoutSeconds"`
+	// BootImageRollout replaces the cells of long-running pods when
+	// the boot image they were launched from is no longer the latest
+	// one matching bootImageSpec, so agent and OS fixes reach pods
+	// that never restart on their own. Pods opt in per namespace here
+	// or per pod with the pod.elotl.co/replace-on-image-update
+	// annotation. Off by default.
+	BootImageRollout BootImageRolloutConfig `json:"bootImageRollout"`
+	// CloudAPIRetry retries throttled and transiently failing cloud
+	// API calls with exponential backoff, so RequestLimitExceeded
+	// and 5xx errors in noisy shared accounts don't surface as pod
+	// failures. Validation errors are never retried. A maxAttempts
+	// of zero or one (the default) disables retries.
+	CloudAPIRetry CloudAPIRetryConfig `json:"cloudAPIRetry"`
+	// MaxVolumeDeploySize caps the uncompressed payload of a single
+	// configMap, secret or projected volume deployed to a cell, as a
+	// resource quantity like "200Mi". Oversized volumes fail the pod
+	// with a clear error instead of tying up the controller and the
+	// cell shipping them. Empty (the default) allows any size.
+	MaxVolumeDeploySize string `json:"maxVolumeDeploySize"`
+}
+
+// InstanceSizeBoundsConfig bounds autosized instances by vCPU count
+// and memory. Values are resource quantities like the ones in a
+// pod's resources; empty strings leave a dimension unbounded.
+type InstanceSizeBoundsConfig struct {
+	MinCPU    string `json:"minCPU"`
+	MinMemory string `json:"minMemory"`
+	MaxCPU    string `json:"maxCPU"`
+	MaxMemory string `json:"maxMemory"`
+}
+
+type LaunchRateLimitConfig struct {
+	// RequestsPerSecond is the sustained rate of instance launch and
+	// tagging calls. Zero disables rate limiting.
+	RequestsPerSecond float64 `json:"requestsPerSecond"`
+	// Burst is how many calls may go out at once after a quiet
+	// period. Zero defaults to 1.
+	Burst int `json:"burst"`
+}
+
+type CloudAPIRetryConfig struct {
+	// MaxAttempts is the total number of times a call is tried,
+	// including the first attempt. Zero or one disables retries.
+	MaxAttempts int `json:"maxAttempts"`
+	// BaseDelayMilliseconds is the delay before the first retry; it
+	// doubles after every failed attempt. Zero defaults to 500ms.
+	BaseDelayMilliseconds int64 `json:"baseDelayMilliseconds"`
+	// Jitter is the fraction of the backoff delay added at random on
+	// top of it, spreading out retries from concurrent callers.
+	// Must be between 0 and 1; zero disables jitter.
+	Jitter float64 `json:"jitter"`
+}
+
+type SubnetSpreadConfig struct {
+	Enabled bool `json:"enabled"`
+	// MaxSkew is the largest difference allowed between the cell
+	// counts of the most and least loaded subnets. Within that
+	// tolerance the subnet with the most free addresses wins, so a
+	// nearly-full subnet isn't picked just because it holds one cell
+	// less than the others. Zero defaults to 1.
+	MaxSkew int `json:"maxSkew"`
+}
+
+type BootImageRolloutConfig struct {
+	Enabled bool `json:"enabled"`
+	// MaxInFlight bounds how many cells may be torn down for
+	// replacement at once, so a boot image update doesn't restart
+	// every opted-in pod at the same time. Zero defaults to 1.
+	MaxInFlight int `json:"maxInFlight"`
+	// WindowStart and WindowEnd bound when replacements happen, as
+	// "HH:MM" times of day in UTC. The window may wrap midnight,
+	// e.g. start 22:00 end 04:00. Leaving both empty allows
+	// replacements at any time.
+	WindowStart string `json:"windowStart"`
+	WindowEnd   string `json:"windowEnd"`
+	// Namespaces lists the kubernetes namespaces whose pods are
+	// opted in. A pod's replace-on-image-update annotation, when
+	// present, overrides its namespace either way.
+	Namespaces []string `json:"namespaces"`
 }
 
 type HealthCheckConfig struct {
@@ -171,6 +410,11 @@ type CloudAPIHealthCheck struct {
 type ItzoConfig struct {
 	Version string `json:"version"`
 	URL     string `json:"url"`
+	// SHA256 is the expected checksum of the agent binary, as 64 hex
+	// characters. When set it is injected alongside the URL and
+	// version so the boot process verifies the download before
+	// running it. Recommended when url points at a private mirror.
+	SHA256 string `json:"sha256"`
 }
 
 type TestingConfig struct {
@@ -245,8 +489,15 @@ func setupAwsEnvVars(c *AWSConfig) error {
 			return err
 		}
 	}
+	if len(c.ServiceEndpoints) > 0 {
+		klog.V(2).Infof("Validating configured AWS service endpoints")
+		if err := aws.CheckEndpointsReachable(c.ServiceEndpoints, c.InsecureTLSSkipVerify); err != nil {
+			return util.WrapError(err, "Error validating AWS service endpoints")
+		}
+	}
 	klog.V(2).Infof("Validating connection to AWS")
-	if err := aws.CheckConnection(c.EndpointURL, c.InsecureTLSSkipVerify); err != nil {
+	ec2Endpoint := aws.ResolveEndpoint("ec2", c.ServiceEndpoints, c.EndpointURL)
+	if err := aws.CheckConnection(ec2Endpoint, c.InsecureTLSSkipVerify); err != nil {
 		return util.WrapError(err, "Error validationg connection to AWS")
 	}
 	klog.V(2).Infof("Validated access to AWS")
@@ -324,6 +575,7 @@ func configureCloudProvider(cf *ServerConfigFile, controllerID, nametag string)
 			PrivateIPOnly:         privateIPOnly,
 			EndpointURL:           cc.AWS.EndpointURL,
 			InsecureTLSSkipVerify: cc.AWS.InsecureTLSSkipVerify,
+			ServiceEndpoints:      cc.AWS.ServiceEndpoints,
 		})
 
 		if err != nil {
@@ -459,6 +711,24 @@ func ConfigureCloud(configFile *ServerConfigFile, controllerID, nametag string)
 	if err != nil {
 		return nil, util.WrapError(err, "Error setting up cloud client security groups")
 	}
+	if rl := configFile.Cells.LaunchRateLimit; rl.RequestsPerSecond > 0 {
+		burst := rl.Burst
+		if burst == 0 {
+			burst = 1
+		}
+		klog.V(2).Infof("limiting instance launch calls to %g/s with a burst of %d", rl.RequestsPerSecond, burst)
+		cloudClient = cloud.NewRateLimitedClient(cloudClient, rl.RequestsPerSecond, burst)
+	}
+	// The retry wrapper goes on the outside so every retry also
+	// waits for a rate limit token when both are configured.
+	if cr := configFile.Cells.CloudAPIRetry; cr.MaxAttempts > 1 {
+		baseDelay := time.Duration(cr.BaseDelayMilliseconds) * time.Millisecond
+		if baseDelay == 0 {
+			baseDelay = 500 * time.Millisecond
+		}
+		klog.V(2).Infof("retrying throttled cloud API calls up to %d attempts with a base delay of %v", cr.MaxAttempts, baseDelay)
+		cloudClient = cloud.NewRetryingClient(cloudClient, cr.MaxAttempts, baseDelay, cr.Jitter)
+	}
 	return cloudClient, err
 }
 
@@ -466,6 +736,8 @@ const awsRegionFormat string = "[a-z]{2}-[a-z]+-[0-9]"
 
 var awsRegionRegexp = regexp.MustCompile("^" + awsRegionFormat + "$")
 
+var sha256HexRegexp = regexp.MustCompile("^[0-9a-fA-F]{64}$")
+
 func validateAWSConfig(cf *AWSConfig) field.ErrorList {
 	allErrs := field.ErrorList{}
 
@@ -488,6 +760,18 @@ func validateAWSConfig(cf *AWSConfig) field.ErrorList {
 		allErrs = append(allErrs, field.Required(fldPath.Child("secretAccessKey"), "secretAccessKey must be set or pulled from the environment"))
 	}
 
+	for service, endpoint := range cf.ServiceEndpoints {
+		epPath := fldPath.Child("serviceEndpoints").Key(service)
+		if endpoint == "" {
+			allErrs = append(allErrs, field.Required(epPath, "endpoint URL must not be empty"))
+			continue
+		}
+		u, err := url.Parse(endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			allErrs = append(allErrs, field.Invalid(epPath, endpoint, "must be an http(s) URL"))
+		}
+	}
+
 	return allErrs
 }
 
@@ -538,6 +822,10 @@ func validateServerConfigFile(cf *ServerConfigFile) field.ErrorList {
 		allErrs = append(allErrs, validation.ValidateFileExists(cells.CloudInitFile, fldPath.Child("cloudInitFile"))...)
 	}
 
+	if cells.TrustedCAFile != "" {
+		allErrs = append(allErrs, validation.ValidateFileExists(cells.TrustedCAFile, fldPath.Child("trustedCAFile"))...)
+	}
+
 	allErrs = append(allErrs, validation.ValidateResourceParses(cells.DefaultVolumeSize, fldPath.Child("defaultVolumeSize"))...)
 
 	if cells.DefaultInstanceType == "" {
@@ -548,6 +836,123 @@ func validateServerConfigFile(cf *ServerConfigFile) field.ErrorList {
 		allErrs = append(allErrs, field.Invalid(fldPath.Child("statusInterval"), cells.StatusInterval, "cells.statusInterval must be >= 1"))
 	}
 
+	allErrs = append(allErrs, validation.ValidateNonnegativeField(int64(cells.DefaultDNSNdots), fldPath.Child("defaultDNSNdots"))...)
+	allErrs = append(allErrs, validation.ValidateNonnegativeField(int64(cells.MaxDNSSearchDomains), fldPath.Child("maxDNSSearchDomains"))...)
+	allErrs = append(allErrs, validation.ValidateNonnegativeField(int64(cells.ImagePullConcurrency), fldPath.Child("imagePullConcurrency"))...)
+	allErrs = append(allErrs, validation.ValidateNonnegativeField(int64(cells.UnitLogMaxSize), fldPath.Child("unitLogMaxSize"))...)
+	allErrs = append(allErrs, validation.ValidateNonnegativeField(int64(cells.UnitLogMaxFiles), fldPath.Child("unitLogMaxFiles"))...)
+
+	allErrs = append(allErrs, validation.ValidateNonnegativeField(cells.BootTimeoutSeconds, fldPath.Child("bootTimeoutSeconds"))...)
+	allErrs = append(allErrs, validation.ValidateNonnegativeField(cells.RecycleNodeMaxAgeSeconds, fldPath.Child("recycleNodeMaxAgeSeconds"))...)
+
+	boundsPath := fldPath.Child("instanceSizeBounds")
+	allErrs = append(allErrs, validation.ValidateResourceParses(cells.InstanceSizeBounds.MinCPU, boundsPath.Child("minCPU"))...)
+	allErrs = append(allErrs, validation.ValidateResourceParses(cells.InstanceSizeBounds.MinMemory, boundsPath.Child("minMemory"))...)
+	allErrs = append(allErrs, validation.ValidateResourceParses(cells.InstanceSizeBounds.MaxCPU, boundsPath.Child("maxCPU"))...)
+	allErrs = append(allErrs, validation.ValidateResourceParses(cells.InstanceSizeBounds.MaxMemory, boundsPath.Child("maxMemory"))...)
+
+	for _, key := range cloudinitfile.UnknownCellConfigKeys(cells.CellConfig) {
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("cellConfig").Key(key), cells.CellConfig[key], cloudinitfile.CellConfigKeys))
+	}
+
+	for ns, rs := range cells.NamespaceDefaultResources {
+		nsPath := fldPath.Child("namespaceDefaultResources").Key(ns)
+		allErrs = append(allErrs, validation.ValidateResourceParses(rs.CPU, nsPath.Child("cpu"))...)
+		allErrs = append(allErrs, validation.ValidateResourceParses(rs.Memory, nsPath.Child("memory"))...)
+		allErrs = append(allErrs, validation.ValidateGPUSpec(rs.GPU, nsPath.Child("gpu"))...)
+		allErrs = append(allErrs, validation.ValidateResourceParses(rs.GPUMemory, nsPath.Child("gpuMemory"))...)
+		allErrs = append(allErrs, validation.ValidateResourceParses(rs.VolumeSize, nsPath.Child("volumeSize"))...)
+	}
+
+	if cells.LaunchRateLimit.RequestsPerSecond < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("launchRateLimit", "requestsPerSecond"), cells.LaunchRateLimit.RequestsPerSecond, "must be greater than or equal to 0"))
+	}
+	allErrs = append(allErrs, validation.ValidateNonnegativeField(int64(cells.LaunchRateLimit.Burst), fldPath.Child("launchRateLimit", "burst"))...)
+
+	imdsPath := fldPath.Child("instanceMetadataOptions")
+	if tokens := cells.InstanceMetadataOptions.HttpTokens; tokens != "" {
+		validHttpTokens := []string{api.HttpTokensRequired, api.HttpTokensOptional}
+		if !util.StringInSlice(tokens, validHttpTokens) {
+			allErrs = append(allErrs, field.NotSupported(imdsPath.Child("httpTokens"), tokens, validHttpTokens))
+		}
+	}
+	if hops := cells.InstanceMetadataOptions.HttpPutResponseHopLimit; hops < 0 || hops > 64 {
+		allErrs = append(allErrs, field.Invalid(imdsPath.Child("httpPutResponseHopLimit"), hops, "must be between 0 and 64"))
+	}
+
+	if cells.DefaultDNSPolicy != "" {
+		validDNSPolicies := []string{
+			string(api.DNSDefault),
+			string(api.DNSClusterFirst),
+			string(api.DNSClusterFirstWithHostNet),
+		}
+		if !util.StringInSlice(cells.DefaultDNSPolicy, validDNSPolicies) {
+			allErrs = append(allErrs, field.NotSupported(fldPath.Child("defaultDNSPolicy"), cells.DefaultDNSPolicy, validDNSPolicies))
+		}
+	}
+
+	if cells.DefaultImagePullSecret != "" {
+		parts := strings.SplitN(cells.DefaultImagePullSecret, "/", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("defaultImagePullSecret"), cells.DefaultImagePullSecret, "must be specified as namespace/name"))
+		}
+	}
+
+	if cells.Itzo.SHA256 != "" && !sha256HexRegexp.MatchString(cells.Itzo.SHA256) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("itzo", "sha256"), cells.Itzo.SHA256, "must be a 64 character hex sha256 checksum"))
+	}
+
+	if cells.SubnetSpread.MaxSkew < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("subnetSpread", "maxSkew"), cells.SubnetSpread.MaxSkew, "maxSkew may not be negative"))
+	}
+
+	rollout := cells.BootImageRollout
+	rolloutPath := fldPath.Child("bootImageRollout")
+	if rollout.MaxInFlight < 0 {
+		allErrs = append(allErrs, field.Invalid(rolloutPath.Child("maxInFlight"), rollout.MaxInFlight, "maxInFlight may not be negative"))
+	}
+	if (rollout.WindowStart == "") != (rollout.WindowEnd == "") {
+		allErrs = append(allErrs, field.Invalid(rolloutPath.Child("windowStart"), rollout.WindowStart, "windowStart and windowEnd must be set together"))
+	}
+	if rollout.WindowStart != "" {
+		if _, err := time.Parse("15:04", rollout.WindowStart); err != nil {
+			allErrs = append(allErrs, field.Invalid(rolloutPath.Child("windowStart"), rollout.WindowStart, "must be a time of day like \"22:30\""))
+		}
+	}
+	if rollout.WindowEnd != "" {
+		if _, err := time.Parse("15:04", rollout.WindowEnd); err != nil {
+			allErrs = append(allErrs, field.Invalid(rolloutPath.Child("windowEnd"), rollout.WindowEnd, "must be a time of day like \"04:30\""))
+		}
+	}
+
+	if cells.MaxVolumeDeploySize != "" {
+		q, err := resource.ParseQuantity(cells.MaxVolumeDeploySize)
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("maxVolumeDeploySize"), cells.MaxVolumeDeploySize, "must be a resource quantity like \"200Mi\""))
+		} else if q.Value() <= 0 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("maxVolumeDeploySize"), cells.MaxVolumeDeploySize, "must be greater than 0"))
+		}
+	}
+
+	retryPath := fldPath.Child("cloudAPIRetry")
+	if cells.CloudAPIRetry.MaxAttempts < 0 {
+		allErrs = append(allErrs, field.Invalid(retryPath.Child("maxAttempts"), cells.CloudAPIRetry.MaxAttempts, "maxAttempts may not be negative"))
+	}
+	allErrs = append(allErrs, validation.ValidateNonnegativeField(cells.CloudAPIRetry.BaseDelayMilliseconds, retryPath.Child("baseDelayMilliseconds"))...)
+	if j := cells.CloudAPIRetry.Jitter; j < 0 || j > 1 {
+		allErrs = append(allErrs, field.Invalid(retryPath.Child("jitter"), j, "must be between 0 and 1"))
+	}
+
+	for registry, mirror := range cells.RegistryMirrors {
+		mirrorPath := fldPath.Child("registryMirrors").Key(registry)
+		if registry == "" {
+			allErrs = append(allErrs, field.Invalid(mirrorPath, registry, "registry host must not be empty, use \"docker.io\" to match bare references"))
+		}
+		if mirror == "" {
+			allErrs = append(allErrs, field.Required(mirrorPath, "mirror host must not be empty"))
+		}
+	}
+
 	if cells.HealthCheck.Status != nil && cells.HealthCheck.CloudAPI != nil {
 		allErrs = append(allErrs, field.Invalid(fldPath.Child("healthcheck"), "multiple healthchecks configured", "cannot set both status and cloudAPI healthchecks"))
 	}
@@ -583,6 +988,21 @@ func validateServerConfigFile(cf *ServerConfigFile) field.ErrorList {
 		}
 	}
 
+	taintsPath := field.NewPath("kubelet").Child("taints")
+	validTaintEffects := []string{
+		string(v1.TaintEffectNoSchedule),
+		string(v1.TaintEffectPreferNoSchedule),
+		string(v1.TaintEffectNoExecute),
+	}
+	for i, taint := range cf.Kubelet.Taints {
+		if taint.Key == "" {
+			allErrs = append(allErrs, field.Required(taintsPath.Index(i).Child("key"), ""))
+		}
+		if !util.StringInSlice(string(taint.Effect), validTaintEffects) {
+			allErrs = append(allErrs, field.NotSupported(taintsPath.Index(i).Child("effect"), string(taint.Effect), validTaintEffects))
+		}
+	}
+
 	return allErrs
 }
 
@@ -36,6 +36,7 @@ const (
 	ResourceLimitsGPU    v1.ResourceName = "nvidia.com/gpu"
 	resolvconfVolumeName                 = "resolvconf"
 	etchostsVolumeName                   = "etchosts"
+	cacertsVolumeName                    = "cacerts"
 )
 
 var (
@@ -46,15 +47,11 @@ var (
 )
 
 func getStatus(internalIP string, milpaPod *api.Pod, pod *v1.Pod) v1.PodStatus {
-	// Todo: make the call if this should be the dispatch time of the
-	// pod in milpa.
 	startTime := metav1.NewTime(milpaPod.CreationTimestamp.Time)
-	privateIPv4Address := ""
-	for _, address := range milpaPod.Status.Addresses {
-		if address.Type == api.PrivateIP {
-			privateIPv4Address = address.Address
-		}
+	if milpaPod.Status.StartTime != nil {
+		startTime = metav1.NewTime(milpaPod.Status.StartTime.Time)
 	}
+	privateIPv4Address := api.GetAddress(milpaPod.Status.Addresses, api.PrivateIP)
 	initComplete := true
 	initContainerStatuses := make([]v1.ContainerStatus, len(milpaPod.Status.InitUnitStatuses))
 	for i, st := range milpaPod.Status.InitUnitStatuses {
@@ -76,6 +73,10 @@ func getStatus(internalIP string, milpaPod *api.Pod, pod *v1.Pod) v1.PodStatus {
 		phase = v1.PodPending
 	case api.PodRunning:
 		phase = v1.PodRunning
+	case api.PodSuspended:
+		// A suspended pod's instance is gone but the pod will be
+		// relaunched on resume, so it's pending rather than failed.
+		phase = v1.PodPending
 	case api.PodSucceeded:
 		phase = v1.PodSucceeded
 	case api.PodFailed:
@@ -143,10 +144,15 @@ func unitStateToContainerState(st api.UnitState) v1.ContainerState {
 }
 
 func unitToContainerStatus(st api.UnitStatus) v1.ContainerStatus {
+	imageID := st.ImageID
+	if imageID == "" {
+		// Older cells don't report the resolved image digest.
+		imageID = st.Image
+	}
 	cst := v1.ContainerStatus{
 		Name:         st.Name,
 		Image:        st.Image,
-		ImageID:      st.Image,
+		ImageID:      imageID,
 		RestartCount: st.RestartCount,
 		Ready:        st.Ready,
 		Started:      st.Started,
@@ -158,17 +164,49 @@ func unitToContainerStatus(st api.UnitStatus) v1.ContainerStatus {
 
 func containerToUnit(container v1.Container) api.Unit {
 	unit := api.Unit{
-		Name:       container.Name,
-		Image:      container.Image,
-		Command:    container.Command,
-		Args:       container.Args,
-		WorkingDir: container.WorkingDir,
+		Name:                     container.Name,
+		Image:                    container.Image,
+		Command:                  container.Command,
+		Args:                     container.Args,
+		WorkingDir:               container.WorkingDir,
+		TerminationMessagePath:   container.TerminationMessagePath,
+		TerminationMessagePolicy: api.TerminationMessagePolicy(string(container.TerminationMessagePolicy)),
 	}
 	for _, e := range container.Env {
-		unit.Env = append(unit.Env, api.EnvVar{
+		ev := api.EnvVar{
 			Name:  e.Name,
 			Value: e.Value,
-		})
+		}
+		if e.ValueFrom != nil {
+			if e.ValueFrom.FieldRef != nil {
+				ev.ValueFrom = &api.EnvVarSource{
+					FieldRef: &api.ObjectFieldSelector{
+						FieldPath: e.ValueFrom.FieldRef.FieldPath,
+					},
+				}
+			} else if e.ValueFrom.ConfigMapKeyRef != nil {
+				ev.ValueFrom = &api.EnvVarSource{
+					ConfigMapKeyRef: &api.ConfigMapKeySelector{
+						LocalObjectReference: api.LocalObjectReference{
+							Name: e.ValueFrom.ConfigMapKeyRef.Name,
+						},
+						Key:      e.ValueFrom.ConfigMapKeyRef.Key,
+						Optional: e.ValueFrom.ConfigMapKeyRef.Optional,
+					},
+				}
+			} else if e.ValueFrom.SecretKeyRef != nil {
+				ev.ValueFrom = &api.EnvVarSource{
+					SecretKeyRef: &api.SecretKeySelector{
+						LocalObjectReference: api.LocalObjectReference{
+							Name: e.ValueFrom.SecretKeyRef.Name,
+						},
+						Key:      e.ValueFrom.SecretKeyRef.Key,
+						Optional: e.ValueFrom.SecretKeyRef.Optional,
+					},
+				}
+			}
+		}
+		unit.Env = append(unit.Env, ev)
 	}
 	if container.SecurityContext != nil {
 		unit.SecurityContext = &api.SecurityContext{
@@ -204,6 +242,8 @@ func containerToUnit(container v1.Container) api.Unit {
 		unit.VolumeMounts = append(unit.VolumeMounts, api.VolumeMount{
 			Name:      vm.Name,
 			MountPath: vm.MountPath,
+			ReadOnly:  vm.ReadOnly,
+			SubPath:   vm.SubPath,
 		})
 	}
 	unit.VolumeMounts = append(unit.VolumeMounts, api.VolumeMount{
@@ -218,6 +258,7 @@ func containerToUnit(container v1.Container) api.Unit {
 	unit.StartupProbe = k8sProbeToMilpaProbe(container.StartupProbe)
 	unit.ReadinessProbe = k8sProbeToMilpaProbe(container.ReadinessProbe)
 	unit.LivenessProbe = k8sProbeToMilpaProbe(container.LivenessProbe)
+	unit.Lifecycle = k8sLifecycleToMilpaLifecycle(container.Lifecycle)
 
 	return unit
 }
@@ -231,12 +272,43 @@ func unitToContainer(unit api.Unit, container *v1.Container) v1.Container {
 	container.Command = unit.Command
 	container.Args = unit.Args
 	container.WorkingDir = unit.WorkingDir
+	container.TerminationMessagePath = unit.TerminationMessagePath
+	container.TerminationMessagePolicy = v1.TerminationMessagePolicy(string(unit.TerminationMessagePolicy))
 	container.Env = make([]v1.EnvVar, len(unit.Env))
 	for i, e := range unit.Env {
 		container.Env[i] = v1.EnvVar{
 			Name:  e.Name,
 			Value: e.Value,
 		}
+		if e.ValueFrom != nil {
+			if e.ValueFrom.FieldRef != nil {
+				container.Env[i].ValueFrom = &v1.EnvVarSource{
+					FieldRef: &v1.ObjectFieldSelector{
+						FieldPath: e.ValueFrom.FieldRef.FieldPath,
+					},
+				}
+			} else if e.ValueFrom.ConfigMapKeyRef != nil {
+				container.Env[i].ValueFrom = &v1.EnvVarSource{
+					ConfigMapKeyRef: &v1.ConfigMapKeySelector{
+						LocalObjectReference: v1.LocalObjectReference{
+							Name: e.ValueFrom.ConfigMapKeyRef.Name,
+						},
+						Key:      e.ValueFrom.ConfigMapKeyRef.Key,
+						Optional: e.ValueFrom.ConfigMapKeyRef.Optional,
+					},
+				}
+			} else if e.ValueFrom.SecretKeyRef != nil {
+				container.Env[i].ValueFrom = &v1.EnvVarSource{
+					SecretKeyRef: &v1.SecretKeySelector{
+						LocalObjectReference: v1.LocalObjectReference{
+							Name: e.ValueFrom.SecretKeyRef.Name,
+						},
+						Key:      e.ValueFrom.SecretKeyRef.Key,
+						Optional: e.ValueFrom.SecretKeyRef.Optional,
+					},
+				}
+			}
+		}
 	}
 	for _, port := range unit.Ports {
 		container.Ports = append(container.Ports,
@@ -272,17 +344,22 @@ func unitToContainer(unit api.Unit, container *v1.Container) v1.Container {
 		}
 	}
 	for _, vm := range unit.VolumeMounts {
-		if vm.Name == resolvconfVolumeName || vm.Name == etchostsVolumeName {
+		if vm.Name == resolvconfVolumeName ||
+			vm.Name == etchostsVolumeName ||
+			vm.Name == cacertsVolumeName {
 			continue
 		}
 		container.VolumeMounts = append(container.VolumeMounts, v1.VolumeMount{
 			Name:      vm.Name,
 			MountPath: vm.MountPath,
+			ReadOnly:  vm.ReadOnly,
+			SubPath:   vm.SubPath,
 		})
 	}
 	container.StartupProbe = milpaProbeToK8sProbe(unit.StartupProbe)
 	container.ReadinessProbe = milpaProbeToK8sProbe(unit.ReadinessProbe)
 	container.LivenessProbe = milpaProbeToK8sProbe(unit.LivenessProbe)
+	container.Lifecycle = milpaLifecycleToK8sLifecycle(unit.Lifecycle)
 
 	return *container
 }
@@ -381,6 +458,22 @@ func k8sToMilpaVolume(vol v1.Volume) *api.Volume {
 					Optional: src.ConfigMap.Optional,
 				}
 				projVol.Sources[i].ConfigMap = apiCM
+			} else if src.DownwardAPI != nil {
+				apiDownward := &api.DownwardAPIProjection{
+					Items: make([]api.DownwardAPIVolumeFile, len(src.DownwardAPI.Items)),
+				}
+				for j, item := range src.DownwardAPI.Items {
+					apiDownward.Items[j] = api.DownwardAPIVolumeFile{
+						Path: item.Path,
+						Mode: item.Mode,
+					}
+					if item.FieldRef != nil {
+						apiDownward.Items[j].FieldRef = &api.ObjectFieldSelector{
+							FieldPath: item.FieldRef.FieldPath,
+						}
+					}
+				}
+				projVol.Sources[i].DownwardAPI = apiDownward
 			}
 		}
 		return &api.Volume{
@@ -389,6 +482,15 @@ func k8sToMilpaVolume(vol v1.Volume) *api.Volume {
 				Projected: projVol,
 			},
 		}
+	} else if vol.AWSElasticBlockStore != nil {
+		return &api.Volume{
+			Name: vol.Name,
+			VolumeSource: api.VolumeSource{
+				CloudVolume: &api.CloudVolumeSource{
+					VolumeID: vol.AWSElasticBlockStore.VolumeID,
+				},
+			},
+		}
 	} else {
 		klog.Warningf("Unsupported volume type for volume: %s", vol.Name)
 		return &api.Volume{
@@ -496,6 +598,22 @@ func milpaToK8sVolume(vol api.Volume) *v1.Volume {
 					Optional: src.ConfigMap.Optional,
 				}
 				projVol.Sources[i].ConfigMap = k8CM
+			} else if src.DownwardAPI != nil {
+				k8Downward := &v1.DownwardAPIProjection{
+					Items: make([]v1.DownwardAPIVolumeFile, len(src.DownwardAPI.Items)),
+				}
+				for j, item := range src.DownwardAPI.Items {
+					k8Downward.Items[j] = v1.DownwardAPIVolumeFile{
+						Path: item.Path,
+						Mode: item.Mode,
+					}
+					if item.FieldRef != nil {
+						k8Downward.Items[j].FieldRef = &v1.ObjectFieldSelector{
+							FieldPath: item.FieldRef.FieldPath,
+						}
+					}
+				}
+				projVol.Sources[i].DownwardAPI = k8Downward
 			}
 		}
 		return &v1.Volume{
@@ -504,6 +622,15 @@ func milpaToK8sVolume(vol api.Volume) *v1.Volume {
 				Projected: projVol,
 			},
 		}
+	} else if vol.CloudVolume != nil {
+		return &v1.Volume{
+			Name: vol.Name,
+			VolumeSource: v1.VolumeSource{
+				AWSElasticBlockStore: &v1.AWSElasticBlockStoreVolumeSource{
+					VolumeID: vol.CloudVolume.VolumeID,
+				},
+			},
+		}
 	} else if vol.PackagePath != nil {
 		klog.V(4).Infof("skipping PackagePath %q", vol.PackagePath.Path)
 	} else {
@@ -521,6 +648,7 @@ func k8sToMilpaPod(pod *v1.Pod) (*api.Pod, error) {
 	milpapod.Labels = pod.Labels
 	milpapod.Annotations = pod.Annotations
 	milpapod.Spec.RestartPolicy = api.RestartPolicy(string(pod.Spec.RestartPolicy))
+	milpapod.Spec.ActiveDeadlineSeconds = pod.Spec.ActiveDeadlineSeconds
 	if len(pod.Spec.ImagePullSecrets) > 0 {
 		milpapod.Spec.ImagePullSecrets = make([]string, len(pod.Spec.ImagePullSecrets))
 		for i := range pod.Spec.ImagePullSecrets {
@@ -529,11 +657,18 @@ func k8sToMilpaPod(pod *v1.Pod) (*api.Pod, error) {
 	}
 
 	podsc := pod.Spec.SecurityContext
+	if podsc == nil &&
+		(pod.Spec.HostNetwork || pod.Spec.HostPID || pod.Spec.HostIPC) {
+		// Host namespaces are represented via NamespaceOptions in the
+		// milpa security context, make sure one gets created.
+		podsc = &v1.PodSecurityContext{}
+	}
 	if podsc != nil {
 		mpsc := &api.PodSecurityContext{
 			RunAsUser:          podsc.RunAsUser,
 			RunAsGroup:         podsc.RunAsGroup,
 			SupplementalGroups: podsc.SupplementalGroups,
+			FSGroup:            podsc.FSGroup,
 		}
 		mpsc.NamespaceOptions = &api.NamespaceOption{
 			Network: api.NamespaceModePod,
@@ -562,6 +697,12 @@ func k8sToMilpaPod(pod *v1.Pod) (*api.Pod, error) {
 		milpapod.Spec.SecurityContext = mpsc
 	}
 	milpapod.Spec.DNSPolicy = api.DNSPolicy(string(pod.Spec.DNSPolicy))
+	if api.IsHostNetwork(&milpapod.Spec) &&
+		milpapod.Spec.DNSPolicy == api.DNSClusterFirst {
+		// Cluster DNS only applies to host network pods that
+		// explicitly opt in with ClusterFirstWithHostNet.
+		milpapod.Spec.DNSPolicy = api.DNSDefault
+	}
 	if pod.Spec.DNSConfig != nil {
 		milpapod.Spec.DNSConfig = &api.PodDNSConfig{}
 		milpapod.Spec.DNSConfig.Nameservers = append(
@@ -610,12 +751,40 @@ func k8sToMilpaPod(pod *v1.Pod) (*api.Pod, error) {
 			},
 		},
 	})
+	if podWantsCABundle(milpapod) {
+		milpapod.Spec.Volumes = append(milpapod.Spec.Volumes, api.Volume{
+			Name: cacertsVolumeName,
+			VolumeSource: api.VolumeSource{
+				PackagePath: &api.PackagePath{
+					Path: caBundleMountPath(),
+				},
+			},
+		})
+		caMount := api.VolumeMount{
+			Name:      cacertsVolumeName,
+			MountPath: caBundleMountPath(),
+		}
+		for i := range milpapod.Spec.Units {
+			milpapod.Spec.Units[i].VolumeMounts = append(
+				milpapod.Spec.Units[i].VolumeMounts, caMount)
+		}
+		for i := range milpapod.Spec.InitUnits {
+			milpapod.Spec.InitUnits[i].VolumeMounts = append(
+				milpapod.Spec.InitUnits[i].VolumeMounts, caMount)
+		}
+	}
 	milpapod.Spec.Resources = aggregateResources(
 		pod.Spec.Containers,
 		pod.Spec.NodeSelector,
 	)
 	milpapod.Spec.Hostname = pod.Spec.Hostname
 	milpapod.Spec.Subdomain = pod.Spec.Subdomain
+	// Service env var injection honors this field upstream of us, we
+	// carry it on the milpa pod so the setting survives a round trip.
+	milpapod.Spec.EnableServiceLinks = pod.Spec.EnableServiceLinks
+	if pod.Spec.RuntimeClassName != nil {
+		milpapod.Spec.RuntimeClassName = *pod.Spec.RuntimeClassName
+	}
 	if len(pod.Spec.HostAliases) > 0 {
 		milpapod.Spec.HostAliases = make(
 			[]api.HostAlias, len(pod.Spec.HostAliases))
@@ -625,30 +794,71 @@ func k8sToMilpaPod(pod *v1.Pod) (*api.Pod, error) {
 		milpapod.Spec.HostAliases[i].Hostnames = append(
 			[]string(nil), hostAlias.Hostnames...)
 	}
-	addAnnotationsToMilpaPod(milpapod)
+	if err := addAnnotationsToMilpaPod(milpapod); err != nil {
+		return nil, err
+	}
 	return milpapod, nil
 }
 
-func addAnnotationsToMilpaPod(milpaPod *api.Pod) {
+// Annotations are the way kubernetes users drive cloud-specific
+// behavior without a custom resource; when both an annotation and the
+// equivalent spec field are set, the annotation wins. Malformed values
+// are rejected here so pod creation fails with an explanatory error
+// instead of the annotation being silently ignored.
+func addAnnotationsToMilpaPod(milpaPod *api.Pod) error {
 	a := milpaPod.Annotations[annotations.PodLaunchType]
-	if strings.ToLower(a) == "spot" {
+	switch strings.ToLower(a) {
+	case "spot":
 		milpaPod.Spec.Spot.Policy = api.SpotAlways
+	case "spot-preferred":
+		milpaPod.Spec.Spot.Policy = api.SpotPreferred
+	case "", "ondemand", "on-demand":
+	default:
+		return fmt.Errorf("annotation %s has invalid value %q, must be \"spot\", \"spot-preferred\" or \"on-demand\"", annotations.PodLaunchType, a)
 	}
 	a = milpaPod.Annotations[annotations.PodInstanceType]
-	if strings.ToLower(a) != "" {
+	if a != "" {
 		milpaPod.Spec.InstanceType = a
 	}
 	a = milpaPod.Annotations[annotations.PodResourcesPrivateIPOnly]
 	if a != "" {
 		val, err := strconv.ParseBool(a)
-		if err == nil {
-			milpaPod.Spec.Resources.PrivateIPOnly = val
+		if err != nil {
+			return fmt.Errorf("annotation %s has invalid value %q, must be a boolean", annotations.PodResourcesPrivateIPOnly, a)
 		}
+		milpaPod.Spec.Resources.PrivateIPOnly = val
 	}
 	a = milpaPod.Annotations[annotations.PodVolumeSize]
 	if a != "" {
+		if _, err := resource.ParseQuantity(a); err != nil {
+			return fmt.Errorf("annotation %s has invalid value %q, must be a quantity like \"10G\"", annotations.PodVolumeSize, a)
+		}
 		milpaPod.Spec.Resources.VolumeSize = a
 	}
+	a = milpaPod.Annotations[annotations.PodAvailabilityZone]
+	if a != "" {
+		milpaPod.Spec.Placement.AvailabilityZone = a
+	}
+	a = milpaPod.Annotations[annotations.PodSuspend]
+	if a != "" {
+		val, err := strconv.ParseBool(a)
+		if err != nil {
+			return fmt.Errorf("annotation %s has invalid value %q, must be a boolean", annotations.PodSuspend, a)
+		}
+		if val {
+			milpaPod.Spec.Phase = api.PodSuspended
+		}
+	}
+	a = milpaPod.Annotations[annotations.PodSecondaryInterfaceSubnet]
+	if a != "" {
+		sni := &api.SecondaryNetworkInterfaceSpec{SubnetID: a}
+		sgs := milpaPod.Annotations[annotations.PodSecondaryInterfaceSecurityGroups]
+		if sgs != "" {
+			sni.SecurityGroupIDs = strings.Split(sgs, ",")
+		}
+		milpaPod.Spec.SecondaryNetworkInterface = sni
+	}
+	return nil
 }
 
 func aggregateResources(containers []v1.Container, nodeSelector map[string]string) api.ResourceSpec {
@@ -724,6 +934,7 @@ func milpaToK8sPod(nodeName, internalIP string, milpaPod *api.Pod) (*v1.Pod, err
 	pod.Spec.NodeName = nodeName
 	pod.Spec.Volumes = []v1.Volume{}
 	pod.Spec.RestartPolicy = v1.RestartPolicy(string(milpaPod.Spec.RestartPolicy))
+	pod.Spec.ActiveDeadlineSeconds = milpaPod.Spec.ActiveDeadlineSeconds
 	if len(milpaPod.Spec.ImagePullSecrets) > 0 {
 		pod.Spec.ImagePullSecrets = make([]v1.LocalObjectReference, len(milpaPod.Spec.ImagePullSecrets))
 		for i := range milpaPod.Spec.ImagePullSecrets {
@@ -740,6 +951,7 @@ func milpaToK8sPod(nodeName, internalIP string, milpaPod *api.Pod) (*v1.Pod, err
 		psc.RunAsUser = mpsc.RunAsUser
 		psc.RunAsGroup = mpsc.RunAsGroup
 		psc.SupplementalGroups = mpsc.SupplementalGroups
+		psc.FSGroup = mpsc.FSGroup
 		if mpsc.NamespaceOptions != nil {
 			if mpsc.NamespaceOptions.Network == api.NamespaceModeNode {
 				pod.Spec.HostNetwork = true
@@ -760,6 +972,9 @@ func milpaToK8sPod(nodeName, internalIP string, milpaPod *api.Pod) (*v1.Pod, err
 		}
 		pod.Spec.SecurityContext = psc
 	}
+	if milpaPod.Spec.HostNetwork {
+		pod.Spec.HostNetwork = true
+	}
 	pod.Spec.DNSPolicy = v1.DNSPolicy(string(milpaPod.Spec.DNSPolicy))
 	if milpaPod.Spec.DNSConfig != nil {
 		pod.Spec.DNSConfig = &v1.PodDNSConfig{}
@@ -780,6 +995,11 @@ func milpaToK8sPod(nodeName, internalIP string, milpaPod *api.Pod) (*v1.Pod, err
 	}
 	pod.Spec.Hostname = milpaPod.Spec.Hostname
 	pod.Spec.Subdomain = milpaPod.Spec.Subdomain
+	pod.Spec.EnableServiceLinks = milpaPod.Spec.EnableServiceLinks
+	if milpaPod.Spec.RuntimeClassName != "" {
+		runtimeClassName := milpaPod.Spec.RuntimeClassName
+		pod.Spec.RuntimeClassName = &runtimeClassName
+	}
 	if len(milpaPod.Spec.HostAliases) > 0 {
 		pod.Spec.HostAliases = make(
 			[]v1.HostAlias, len(milpaPod.Spec.HostAliases))
@@ -830,36 +1050,42 @@ func milpaProbeToK8sProbe(mp *api.Probe) *v1.Probe {
 		return nil
 	}
 	kp := &v1.Probe{
+		Handler:             milpaHandlerToK8sHandler(mp.Handler),
 		InitialDelaySeconds: mp.InitialDelaySeconds,
 		TimeoutSeconds:      mp.TimeoutSeconds,
 		PeriodSeconds:       mp.PeriodSeconds,
 		SuccessThreshold:    mp.SuccessThreshold,
 		FailureThreshold:    mp.FailureThreshold,
 	}
-	if mp.Exec != nil {
-		kp.Exec = &v1.ExecAction{
-			Command: mp.Exec.Command,
-		}
-	} else if mp.HTTPGet != nil {
-		kp.HTTPGet = &v1.HTTPGetAction{
-			Path:   mp.HTTPGet.Path,
-			Port:   mp.HTTPGet.Port,
-			Host:   mp.HTTPGet.Host,
-			Scheme: v1.URIScheme(string(mp.HTTPGet.Scheme)),
-		}
-		h := make([]v1.HTTPHeader, len(mp.HTTPGet.HTTPHeaders))
-		for i := range mp.HTTPGet.HTTPHeaders {
-			h[i].Name = mp.HTTPGet.HTTPHeaders[i].Name
-			h[i].Value = mp.HTTPGet.HTTPHeaders[i].Value
-		}
-		kp.HTTPGet.HTTPHeaders = h
-	} else if mp.TCPSocket != nil {
-		kp.TCPSocket = &v1.TCPSocketAction{
-			Port: mp.TCPSocket.Port,
-			Host: mp.TCPSocket.Host,
+	return kp
+}
+
+func milpaHandlerToK8sHandler(mh api.Handler) v1.Handler {
+	kh := v1.Handler{}
+	if mh.Exec != nil {
+		kh.Exec = &v1.ExecAction{
+			Command: mh.Exec.Command,
+		}
+	} else if mh.HTTPGet != nil {
+		kh.HTTPGet = &v1.HTTPGetAction{
+			Path:   mh.HTTPGet.Path,
+			Port:   mh.HTTPGet.Port,
+			Host:   mh.HTTPGet.Host,
+			Scheme: v1.URIScheme(string(mh.HTTPGet.Scheme)),
+		}
+		h := make([]v1.HTTPHeader, len(mh.HTTPGet.HTTPHeaders))
+		for i := range mh.HTTPGet.HTTPHeaders {
+			h[i].Name = mh.HTTPGet.HTTPHeaders[i].Name
+			h[i].Value = mh.HTTPGet.HTTPHeaders[i].Value
+		}
+		kh.HTTPGet.HTTPHeaders = h
+	} else if mh.TCPSocket != nil {
+		kh.TCPSocket = &v1.TCPSocketAction{
+			Port: mh.TCPSocket.Port,
+			Host: mh.TCPSocket.Host,
 		}
 	}
-	return kp
+	return kh
 }
 
 func k8sProbeToMilpaProbe(kp *v1.Probe) *api.Probe {
@@ -867,35 +1093,77 @@ func k8sProbeToMilpaProbe(kp *v1.Probe) *api.Probe {
 		return nil
 	}
 	mp := &api.Probe{
+		Handler:             k8sHandlerToMilpaHandler(kp.Handler),
 		InitialDelaySeconds: kp.InitialDelaySeconds,
 		TimeoutSeconds:      kp.TimeoutSeconds,
 		PeriodSeconds:       kp.PeriodSeconds,
 		SuccessThreshold:    kp.SuccessThreshold,
 		FailureThreshold:    kp.FailureThreshold,
 	}
-	if kp.Exec != nil {
-		mp.Exec = &api.ExecAction{
-			Command: kp.Exec.Command,
-		}
-	} else if kp.HTTPGet != nil {
-		mp.HTTPGet = &api.HTTPGetAction{
-			Path:   kp.HTTPGet.Path,
-			Port:   kp.HTTPGet.Port,
-			Host:   kp.HTTPGet.Host,
-			Scheme: api.URIScheme(string(kp.HTTPGet.Scheme)),
-		}
-		h := make([]api.HTTPHeader, len(kp.HTTPGet.HTTPHeaders))
-		for i := range kp.HTTPGet.HTTPHeaders {
-			h[i].Name = kp.HTTPGet.HTTPHeaders[i].Name
-			h[i].Value = kp.HTTPGet.HTTPHeaders[i].Value
-		}
-		mp.HTTPGet.HTTPHeaders = h
-	} else if kp.TCPSocket != nil {
-		mp.TCPSocket = &api.TCPSocketAction{
-			Port: kp.TCPSocket.Port,
-			Host: kp.TCPSocket.Host,
+	return mp
+}
+
+func k8sHandlerToMilpaHandler(kh v1.Handler) api.Handler {
+	mh := api.Handler{}
+	if kh.Exec != nil {
+		mh.Exec = &api.ExecAction{
+			Command: kh.Exec.Command,
+		}
+	} else if kh.HTTPGet != nil {
+		mh.HTTPGet = &api.HTTPGetAction{
+			Path:   kh.HTTPGet.Path,
+			Port:   kh.HTTPGet.Port,
+			Host:   kh.HTTPGet.Host,
+			Scheme: api.URIScheme(string(kh.HTTPGet.Scheme)),
+		}
+		if mh.HTTPGet.Scheme == "" {
+			// the scheme is documented to default to HTTP, make that
+			// explicit so the cell agent never has to guess
+			mh.HTTPGet.Scheme = api.URISchemeHTTP
+		}
+		h := make([]api.HTTPHeader, len(kh.HTTPGet.HTTPHeaders))
+		for i := range kh.HTTPGet.HTTPHeaders {
+			h[i].Name = kh.HTTPGet.HTTPHeaders[i].Name
+			h[i].Value = kh.HTTPGet.HTTPHeaders[i].Value
+		}
+		mh.HTTPGet.HTTPHeaders = h
+	} else if kh.TCPSocket != nil {
+		mh.TCPSocket = &api.TCPSocketAction{
+			Port: kh.TCPSocket.Port,
+			Host: kh.TCPSocket.Host,
 		}
 	}
-	return mp
+	return mh
+}
 
+func milpaLifecycleToK8sLifecycle(ml *api.Lifecycle) *v1.Lifecycle {
+	if ml == nil {
+		return nil
+	}
+	kl := &v1.Lifecycle{}
+	if ml.PostStart != nil {
+		h := milpaHandlerToK8sHandler(*ml.PostStart)
+		kl.PostStart = &h
+	}
+	if ml.PreStop != nil {
+		h := milpaHandlerToK8sHandler(*ml.PreStop)
+		kl.PreStop = &h
+	}
+	return kl
+}
+
+func k8sLifecycleToMilpaLifecycle(kl *v1.Lifecycle) *api.Lifecycle {
+	if kl == nil {
+		return nil
+	}
+	ml := &api.Lifecycle{}
+	if kl.PostStart != nil {
+		h := k8sHandlerToMilpaHandler(*kl.PostStart)
+		ml.PostStart = &h
+	}
+	if kl.PreStop != nil {
+		h := k8sHandlerToMilpaHandler(*kl.PreStop)
+		ml.PreStop = &h
+	}
+	return ml
 }
@@ -19,9 +19,11 @@ package server
 import (
 	"fmt"
 	"math"
+	"strings"
 	"testing"
 
 	"github.com/elotl/kip/pkg/api"
+	"github.com/elotl/kip/pkg/api/annotations"
 	"github.com/elotl/kip/pkg/util"
 	"github.com/elotl/kip/pkg/util/rand"
 	"github.com/stretchr/testify/assert"
@@ -166,6 +168,21 @@ func TestUnitToContainerStatus(t *testing.T) {
 	}
 }
 
+func TestUnitToContainerStatusImageID(t *testing.T) {
+	digest := "elotl/myimage@sha256:" + strings.Repeat("a", 64)
+	us := api.UnitStatus{
+		Name:    "myunit",
+		Image:   "elotl/myimage:latest",
+		ImageID: digest,
+	}
+	cs := unitToContainerStatus(us)
+	assert.Equal(t, digest, cs.ImageID)
+	// Cells that don't report a digest fall back to the image name.
+	us.ImageID = ""
+	cs = unitToContainerStatus(us)
+	assert.Equal(t, us.Image, cs.ImageID)
+}
+
 //func containerToUnit(container v1.Container) api.Unit
 //func unitToContainer(unit api.Unit, container *v1.Container) v1.Container
 func TestUnitToContainer(t *testing.T) {
@@ -641,6 +658,7 @@ func TestProjectedVolumeConversion(t *testing.T) {
 //func milpaToK8sPod(milpaPod *api.Pod) (*v1.Pod, error)
 func TestMilpaToK8sPod(t *testing.T) {
 	i64 := int64(rand.Intn(math.MaxInt64))
+	disableServiceLinks := false
 	node, ip := fakeInstanceProvider()
 	milpaPod := api.NewPod()
 	milpaPod.Namespace = rand.String(16)
@@ -649,8 +667,9 @@ func TestMilpaToK8sPod(t *testing.T) {
 		Spot: api.PodSpot{
 			Policy: api.SpotNever,
 		},
-		Phase:         api.PodRunning,
-		RestartPolicy: api.RestartPolicyOnFailure,
+		Phase:              api.PodRunning,
+		RestartPolicy:      api.RestartPolicyOnFailure,
+		EnableServiceLinks: &disableServiceLinks,
 		Units: []api.Unit{
 			{
 				Name:  rand.String(8),
@@ -942,3 +961,106 @@ func TestAggregateResources(t *testing.T) {
 		assert.Equal(t, tc.resources, resources)
 	}
 }
+
+func TestAddAnnotationsToMilpaPod(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		annotations map[string]string
+		isError     bool
+		check       func(t *testing.T, pod *api.Pod)
+	}{
+		{
+			annotations: map[string]string{
+				annotations.PodLaunchType: "Spot",
+			},
+			check: func(t *testing.T, pod *api.Pod) {
+				assert.Equal(t, api.SpotAlways, pod.Spec.Spot.Policy)
+			},
+		},
+		{
+			annotations: map[string]string{
+				annotations.PodLaunchType: "cheapest",
+			},
+			isError: true,
+		},
+		{
+			annotations: map[string]string{
+				annotations.PodInstanceType: "c5.large",
+			},
+			check: func(t *testing.T, pod *api.Pod) {
+				assert.Equal(t, "c5.large", pod.Spec.InstanceType)
+			},
+		},
+		{
+			annotations: map[string]string{
+				annotations.PodResourcesPrivateIPOnly: "true",
+			},
+			check: func(t *testing.T, pod *api.Pod) {
+				assert.True(t, pod.Spec.Resources.PrivateIPOnly)
+			},
+		},
+		{
+			annotations: map[string]string{
+				annotations.PodResourcesPrivateIPOnly: "yes please",
+			},
+			isError: true,
+		},
+		{
+			annotations: map[string]string{
+				annotations.PodVolumeSize: "10G",
+			},
+			check: func(t *testing.T, pod *api.Pod) {
+				assert.Equal(t, "10G", pod.Spec.Resources.VolumeSize)
+			},
+		},
+		{
+			annotations: map[string]string{
+				annotations.PodVolumeSize: "huge",
+			},
+			isError: true,
+		},
+		{
+			annotations: map[string]string{
+				annotations.PodAvailabilityZone: "us-east-1b",
+			},
+			check: func(t *testing.T, pod *api.Pod) {
+				assert.Equal(t, "us-east-1b", pod.Spec.Placement.AvailabilityZone)
+			},
+		},
+		{
+			annotations: map[string]string{
+				annotations.PodSuspend: "true",
+			},
+			check: func(t *testing.T, pod *api.Pod) {
+				assert.Equal(t, api.PodSuspended, pod.Spec.Phase)
+			},
+		},
+		{
+			annotations: map[string]string{
+				annotations.PodSuspend: "false",
+			},
+			check: func(t *testing.T, pod *api.Pod) {
+				assert.Equal(t, api.PodRunning, pod.Spec.Phase)
+			},
+		},
+		{
+			annotations: map[string]string{
+				annotations.PodSuspend: "maybe",
+			},
+			isError: true,
+		},
+	}
+	for i, tc := range tests {
+		pod := api.GetFakePod()
+		// The annotation should win over the spec field.
+		pod.Spec.InstanceType = "t2.nano"
+		pod.Annotations = tc.annotations
+		err := addAnnotationsToMilpaPod(pod)
+		if tc.isError {
+			assert.Error(t, err, "test %d", i)
+			continue
+		}
+		assert.NoError(t, err, "test %d", i)
+		tc.check(t, pod)
+	}
+}
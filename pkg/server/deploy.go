@@ -17,6 +17,7 @@ limitations under the License.
 package server
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -28,7 +29,7 @@ import (
 	"k8s.io/klog"
 )
 
-func (s InstanceProvider) deploy(podName, pkgName string, pkgData io.Reader) error {
+func (s InstanceProvider) deploy(ctx context.Context, podName, pkgName string, pkgData io.Reader) error {
 	reg, exists := s.Registries["Pod"]
 	if !exists {
 		return fmt.Errorf("Fatal error: can't find pod registry in storage")
@@ -53,7 +54,7 @@ func (s InstanceProvider) deploy(podName, pkgName string, pkgData io.Reader) err
 			err, "Could not get node %s from storage", pod.Status.BoundNodeName)
 	}
 	client := s.ItzoClientFactory.GetClient(node.Status.Addresses)
-	err = client.Deploy(podName, pkgName, pkgData)
+	err = client.Deploy(ctx, podName, pkgName, pkgData)
 	if err != nil {
 		return util.WrapError(
 			err, "Error deploying package %s for %s: %v", pkgName, podName, err)
@@ -105,7 +106,7 @@ func (s InstanceProvider) Deploy(stream clientapi.Kip_DeployServer) error {
 		}
 		return stream.SendAndClose(&reply)
 	}
-	err = s.deploy(pod, name, tmpfile)
+	err = s.deploy(stream.Context(), pod, name, tmpfile)
 	if err != nil {
 		reply := clientapi.APIReply{
 			Status: 500,
@@ -18,6 +18,7 @@ package server
 
 import (
 	"bytes"
+	"context"
 	"testing"
 
 	"github.com/elotl/kip/pkg/api"
@@ -55,7 +56,7 @@ func TestDeploy(t *testing.T) {
 	assert.NoError(t, err)
 	var buf bytes.Buffer
 	buf.WriteString("foobar")
-	err = s.deploy(pod.Name, "mypkg", &buf) //make([]byte, 1))
+	err = s.deploy(context.Background(), pod.Name, "mypkg", &buf) //make([]byte, 1))
 	assert.NoError(t, err)
 }
 
@@ -64,7 +65,7 @@ func TestDeployNoPod(t *testing.T) {
 	defer closer()
 	var buf bytes.Buffer
 	buf.WriteString("foobar")
-	err := s.deploy("mypod", "mypkg", &buf)
+	err := s.deploy(context.Background(), "mypod", "mypkg", &buf)
 	assert.Error(t, err)
 }
 
@@ -79,6 +80,6 @@ func TestDeployNoNode(t *testing.T) {
 	assert.NoError(t, err)
 	var buf bytes.Buffer
 	buf.WriteString("foobar")
-	err = s.deploy(pod.Name, "mypkg", &buf)
+	err = s.deploy(context.Background(), pod.Name, "mypkg", &buf)
 	assert.Error(t, err)
 }
@@ -21,13 +21,22 @@ import (
 	"bufio"
 	"bytes"
 	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/elotl/kip/pkg/api"
+	"github.com/elotl/kip/pkg/api/annotations"
 	"github.com/elotl/kip/pkg/nodeclient"
 	"github.com/elotl/kip/pkg/util"
+	"github.com/elotl/kip/pkg/util/validation"
 	"github.com/elotl/node-cli/manager"
 	"github.com/kubernetes/kubernetes/pkg/kubelet/network/dns"
 	v1 "k8s.io/api/core/v1"
@@ -39,22 +48,13 @@ import (
 	"k8s.io/klog"
 )
 
-const (
-	defaultVolumeFileMode = int32(0644)
-)
-
 type packageFile struct {
 	data []byte
 	mode int32
+	gid  int64
 }
 
-// Creates a tar.gz buffer filled with the package files
-func makeDeployPackage(contents map[string]packageFile) (*bytes.Buffer, error) {
-	var buf bytes.Buffer
-	gw := gzip.NewWriter(&buf)
-	defer gw.Close()
-	tw := tar.NewWriter(gw)
-	defer tw.Close()
+func writePackageFiles(tw *tar.Writer, contents map[string]packageFile) error {
 	for path, file := range contents {
 		tarFilepath := filepath.Join(".", "ROOTFS", path)
 		hdr := &tar.Header{
@@ -63,17 +63,30 @@ func makeDeployPackage(contents map[string]packageFile) (*bytes.Buffer, error) {
 			Size:     int64(len(file.data)),
 			Typeflag: byte(tar.TypeReg),
 			Uid:      0,
-			Gid:      0,
+			Gid:      int(file.gid),
 		}
 		err := tw.WriteHeader(hdr)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		_, err = tw.Write(file.data)
 		if err != nil {
-			return nil, err
+			return err
 		}
 	}
+	return nil
+}
+
+// Creates a tar.gz buffer filled with the package files
+func makeDeployPackage(contents map[string]packageFile) (*bytes.Buffer, error) {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	defer gw.Close()
+	tw := tar.NewWriter(gw)
+	defer tw.Close()
+	if err := writePackageFiles(tw, contents); err != nil {
+		return nil, err
+	}
 	if err := tw.Close(); err != nil {
 		return nil, err
 	}
@@ -83,9 +96,120 @@ func makeDeployPackage(contents map[string]packageFile) (*bytes.Buffer, error) {
 	return &buf, nil
 }
 
+// streamDeployPackage streams a tar.gz of the package files through a
+// pipe instead of buffering the whole archive in memory, so deploying
+// a large binary configmap or secret doesn't spike controller memory.
+// Any archiving error surfaces on the reader; the caller must close
+// the reader when done so the writing goroutine can exit early on a
+// failed deploy.
+func streamDeployPackage(contents map[string]packageFile) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		gw := gzip.NewWriter(pw)
+		tw := tar.NewWriter(gw)
+		err := writePackageFiles(tw, contents)
+		if cerr := tw.Close(); err == nil {
+			err = cerr
+		}
+		if cerr := gw.Close(); err == nil {
+			err = cerr
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr
+}
+
+// packageFilesSize is the uncompressed payload size of a deploy
+// package, used to enforce cells.maxVolumeDeploySize.
+func packageFilesSize(contents map[string]packageFile) int64 {
+	var size int64
+	for _, file := range contents {
+		size += int64(len(file.data))
+	}
+	return size
+}
+
+// packageFilesHash is a content hash over a deploy package: paths,
+// modes, gids and data. Two packages with the same hash unpack to the
+// same files on the cell.
+func packageFilesHash(contents map[string]packageFile) string {
+	paths := make([]string, 0, len(contents))
+	for path := range contents {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	h := sha256.New()
+	for _, path := range paths {
+		file := contents[path]
+		fmt.Fprintf(h, "%s\x00%d\x00%d\x00%d\x00", path, file.mode, file.gid, len(file.data))
+		h.Write(file.data)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// volumeDeployCache remembers the content hash of every volume
+// successfully deployed to a cell, so reconciling a running pod
+// doesn't re-ship unchanged configmaps and secrets. Entries are keyed
+// by node as well as pod and volume, so a pod restarted on a new cell
+// always gets its volumes deployed again.
+type volumeDeployCache struct {
+	lock   sync.Mutex
+	hashes map[string]string
+}
+
+func newVolumeDeployCache() *volumeDeployCache {
+	return &volumeDeployCache{
+		hashes: make(map[string]string),
+	}
+}
+
+func volumeDeployKey(podName, nodeName, volName string) string {
+	return podName + "/" + nodeName + "/" + volName
+}
+
+func (c *volumeDeployCache) unchanged(podName, nodeName, volName, hash string) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.hashes[volumeDeployKey(podName, nodeName, volName)] == hash
+}
+
+func (c *volumeDeployCache) remember(podName, nodeName, volName, hash string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.hashes[volumeDeployKey(podName, nodeName, volName)] = hash
+}
+
+// deployedOnce reports whether the volume was ever deployed to this
+// cell, telling the initial deploy apart from a later reconcile.
+func (c *volumeDeployCache) deployedOnce(podName, nodeName, volName string) bool {
+	if c == nil {
+		return false
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	_, ok := c.hashes[volumeDeployKey(podName, nodeName, volName)]
+	return ok
+}
+
+// forgetPod drops every cached hash for the pod, called when the pod
+// goes away so the cache doesn't grow without bound.
+func (c *volumeDeployCache) forgetPod(podName string) {
+	if c == nil {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	prefix := podName + "/"
+	for key := range c.hashes {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.hashes, key)
+		}
+	}
+}
+
 func getConfigMapFiles(cmVol *api.ConfigMapVolumeSource, cm *v1.ConfigMap) (map[string]packageFile, error) {
 	packageItems := make(map[string]packageFile)
-	defaultMode := defaultVolumeFileMode
+	defaultMode := api.ConfigMapVolumeSourceDefaultMode
 	if cmVol.DefaultMode != nil {
 		defaultMode = *cmVol.DefaultMode
 	}
@@ -131,19 +255,32 @@ func getConfigMapFiles(cmVol *api.ConfigMapVolumeSource, cm *v1.ConfigMap) (map[
 	return packageItems, nil
 }
 
+// getSecretFiles turns a secret into volume files. The contract
+// mirrors how v1.Secret is populated: Data holds raw bytes (the client
+// already decoded the base64 wire form) and is written to the file
+// byte-for-byte, never decoded again. StringData is write-only on the
+// apiserver and normally merged into Data before we ever see the
+// secret, but sources that hand us unserved manifests can still carry
+// it, so a key missing from Data falls back to StringData. When both
+// are set, Data wins, matching apiserver merge semantics.
 func getSecretFiles(secVol *api.SecretVolumeSource, sec *v1.Secret) (map[string]packageFile, error) {
 	packageItems := make(map[string]packageFile)
-	defaultMode := defaultVolumeFileMode
+	defaultMode := api.SecretVolumeSourceDefaultMode
 	if secVol.DefaultMode != nil {
 		defaultMode = *secVol.DefaultMode
 	}
 	optional := secVol.Optional != nil && *secVol.Optional
 	var items []api.KeyToPath
 	if len(secVol.Items) == 0 {
-		items = make([]api.KeyToPath, 0, len(sec.Data))
+		items = make([]api.KeyToPath, 0, len(sec.Data)+len(sec.StringData))
 		for k := range sec.Data {
 			items = append(items, api.KeyToPath{Key: k})
 		}
+		for k := range sec.StringData {
+			if _, ok := sec.Data[k]; !ok {
+				items = append(items, api.KeyToPath{Key: k})
+			}
+		}
 	} else {
 		items = secVol.Items
 	}
@@ -152,6 +289,8 @@ func getSecretFiles(secVol *api.SecretVolumeSource, sec *v1.Secret) (map[string]
 		var data []byte
 		if binaryData, ok := sec.Data[item.Key]; ok {
 			data = binaryData
+		} else if stringData, ok := sec.StringData[item.Key]; ok {
+			data = []byte(stringData)
 		} else {
 			if optional {
 				continue
@@ -174,13 +313,23 @@ func getSecretFiles(secVol *api.SecretVolumeSource, sec *v1.Secret) (map[string]
 	return packageItems, nil
 }
 
+// resourceErrorCategory classifies errors from the resource manager:
+// a missing object is not-found, anything else (cache not synced yet,
+// apiserver trouble) is worth retrying.
+func resourceErrorCategory(err error) util.ErrorCategory {
+	if errors.IsNotFound(err) {
+		return util.CategoryNotFound
+	}
+	return util.CategoryTransient
+}
+
 func getConfigMapVolumeFiles(namespace string, cmVol *api.ConfigMapVolumeSource, rm *manager.ResourceManager) (map[string]packageFile, error) {
 	optional := cmVol.Optional != nil && *cmVol.Optional
 	// get the configmap
 	configMap, err := rm.GetConfigMap(cmVol.Name, namespace)
 	if err != nil {
 		if !(errors.IsNotFound(err) && optional) {
-			return nil, util.WrapError(err, "Couldn't get configMap %v/%v", namespace, cmVol.Name)
+			return nil, util.WrapErrorWithCategory(resourceErrorCategory(err), err, "Couldn't get configMap %v/%v", namespace, cmVol.Name)
 		}
 		configMap = &v1.ConfigMap{
 			ObjectMeta: metav1.ObjectMeta{
@@ -197,7 +346,7 @@ func getSecretVolumeFiles(namespace string, secVol *api.SecretVolumeSource, rm *
 	secret, err := rm.GetSecret(secVol.SecretName, namespace)
 	if err != nil {
 		if !(errors.IsNotFound(err) && optional) {
-			return nil, util.WrapError(err, "Couldn't get secret %v/%v", namespace, secVol.SecretName)
+			return nil, util.WrapErrorWithCategory(resourceErrorCategory(err), err, "Couldn't get secret %v/%v", namespace, secVol.SecretName)
 		}
 		secret = &v1.Secret{
 			ObjectMeta: metav1.ObjectMeta{
@@ -209,7 +358,63 @@ func getSecretVolumeFiles(namespace string, secVol *api.SecretVolumeSource, rm *
 	return getSecretFiles(secVol, secret)
 }
 
-func getProjectedVolumeFiles(namespace string, vol *api.ProjectedVolumeSource, rm *manager.ResourceManager) (map[string]packageFile, error) {
+// formatFieldMap renders a label or annotation map the way the downward
+// API does for volume files: one key="value" line per entry, sorted by
+// key so the output is stable across deploys.
+func formatFieldMap(m map[string]string) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	lines := make([]string, 0, len(keys))
+	for _, k := range keys {
+		lines = append(lines, fmt.Sprintf("%s=%q", k, m[k]))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// getDownwardAPIFiles resolves the field refs of a downwardAPI projection
+// against the pod. Unlike the env var field refs, everything supported
+// here is known before dispatch so the files are resolved when the volume
+// payload is built.
+func getDownwardAPIFiles(proj *api.DownwardAPIProjection, pod *api.Pod, defaultMode int32) (map[string]packageFile, error) {
+	namespace, name := util.SplitNamespaceAndName(pod.Name)
+	packageFiles := make(map[string]packageFile)
+	for _, item := range proj.Items {
+		if item.FieldRef == nil {
+			return nil, fmt.Errorf("downwardAPI file %s has no fieldRef", item.Path)
+		}
+		var value string
+		switch item.FieldRef.FieldPath {
+		case "metadata.name":
+			value = name
+		case "metadata.namespace":
+			value = namespace
+		case "metadata.uid":
+			value = pod.UID
+		case "metadata.labels":
+			value = formatFieldMap(pod.Labels)
+		case "metadata.annotations":
+			value = formatFieldMap(pod.Annotations)
+		default:
+			return nil, fmt.Errorf("downwardAPI file %s references unsupported field path %q",
+				item.Path, item.FieldRef.FieldPath)
+		}
+		mode := defaultMode
+		if item.Mode != nil {
+			mode = *item.Mode
+		}
+		packageFiles[item.Path] = packageFile{
+			data: []byte(value),
+			mode: mode,
+		}
+	}
+	return packageFiles, nil
+}
+
+func getProjectedVolumeFiles(pod *api.Pod, vol *api.ProjectedVolumeSource, rm *manager.ResourceManager) (map[string]packageFile, error) {
+	namespace := pod.Namespace
 	defaultMode := api.ProjectedVolumeSourceDefaultMode
 	if vol.DefaultMode != nil {
 		defaultMode = *vol.DefaultMode
@@ -220,11 +425,18 @@ func getProjectedVolumeFiles(namespace string, vol *api.ProjectedVolumeSource, r
 			packageFiles map[string]packageFile
 			err          error
 		)
+		// Mode precedence is per-item mode > per-source default mode >
+		// projected volume default mode. Per-item modes are applied when
+		// the source's files are collected below.
 		if src.ConfigMap != nil {
+			srcMode := defaultMode
+			if src.ConfigMap.DefaultMode != nil {
+				srcMode = *src.ConfigMap.DefaultMode
+			}
 			vol := &api.ConfigMapVolumeSource{
 				LocalObjectReference: src.ConfigMap.LocalObjectReference,
 				Items:                src.ConfigMap.Items,
-				DefaultMode:          &defaultMode,
+				DefaultMode:          &srcMode,
 				Optional:             src.ConfigMap.Optional,
 			}
 			packageFiles, err = getConfigMapVolumeFiles(namespace, vol, rm)
@@ -232,16 +444,25 @@ func getProjectedVolumeFiles(namespace string, vol *api.ProjectedVolumeSource, r
 				return nil, util.WrapError(err, "couldn't get projected configMap payload %v/%v", namespace, src.ConfigMap.Name)
 			}
 		} else if src.Secret != nil {
+			srcMode := defaultMode
+			if src.Secret.DefaultMode != nil {
+				srcMode = *src.Secret.DefaultMode
+			}
 			vol := &api.SecretVolumeSource{
 				SecretName:  src.Secret.LocalObjectReference.Name,
 				Items:       src.Secret.Items,
-				DefaultMode: &defaultMode,
+				DefaultMode: &srcMode,
 				Optional:    src.Secret.Optional,
 			}
 			packageFiles, err = getSecretVolumeFiles(namespace, vol, rm)
 			if err != nil {
 				return nil, util.WrapError(err, "couldn't get projected secret payload %v/%v", namespace, src.Secret.Name)
 			}
+		} else if src.DownwardAPI != nil {
+			packageFiles, err = getDownwardAPIFiles(src.DownwardAPI, pod, defaultMode)
+			if err != nil {
+				return nil, util.WrapError(err, "couldn't build projected downwardAPI payload for %s", pod.Name)
+			}
 		}
 		for k, v := range packageFiles {
 			allPackageFiles[k] = v
@@ -250,9 +471,498 @@ func getProjectedVolumeFiles(namespace string, vol *api.ProjectedVolumeSource, r
 	return allPackageFiles, nil
 }
 
-func deployPodVolumes(pod *api.Pod, node *api.Node, rm *manager.ResourceManager, nodeClientFactory nodeclient.ItzoClientFactoryer) error {
+// parseEnvFile parses dotenv-style KEY=VALUE lines into env vars.
+// Blank lines and comments are skipped and CRLF line endings are
+// accepted.
+func parseEnvFile(data []byte) ([]api.EnvVar, error) {
+	var vars []api.EnvVar
+	contents := strings.ReplaceAll(string(data), "\r\n", "\n")
+	for i, line := range strings.Split(contents, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("env file line %d is not KEY=VALUE", i+1)
+		}
+		name := strings.TrimSpace(parts[0])
+		if msgs := validation.IsCIdentifier(name); len(msgs) > 0 {
+			return nil, fmt.Errorf("env file line %d: invalid environment variable name %q", i+1, name)
+		}
+		vars = append(vars, api.EnvVar{Name: name, Value: parts[1]})
+	}
+	return vars, nil
+}
+
+// getEnvFileVars fetches and parses the env files a unit references.
+func getEnvFileVars(namespace string, sources []api.EnvFileSource, rm *manager.ResourceManager) ([]api.EnvVar, error) {
+	var allVars []api.EnvVar
+	for _, src := range sources {
+		var (
+			data  []byte
+			found bool
+		)
+		if src.ConfigMapKey != nil {
+			sel := src.ConfigMapKey
+			optional := sel.Optional != nil && *sel.Optional
+			cm, err := rm.GetConfigMap(sel.Name, namespace)
+			if err != nil {
+				if errors.IsNotFound(err) && optional {
+					continue
+				}
+				return nil, util.WrapErrorWithCategory(resourceErrorCategory(err), err, "Couldn't get configMap %v/%v", namespace, sel.Name)
+			}
+			if stringData, ok := cm.Data[sel.Key]; ok {
+				data, found = []byte(stringData), true
+			} else if binaryData, ok := cm.BinaryData[sel.Key]; ok {
+				data, found = binaryData, true
+			}
+			if !found {
+				if optional {
+					continue
+				}
+				return nil, fmt.Errorf("env file references non-existent config key: %s/%s.%s", namespace, sel.Name, sel.Key)
+			}
+		} else if src.SecretKey != nil {
+			sel := src.SecretKey
+			optional := sel.Optional != nil && *sel.Optional
+			sec, err := rm.GetSecret(sel.Name, namespace)
+			if err != nil {
+				if errors.IsNotFound(err) && optional {
+					continue
+				}
+				return nil, util.WrapErrorWithCategory(resourceErrorCategory(err), err, "Couldn't get secret %v/%v", namespace, sel.Name)
+			}
+			if binaryData, ok := sec.Data[sel.Key]; ok {
+				data, found = binaryData, true
+			}
+			if !found {
+				if optional {
+					continue
+				}
+				return nil, fmt.Errorf("env file references non-existent secret key: %s/%s.%s", namespace, sel.Name, sel.Key)
+			}
+		} else {
+			continue
+		}
+		vars, err := parseEnvFile(data)
+		if err != nil {
+			return nil, util.WrapErrorWithCategory(util.CategoryInvalid, err, "parsing env file")
+		}
+		allVars = append(allVars, vars...)
+	}
+	return allVars, nil
+}
+
+// mergeUnitEnv merges env file vars into a unit's explicit env,
+// explicit entries win on conflict.
+func mergeUnitEnv(fileVars, explicit []api.EnvVar) []api.EnvVar {
+	if len(fileVars) == 0 {
+		return explicit
+	}
+	explicitNames := make(map[string]bool, len(explicit))
+	for _, e := range explicit {
+		explicitNames[e.Name] = true
+	}
+	merged := make([]api.EnvVar, 0, len(fileVars)+len(explicit))
+	for _, v := range fileVars {
+		if !explicitNames[v.Name] {
+			merged = append(merged, v)
+		}
+	}
+	return append(merged, explicit...)
+}
+
+// resolveUnitEnvFiles parses each unit's EnvFileFrom sources and
+// merges the result into the unit's env before the spec is sent to
+// the node.
+func resolveUnitEnvFiles(namespace string, spec api.PodSpec, rm *manager.ResourceManager) (api.PodSpec, error) {
+	for i, unit := range spec.Units {
+		if len(unit.EnvFileFrom) == 0 {
+			continue
+		}
+		fileVars, err := getEnvFileVars(namespace, unit.EnvFileFrom, rm)
+		if err != nil {
+			return spec, util.WrapError(err, "resolving env files for unit %s", unit.Name)
+		}
+		spec.Units[i].Env = mergeUnitEnv(fileVars, unit.Env)
+	}
+	return spec, nil
+}
+
+// applyPodDefaultEnv merges pod-level env vars into every unit's env.
+// Anything set at the unit level (explicit env or env files) wins over
+// the pod-level default.
+func applyPodDefaultEnv(spec api.PodSpec) api.PodSpec {
+	if len(spec.Env) == 0 {
+		return spec
+	}
+	for i, unit := range spec.Units {
+		spec.Units[i].Env = mergeUnitEnv(spec.Env, unit.Env)
+	}
+	for i, unit := range spec.InitUnits {
+		spec.InitUnits[i].Env = mergeUnitEnv(spec.Env, unit.Env)
+	}
+	return spec
+}
+
+// envVarKeyRefValue looks up the configmap or secret key an env var
+// takes its value from. A missing key or object is only an error when
+// the reference isn't optional; otherwise found is false and the env
+// var is dropped by the caller.
+func envVarKeyRefValue(namespace string, src *api.EnvVarSource, rm *manager.ResourceManager) (string, bool, error) {
+	if src.ConfigMapKeyRef != nil {
+		sel := src.ConfigMapKeyRef
+		optional := sel.Optional != nil && *sel.Optional
+		cm, err := rm.GetConfigMap(sel.Name, namespace)
+		if err != nil {
+			if errors.IsNotFound(err) && optional {
+				return "", false, nil
+			}
+			return "", false, util.WrapErrorWithCategory(resourceErrorCategory(err), err, "Couldn't get configMap %v/%v", namespace, sel.Name)
+		}
+		if stringData, ok := cm.Data[sel.Key]; ok {
+			return stringData, true, nil
+		}
+		if binaryData, ok := cm.BinaryData[sel.Key]; ok {
+			return string(binaryData), true, nil
+		}
+		if optional {
+			return "", false, nil
+		}
+		return "", false, fmt.Errorf("env var references non-existent config key: %s/%s.%s", namespace, sel.Name, sel.Key)
+	} else if src.SecretKeyRef != nil {
+		sel := src.SecretKeyRef
+		optional := sel.Optional != nil && *sel.Optional
+		sec, err := rm.GetSecret(sel.Name, namespace)
+		if err != nil {
+			if errors.IsNotFound(err) && optional {
+				return "", false, nil
+			}
+			return "", false, util.WrapErrorWithCategory(resourceErrorCategory(err), err, "Couldn't get secret %v/%v", namespace, sel.Name)
+		}
+		if binaryData, ok := sec.Data[sel.Key]; ok {
+			return string(binaryData), true, nil
+		}
+		if optional {
+			return "", false, nil
+		}
+		return "", false, fmt.Errorf("env var references non-existent secret key: %s/%s.%s", namespace, sel.Name, sel.Key)
+	}
+	return "", false, nil
+}
+
+// resolveKeyRefEnv fills in env vars that take their value from a
+// configmap or secret key via valueFrom. Optional references to
+// missing keys drop the env var; a required reference that can't be
+// resolved fails the deploy. Like resolveFieldRefEnv below, the unit
+// and env slices are copied before being modified so resolved values
+// never show up on the stored pod.
+func resolveKeyRefEnv(namespace string, spec api.PodSpec, rm *manager.ResourceManager) (api.PodSpec, error) {
+	hasKeyRef := func(e api.EnvVar) bool {
+		return e.Value == "" && e.ValueFrom != nil &&
+			(e.ValueFrom.ConfigMapKeyRef != nil || e.ValueFrom.SecretKeyRef != nil)
+	}
+	resolveUnits := func(units []api.Unit) ([]api.Unit, error) {
+		copied := false
+		for i := range units {
+			needsResolve := false
+			for _, e := range units[i].Env {
+				if hasKeyRef(e) {
+					needsResolve = true
+					break
+				}
+			}
+			if !needsResolve {
+				continue
+			}
+			resolved := make([]api.EnvVar, 0, len(units[i].Env))
+			for _, e := range units[i].Env {
+				if !hasKeyRef(e) {
+					resolved = append(resolved, e)
+					continue
+				}
+				val, found, err := envVarKeyRefValue(namespace, e.ValueFrom, rm)
+				if err != nil {
+					return nil, util.WrapError(err, "resolving env var %s of unit %s", e.Name, units[i].Name)
+				}
+				if !found {
+					continue
+				}
+				e.Value = val
+				e.ValueFrom = nil
+				resolved = append(resolved, e)
+			}
+			if !copied {
+				units = append([]api.Unit(nil), units...)
+				copied = true
+			}
+			units[i].Env = resolved
+		}
+		return units, nil
+	}
+	var err error
+	if spec.InitUnits, err = resolveUnits(spec.InitUnits); err != nil {
+		return spec, err
+	}
+	if spec.Units, err = resolveUnits(spec.Units); err != nil {
+		return spec, err
+	}
+	return spec, nil
+}
+
+// fieldRefValue resolves a downward-API style field path to its value
+// for this pod. The supported paths are listed on ObjectFieldSelector.
+func fieldRefValue(fieldPath string, pod *api.Pod, nodeName, podIP string) (string, bool) {
+	namespace, name := util.SplitNamespaceAndName(pod.Name)
+	switch fieldPath {
+	case "metadata.name":
+		return name, true
+	case "metadata.namespace":
+		return namespace, true
+	case "spec.nodeName":
+		return nodeName, true
+	case "status.boundNodeName":
+		return pod.Status.BoundNodeName, true
+	case "status.podIP":
+		return podIP, true
+	}
+	return "", false
+}
+
+// resolveFieldRefEnv fills in env vars that take their value from a
+// field of the pod via valueFrom.fieldRef. The pod IP and node names
+// aren't known until the pod has been dispatched to a cell, so this
+// runs right before the units are sent to the cell, after env files
+// and pod-level defaults have been merged and before commands are
+// expanded so $(VAR) references to these vars work. The unit and env
+// slices are copied before being modified so resolved values only
+// show up in the spec sent to the cell, never on the stored pod.
+func resolveFieldRefEnv(spec api.PodSpec, pod *api.Pod, nodeName, podIP string) api.PodSpec {
+	resolveUnits := func(units []api.Unit) []api.Unit {
+		copied := false
+		for i := range units {
+			var resolved []api.EnvVar
+			for j, e := range units[i].Env {
+				if e.ValueFrom == nil || e.ValueFrom.FieldRef == nil || e.Value != "" {
+					continue
+				}
+				val, ok := fieldRefValue(e.ValueFrom.FieldRef.FieldPath, pod, nodeName, podIP)
+				if !ok {
+					// validation rejects unsupported paths, leave the
+					// value empty if one got through anyway
+					klog.Warningf("env var %s of unit %s references unsupported field path %q",
+						e.Name, units[i].Name, e.ValueFrom.FieldRef.FieldPath)
+				}
+				if resolved == nil {
+					resolved = append([]api.EnvVar(nil), units[i].Env...)
+				}
+				resolved[j].Value = val
+				resolved[j].ValueFrom = nil
+			}
+			if resolved != nil {
+				if !copied {
+					units = append([]api.Unit(nil), units...)
+					copied = true
+				}
+				units[i].Env = resolved
+			}
+		}
+		return units
+	}
+	spec.InitUnits = resolveUnits(spec.InitUnits)
+	spec.Units = resolveUnits(spec.Units)
+	return spec
+}
+
+// volumeMountFor returns the first mount of the named volume across
+// the pod's units. Itzo deploys one package per volume, so when units
+// mount the same volume with different options only the first mount's
+// subPath and readOnly settings are honored.
+func volumeMountFor(pod *api.Pod, volName string) *api.VolumeMount {
+	allUnits := [][]api.Unit{pod.Spec.InitUnits, pod.Spec.Units}
+	for _, units := range allUnits {
+		for i := range units {
+			for j := range units[i].VolumeMounts {
+				if units[i].VolumeMounts[j].Name == volName {
+					return &units[i].VolumeMounts[j]
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// applyVolumeMountOptions narrows a volume's package to the subPath
+// selected by the pod's mounts and strips the write bits when the
+// mount is read-only. The whole package is laid down at the mount
+// point on the cell, so subPath selection has to happen here, while
+// the package is built. The read-only modes end up in the tar headers
+// of the deploy package.
+func applyVolumeMountOptions(files map[string]packageFile, pod *api.Pod, volName string) map[string]packageFile {
+	mount := volumeMountFor(pod, volName)
+	if mount == nil {
+		return files
+	}
+	if mount.SubPath != "" {
+		prefix := mount.SubPath + "/"
+		selected := make(map[string]packageFile)
+		for p, f := range files {
+			if p == mount.SubPath {
+				selected[filepath.Base(p)] = f
+			} else if strings.HasPrefix(p, prefix) {
+				selected[strings.TrimPrefix(p, prefix)] = f
+			}
+		}
+		files = selected
+	}
+	if mount.ReadOnly {
+		for p, f := range files {
+			f.mode = f.mode &^ 0222
+			files[p] = f
+		}
+	}
+	return files
+}
+
+// volumeFileGid returns the group that should own deployed volume files
+// so they remain readable by pod processes started with supplemental
+// groups. FSGroup wins when set, otherwise the first supplemental group
+// keeps ownership for pods predating FSGroup.
+func volumeFileGid(pod *api.Pod) int64 {
+	sc := pod.Spec.SecurityContext
+	if sc == nil {
+		return 0
+	}
+	if sc.FSGroup != nil {
+		return *sc.FSGroup
+	}
+	if len(sc.SupplementalGroups) > 0 {
+		return sc.SupplementalGroups[0]
+	}
+	return 0
+}
+
+// applyFsGroup stamps the volume group owner on the package files.
+// With the OnRootMismatch policy, files already owned by the group are
+// left untouched, the way the kubelet skips the recursive chown when
+// the volume root already matches.
+func applyFsGroup(files map[string]packageFile, pod *api.Pod) {
+	gid := volumeFileGid(pod)
+	if gid == 0 {
+		return
+	}
+	onRootMismatch := false
+	if sc := pod.Spec.SecurityContext; sc != nil && sc.FSGroupChangePolicy != nil {
+		onRootMismatch = *sc.FSGroupChangePolicy == api.FSGroupChangeOnRootMismatch
+	}
+	for path, file := range files {
+		if onRootMismatch && file.gid == gid {
+			continue
+		}
+		file.gid = gid
+		files[path] = file
+	}
+}
+
+// expectedHostPathKind maps a requested HostPathType to the path kind
+// the cell agent should report. The OrCreate variants expect the same
+// kind as their strict counterparts when the path already exists.
+func expectedHostPathKind(hpType api.HostPathType) string {
+	switch hpType {
+	case api.HostPathDirectory, api.HostPathDirectoryOrCreate:
+		return string(api.HostPathDirectory)
+	case api.HostPathFile, api.HostPathFileOrCreate:
+		return string(api.HostPathFile)
+	default:
+		return string(hpType)
+	}
+}
+
+// validateHostPathVolumes checks the pod's hostPath volumes against
+// what the cell reports at each path. A strict type (Directory, File,
+// Socket, ...) requires the path to exist with that kind; the OrCreate
+// variants only require a matching kind when something already exists
+// there, the agent creates the rest.
+func validateHostPathVolumes(pod *api.Pod, client nodeclient.NodeClient) error {
+	for _, vol := range pod.Spec.Volumes {
+		if vol.HostPath == nil {
+			continue
+		}
+		hpType := api.HostPathUnset
+		if vol.HostPath.Type != nil {
+			hpType = *vol.HostPath.Type
+		}
+		if hpType == api.HostPathUnset {
+			continue
+		}
+		reply, err := client.StatPath(vol.HostPath.Path)
+		if err != nil {
+			return util.WrapErrorWithCategory(util.CategoryTransient, err,
+				"error checking host path %s for volume %s", vol.HostPath.Path, vol.Name)
+		}
+		if !reply.Exists {
+			if hpType == api.HostPathDirectoryOrCreate ||
+				hpType == api.HostPathFileOrCreate {
+				continue
+			}
+			return fmt.Errorf("volume %s requires a %s at host path %s but nothing exists there",
+				vol.Name, hpType, vol.HostPath.Path)
+		}
+		if want := expectedHostPathKind(hpType); reply.Kind != want {
+			return fmt.Errorf("volume %s requires a %s at host path %s but the node reports a %s",
+				vol.Name, want, vol.HostPath.Path, reply.Kind)
+		}
+	}
+	return nil
+}
+
+// applyHostPathReadOnlyHints marks a hostPath volume read-only for the
+// cell agent when every unit mounting it does so read-only. The
+// volumes slice and sources are copied so the pod in the registry is
+// left untouched.
+func applyHostPathReadOnlyHints(spec api.PodSpec) api.PodSpec {
+	allUnits := append(spec.InitUnits[:len(spec.InitUnits):len(spec.InitUnits)], spec.Units...)
+	var vols []api.Volume
+	for i, vol := range spec.Volumes {
+		if vol.HostPath == nil {
+			continue
+		}
+		mounted := false
+		readOnly := true
+		for _, unit := range allUnits {
+			for _, mount := range unit.VolumeMounts {
+				if mount.Name != vol.Name {
+					continue
+				}
+				mounted = true
+				readOnly = readOnly && mount.ReadOnly
+			}
+		}
+		if !mounted || !readOnly {
+			continue
+		}
+		if vols == nil {
+			vols = make([]api.Volume, len(spec.Volumes))
+			copy(vols, spec.Volumes)
+		}
+		hostPath := *vol.HostPath
+		hostPath.ReadOnly = true
+		vols[i].HostPath = &hostPath
+	}
+	if vols != nil {
+		spec.Volumes = vols
+	}
+	return spec
+}
+
+func deployPodVolumes(ctx context.Context, pod *api.Pod, node *api.Node, rm *manager.ResourceManager, nodeClientFactory nodeclient.ItzoClientFactoryer, maxSize int64, cache *volumeDeployCache) error {
 	client := nodeClientFactory.GetClient(node.Status.Addresses)
 	for _, vol := range pod.Spec.Volumes {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		var (
 			packageFiles map[string]packageFile
 			err          error
@@ -268,26 +978,51 @@ func deployPodVolumes(pod *api.Pod, node *api.Node, rm *manager.ResourceManager,
 				return util.WrapError(err, "couldn't get secret payload %v/%v", pod.Namespace, vol.Secret.SecretName)
 			}
 		} else if vol.Projected != nil {
-			packageFiles, err = getProjectedVolumeFiles(pod.Namespace, vol.Projected, rm)
+			packageFiles, err = getProjectedVolumeFiles(pod, vol.Projected, rm)
 			if err != nil {
 				return err
 			}
 		}
+		// Kubernetes never updates subPath-mounted configmaps and
+		// secrets in a running container; keep the content from the
+		// initial deploy.
+		if mount := volumeMountFor(pod, vol.Name); mount != nil && mount.SubPath != "" &&
+			cache.deployedOnce(pod.Name, node.Name, vol.Name) {
+			klog.V(4).Infof("volume %s of pod %s is mounted via subPath, not updating it",
+				vol.Name, pod.Name)
+			continue
+		}
+		packageFiles = applyVolumeMountOptions(packageFiles, pod, vol.Name)
+		applyFsGroup(packageFiles, pod)
+		if maxSize > 0 {
+			if size := packageFilesSize(packageFiles); size > maxSize {
+				return util.WrapErrorWithCategory(util.CategoryInvalid,
+					fmt.Errorf("volume payload is %d bytes, the configured cells.maxVolumeDeploySize allows %d", size, maxSize),
+					"volume %s of pod %s is too large to deploy", vol.Name, pod.Name)
+			}
+		}
+		hash := packageFilesHash(packageFiles)
+		if cache != nil && cache.unchanged(pod.Name, node.Name, vol.Name, hash) {
+			klog.V(4).Infof("volume %s of pod %s is unchanged on %s, skipping deploy",
+				vol.Name, pod.Name, node.Name)
+			continue
+		}
 		// Deploy empty packages as well since they might be
 		// referenced in a container (but will have no data)
-		payload, err := makeDeployPackage(packageFiles)
+		payload := streamDeployPackage(packageFiles)
+		err = client.Deploy(ctx, pod.Name, vol.Name, bufio.NewReader(payload))
+		payload.Close()
 		if err != nil {
-			return util.WrapError(err, "error creating tar.gz package %s for %s", vol.Name, pod.Name)
+			return util.WrapErrorWithCategory(util.CategoryTransient, err, "error deploying package %s to %s", vol.Name, pod.Name)
 		}
-		err = client.Deploy(pod.Name, vol.Name, bufio.NewReader(payload))
-		if err != nil {
-			return util.WrapError(err, "error deploying package %s to %s", vol.Name, pod.Name)
+		if cache != nil {
+			cache.remember(pod.Name, node.Name, vol.Name, hash)
 		}
 	}
 	return nil
 }
 
-func deployNetworkAgentToken(cfg *clientcmdapi.Config, pod *api.Pod, node *api.Node, nodeClientFactory nodeclient.ItzoClientFactoryer) error {
+func deployNetworkAgentToken(ctx context.Context, cfg *clientcmdapi.Config, pod *api.Pod, node *api.Node, nodeClientFactory nodeclient.ItzoClientFactoryer) error {
 	if cfg == nil {
 		klog.V(4).Infof("no network agent kubeconfig provided for %s", pod.Name)
 		return nil
@@ -309,28 +1044,66 @@ func deployNetworkAgentToken(cfg *clientcmdapi.Config, pod *api.Pod, node *api.N
 			"error creating kubeconfig package for %s", pod.Name)
 	}
 	client := nodeClientFactory.GetClient(node.Status.Addresses)
-	err = client.Deploy(pod.Name, "kubeconfig", bufio.NewReader(payload))
+	err = client.Deploy(ctx, pod.Name, "kubeconfig", bufio.NewReader(payload))
 	if err != nil {
-		return util.WrapError(err,
+		return util.WrapErrorWithCategory(util.CategoryTransient, err,
 			"error deploying kubeconfig package for %s", pod.Name)
 	}
 	return nil
 }
 
-func deployResolvconf(pod *api.Pod, node *api.Node, dnsConfigurer *dns.Configurer, nodeClientFactory nodeclient.ItzoClientFactoryer) error {
-	if dnsConfigurer == nil {
-		return fmt.Errorf("no DNS configurer")
+// podDNSNoneConfig builds the resolver config for a pod with DNSPolicy
+// "None" straight from its DNSConfig. The cluster DNS settings don't
+// apply with that policy, so the cluster configurer is never consulted
+// and nothing from it can leak into the pod's resolv.conf.
+func podDNSNoneConfig(pod *api.Pod) (*runtimeapi.DNSConfig, error) {
+	dnsConfig := pod.Spec.DNSConfig
+	if dnsConfig == nil || len(dnsConfig.Nameservers) == 0 {
+		return nil, util.WrapErrorWithCategory(
+			util.CategoryInvalid,
+			fmt.Errorf("no nameservers specified in dnsConfig"),
+			"pod %s has DNSPolicy %q", pod.Name, api.DNSNone)
 	}
-	client := nodeClientFactory.GetClient(node.Status.Addresses)
-	k8spod, err := milpaToK8sPod("", "", pod)
-	if err != nil {
-		return util.WrapError(err, "converting pod to generate DNS config")
+	dnsconf := &runtimeapi.DNSConfig{
+		Servers:  dnsConfig.Nameservers,
+		Searches: dnsConfig.Searches,
 	}
-	dnsconf, err := dnsConfigurer.GetPodDNS(k8spod)
-	if err != nil {
-		return util.WrapError(err, "creating pod DNS config")
+	for _, opt := range dnsConfig.Options {
+		option := opt.Name
+		if opt.Value != nil && *opt.Value != "" {
+			option = option + ":" + *opt.Value
+		}
+		dnsconf.Options = append(dnsconf.Options, option)
 	}
-	data, err := createResolvconf(pod.Name, dnsconf)
+	return dnsconf, nil
+}
+
+func deployResolvconf(ctx context.Context, pod *api.Pod, node *api.Node, dnsConfigurer *dns.Configurer, nodeClientFactory nodeclient.ItzoClientFactoryer) error {
+	var (
+		dnsconf *runtimeapi.DNSConfig
+		err     error
+	)
+	pod = podWithDefaultDNSPolicy(pod)
+	if pod.Spec.DNSPolicy == api.DNSNone {
+		dnsconf, err = podDNSNoneConfig(pod)
+		if err != nil {
+			return err
+		}
+	} else {
+		if dnsConfigurer == nil {
+			return fmt.Errorf("no DNS configurer")
+		}
+		k8spod, err := milpaToK8sPod("", "", pod)
+		if err != nil {
+			return util.WrapError(err, "converting pod to generate DNS config")
+		}
+		dnsconf, err = dnsConfigurer.GetPodDNS(k8spod)
+		if err != nil {
+			return util.WrapError(err, "creating pod DNS config")
+		}
+	}
+	client := nodeClientFactory.GetClient(node.Status.Addresses)
+	data, err := createResolvconf(pod.Name, dnsconf, pod.Spec.DNSConfig)
 	if err != nil {
 		return util.WrapError(err, "creating pod resolv.conf")
 	}
@@ -343,15 +1116,86 @@ func deployResolvconf(pod *api.Pod, node *api.Node, dnsConfigurer *dns.Configure
 	if err != nil {
 		return util.WrapError(err, "creating pod resolv.conf package")
 	}
-	err = client.Deploy(pod.Name, resolvconfVolumeName, bufio.NewReader(payload))
+	err = client.Deploy(ctx, pod.Name, resolvconfVolumeName, bufio.NewReader(payload))
 	if err != nil {
-		return util.WrapError(
+		return util.WrapErrorWithCategory(util.CategoryTransient,
 			err, "error deploying resolv.conf package to %s", pod.Name)
 	}
 	return nil
 }
 
-func createResolvconf(podName string, dnsconf *runtimeapi.DNSConfig) ([]byte, error) {
+const dnsNdotsOption = "ndots"
+
+// defaultDNSNdots and maxDNSSearchDomains come from
+// cells.defaultDNSNdots and cells.maxDNSSearchDomains in
+// provider.yaml, see CellsConfig.
+var (
+	defaultDNSNdots     int
+	maxDNSSearchDomains int
+)
+
+func setDNSDefaults(ndots, maxSearchDomains int) {
+	defaultDNSNdots = ndots
+	maxDNSSearchDomains = maxSearchDomains
+}
+
+// defaultDNSPolicy comes from cells.defaultDNSPolicy in
+// provider.yaml. Empty keeps the implicit default, which treats pods
+// without a policy as ClusterFirst.
+var defaultDNSPolicy api.DNSPolicy
+
+func setDefaultDNSPolicy(policy api.DNSPolicy) {
+	defaultDNSPolicy = policy
+}
+
+// podWithDefaultDNSPolicy fills in the controller-wide default DNS
+// policy on a copy of the pod when the pod doesn't specify one.
+func podWithDefaultDNSPolicy(pod *api.Pod) *api.Pod {
+	if pod.Spec.DNSPolicy != "" || defaultDNSPolicy == "" {
+		return pod
+	}
+	p := pod.DeepCopy()
+	p.Spec.DNSPolicy = defaultDNSPolicy
+	return p
+}
+
+// applyDNSDefaults lowers the ndots resolver option and trims the
+// search domains handed to us by cluster DNS according to the
+// controller's DNS defaults. Anything the pod asked for explicitly in
+// its dnsConfig wins over the controller defaults.
+func applyDNSDefaults(dnsconf *runtimeapi.DNSConfig, podDNSConfig *api.PodDNSConfig) {
+	podSetsNdots := false
+	podSetsSearches := false
+	if podDNSConfig != nil {
+		podSetsSearches = len(podDNSConfig.Searches) > 0
+		for _, opt := range podDNSConfig.Options {
+			if opt.Name == dnsNdotsOption {
+				podSetsNdots = true
+			}
+		}
+	}
+	if defaultDNSNdots > 0 && !podSetsNdots {
+		ndots := fmt.Sprintf("%s:%d", dnsNdotsOption, defaultDNSNdots)
+		replaced := false
+		for i, opt := range dnsconf.Options {
+			if opt == dnsNdotsOption ||
+				strings.HasPrefix(opt, dnsNdotsOption+":") {
+				dnsconf.Options[i] = ndots
+				replaced = true
+			}
+		}
+		if !replaced {
+			dnsconf.Options = append(dnsconf.Options, ndots)
+		}
+	}
+	if maxDNSSearchDomains > 0 && !podSetsSearches &&
+		len(dnsconf.Searches) > maxDNSSearchDomains {
+		dnsconf.Searches = dnsconf.Searches[:maxDNSSearchDomains]
+	}
+}
+
+func createResolvconf(podName string, dnsconf *runtimeapi.DNSConfig, podDNSConfig *api.PodDNSConfig) ([]byte, error) {
+	applyDNSDefaults(dnsconf, podDNSConfig)
 	buf := bytes.Buffer{}
 	for _, srv := range dnsconf.Servers {
 		_, err := buf.WriteString(fmt.Sprintf("nameserver %s\n", srv))
@@ -379,14 +1223,14 @@ func createResolvconf(podName string, dnsconf *runtimeapi.DNSConfig) ([]byte, er
 	return buf.Bytes(), nil
 }
 
-func deployEtcHosts(pod *api.Pod, node *api.Node, dnsConfigurer *dns.Configurer, nodeClientFactory nodeclient.ItzoClientFactoryer) error {
+func deployEtcHosts(ctx context.Context, pod *api.Pod, node *api.Node, dnsConfigurer *dns.Configurer, nodeClientFactory nodeclient.ItzoClientFactoryer) error {
 	if dnsConfigurer == nil {
 		return fmt.Errorf("no DNS configurer")
 	}
 	client := nodeClientFactory.GetClient(node.Status.Addresses)
 	namespace, podName := util.SplitNamespaceAndName(pod.Name)
 	podIPs := []string{api.GetPodIP(node.Status.Addresses)}
-	useHostNetwork := api.IsHostNetwork(pod.Spec.SecurityContext)
+	useHostNetwork := api.IsHostNetwork(&pod.Spec)
 	data, err := util.CreateEtcHosts(
 		dnsConfigurer,
 		podName,
@@ -408,10 +1252,68 @@ func deployEtcHosts(pod *api.Pod, node *api.Node, dnsConfigurer *dns.Configurer,
 	if err != nil {
 		return util.WrapError(err, "creating pod /etc/hosts package")
 	}
-	err = client.Deploy(pod.Name, etchostsVolumeName, bufio.NewReader(payload))
+	err = client.Deploy(ctx, pod.Name, etchostsVolumeName, bufio.NewReader(payload))
 	if err != nil {
-		return util.WrapError(
+		return util.WrapErrorWithCategory(util.CategoryTransient,
 			err, "error deploying /etc/hosts package to %s", pod.Name)
 	}
 	return nil
 }
+
+const (
+	// Where the CA bundle from cells.trustedCAFile lands in pods. The
+	// default path sits next to the bundle the image ships so we don't
+	// clobber it; cells.replaceImageCABundle switches to mounting over
+	// the standard bundle location.
+	caBundleExtraPath   = "/etc/ssl/certs/kip-ca-bundle.pem"
+	caBundleReplacePath = "/etc/ssl/certs/ca-certificates.crt"
+)
+
+// CA bundle read from cells.trustedCAFile, set once at startup and
+// mounted into every pod that doesn't opt out.
+var (
+	trustedCABundle      []byte
+	replaceImageCABundle bool
+)
+
+func setTrustedCABundle(pemData []byte, replaceImageBundle bool) {
+	trustedCABundle = pemData
+	replaceImageCABundle = replaceImageBundle
+}
+
+func caBundleMountPath() string {
+	if replaceImageCABundle {
+		return caBundleReplacePath
+	}
+	return caBundleExtraPath
+}
+
+func podWantsCABundle(pod *api.Pod) bool {
+	if len(trustedCABundle) == 0 {
+		return false
+	}
+	skip, err := strconv.ParseBool(pod.Annotations[annotations.PodSkipCABundle])
+	return err != nil || !skip
+}
+
+func deployTrustedCerts(ctx context.Context, pod *api.Pod, node *api.Node, nodeClientFactory nodeclient.ItzoClientFactoryer) error {
+	if !podWantsCABundle(pod) {
+		return nil
+	}
+	payload, err := makeDeployPackage(map[string]packageFile{
+		caBundleMountPath(): packageFile{
+			data: trustedCABundle,
+			mode: 0644,
+		},
+	})
+	if err != nil {
+		return util.WrapError(err, "creating pod CA bundle package")
+	}
+	client := nodeClientFactory.GetClient(node.Status.Addresses)
+	err = client.Deploy(ctx, pod.Name, cacertsVolumeName, bufio.NewReader(payload))
+	if err != nil {
+		return util.WrapErrorWithCategory(util.CategoryTransient,
+			err, "error deploying CA bundle package to %s", pod.Name)
+	}
+	return nil
+}
@@ -20,6 +20,7 @@ import (
 	"archive/tar"
 	"bufio"
 	"compress/gzip"
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -30,6 +31,7 @@ import (
 	"testing"
 
 	"github.com/elotl/kip/pkg/api"
+	"github.com/elotl/kip/pkg/api/annotations"
 	"github.com/elotl/kip/pkg/nodeclient"
 	"github.com/elotl/kip/pkg/util/k8s/eventrecorder"
 	"github.com/elotl/node-cli/manager"
@@ -39,6 +41,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	corev1listers "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/cache"
+	runtimeapi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
 )
 
 func tarPkgToPackageFile(tarfile io.Reader) (map[string]packageFile, error) {
@@ -74,6 +77,7 @@ func tarPkgToPackageFile(tarfile io.Reader) (map[string]packageFile, error) {
 			tfContents[header.Name[7:]] = packageFile{
 				data: data,
 				mode: int32(header.Mode),
+				gid:  int64(header.Gid),
 			}
 		}
 	}
@@ -84,6 +88,7 @@ func TestMakeDeployPackage(t *testing.T) {
 	contents := map[string]packageFile{
 		"file1":         packageFile{data: []byte("file1"), mode: 0777},
 		"path/to/file2": {data: []byte("file2"), mode: 0400},
+		"group/file3":   {data: []byte("file3"), mode: 0640, gid: 2000},
 	}
 	buf, err := makeDeployPackage(contents)
 	assert.NoError(t, err)
@@ -92,6 +97,102 @@ func TestMakeDeployPackage(t *testing.T) {
 	assert.Equal(t, contents, tfContents)
 }
 
+func TestStreamDeployPackage(t *testing.T) {
+	contents := map[string]packageFile{
+		"file1":         packageFile{data: []byte("file1"), mode: 0777},
+		"path/to/file2": {data: []byte("file2"), mode: 0400},
+		"group/file3":   {data: []byte("file3"), mode: 0640, gid: 2000},
+	}
+	payload := streamDeployPackage(contents)
+	defer payload.Close()
+	tfContents, err := tarPkgToPackageFile(bufio.NewReader(payload))
+	assert.NoError(t, err)
+	assert.Equal(t, contents, tfContents)
+}
+
+func TestApplyFsGroup(t *testing.T) {
+	fsGroup := int64(3000)
+	pod := api.GetFakePod()
+	pod.Spec.SecurityContext = &api.PodSecurityContext{
+		SupplementalGroups: []int64{2000},
+		FSGroup:            &fsGroup,
+	}
+	files := map[string]packageFile{
+		"config": {data: []byte("config"), mode: 0644},
+		"token":  {data: []byte("token"), mode: 0640},
+	}
+	applyFsGroup(files, pod)
+	buf, err := makeDeployPackage(files)
+	assert.NoError(t, err)
+	tfContents, err := tarPkgToPackageFile(bufio.NewReader(buf))
+	assert.NoError(t, err)
+	assert.Len(t, tfContents, 2)
+	for path, file := range tfContents {
+		// FSGroup wins over the supplemental group.
+		assert.Equal(t, int64(3000), file.gid, "file %s", path)
+	}
+}
+
+func TestApplyFsGroupSupplementalFallback(t *testing.T) {
+	pod := api.GetFakePod()
+	pod.Spec.SecurityContext = &api.PodSecurityContext{
+		SupplementalGroups: []int64{2000},
+	}
+	files := map[string]packageFile{
+		"config": {data: []byte("config"), mode: 0644},
+	}
+	applyFsGroup(files, pod)
+	assert.Equal(t, int64(2000), files["config"].gid)
+}
+
+func TestApplyFsGroupOnRootMismatch(t *testing.T) {
+	fsGroup := int64(3000)
+	policy := api.FSGroupChangeOnRootMismatch
+	pod := api.GetFakePod()
+	pod.Spec.SecurityContext = &api.PodSecurityContext{
+		FSGroup:             &fsGroup,
+		FSGroupChangePolicy: &policy,
+	}
+	files := map[string]packageFile{
+		"matching": {data: []byte("matching"), mode: 0644, gid: 3000},
+		"other":    {data: []byte("other"), mode: 0644, gid: 0},
+	}
+	applyFsGroup(files, pod)
+	assert.Equal(t, int64(3000), files["matching"].gid)
+	assert.Equal(t, int64(3000), files["other"].gid)
+}
+
+func TestStreamDeployPackageLargeVolume(t *testing.T) {
+	// A multi-hundred-MB binary volume streams through the pipe
+	// instead of being buffered, the reader sees every byte.
+	size := 300 * 1024 * 1024
+	contents := map[string]packageFile{
+		"blob.bin": {data: make([]byte, size), mode: 0644},
+	}
+	payload := streamDeployPackage(contents)
+	defer payload.Close()
+	gzr, err := gzip.NewReader(payload)
+	assert.NoError(t, err)
+	tr := tar.NewReader(gzr)
+	header, err := tr.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(size), header.Size)
+	n, err := io.Copy(ioutil.Discard, tr)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(size), n)
+	_, err = tr.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestPackageFilesSize(t *testing.T) {
+	contents := map[string]packageFile{
+		"file1": {data: []byte("12345"), mode: 0644},
+		"file2": {data: []byte("123"), mode: 0644},
+	}
+	assert.Equal(t, int64(8), packageFilesSize(contents))
+	assert.Equal(t, int64(0), packageFilesSize(nil))
+}
+
 func TestGetConfigMapFiles(t *testing.T) {
 	trueVal := true
 	readonlyVal := int32(0444)
@@ -132,15 +233,15 @@ func TestGetConfigMapFiles(t *testing.T) {
 			expectedFiles: map[string]packageFile{
 				"foo": packageFile{
 					data: []byte("foocontent"),
-					mode: defaultVolumeFileMode,
+					mode: api.ConfigMapVolumeSourceDefaultMode,
 				},
 				"bar": packageFile{
 					data: []byte("barcontent"),
-					mode: defaultVolumeFileMode,
+					mode: api.ConfigMapVolumeSourceDefaultMode,
 				},
 				"zed": packageFile{
 					data: []byte("zedstuff"),
-					mode: defaultVolumeFileMode,
+					mode: api.ConfigMapVolumeSourceDefaultMode,
 				},
 			},
 		},
@@ -185,8 +286,525 @@ func TestGetConfigMapFiles(t *testing.T) {
 	}
 }
 
+func TestGetSecretFiles(t *testing.T) {
+	trueVal := true
+	binary := []byte{0x00, 0x1f, 0x8b, 0xff, 0xfe, 0x42}
+	simpleSecret := v1.Secret{
+		Data: map[string][]byte{
+			"cert.der": binary,
+			"both":     []byte("from-data"),
+		},
+		StringData: map[string]string{
+			"token": "string-token",
+			"both":  "from-string-data",
+		},
+	}
+
+	tests := []struct {
+		name          string
+		vol           api.SecretVolumeSource
+		sec           v1.Secret
+		isErr         bool
+		expectedFiles map[string]packageFile
+	}{
+		{
+			name: "data bytes are written verbatim, never decoded",
+			vol: api.SecretVolumeSource{
+				Items: []api.KeyToPath{
+					{Key: "cert.der"},
+				},
+			},
+			sec:   simpleSecret,
+			isErr: false,
+			expectedFiles: map[string]packageFile{
+				"cert.der": packageFile{
+					data: binary,
+					mode: api.SecretVolumeSourceDefaultMode,
+				},
+			},
+		},
+		{
+			name:  "no items gets data and stringData, data wins",
+			vol:   api.SecretVolumeSource{},
+			sec:   simpleSecret,
+			isErr: false,
+			expectedFiles: map[string]packageFile{
+				"cert.der": packageFile{
+					data: binary,
+					mode: api.SecretVolumeSourceDefaultMode,
+				},
+				"both": packageFile{
+					data: []byte("from-data"),
+					mode: api.SecretVolumeSourceDefaultMode,
+				},
+				"token": packageFile{
+					data: []byte("string-token"),
+					mode: api.SecretVolumeSourceDefaultMode,
+				},
+			},
+		},
+		{
+			name: "missing key errors",
+			vol: api.SecretVolumeSource{
+				Items: []api.KeyToPath{
+					{Key: "nope"},
+				},
+			},
+			sec:   simpleSecret,
+			isErr: true,
+		},
+		{
+			name: "missing key is skipped when optional",
+			vol: api.SecretVolumeSource{
+				Optional: &trueVal,
+				Items: []api.KeyToPath{
+					{Key: "nope"},
+				},
+			},
+			sec:           simpleSecret,
+			isErr:         false,
+			expectedFiles: map[string]packageFile{},
+		},
+	}
+	for _, tc := range tests {
+		files, err := getSecretFiles(&tc.vol, &tc.sec)
+		if tc.isErr {
+			assert.Error(t, err, tc.name)
+		} else {
+			assert.NoError(t, err, tc.name)
+			assert.Equal(t, tc.expectedFiles, files, tc.name)
+		}
+	}
+}
+
+func TestValidateHostPathVolumes(t *testing.T) {
+	hostPathType := func(t api.HostPathType) *api.HostPathType {
+		return &t
+	}
+	makePod := func(hpType *api.HostPathType) *api.Pod {
+		pod := api.GetFakePod()
+		pod.Spec.Volumes = []api.Volume{
+			{
+				Name: "host-volume",
+				VolumeSource: api.VolumeSource{
+					HostPath: &api.HostPathVolumeSource{
+						Path: "/var/run/thing",
+						Type: hpType,
+					},
+				},
+			},
+		}
+		return pod
+	}
+	tests := []struct {
+		name   string
+		hpType *api.HostPathType
+		exists bool
+		kind   string
+		isErr  bool
+	}{
+		{
+			name:   "unset type skips the check",
+			hpType: nil,
+			exists: false,
+			isErr:  false,
+		},
+		{
+			name:   "directory matches",
+			hpType: hostPathType(api.HostPathDirectory),
+			exists: true,
+			kind:   "Directory",
+			isErr:  false,
+		},
+		{
+			name:   "directory over a file mismatches",
+			hpType: hostPathType(api.HostPathDirectory),
+			exists: true,
+			kind:   "File",
+			isErr:  true,
+		},
+		{
+			name:   "missing strict directory fails",
+			hpType: hostPathType(api.HostPathDirectory),
+			exists: false,
+			isErr:  true,
+		},
+		{
+			name:   "missing DirectoryOrCreate is fine, the agent creates it",
+			hpType: hostPathType(api.HostPathDirectoryOrCreate),
+			exists: false,
+			isErr:  false,
+		},
+		{
+			name:   "existing DirectoryOrCreate still needs a directory",
+			hpType: hostPathType(api.HostPathDirectoryOrCreate),
+			exists: true,
+			kind:   "File",
+			isErr:  true,
+		},
+		{
+			name:   "file matches",
+			hpType: hostPathType(api.HostPathFile),
+			exists: true,
+			kind:   "File",
+			isErr:  false,
+		},
+		{
+			name:   "missing FileOrCreate is fine",
+			hpType: hostPathType(api.HostPathFileOrCreate),
+			exists: false,
+			isErr:  false,
+		},
+		{
+			name:   "socket matches",
+			hpType: hostPathType(api.HostPathSocket),
+			exists: true,
+			kind:   "Socket",
+			isErr:  false,
+		},
+		{
+			name:   "socket over a directory mismatches",
+			hpType: hostPathType(api.HostPathSocket),
+			exists: true,
+			kind:   "Directory",
+			isErr:  true,
+		},
+		{
+			name:   "char device matches",
+			hpType: hostPathType(api.HostPathCharDev),
+			exists: true,
+			kind:   "CharDevice",
+			isErr:  false,
+		},
+		{
+			name:   "block device matches",
+			hpType: hostPathType(api.HostPathBlockDev),
+			exists: true,
+			kind:   "BlockDevice",
+			isErr:  false,
+		},
+	}
+	for _, tc := range tests {
+		nc := nodeclient.NewMockItzoClientFactory()
+		nc.Stat = func(path string) (*api.StatPathReply, error) {
+			assert.Equal(t, "/var/run/thing", path, tc.name)
+			return &api.StatPathReply{Exists: tc.exists, Kind: tc.kind}, nil
+		}
+		err := validateHostPathVolumes(makePod(tc.hpType), nc)
+		if tc.isErr {
+			assert.Error(t, err, tc.name)
+		} else {
+			assert.NoError(t, err, tc.name)
+		}
+	}
+}
+
+func TestApplyHostPathReadOnlyHints(t *testing.T) {
+	makeSpec := func() api.PodSpec {
+		return api.PodSpec{
+			Volumes: []api.Volume{
+				{
+					Name: "host-volume",
+					VolumeSource: api.VolumeSource{
+						HostPath: &api.HostPathVolumeSource{
+							Path: "/var/log",
+						},
+					},
+				},
+			},
+			Units: []api.Unit{
+				{
+					Name: "unit-1",
+					VolumeMounts: []api.VolumeMount{
+						{Name: "host-volume", MountPath: "/logs", ReadOnly: true},
+					},
+				},
+				{
+					Name: "unit-2",
+					VolumeMounts: []api.VolumeMount{
+						{Name: "host-volume", MountPath: "/logs", ReadOnly: true},
+					},
+				},
+			},
+		}
+	}
+	// Every mount read-only sets the hint; the original spec is left
+	// untouched.
+	spec := makeSpec()
+	out := applyHostPathReadOnlyHints(spec)
+	assert.True(t, out.Volumes[0].HostPath.ReadOnly)
+	assert.False(t, spec.Volumes[0].HostPath.ReadOnly)
+	// One writable mount keeps the volume writable.
+	spec = makeSpec()
+	spec.Units[1].VolumeMounts[0].ReadOnly = false
+	out = applyHostPathReadOnlyHints(spec)
+	assert.False(t, out.Volumes[0].HostPath.ReadOnly)
+	// An unmounted volume is never marked read-only.
+	spec = makeSpec()
+	spec.Units = nil
+	out = applyHostPathReadOnlyHints(spec)
+	assert.False(t, out.Volumes[0].HostPath.ReadOnly)
+}
+
+func TestParseEnvFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		expected []api.EnvVar
+		isErr    bool
+	}{
+		{
+			name: "comments and blank lines skipped",
+			data: "# a comment\n\nFOO=bar\nBAZ=with=equals\n",
+			expected: []api.EnvVar{
+				{Name: "FOO", Value: "bar"},
+				{Name: "BAZ", Value: "with=equals"},
+			},
+		},
+		{
+			name: "CRLF line endings",
+			data: "FOO=bar\r\nBAZ=zed\r\n",
+			expected: []api.EnvVar{
+				{Name: "FOO", Value: "bar"},
+				{Name: "BAZ", Value: "zed"},
+			},
+		},
+		{
+			name:  "missing equals sign",
+			data:  "FOO\n",
+			isErr: true,
+		},
+		{
+			name:  "invalid variable name",
+			data:  "1FOO=bar\n",
+			isErr: true,
+		},
+	}
+	for _, tc := range tests {
+		vars, err := parseEnvFile([]byte(tc.data))
+		if tc.isErr {
+			assert.Error(t, err, tc.name)
+		} else {
+			assert.NoError(t, err, tc.name)
+			assert.Equal(t, tc.expected, vars, tc.name)
+		}
+	}
+}
+
+func TestMergeUnitEnv(t *testing.T) {
+	fileVars := []api.EnvVar{
+		{Name: "FOO", Value: "from-file"},
+		{Name: "BAR", Value: "from-file"},
+	}
+	explicit := []api.EnvVar{
+		{Name: "FOO", Value: "explicit"},
+	}
+	merged := mergeUnitEnv(fileVars, explicit)
+	assert.Equal(t, []api.EnvVar{
+		{Name: "BAR", Value: "from-file"},
+		{Name: "FOO", Value: "explicit"},
+	}, merged)
+}
+
+func TestResolveUnitEnvFiles(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	assert.Nil(t, indexer.Add(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "dotenv",
+			Namespace: "default",
+		},
+		Data: map[string]string{
+			"app.env": "FOO=from-file\nBAR=from-file\n",
+		},
+	}))
+	configMapLister := corev1listers.NewConfigMapLister(indexer)
+	secretLister := corev1listers.NewSecretLister(indexer)
+	rm, err := manager.NewResourceManager(nil, secretLister, configMapLister, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec := api.PodSpec{
+		Units: []api.Unit{
+			{
+				Name: "unit",
+				Env:  []api.EnvVar{{Name: "FOO", Value: "explicit"}},
+				EnvFileFrom: []api.EnvFileSource{
+					{
+						ConfigMapKey: &api.ConfigMapKeySelector{
+							LocalObjectReference: api.LocalObjectReference{
+								Name: "dotenv",
+							},
+							Key: "app.env",
+						},
+					},
+				},
+			},
+		},
+	}
+	spec, err = resolveUnitEnvFiles("default", spec, rm)
+	assert.NoError(t, err)
+	assert.Equal(t, []api.EnvVar{
+		{Name: "BAR", Value: "from-file"},
+		{Name: "FOO", Value: "explicit"},
+	}, spec.Units[0].Env)
+}
+
+func TestResolveKeyRefEnv(t *testing.T) {
+	trueVal := true
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	assert.Nil(t, indexer.Add(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "settings",
+			Namespace: "default",
+		},
+		Data: map[string]string{
+			"loglevel": "debug",
+		},
+	}))
+	assert.Nil(t, indexer.Add(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "creds",
+			Namespace: "default",
+		},
+		Data: map[string][]byte{
+			"password": []byte("hunter2"),
+		},
+	}))
+	configMapLister := corev1listers.NewConfigMapLister(indexer)
+	secretLister := corev1listers.NewSecretLister(indexer)
+	rm, err := manager.NewResourceManager(nil, secretLister, configMapLister, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	configMapRef := func(name, key string, optional *bool) *api.EnvVarSource {
+		return &api.EnvVarSource{
+			ConfigMapKeyRef: &api.ConfigMapKeySelector{
+				LocalObjectReference: api.LocalObjectReference{Name: name},
+				Key:                  key,
+				Optional:             optional,
+			},
+		}
+	}
+	origEnv := []api.EnvVar{
+		{Name: "LOG_LEVEL", ValueFrom: configMapRef("settings", "loglevel", nil)},
+		{Name: "PASSWORD", ValueFrom: &api.EnvVarSource{
+			SecretKeyRef: &api.SecretKeySelector{
+				LocalObjectReference: api.LocalObjectReference{Name: "creds"},
+				Key:                  "password",
+			},
+		}},
+		{Name: "MISSING", ValueFrom: configMapRef("settings", "no-such-key", &trueVal)},
+		{Name: "LITERAL", Value: "untouched"},
+	}
+	spec := api.PodSpec{
+		Units: []api.Unit{{Name: "unit", Env: origEnv}},
+	}
+	resolved, err := resolveKeyRefEnv("default", spec, rm)
+	assert.NoError(t, err)
+	assert.Equal(t, []api.EnvVar{
+		{Name: "LOG_LEVEL", Value: "debug"},
+		{Name: "PASSWORD", Value: "hunter2"},
+		// the missing optional key drops the env var
+		{Name: "LITERAL", Value: "untouched"},
+	}, resolved.Units[0].Env)
+	// the stored spec keeps the unresolved references
+	assert.Equal(t, origEnv, spec.Units[0].Env)
+
+	// a required key that is absent fails the resolve
+	spec = api.PodSpec{
+		Units: []api.Unit{{
+			Name: "unit",
+			Env: []api.EnvVar{
+				{Name: "MISSING", ValueFrom: configMapRef("settings", "no-such-key", nil)},
+			},
+		}},
+	}
+	_, err = resolveKeyRefEnv("default", spec, rm)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "no-such-key")
+	}
+}
+
+func TestApplyPodDefaultEnv(t *testing.T) {
+	spec := api.PodSpec{
+		Env: []api.EnvVar{
+			{Name: "AWS_REGION", Value: "us-east-1"},
+			{Name: "LOG_LEVEL", Value: "info"},
+		},
+		Units: []api.Unit{
+			{
+				Name: "override",
+				Env:  []api.EnvVar{{Name: "LOG_LEVEL", Value: "debug"}},
+			},
+			{
+				Name: "inherit",
+			},
+		},
+		InitUnits: []api.Unit{
+			{
+				Name: "init",
+			},
+		},
+	}
+	spec = applyPodDefaultEnv(spec)
+	assert.Equal(t, []api.EnvVar{
+		{Name: "AWS_REGION", Value: "us-east-1"},
+		{Name: "LOG_LEVEL", Value: "debug"},
+	}, spec.Units[0].Env)
+	assert.Equal(t, []api.EnvVar{
+		{Name: "AWS_REGION", Value: "us-east-1"},
+		{Name: "LOG_LEVEL", Value: "info"},
+	}, spec.Units[1].Env)
+	assert.Equal(t, spec.Env, spec.InitUnits[0].Env)
+}
+
+func TestResolveFieldRefEnv(t *testing.T) {
+	fieldRef := func(path string) *api.EnvVarSource {
+		return &api.EnvVarSource{
+			FieldRef: &api.ObjectFieldSelector{FieldPath: path},
+		}
+	}
+	pod := api.GetFakePod()
+	pod.Name = "default_mypod"
+	pod.Status.BoundNodeName = "cell-1234"
+	origEnv := []api.EnvVar{
+		{Name: "MY_POD_NAME", ValueFrom: fieldRef("metadata.name")},
+		{Name: "MY_POD_NAMESPACE", ValueFrom: fieldRef("metadata.namespace")},
+		{Name: "MY_NODE_NAME", ValueFrom: fieldRef("spec.nodeName")},
+		{Name: "MY_CELL_NAME", ValueFrom: fieldRef("status.boundNodeName")},
+		{Name: "MY_POD_IP", ValueFrom: fieldRef("status.podIP")},
+		{Name: "EXPLICIT", Value: "untouched", ValueFrom: fieldRef("status.podIP")},
+		{Name: "BOGUS", ValueFrom: fieldRef("status.hostIP")},
+	}
+	pod.Spec.Units[0].Env = origEnv
+	pod.Spec.InitUnits = []api.Unit{
+		{
+			Name: "init",
+			Env:  []api.EnvVar{{Name: "MY_POD_IP", ValueFrom: fieldRef("status.podIP")}},
+		},
+	}
+	spec := resolveFieldRefEnv(pod.Spec, pod, "kip-node", "10.0.0.8")
+	assert.Equal(t, []api.EnvVar{
+		{Name: "MY_POD_NAME", Value: "mypod"},
+		{Name: "MY_POD_NAMESPACE", Value: "default"},
+		{Name: "MY_NODE_NAME", Value: "kip-node"},
+		{Name: "MY_CELL_NAME", Value: "cell-1234"},
+		{Name: "MY_POD_IP", Value: "10.0.0.8"},
+		{Name: "EXPLICIT", Value: "untouched", ValueFrom: fieldRef("status.podIP")},
+		{Name: "BOGUS", Value: ""},
+	}, spec.Units[0].Env)
+	assert.Equal(t, []api.EnvVar{
+		{Name: "MY_POD_IP", Value: "10.0.0.8"},
+	}, spec.InitUnits[0].Env)
+	// the stored pod keeps the unresolved references
+	assert.Equal(t, origEnv, pod.Spec.Units[0].Env)
+}
+
 func TestDeployVolumes(t *testing.T) {
 	trueVal := true
+	secretItemMode := int32(0400)
+	secretSourceMode := int32(0440)
+	projectedDefaultMode := int32(0644)
 	pod := api.GetFakePod()
 	pod.Namespace = "default"
 	testNode := api.GetFakeNode()
@@ -244,7 +862,7 @@ func TestDeployVolumes(t *testing.T) {
 				},
 			},
 			expectedFiles: map[string]packageFile{
-				"bar": packageFile{data: []byte("123"), mode: defaultVolumeFileMode},
+				"bar": packageFile{data: []byte("123"), mode: api.ConfigMapVolumeSourceDefaultMode},
 			},
 			isErr: false,
 		},
@@ -274,7 +892,109 @@ func TestDeployVolumes(t *testing.T) {
 				},
 			},
 			expectedFiles: map[string]packageFile{
-				"bar": packageFile{data: []byte("123"), mode: defaultVolumeFileMode},
+				"bar": packageFile{data: []byte("123"), mode: api.SecretVolumeSourceDefaultMode},
+			},
+			isErr: false,
+		},
+		{
+			name: "projected volume mode precedence",
+			volumes: []api.Volume{
+				{
+					Name: "mytest",
+					VolumeSource: api.VolumeSource{
+						Projected: &api.ProjectedVolumeSource{
+							Sources: []api.VolumeProjection{
+								{
+									Secret: &api.SecretProjection{
+										LocalObjectReference: api.LocalObjectReference{
+											Name: "test-secret",
+										},
+										Items: []api.KeyToPath{
+											{Key: "foo", Mode: &secretItemMode},
+											{Key: "bar"},
+										},
+										DefaultMode: &secretSourceMode,
+									},
+								},
+								{
+									ConfigMap: &api.ConfigMapProjection{
+										LocalObjectReference: api.LocalObjectReference{
+											Name: "test-config-map",
+										},
+										Items: []api.KeyToPath{
+											{Key: "foo", Path: "cmfoo"},
+										},
+									},
+								},
+							},
+							DefaultMode: &projectedDefaultMode,
+						},
+					},
+				},
+			},
+			configMap: &v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-config-map",
+					Namespace: "default",
+				},
+				Data: map[string]string{
+					"foo": "abc",
+				},
+			},
+			secret: &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-secret",
+					Namespace: "default",
+				},
+				Data: map[string][]byte{
+					"foo": []byte("abc"),
+					"bar": []byte("123"),
+				},
+			},
+			expectedFiles: map[string]packageFile{
+				// per-item mode wins over the source default
+				"foo": packageFile{data: []byte("abc"), mode: secretItemMode},
+				// per-source default wins over the projected default
+				"bar": packageFile{data: []byte("123"), mode: secretSourceMode},
+				// no source default, fall back to the projected default
+				"cmfoo": packageFile{data: []byte("abc"), mode: projectedDefaultMode},
+			},
+			isErr: false,
+		},
+		{
+			name: "projected volume default mode when unspecified",
+			volumes: []api.Volume{
+				{
+					Name: "mytest",
+					VolumeSource: api.VolumeSource{
+						Projected: &api.ProjectedVolumeSource{
+							Sources: []api.VolumeProjection{
+								{
+									ConfigMap: &api.ConfigMapProjection{
+										LocalObjectReference: api.LocalObjectReference{
+											Name: "test-config-map",
+										},
+										Items: []api.KeyToPath{
+											{Key: "foo"},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			configMap: &v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-config-map",
+					Namespace: "default",
+				},
+				Data: map[string]string{
+					"foo": "abc",
+				},
+			},
+			expectedFiles: map[string]packageFile{
+				"foo": packageFile{data: []byte("abc"), mode: api.ProjectedVolumeSourceDefaultMode},
 			},
 			isErr: false,
 		},
@@ -296,14 +1016,14 @@ func TestDeployVolumes(t *testing.T) {
 
 		// create the nodeClientFactory
 		nc := nodeclient.NewMockItzoClientFactory()
-		nc.DeployPackage = func(pod, name string, data io.Reader) error {
+		nc.DeployPackage = func(ctx context.Context, pod, name string, data io.Reader) error {
 			tfContents, err := tarPkgToPackageFile(data)
 			assert.NoError(t, err, tc.name)
 			assert.Equal(t, tc.expectedFiles, tfContents, tc.name)
 			return nil
 		}
 		pod.Spec.Volumes = tc.volumes
-		err = deployPodVolumes(pod, testNode, rm, nc)
+		err = deployPodVolumes(context.Background(), pod, testNode, rm, nc, 0, nil)
 		if tc.isErr {
 			assert.Error(t, err, tc.name)
 		} else {
@@ -312,6 +1032,301 @@ func TestDeployVolumes(t *testing.T) {
 	}
 }
 
+func TestDeployVolumesSubPath(t *testing.T) {
+	pod := api.GetFakePod()
+	pod.Namespace = "default"
+	testNode := api.GetFakeNode()
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	assert.Nil(t, indexer.Add(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-config-map",
+			Namespace: "default",
+		},
+		Data: map[string]string{
+			"foo": "abc",
+			"bar": "123",
+		},
+	}))
+	configMapLister := corev1listers.NewConfigMapLister(indexer)
+	rm, err := manager.NewResourceManager(nil, nil, configMapLister, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pod.Spec.Volumes = []api.Volume{
+		{
+			Name: "mytest",
+			VolumeSource: api.VolumeSource{
+				ConfigMap: &api.ConfigMapVolumeSource{
+					LocalObjectReference: api.LocalObjectReference{
+						Name: "test-config-map",
+					},
+				},
+			},
+		},
+	}
+	pod.Spec.Units = []api.Unit{
+		{
+			Name: "unit",
+			VolumeMounts: []api.VolumeMount{
+				{
+					Name:      "mytest",
+					MountPath: "/etc/bar",
+					SubPath:   "bar",
+					ReadOnly:  true,
+				},
+			},
+		},
+	}
+	nc := nodeclient.NewMockItzoClientFactory()
+	deployed := false
+	nc.DeployPackage = func(ctx context.Context, pod, name string, data io.Reader) error {
+		deployed = true
+		tfContents, err := tarPkgToPackageFile(data)
+		assert.NoError(t, err)
+		// only the selected key is laid down, without its write bits
+		assert.Equal(t, map[string]packageFile{
+			"bar": packageFile{
+				data: []byte("123"),
+				mode: api.ConfigMapVolumeSourceDefaultMode &^ 0222,
+			},
+		}, tfContents)
+		return nil
+	}
+	err = deployPodVolumes(context.Background(), pod, testNode, rm, nc, 0, nil)
+	assert.NoError(t, err)
+	assert.True(t, deployed)
+}
+
+func TestGetDownwardAPIFiles(t *testing.T) {
+	itemMode := int32(0400)
+	pod := api.GetFakePod()
+	pod.Name = "default_mypod"
+	pod.Namespace = "default"
+	pod.UID = "1234-5678"
+	pod.Labels = map[string]string{"app": "web", "tier": "frontend"}
+	pod.Annotations = map[string]string{"build": "42"}
+	proj := &api.DownwardAPIProjection{
+		Items: []api.DownwardAPIVolumeFile{
+			{Path: "podname", FieldRef: &api.ObjectFieldSelector{FieldPath: "metadata.name"}},
+			{Path: "namespace", FieldRef: &api.ObjectFieldSelector{FieldPath: "metadata.namespace"}},
+			{Path: "uid", FieldRef: &api.ObjectFieldSelector{FieldPath: "metadata.uid"}},
+			{Path: "labels", FieldRef: &api.ObjectFieldSelector{FieldPath: "metadata.labels"}},
+			{Path: "annotations", FieldRef: &api.ObjectFieldSelector{FieldPath: "metadata.annotations"}, Mode: &itemMode},
+		},
+	}
+	files, err := getDownwardAPIFiles(proj, pod, 0644)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]packageFile{
+		"podname":     packageFile{data: []byte("mypod"), mode: 0644},
+		"namespace":   packageFile{data: []byte("default"), mode: 0644},
+		"uid":         packageFile{data: []byte("1234-5678"), mode: 0644},
+		"labels":      packageFile{data: []byte("app=\"web\"\ntier=\"frontend\""), mode: 0644},
+		"annotations": packageFile{data: []byte("build=\"42\""), mode: itemMode},
+	}, files)
+
+	proj = &api.DownwardAPIProjection{
+		Items: []api.DownwardAPIVolumeFile{
+			{Path: "ip", FieldRef: &api.ObjectFieldSelector{FieldPath: "status.podIP"}},
+		},
+	}
+	_, err = getDownwardAPIFiles(proj, pod, 0644)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "status.podIP")
+	}
+
+	proj = &api.DownwardAPIProjection{
+		Items: []api.DownwardAPIVolumeFile{{Path: "podname"}},
+	}
+	_, err = getDownwardAPIFiles(proj, pod, 0644)
+	assert.Error(t, err)
+}
+
+func TestDeployPodVolumesSizeLimit(t *testing.T) {
+	pod := api.GetFakePod()
+	pod.Namespace = "default"
+	testNode := api.GetFakeNode()
+	configMap := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "big-config-map",
+			Namespace: "default",
+		},
+		BinaryData: map[string][]byte{
+			"blob": make([]byte, 2048),
+		},
+	}
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	assert.Nil(t, indexer.Add(configMap))
+	configMapLister := corev1listers.NewConfigMapLister(indexer)
+	rm, err := manager.NewResourceManager(nil, nil, configMapLister, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nc := nodeclient.NewMockItzoClientFactory()
+	deployed := false
+	nc.DeployPackage = func(ctx context.Context, pod, name string, data io.Reader) error {
+		deployed = true
+		_, err := ioutil.ReadAll(data)
+		return err
+	}
+	pod.Spec.Volumes = []api.Volume{
+		{
+			Name: "big-volume",
+			VolumeSource: api.VolumeSource{
+				ConfigMap: &api.ConfigMapVolumeSource{
+					LocalObjectReference: api.LocalObjectReference{
+						Name: "big-config-map",
+					},
+				},
+			},
+		},
+	}
+	// Under the limit the volume deploys.
+	err = deployPodVolumes(context.Background(), pod, testNode, rm, nc, 4096, nil)
+	assert.NoError(t, err)
+	assert.True(t, deployed)
+	// Over the limit the deploy is rejected before anything is sent.
+	deployed = false
+	err = deployPodVolumes(context.Background(), pod, testNode, rm, nc, 1024, nil)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "maxVolumeDeploySize")
+	assert.False(t, deployed)
+}
+
+func TestDeployPodVolumesSkipsUnchanged(t *testing.T) {
+	pod := api.GetFakePod()
+	pod.Namespace = "default"
+	testNode := api.GetFakeNode()
+	configMap := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-config-map",
+			Namespace: "default",
+		},
+		Data: map[string]string{
+			"foo": "abc",
+		},
+	}
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	assert.Nil(t, indexer.Add(configMap))
+	configMapLister := corev1listers.NewConfigMapLister(indexer)
+	rm, err := manager.NewResourceManager(nil, nil, configMapLister, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nc := nodeclient.NewMockItzoClientFactory()
+	deploys := 0
+	nc.DeployPackage = func(ctx context.Context, pod, name string, data io.Reader) error {
+		deploys++
+		_, err := ioutil.ReadAll(data)
+		return err
+	}
+	pod.Spec.Volumes = []api.Volume{
+		{
+			Name: "config-volume",
+			VolumeSource: api.VolumeSource{
+				ConfigMap: &api.ConfigMapVolumeSource{
+					LocalObjectReference: api.LocalObjectReference{
+						Name: "test-config-map",
+					},
+				},
+			},
+		},
+	}
+	deployCache := newVolumeDeployCache()
+	deploy := func(node *api.Node) error {
+		return deployPodVolumes(context.Background(), pod, node, rm, nc, 0, deployCache)
+	}
+	// First deploy ships the volume, a reconcile with unchanged
+	// content doesn't.
+	assert.NoError(t, deploy(testNode))
+	assert.Equal(t, 1, deploys)
+	assert.NoError(t, deploy(testNode))
+	assert.Equal(t, 1, deploys)
+	// Changed content is re-shipped.
+	configMap.Data["foo"] = "xyz"
+	assert.Nil(t, indexer.Update(configMap))
+	assert.NoError(t, deploy(testNode))
+	assert.Equal(t, 2, deploys)
+	// A new cell always gets the volume even with unchanged content.
+	otherNode := api.GetFakeNode()
+	assert.NoError(t, deploy(otherNode))
+	assert.Equal(t, 3, deploys)
+	// Forgetting the pod forces a redeploy on the old cell too.
+	deployCache.forgetPod(pod.Name)
+	assert.NoError(t, deploy(testNode))
+	assert.Equal(t, 4, deploys)
+}
+
+func TestDeployPodVolumesSubPathNotReloaded(t *testing.T) {
+	pod := api.GetFakePod()
+	pod.Namespace = "default"
+	testNode := api.GetFakeNode()
+	configMap := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-config-map",
+			Namespace: "default",
+		},
+		Data: map[string]string{
+			"foo": "abc",
+		},
+	}
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	assert.Nil(t, indexer.Add(configMap))
+	configMapLister := corev1listers.NewConfigMapLister(indexer)
+	rm, err := manager.NewResourceManager(nil, nil, configMapLister, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nc := nodeclient.NewMockItzoClientFactory()
+	deploys := 0
+	nc.DeployPackage = func(ctx context.Context, pod, name string, data io.Reader) error {
+		deploys++
+		_, err := ioutil.ReadAll(data)
+		return err
+	}
+	pod.Spec.Volumes = []api.Volume{
+		{
+			Name: "config-volume",
+			VolumeSource: api.VolumeSource{
+				ConfigMap: &api.ConfigMapVolumeSource{
+					LocalObjectReference: api.LocalObjectReference{
+						Name: "test-config-map",
+					},
+				},
+			},
+		},
+	}
+	pod.Spec.Units = []api.Unit{
+		{
+			Name: "unit",
+			VolumeMounts: []api.VolumeMount{
+				{
+					Name:      "config-volume",
+					MountPath: "/etc/foo",
+					SubPath:   "foo",
+				},
+			},
+		},
+	}
+	deployCache := newVolumeDeployCache()
+	deploy := func() error {
+		return deployPodVolumes(context.Background(), pod, testNode, rm, nc, 0, deployCache)
+	}
+	// The initial deploy ships the subPath-mounted volume.
+	assert.NoError(t, deploy())
+	assert.Equal(t, 1, deploys)
+	// A content change is not re-shipped: kubernetes never updates
+	// subPath mounts in place.
+	configMap.Data["foo"] = "xyz"
+	assert.Nil(t, indexer.Update(configMap))
+	assert.NoError(t, deploy())
+	assert.Equal(t, 1, deploys)
+	// The same change to a volume mounted without subPath is
+	// re-shipped.
+	pod.Spec.Units[0].VolumeMounts[0].SubPath = ""
+	assert.NoError(t, deploy())
+	assert.Equal(t, 2, deploys)
+}
+
 func createFakeDNSConfigurer(dnsIP, resolvconfPath, clusterDomain string) *dns.Configurer {
 	loggingEventRecorder := eventrecorder.NewLoggingEventRecorder(4)
 	nodeRef := &v1.ObjectReference{
@@ -467,7 +1482,7 @@ func TestCreateResolvconf(t *testing.T) {
 		pod.Spec.HostNetwork = tc.HostNetwork
 		dnsconf, err := dnsConfigurer.GetPodDNS(pod)
 		assert.NoError(t, err)
-		resolvconf, err := createResolvconf(pod.Name, dnsconf)
+		resolvconf, err := createResolvconf(pod.Name, dnsconf, nil)
 		assert.NoError(t, err)
 		msg := fmt.Sprintf("Test case %d: %+v", i+1, tc)
 		assert.Equal(
@@ -479,6 +1494,115 @@ func TestCreateResolvconf(t *testing.T) {
 	}
 }
 
+func TestPodDNSNoneConfig(t *testing.T) {
+	pod := api.GetFakePod()
+	pod.Spec.DNSPolicy = api.DNSNone
+	pod.Spec.DNSConfig = &api.PodDNSConfig{
+		Nameservers: []string{"44.44.44.44", "55.55.55.55"},
+		Searches:    []string{"m.n.o.p"},
+		Options: []api.PodDNSConfigOption{
+			{
+				Name: "debug",
+			},
+			{
+				Name:  "attempts",
+				Value: stringPtr("5"),
+			},
+		},
+	}
+	dnsconf, err := podDNSNoneConfig(pod)
+	assert.NoError(t, err)
+	resolvconf, err := createResolvconf(pod.Name, dnsconf, pod.Spec.DNSConfig)
+	assert.NoError(t, err)
+	// The pod gets exactly what it configured, no cluster nameservers
+	// or search domains leak in.
+	expected := "nameserver 44.44.44.44\nnameserver 55.55.55.55\nsearch m.n.o.p\noptions debug attempts:5\n"
+	assert.Equal(
+		t,
+		resolvconfToMap(expected),
+		resolvconfToMap(string(resolvconf)),
+	)
+
+	// With DNSPolicy None the pod has to specify nameservers.
+	pod.Spec.DNSConfig = nil
+	_, err = podDNSNoneConfig(pod)
+	assert.Error(t, err)
+	pod.Spec.DNSConfig = &api.PodDNSConfig{Searches: []string{"m.n.o.p"}}
+	_, err = podDNSNoneConfig(pod)
+	assert.Error(t, err)
+}
+
+func TestPodWithDefaultDNSPolicy(t *testing.T) {
+	defer setDefaultDNSPolicy("")
+	pod := api.GetFakePod()
+	pod.Spec.DNSPolicy = ""
+	// without a configured default the pod is left alone
+	assert.Equal(t, api.DNSPolicy(""), podWithDefaultDNSPolicy(pod).Spec.DNSPolicy)
+	setDefaultDNSPolicy(api.DNSDefault)
+	assert.Equal(t, api.DNSDefault, podWithDefaultDNSPolicy(pod).Spec.DNSPolicy)
+	// a pod that specifies its policy keeps it
+	pod.Spec.DNSPolicy = api.DNSClusterFirst
+	assert.Equal(t, api.DNSClusterFirst, podWithDefaultDNSPolicy(pod).Spec.DNSPolicy)
+}
+
+func TestCreateResolvconfDNSDefaults(t *testing.T) {
+	defer setDNSDefaults(0, 0)
+	setDNSDefaults(2, 1)
+
+	// The controller defaults replace the cluster ndots and trim the
+	// cluster search domains.
+	dnsconf := &runtimeapi.DNSConfig{
+		Servers:  []string{"10.20.30.40"},
+		Searches: []string{"default.svc.cluster.local", "svc.cluster.local", "cluster.local"},
+		Options:  []string{"ndots:5"},
+	}
+	resolvconf, err := createResolvconf("dnsdefaults", dnsconf, nil)
+	assert.NoError(t, err)
+	expected := "nameserver 10.20.30.40\nsearch default.svc.cluster.local\noptions ndots:2\n"
+	assert.Equal(
+		t,
+		resolvconfToMap(expected),
+		resolvconfToMap(string(resolvconf)),
+	)
+
+	// Explicit dnsConfig options and searches win over the defaults.
+	podDNSConfig := &api.PodDNSConfig{
+		Searches: []string{"example.com"},
+		Options: []api.PodDNSConfigOption{
+			{
+				Name:  "ndots",
+				Value: stringPtr("4"),
+			},
+		},
+	}
+	dnsconf = &runtimeapi.DNSConfig{
+		Servers:  []string{"10.20.30.40"},
+		Searches: []string{"example.com", "cluster.local"},
+		Options:  []string{"ndots:4"},
+	}
+	resolvconf, err = createResolvconf("dnsdefaults", dnsconf, podDNSConfig)
+	assert.NoError(t, err)
+	expected = "nameserver 10.20.30.40\nsearch example.com cluster.local\noptions ndots:4\n"
+	assert.Equal(
+		t,
+		resolvconfToMap(expected),
+		resolvconfToMap(string(resolvconf)),
+	)
+
+	// A configured ndots is added even if cluster DNS didn't set one.
+	dnsconf = &runtimeapi.DNSConfig{
+		Servers: []string{"10.20.30.40"},
+	}
+	resolvconf, err = createResolvconf("dnsdefaults", dnsconf, nil)
+	assert.NoError(t, err)
+	expected = "nameserver 10.20.30.40\noptions ndots:2\n"
+	assert.Equal(
+		t,
+		resolvconfToMap(expected),
+		resolvconfToMap(string(resolvconf)),
+	)
+}
+
 func resolvconfToMap(conf string) map[string][]string {
 	lines := strings.Split(conf, "\n")
 	output := make(map[string][]string)
@@ -498,3 +1622,22 @@ func resolvconfToMap(conf string) map[string][]string {
 	}
 	return output
 }
+
+func TestPodWantsCABundle(t *testing.T) {
+	pod := api.GetFakePod()
+	// No bundle configured, nothing gets deployed.
+	assert.False(t, podWantsCABundle(pod))
+	setTrustedCABundle([]byte("-----BEGIN CERTIFICATE-----"), false)
+	defer setTrustedCABundle(nil, false)
+	assert.True(t, podWantsCABundle(pod))
+	assert.Equal(t, caBundleExtraPath, caBundleMountPath())
+	pod.Annotations = map[string]string{
+		annotations.PodSkipCABundle: "true",
+	}
+	assert.False(t, podWantsCABundle(pod))
+	// An unparsable opt-out doesn't opt the pod out.
+	pod.Annotations[annotations.PodSkipCABundle] = "junk"
+	assert.True(t, podWantsCABundle(pod))
+	setTrustedCABundle([]byte("-----BEGIN CERTIFICATE-----"), true)
+	assert.Equal(t, caBundleReplacePath, caBundleMountPath())
+}
@@ -60,15 +60,32 @@ func NewEventSystem(quit <-chan struct{}, wg *sync.WaitGroup) *EventSystem {
 	return e
 }
 
-// At this time, you can't unregister from an event
-// Why?  Because we don't need that functionality yet
-// If you need that functionality, feel free to add it
 func (es *EventSystem) RegisterHandler(status string, h EventHandler) {
 	es.Lock()
 	defer es.Unlock()
 	es.eventHandlers[status] = append(es.eventHandlers[status], h)
 }
 
+// UnregisterHandler removes a handler added with RegisterHandler.
+// Handlers registered through RegisterHandlerFunc can't be removed
+// (function values aren't comparable); connection-scoped subscribers
+// like event watches should register a handler object instead.
+func (es *EventSystem) UnregisterHandler(status string, h EventHandler) {
+	es.Lock()
+	defer es.Unlock()
+	handlers := es.eventHandlers[status]
+	for i := range handlers {
+		if _, isFunc := handlers[i].(HandlerFunc); isFunc {
+			continue
+		}
+		if handlers[i] == h {
+			es.eventHandlers[status] = append(
+				handlers[:i], handlers[i+1:]...)
+			return
+		}
+	}
+}
+
 func (es *EventSystem) RegisterHandlerFunc(status string, h func(Event) error) {
 	es.Lock()
 	defer es.Unlock()
@@ -69,6 +69,23 @@ func TestBasicHandler(t *testing.T) {
 	assert.Equal(t, "Extinguished", dracula.Status)
 }
 
+func TestUnregisterHandler(t *testing.T) {
+	t.Parallel()
+	quitChan := make(chan struct{})
+	wg := &sync.WaitGroup{}
+	es := NewEventSystem(quitChan, wg)
+	dracula := Vampire{"Dracula", "Undead"}
+	buffy := VampireHunter{"Buffy", "Alive"}
+	es.RegisterHandler(VampireHunterEntersCastleEventType, &dracula)
+	es.UnregisterHandler(VampireHunterEntersCastleEventType, &dracula)
+	es.Emit(VampireHunterEntersCastleEventType, "events-test", &buffy, "testing events")
+	time.Sleep(1 * time.Second)
+	// dracula stopped listening and lives to bite another day
+	assert.Equal(t, "Undead", dracula.Status)
+	// unregistering a handler that was never registered is a no-op
+	es.UnregisterHandler(VampireHunterEntersCastleEventType, &dracula)
+}
+
 type DeepCopyable struct {
 	Name  string
 	Slice []string
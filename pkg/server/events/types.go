@@ -17,23 +17,39 @@ limitations under the License.
 package events
 
 const (
-	AllEvents       = "all-events"
-	NodeCleaning    = "node-cleaning"
-	NodeCreated     = "node-created"
-	NodePurged      = "node-purged"
-	NodeRunning     = "node-running"
-	PodCreated      = "pod-created"
-	PodEjected      = "pod-ejected" // We found a lost node with a bound pod
-	PodRunning      = "pod-running"
-	PodShouldDelete = "pod-should-delete"
-	PodTerminated   = "pod-terminated"
-	PodUpdated      = "pod-updated"
-	SecretCreated   = "secret-created"
-	SecretDeleted   = "secret-deleted"
-	SecretUpdated   = "secret-updated"
-	ServiceCreated  = "service-created"
-	ServiceDeleted  = "service-deleted"
-	ServiceUpdated  = "service-updated"
-	StartSpotFailed = "start-spot-failed"
-	UsageCreated    = "usage-created"
+	AllEvents                = "all-events"
+	InstanceTagsRepaired     = "instance-tags-repaired"
+	NodeBootTimeout          = "node-boot-timeout"
+	NodeCleaning             = "node-cleaning"
+	NodeCreated              = "node-created"
+	NodeLaunchAborted        = "node-launch-aborted"
+	NodePurged               = "node-purged"
+	NodeRecycled             = "node-recycled"
+	NodeRunning              = "node-running"
+	PodCellReplaced          = "pod-cell-replaced" // Cell replaced because its boot image was outdated
+	PodCreated               = "pod-created"
+	PodDeadlineExceeded      = "pod-deadline-exceeded" // Pod ran past its activeDeadlineSeconds
+	PodDefaultInstanceType   = "pod-default-instance-type"
+	PodEjected               = "pod-ejected" // We found a lost node with a bound pod
+	PodExceededStartFailures = "pod-exceeded-start-failures"
+	PodHostPathMismatch      = "pod-host-path-mismatch" // A hostPath volume's type doesn't match what's on the node
+	PodLaunchAborted         = "pod-launch-aborted"
+	PodPreempted             = "pod-preempted"
+	PodResumed               = "pod-resumed"
+	PodRunning               = "pod-running"
+	PodScheduleTimeout       = "pod-schedule-timeout"
+	PodScheduled             = "pod-scheduled"
+	PodShouldDelete          = "pod-should-delete"
+	PodSuspended             = "pod-suspended"
+	PodTerminated            = "pod-terminated"
+	PodUpdated               = "pod-updated"
+	RouteTableFull           = "route-table-full"
+	SecretCreated            = "secret-created"
+	SecretDeleted            = "secret-deleted"
+	SecretUpdated            = "secret-updated"
+	ServiceCreated           = "service-created"
+	ServiceDeleted           = "service-deleted"
+	ServiceUpdated           = "service-updated"
+	StartSpotFailed          = "start-spot-failed"
+	UsageCreated             = "usage-created"
 )
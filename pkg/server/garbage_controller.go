@@ -26,6 +26,7 @@ import (
 	"github.com/elotl/kip/pkg/server/cloud"
 	"github.com/elotl/kip/pkg/server/cloud/aws"
 	"github.com/elotl/kip/pkg/server/cloud/azure"
+	"github.com/elotl/kip/pkg/server/events"
 	"github.com/elotl/kip/pkg/server/registry"
 	"github.com/elotl/kip/pkg/util/stats"
 	"k8s.io/apimachinery/pkg/util/sets"
@@ -49,7 +50,10 @@ type GarbageController struct {
 	nodeRegistry            *registry.NodeRegistry
 	cloudClient             cloud.CloudClient
 	controllerID            string
+	nametag                 string
+	events                  *events.EventSystem
 	timer                   stats.LoopTimer
+	tagsRepaired            int64
 	lastOrphanedAzureGroups sets.String
 	lastOldTaskDefs         sets.String
 }
@@ -60,7 +64,14 @@ func (c *GarbageController) Start(quit <-chan struct{}, wg *sync.WaitGroup) {
 }
 
 func (c *GarbageController) Dump() []byte {
-	b, err := json.MarshalIndent(c.timer, "", "    ")
+	dumpStruct := struct {
+		Timer        stats.LoopTimer `json:"timer"`
+		TagsRepaired int64           `json:"tagsRepaired"`
+	}{
+		Timer:        c.timer,
+		TagsRepaired: c.tagsRepaired,
+	}
+	b, err := json.MarshalIndent(dumpStruct, "", "    ")
 	if err != nil {
 		klog.Errorln("Error dumping data from GarbageController", err)
 		return nil
@@ -89,6 +100,7 @@ func (c *GarbageController) GCLoop(quit <-chan struct{}, wg *sync.WaitGroup) {
 		select {
 		case <-instancesTicker.C:
 			c.timer.StartLoop()
+			c.ReconcileInstanceTags()
 			c.CleanInstances()
 			c.CleanDanglingRoutes()
 			c.timer.EndLoop()
@@ -119,6 +131,26 @@ func (c *GarbageController) CleanTerminatedNodes() {
 	}
 	now := api.Now()
 	for _, node := range nodes.Items {
+		if node.HasFinalizer(api.CloudResourcesFinalizer) {
+			// the node terminated before its cloud resources were
+			// confirmed released, retry the stop and only drop the
+			// finalizer once the cloud agrees the instance is gone
+			if node.Status.InstanceID != "" {
+				if err := c.cloudClient.StopInstance(node.Status.InstanceID); err != nil {
+					klog.Warningf("Error stopping instance %s of terminated node %s, will retry: %v",
+						node.Status.InstanceID, node.Name, err)
+					continue
+				}
+			}
+			node, err = c.nodeRegistry.AtomicUpdate(node.Name, func(in *api.Node) error {
+				in.RemoveFinalizer(api.CloudResourcesFinalizer)
+				return nil
+			})
+			if err != nil {
+				klog.Errorf("Error removing finalizer from node: %v", err)
+				continue
+			}
+		}
 		if node.DeletionTimestamp == nil {
 			klog.Warningf("Found node with nil deletion timestamp")
 			_, _ = c.nodeRegistry.SetNodeDeletionTimestamp(node)
@@ -172,6 +204,87 @@ func (c *GarbageController) CleanInstances() {
 	lastUnknownInstances = unknownInstances
 }
 
+// ReconcileInstanceTags compares the tags we expect on the instances
+// backing our nodes against what the cloud reports and re-applies any
+// that have drifted.  Discovery is driven by the controller and node
+// tags, so an instance that loses them (manual edits, other tooling in
+// a shared account) becomes invisible to ListInstances and we would
+// silently lose track of it.
+func (c *GarbageController) ReconcileInstanceTags() {
+	nodes, err := c.nodeRegistry.ListNodes(func(n *api.Node) bool {
+		return (n.Status.Phase != api.NodeCreating &&
+			n.Status.Phase != api.NodeTerminating &&
+			n.Status.Phase != api.NodeTerminated &&
+			n.Status.InstanceID != "")
+	})
+	if err != nil {
+		klog.Errorf("Error listing nodes for tag reconciliation: %s", err.Error())
+		return
+	}
+	if len(nodes.Items) == 0 {
+		return
+	}
+	instances, err := c.cloudClient.ListInstances()
+	if err != nil {
+		klog.Errorf("Error listing cloud instances for tag reconciliation: %s", err.Error())
+		return
+	}
+	taggedNodeNames := make(map[string]string)
+	for _, inst := range instances {
+		taggedNodeNames[inst.ID] = inst.NodeName
+	}
+	untaggedNodes := make(map[string]*api.Node)
+	for _, node := range nodes.Items {
+		iid := node.Status.InstanceID
+		nodeName, found := taggedNodeNames[iid]
+		if !found {
+			// ListInstances selects on the controller tag so the
+			// instance either lost it or was terminated behind our
+			// back.  Look those up by ID below before re-tagging.
+			untaggedNodes[iid] = node
+			continue
+		}
+		if nodeName != node.Name {
+			c.repairInstanceTags(node, fmt.Sprintf("node tag was %q", nodeName))
+		}
+	}
+	if len(untaggedNodes) == 0 {
+		return
+	}
+	iids := make([]string, 0, len(untaggedNodes))
+	for iid := range untaggedNodes {
+		iids = append(iids, iid)
+	}
+	liveInstances, err := c.cloudClient.ListInstancesFilterID(iids)
+	if err != nil {
+		klog.Errorf("Error looking up untagged instances %v: %s", iids, err.Error())
+		return
+	}
+	for _, inst := range liveInstances {
+		node := untaggedNodes[inst.ID]
+		klog.Warningf("Instance %s backing node %s is missing the %s tag, re-tagging it",
+			inst.ID, node.Name, cloud.ControllerTagKey)
+		c.repairInstanceTags(node, fmt.Sprintf("missing %s tag", cloud.ControllerTagKey))
+	}
+}
+
+func (c *GarbageController) repairInstanceTags(node *api.Node, reason string) {
+	tags := map[string]string{
+		"Node":                 node.Name,
+		cloud.ControllerTagKey: c.controllerID,
+		cloud.NametagTagKey:    c.nametag,
+	}
+	err := c.cloudClient.AddInstanceTags(node.Status.InstanceID, tags)
+	if err != nil {
+		klog.Errorf("Error re-applying tags to instance %s: %s",
+			node.Status.InstanceID, err.Error())
+		return
+	}
+	c.tagsRepaired++
+	c.events.Emit(events.InstanceTagsRepaired, "garbage-controller", node,
+		"Re-applied instance tags to %s: %s", node.Status.InstanceID, reason)
+}
+
 func (c *GarbageController) CleanAzureResourceGroups() {
 	az, ok := c.cloudClient.(*azure.AzureClient)
 	if !ok {
@@ -18,9 +18,12 @@ package server
 
 import (
 	"fmt"
+	"sync"
 	"testing"
 
 	"github.com/elotl/kip/pkg/api"
+	"github.com/elotl/kip/pkg/server/cloud"
+	"github.com/elotl/kip/pkg/server/events"
 	"github.com/elotl/kip/pkg/server/registry"
 	"github.com/stretchr/testify/assert"
 	"k8s.io/apimachinery/pkg/util/sets"
@@ -39,6 +42,61 @@ func createGarbageController() (*GarbageController, func()) {
 	return ctl, closer
 }
 
+func TestReconcileInstanceTags(t *testing.T) {
+	ctl, closer := createGarbageController()
+	defer closer()
+	quit := make(chan struct{})
+	wg := &sync.WaitGroup{}
+	defer close(quit)
+	cloudClient := cloud.NewMockClient()
+	ctl.cloudClient = cloudClient
+	ctl.controllerID = "test-controller"
+	ctl.nametag = "test-nametag"
+	ctl.events = events.NewEventSystem(quit, wg)
+
+	makeNode := func(name, instanceID string) {
+		n := api.GetFakeNode()
+		n.Name = name
+		n.Status.Phase = api.NodeClaimed
+		n.Status.InstanceID = instanceID
+		_, err := ctl.nodeRegistry.CreateNode(n)
+		assert.NoError(t, err)
+	}
+	// correctly tagged, node tag drifted, controller tag lost and
+	// terminated behind our back, respectively
+	makeNode("good", "i-good")
+	makeNode("drifted", "i-drifted")
+	makeNode("untagged", "i-untagged")
+	makeNode("gone", "i-gone")
+
+	cloudClient.InstanceLister = func() ([]cloud.CloudInstance, error) {
+		return []cloud.CloudInstance{
+			{ID: "i-good", NodeName: "good"},
+			{ID: "i-drifted", NodeName: "wrong-name"},
+		}, nil
+	}
+	cloudClient.InstanceListerFilter = func(iids []string) ([]cloud.CloudInstance, error) {
+		assert.ElementsMatch(t, []string{"i-untagged", "i-gone"}, iids)
+		return []cloud.CloudInstance{
+			{ID: "i-untagged", NodeName: "untagged"},
+		}, nil
+	}
+	repaired := make(map[string]map[string]string)
+	cloudClient.InstanceTagAdder = func(iid string, tags map[string]string) error {
+		repaired[iid] = tags
+		return nil
+	}
+
+	ctl.ReconcileInstanceTags()
+	assert.Len(t, repaired, 2)
+	assert.NotContains(t, repaired, "i-good")
+	assert.NotContains(t, repaired, "i-gone")
+	assert.Equal(t, "drifted", repaired["i-drifted"]["Node"])
+	assert.Equal(t, "test-controller", repaired["i-untagged"][cloud.ControllerTagKey])
+	assert.Equal(t, "test-nametag", repaired["i-untagged"][cloud.NametagTagKey])
+	assert.Equal(t, int64(2), ctl.tagsRepaired)
+}
+
 type MockResourcer struct {
 	groups sets.String
 }
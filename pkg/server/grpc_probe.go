@@ -0,0 +1,157 @@
+/*
+Copyright 2020 Elotl Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elotl/kip/pkg/api"
+	"github.com/elotl/kip/pkg/util"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"k8s.io/klog"
+)
+
+const (
+	// Defaults match the kubernetes probe defaults.
+	defaultProbeTimeout          = 1 * time.Second
+	defaultProbeFailureThreshold = 3
+)
+
+// probeFailureTracker counts consecutive probe failures per pod unit.
+type probeFailureTracker struct {
+	lock     sync.Mutex
+	failures map[string]int32
+}
+
+func newProbeFailureTracker() *probeFailureTracker {
+	return &probeFailureTracker{
+		failures: make(map[string]int32),
+	}
+}
+
+func probeFailureKey(podName, unitName string) string {
+	return podName + "/" + unitName
+}
+
+// record adds a failure and returns the consecutive failure count.
+func (t *probeFailureTracker) record(podName, unitName string) int32 {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	key := probeFailureKey(podName, unitName)
+	t.failures[key]++
+	return t.failures[key]
+}
+
+func (t *probeFailureTracker) reset(podName, unitName string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	delete(t.failures, probeFailureKey(podName, unitName))
+}
+
+// forgetPod drops every count for the pod, called when the pod goes
+// away so the tracker doesn't grow without bound.
+func (t *probeFailureTracker) forgetPod(podName string) {
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	prefix := podName + "/"
+	for key := range t.failures {
+		if strings.HasPrefix(key, prefix) {
+			delete(t.failures, key)
+		}
+	}
+}
+
+// runGRPCHealthCheck queries the standard grpc_health_v1 Health
+// service at host:port and fails unless the reply is SERVING.
+func runGRPCHealthCheck(host string, action *api.GRPCAction, timeout time.Duration) error {
+	addr := net.JoinHostPort(host, strconv.Itoa(int(action.Port)))
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, addr, grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		return util.WrapError(err, "connecting to gRPC health service at %s", addr)
+	}
+	defer conn.Close()
+	reply, err := healthpb.NewHealthClient(conn).Check(
+		ctx, &healthpb.HealthCheckRequest{Service: action.Service})
+	if err != nil {
+		return util.WrapError(err, "gRPC health check at %s", addr)
+	}
+	if reply.Status != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("gRPC health check at %s returned status %s",
+			addr, reply.Status)
+	}
+	return nil
+}
+
+// probeTimeout is the probe's TimeoutSeconds as a duration, falling
+// back to the kubernetes default of one second.
+func probeTimeout(probe *api.Probe) time.Duration {
+	if probe.TimeoutSeconds > 0 {
+		return time.Duration(probe.TimeoutSeconds) * time.Second
+	}
+	return defaultProbeTimeout
+}
+
+// checkGRPCProbes runs the gRPC liveness probes of the pod's units.
+// The cell agent executes exec, http and tcp probes on the cell, but
+// it doesn't speak gRPC, so those probes run here, piggybacking on the
+// controller's status interval instead of the probe's PeriodSeconds.
+// A pod whose probe fails FailureThreshold times in a row is marked
+// failed, the same path a failed cell health check takes.
+func (c *PodController) checkGRPCProbes(pod *api.Pod) {
+	podIP := api.GetPrivateIP(pod.Status.Addresses)
+	if podIP == "" {
+		return
+	}
+	for i := range pod.Spec.Units {
+		unit := &pod.Spec.Units[i]
+		probe := unit.LivenessProbe
+		if probe == nil || probe.GRPC == nil {
+			continue
+		}
+		err := runGRPCHealthCheck(podIP, probe.GRPC, probeTimeout(probe))
+		if err == nil {
+			c.grpcProbeFailures.reset(pod.Name, unit.Name)
+			continue
+		}
+		failures := c.grpcProbeFailures.record(pod.Name, unit.Name)
+		threshold := probe.FailureThreshold
+		if threshold <= 0 {
+			threshold = defaultProbeFailureThreshold
+		}
+		klog.V(2).Infof("gRPC liveness probe for unit %s of pod %s failed (%d/%d): %v",
+			unit.Name, pod.Name, failures, threshold, err)
+		if failures >= threshold {
+			msg := fmt.Sprintf("unit %s of pod %s failed its gRPC liveness probe %d times: %v",
+				unit.Name, pod.Name, failures, err)
+			klog.Warningf(msg)
+			c.markFailedPod(pod, false, msg)
+			return
+		}
+	}
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2020 Elotl Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/elotl/kip/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// startFakeHealthServer runs a grpc_health_v1 server on a loopback
+// port and returns the port along with the health server so tests can
+// flip the serving status.
+func startFakeHealthServer(t *testing.T) (int32, *health.Server, func()) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	grpcServer := grpc.NewServer()
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+	go grpcServer.Serve(lis)
+	port := int32(lis.Addr().(*net.TCPAddr).Port)
+	return port, healthServer, grpcServer.Stop
+}
+
+func TestRunGRPCHealthCheckServing(t *testing.T) {
+	t.Parallel()
+	port, healthServer, stop := startFakeHealthServer(t)
+	defer stop()
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	action := &api.GRPCAction{Port: port}
+	err := runGRPCHealthCheck("127.0.0.1", action, 2*time.Second)
+	assert.NoError(t, err)
+}
+
+func TestRunGRPCHealthCheckNotServing(t *testing.T) {
+	t.Parallel()
+	port, healthServer, stop := startFakeHealthServer(t)
+	defer stop()
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	action := &api.GRPCAction{Port: port}
+	err := runGRPCHealthCheck("127.0.0.1", action, 2*time.Second)
+	assert.Error(t, err)
+}
+
+func TestRunGRPCHealthCheckService(t *testing.T) {
+	t.Parallel()
+	port, healthServer, stop := startFakeHealthServer(t)
+	defer stop()
+	healthServer.SetServingStatus("ready", healthpb.HealthCheckResponse_SERVING)
+	err := runGRPCHealthCheck(
+		"127.0.0.1", &api.GRPCAction{Port: port, Service: "ready"}, 2*time.Second)
+	assert.NoError(t, err)
+	err = runGRPCHealthCheck(
+		"127.0.0.1", &api.GRPCAction{Port: port, Service: "no-such-service"}, 2*time.Second)
+	assert.Error(t, err)
+}
+
+func TestProbeFailureTracker(t *testing.T) {
+	t.Parallel()
+	tracker := newProbeFailureTracker()
+	assert.Equal(t, int32(1), tracker.record("p1", "u1"))
+	assert.Equal(t, int32(2), tracker.record("p1", "u1"))
+	assert.Equal(t, int32(1), tracker.record("p1", "u2"))
+	tracker.reset("p1", "u1")
+	assert.Equal(t, int32(1), tracker.record("p1", "u1"))
+	tracker.forgetPod("p1")
+	assert.Equal(t, int32(1), tracker.record("p1", "u2"))
+	var nilTracker *probeFailureTracker
+	nilTracker.forgetPod("p1")
+}
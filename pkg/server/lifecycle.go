@@ -0,0 +1,270 @@
+/*
+Copyright 2020 Elotl Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elotl/kip/pkg/api"
+	"github.com/elotl/kip/pkg/nodeclient"
+	"github.com/elotl/kip/pkg/util"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/klog"
+)
+
+const (
+	// preStopGracePeriod bounds how long pod termination waits for
+	// PreStop hooks to finish; it matches the kubernetes default
+	// termination grace period.
+	preStopGracePeriod = 30 * time.Second
+	// lifecycleActionTimeout bounds a single httpGet or tcpSocket hook
+	// action.
+	lifecycleActionTimeout = 10 * time.Second
+)
+
+// postStartTracker remembers which units a PostStart hook already ran
+// for, so a hook fires once per unit start. Entries are keyed by node
+// as well as pod and unit, so a pod restarted on a new cell runs its
+// hooks again.
+type postStartTracker struct {
+	lock sync.Mutex
+	ran  map[string]bool
+}
+
+func newPostStartTracker() *postStartTracker {
+	return &postStartTracker{
+		ran: make(map[string]bool),
+	}
+}
+
+func postStartKey(podName, nodeName, unitName string) string {
+	return podName + "/" + nodeName + "/" + unitName
+}
+
+// claim marks the unit's PostStart hook as run and reports whether
+// this caller was the first to do so.
+func (t *postStartTracker) claim(podName, nodeName, unitName string) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	key := postStartKey(podName, nodeName, unitName)
+	if t.ran[key] {
+		return false
+	}
+	t.ran[key] = true
+	return true
+}
+
+// forgetPod drops every entry for the pod, called when the pod goes
+// away so the tracker doesn't grow without bound.
+func (t *postStartTracker) forgetPod(podName string) {
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	prefix := podName + "/"
+	for key := range t.ran {
+		if strings.HasPrefix(key, prefix) {
+			delete(t.ran, key)
+		}
+	}
+}
+
+// resolveHandlerPort turns a handler's port into a number, looking
+// named ports up in the unit's port list.
+func resolveHandlerPort(unit *api.Unit, port intstr.IntOrString) (int, error) {
+	if port.Type == intstr.String {
+		name := port.StrVal
+		for _, p := range unit.Ports {
+			if p.Name == name {
+				return int(p.ContainerPort), nil
+			}
+		}
+		return 0, fmt.Errorf("unit %s has no port named %q", unit.Name, name)
+	}
+	return port.IntValue(), nil
+}
+
+// runLifecycleHandler performs a single lifecycle hook action. Exec
+// commands run on the cell through the node client, httpGet and
+// tcpSocket actions connect to the pod IP unless the handler names
+// another host.
+func runLifecycleHandler(client nodeclient.NodeClient, podIP string, unit *api.Unit, handler *api.Handler) error {
+	switch {
+	case handler.Exec != nil:
+		output, err := client.RunCmd(api.RunCmdParams{Command: handler.Exec.Command})
+		if err != nil {
+			return util.WrapError(err, "exec %v: %s", handler.Exec.Command, output)
+		}
+		return nil
+	case handler.HTTPGet != nil:
+		return runHTTPGetHandler(podIP, unit, handler.HTTPGet)
+	case handler.TCPSocket != nil:
+		return runTCPSocketHandler(podIP, unit, handler.TCPSocket)
+	case handler.GRPC != nil:
+		return runGRPCHealthCheck(podIP, handler.GRPC, lifecycleActionTimeout)
+	}
+	return fmt.Errorf("handler specifies no action")
+}
+
+func runHTTPGetHandler(podIP string, unit *api.Unit, action *api.HTTPGetAction) error {
+	host := action.Host
+	if host == "" {
+		host = podIP
+	}
+	port, err := resolveHandlerPort(unit, action.Port)
+	if err != nil {
+		return err
+	}
+	scheme := "http"
+	if action.Scheme == api.URISchemeHTTPS {
+		scheme = "https"
+	}
+	url := fmt.Sprintf("%s://%s%s",
+		scheme, net.JoinHostPort(host, strconv.Itoa(port)), action.Path)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	for _, header := range action.HTTPHeaders {
+		req.Header.Add(header.Name, header.Value)
+	}
+	httpClient := &http.Client{Timeout: lifecycleActionTimeout}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < http.StatusOK ||
+		resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("GET %s returned status %d", url, resp.StatusCode)
+	}
+	return nil
+}
+
+func runTCPSocketHandler(podIP string, unit *api.Unit, action *api.TCPSocketAction) error {
+	host := action.Host
+	if host == "" {
+		host = podIP
+	}
+	port, err := resolveHandlerPort(unit, action.Port)
+	if err != nil {
+		return err
+	}
+	conn, err := net.DialTimeout(
+		"tcp", net.JoinHostPort(host, strconv.Itoa(port)), lifecycleActionTimeout)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// runPostStartHooks runs the PostStart hook of every unit that has
+// been seen running for the first time. Hook failures are logged;
+// unlike kubernetes the unit isn't killed, the cell agent owns unit
+// restarts.
+func (c *PodController) runPostStartHooks(pod *api.Pod, statuses []api.UnitStatus) {
+	running := make(map[string]bool, len(statuses))
+	for i := range statuses {
+		if statuses[i].State.Running != nil {
+			running[statuses[i].Name] = true
+		}
+	}
+	var client nodeclient.NodeClient
+	podIP := ""
+	for i := range pod.Spec.Units {
+		unit := &pod.Spec.Units[i]
+		if unit.Lifecycle == nil || unit.Lifecycle.PostStart == nil {
+			continue
+		}
+		if !running[unit.Name] {
+			continue
+		}
+		if !c.postStartRuns.claim(pod.Name, pod.Status.BoundNodeName, unit.Name) {
+			continue
+		}
+		if client == nil {
+			node, err := c.nodeLister.GetNode(pod.Status.BoundNodeName)
+			if err != nil {
+				klog.Warningf("Getting node for pod %s PostStart hooks: %v",
+					pod.Name, err)
+				return
+			}
+			client = c.nodeClientFactory.GetClient(node.Status.Addresses)
+			podIP = api.GetPodIP(node.Status.Addresses)
+		}
+		klog.V(2).Infof("Running PostStart hook for unit %s of pod %s",
+			unit.Name, pod.Name)
+		if err := runLifecycleHandler(client, podIP, unit, unit.Lifecycle.PostStart); err != nil {
+			klog.Warningf("PostStart hook for unit %s of pod %s: %v",
+				unit.Name, pod.Name, err)
+		}
+	}
+}
+
+// runPreStopHooks runs every unit's PreStop hook before the pod's
+// cell goes away. Termination blocks until the hooks complete or
+// preStopGracePeriod elapses.
+func (c *PodController) runPreStopHooks(pod *api.Pod) {
+	hasHooks := false
+	for i := range pod.Spec.Units {
+		if pod.Spec.Units[i].Lifecycle != nil &&
+			pod.Spec.Units[i].Lifecycle.PreStop != nil {
+			hasHooks = true
+			break
+		}
+	}
+	if !hasHooks {
+		return
+	}
+	node, err := c.nodeLister.GetNode(pod.Status.BoundNodeName)
+	if err != nil {
+		klog.Warningf("Getting node for pod %s PreStop hooks: %v",
+			pod.Name, err)
+		return
+	}
+	client := c.nodeClientFactory.GetClient(node.Status.Addresses)
+	podIP := api.GetPodIP(node.Status.Addresses)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range pod.Spec.Units {
+			unit := &pod.Spec.Units[i]
+			if unit.Lifecycle == nil || unit.Lifecycle.PreStop == nil {
+				continue
+			}
+			klog.V(2).Infof("Running PreStop hook for unit %s of pod %s",
+				unit.Name, pod.Name)
+			if err := runLifecycleHandler(client, podIP, unit, unit.Lifecycle.PreStop); err != nil {
+				klog.Warningf("PreStop hook for unit %s of pod %s: %v",
+					unit.Name, pod.Name, err)
+			}
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(preStopGracePeriod):
+		klog.Warningf("PreStop hooks for pod %s did not finish within %v",
+			pod.Name, preStopGracePeriod)
+	}
+}
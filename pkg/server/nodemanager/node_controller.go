@@ -19,15 +19,18 @@ package nodemanager
 import (
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math/rand"
 	"path"
 	"reflect"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/docker/libkv/store"
 	"github.com/elotl/kip/pkg/api"
+	"github.com/elotl/kip/pkg/api/annotations"
 	"github.com/elotl/kip/pkg/certs"
 	"github.com/elotl/kip/pkg/nodeclient"
 	"github.com/elotl/kip/pkg/server/cloud"
@@ -64,7 +67,41 @@ type NodeControllerConfig struct {
 	ReaperInterval    time.Duration
 	ItzoVersion       string
 	ItzoURL           string
-	CellConfig        map[string]string
+	// ItzoSHA256 is the expected checksum of the agent binary, from
+	// cells.itzo.sha256. Injected so the boot process verifies the
+	// download.
+	ItzoSHA256 string
+	CellConfig map[string]string
+	// RecycleNodes returns a used node to the available pool after
+	// its pod terminates instead of terminating the instance, as
+	// long as the node is young and healthy enough.
+	RecycleNodes bool
+	// RecycleNodeMaxAge bounds how old an instance may be and still
+	// be reused for another pod.
+	RecycleNodeMaxAge time.Duration
+	// SpreadSubnets launches new cells into the least-loaded eligible
+	// subnet instead of always using the configured one, from
+	// cells.subnetSpread. Only supported on AWS.
+	SpreadSubnets bool
+	// SubnetSpreadMaxSkew is how uneven the per-subnet cell counts
+	// may get before a subnet stops being a spread candidate. Values
+	// below 1 are treated as 1.
+	SubnetSpreadMaxSkew int
+	// ReplaceOutdatedCells enables the boot image rollout from
+	// cells.bootImageRollout: cells running an outdated boot image
+	// are replaced when their pod has opted in.
+	ReplaceOutdatedCells bool
+	// ReplaceMaxInFlight bounds how many cells may be tearing down
+	// for replacement at once. Values below 1 are treated as 1.
+	ReplaceMaxInFlight int
+	// ReplaceWindowStart and ReplaceWindowEnd bound when replacements
+	// happen, as "HH:MM" UTC times of day validated at startup. Empty
+	// allows replacements at any time.
+	ReplaceWindowStart string
+	ReplaceWindowEnd   string
+	// ReplaceNamespaces lists the namespaces whose pods are opted in
+	// to the rollout without a per-pod annotation.
+	ReplaceNamespaces []string
 }
 
 type NodeController struct {
@@ -83,6 +120,20 @@ type NodeController struct {
 	CertificateFactory *certs.CertificateFactory
 	CloudStatus        cloud.StatusKeeper
 	BootImageSpec      cloud.BootImageSpec
+	// lastCloudInit holds a redacted copy of the most recent merged
+	// cloud-init document we sent to the cloud launch API, kept
+	// around so operators can read it back via the dump endpoint.
+	lastCloudInit     string
+	lastCloudInitLock sync.Mutex
+	// launchWaitGroup tracks in-flight instance launches so that
+	// shutdown can wait for each launched instance ID to be recorded
+	// in the node registry instead of leaking untracked instances.
+	launchWaitGroup sync.WaitGroup
+	// launchLatencies holds one launch timing histogram per
+	// instance type, availability zone, lifecycle and boot phase,
+	// see launchLatencyKey for the key format.
+	launchLatencies   map[string]*stats.Histogram
+	launchLatencyLock sync.Mutex
 }
 
 func (c *NodeController) Start(quit <-chan struct{}, wg *sync.WaitGroup) {
@@ -94,12 +145,69 @@ func (c *NodeController) Start(quit <-chan struct{}, wg *sync.WaitGroup) {
 	nodeBindingsUpdate := make(chan map[string]string)
 	go c.updateBufferedNodesLoop(quit, wg, nodeBindingsUpdate)
 	go c.dispatchNodesLoop(quit, wg, nodeBindingsUpdate)
+	go c.drainLaunchesOnQuit(quit, wg)
 	go c.ImageIdCache.Start(30 * time.Second)
 }
 
+// drainLaunchesOnQuit keeps the system wait group open after quit
+// until every in-flight launch has recorded its instance ID in the
+// node registry.  Nodes left in Creating or Created at that point are
+// reaped by StopCreatingNodes or adopted by ResumeWaits on the next
+// start, so a restart mid-launch no longer orphans instances.
+func (c *NodeController) drainLaunchesOnQuit(quit <-chan struct{}, wg *sync.WaitGroup) {
+	wg.Add(1)
+	defer wg.Done()
+	<-quit
+	c.launchWaitGroup.Wait()
+}
+
 func (c *NodeController) Dump() []byte {
 	t := c.PoolLoopTimer.Copy()
-	b, err := json.MarshalIndent(*t, "", "    ")
+	c.lastCloudInitLock.Lock()
+	lastCloudInit := c.lastCloudInit
+	c.lastCloudInitLock.Unlock()
+	// The full candidate list shows operators why boot image
+	// selection picked the image it did (the last entry wins).
+	bootImageCandidates, err := c.CloudClient.ListImages(c.BootImageSpec)
+	if err != nil {
+		klog.Warningf("listing boot image candidates for dump: %v", err)
+	}
+	// LaunchQueueDepth is how many cloud calls are waiting on the
+	// launch rate limiter, it stays 0 unless one is configured.
+	launchQueueDepth := 0
+	if rl, ok := c.CloudClient.(cloud.RateLimitedClient); ok {
+		launchQueueDepth = rl.QueueDepth()
+	}
+	// CloudAPIRetries counts retried cloud calls since startup, it
+	// stays 0 unless the retry policy is configured.
+	var cloudAPIRetries int64
+	if rs, ok := c.CloudClient.(cloud.RetryStats); ok {
+		cloudAPIRetries = rs.RetryCount()
+	}
+	c.launchLatencyLock.Lock()
+	launchLatencies := make(map[string]*stats.Histogram, len(c.launchLatencies))
+	for key, hist := range c.launchLatencies {
+		launchLatencies[key] = hist.Copy()
+	}
+	c.launchLatencyLock.Unlock()
+	dumpStruct := struct {
+		Timer               *stats.LoopTimer            `json:"timer"`
+		LastCloudInit       string                      `json:"lastCloudInit"`
+		BootImageSpec       cloud.BootImageSpec         `json:"bootImageSpec"`
+		BootImageCandidates []cloud.Image               `json:"bootImageCandidates"`
+		LaunchQueueDepth    int                         `json:"launchQueueDepth"`
+		CloudAPIRetries     int64                       `json:"cloudAPIRetries"`
+		LaunchLatencies     map[string]*stats.Histogram `json:"launchLatencies"`
+	}{
+		Timer:               t,
+		LastCloudInit:       lastCloudInit,
+		BootImageSpec:       c.BootImageSpec,
+		BootImageCandidates: bootImageCandidates,
+		LaunchQueueDepth:    launchQueueDepth,
+		CloudAPIRetries:     cloudAPIRetries,
+		LaunchLatencies:     launchLatencies,
+	}
+	b, err := json.MarshalIndent(dumpStruct, "", "    ")
 	if err != nil {
 		klog.Errorln("Error dumping data from NodeController", err)
 		return nil
@@ -179,9 +287,115 @@ func (c *NodeController) doPoolsCalculation() (map[string]string, error) {
 			continue
 		}
 	}
+	c.replaceOutdatedNodes(nodes.Items)
 	return podNodeMap, nil
 }
 
+// replaceOutdatedNodes rolls a boot image update out to long-running
+// pods. A pod keeps the cell it was dispatched to until it exits, so
+// an updated boot image normally only reaches new pods; here the
+// cells of opted-in pods are marked for termination, which ejects the
+// pod and lets it restart on a cell running the latest image. Only
+// pods whose restart policy brings them back are touched, at most
+// maxInFlight replacements tear down at once and nothing happens
+// outside the configured maintenance window.
+func (c *NodeController) replaceOutdatedNodes(nodes []*api.Node) {
+	if !c.Config.ReplaceOutdatedCells || BootImage.ID == "" {
+		return
+	}
+	if !inMaintenanceWindow(time.Now().UTC(),
+		c.Config.ReplaceWindowStart, c.Config.ReplaceWindowEnd) {
+		return
+	}
+	budget := c.Config.ReplaceMaxInFlight
+	if budget < 1 {
+		budget = 1
+	}
+	for _, node := range nodes {
+		// replacements started earlier that are still tearing down
+		// count against the budget
+		if nodeImageOutdated(node) && node.Spec.Terminate &&
+			node.Status.Phase != api.NodeTerminated {
+			budget--
+		}
+	}
+	for _, node := range nodes {
+		if budget <= 0 {
+			return
+		}
+		if node.Status.Phase != api.NodeClaimed ||
+			node.Spec.Terminate ||
+			node.Status.BoundPodName == "" ||
+			!nodeImageOutdated(node) {
+			continue
+		}
+		pod, err := c.PodReader.GetPod(node.Status.BoundPodName)
+		if err != nil {
+			klog.Warningf("Could not get pod %s bound to outdated node %s: %v",
+				node.Status.BoundPodName, node.Name, err)
+			continue
+		}
+		if pod.Spec.RestartPolicy == api.RestartPolicyNever ||
+			!c.podWantsImageUpdate(pod) {
+			continue
+		}
+		c.Events.Emit(events.PodCellReplaced, "node-controller", pod,
+			"replacing cell of pod %s: boot image %s is outdated, latest is %s",
+			pod.Name, node.Spec.BootImage, BootImage.ID)
+		if _, err := c.NodeRegistry.MarkForTermination(node); err != nil {
+			klog.Errorf("Error marking outdated node %s for replacement: %v",
+				node.Name, err)
+			continue
+		}
+		budget--
+	}
+}
+
+func nodeImageOutdated(node *api.Node) bool {
+	return node.Spec.BootImage != "" && node.Spec.BootImage != BootImage.ID
+}
+
+// podWantsImageUpdate reports whether the pod opted in to the boot
+// image rollout, through its replace-on-image-update annotation or,
+// absent that, through its namespace being listed in the rollout
+// config.
+func (c *NodeController) podWantsImageUpdate(pod *api.Pod) bool {
+	if val, ok := pod.Annotations[annotations.PodReplaceOnImageUpdate]; ok {
+		optIn, err := strconv.ParseBool(val)
+		if err != nil {
+			klog.Warningf("annotation %s on pod %s has invalid value %q, ignoring",
+				annotations.PodReplaceOnImageUpdate, pod.Name, val)
+			return false
+		}
+		return optIn
+	}
+	return util.StringInSlice(pod.Namespace, c.Config.ReplaceNamespaces)
+}
+
+// inMaintenanceWindow reports whether now falls inside the [start,
+// end) time-of-day window. The window may wrap midnight and an empty
+// window means any time.
+func inMaintenanceWindow(now time.Time, start, end string) bool {
+	if start == "" || end == "" {
+		return true
+	}
+	startTime, err := time.Parse("15:04", start)
+	if err != nil {
+		return false
+	}
+	endTime, err := time.Parse("15:04", end)
+	if err != nil {
+		return false
+	}
+	minute := now.Hour()*60 + now.Minute()
+	startMinute := startTime.Hour()*60 + startTime.Minute()
+	endMinute := endTime.Hour()*60 + endTime.Minute()
+	if startMinute <= endMinute {
+		return minute >= startMinute && minute < endMinute
+	}
+	return minute >= startMinute || minute < endMinute
+}
+
 func (c *NodeController) getInstanceCloudInit() error {
 	cert, key, err := c.CertificateFactory.CreateNodeCertAndKey()
 	if err != nil {
@@ -205,10 +419,11 @@ func (c *NodeController) getInstanceCloudInit() error {
 		string(rootCertBytes), path.Join(itzoDir, "ca.crt"), "0644")
 	c.CloudInitFile.AddKipFile(
 		string(certBytes), path.Join(itzoDir, "server.crt"), "0644")
-	c.CloudInitFile.AddKipFile(
+	c.CloudInitFile.AddKipSecretFile(
 		string(keyBytes), path.Join(itzoDir, "server.key"), "0600")
 	c.CloudInitFile.AddItzoVersion(c.Config.ItzoVersion)
 	c.CloudInitFile.AddItzoURL(c.Config.ItzoURL)
+	c.CloudInitFile.AddItzoSHA256(c.Config.ItzoSHA256)
 	if len(c.Config.CellConfig) > 0 {
 		c.CloudInitFile.AddCellConfig(c.Config.CellConfig)
 	}
@@ -221,9 +436,21 @@ func (c *NodeController) getCloudInitContents() (string, error) {
 		return "", util.WrapError(
 			err, "Error creating Kip instance keys for cloud-init data")
 	}
+	// The size limit and gzip fallback live on the cloud-init file,
+	// configure them from the provider's user-data limit before
+	// rendering.
+	attrs := c.CloudClient.GetAttributes()
+	c.CloudInitFile.SetSizeLimit(attrs.MaxUserDataSize)
+	c.CloudInitFile.SetGzipOverLimit(true)
 	cloudInitData, err := c.CloudInitFile.Contents()
 	if err != nil {
-		return "", util.WrapError(err, "Error creating Kip cloud-init contents")
+		return "", util.WrapError(err, "Error creating %s cloud-init user data",
+			attrs.Provider)
+	}
+	if redacted, err := c.CloudInitFile.RedactedContents(); err == nil {
+		c.lastCloudInitLock.Lock()
+		c.lastCloudInit = string(redacted)
+		c.lastCloudInitLock.Unlock()
 	}
 	metadata := base64.StdEncoding.EncodeToString(cloudInitData)
 	return metadata, nil
@@ -251,15 +478,168 @@ func (c *NodeController) startNodes(nodes []*api.Node, image cloud.Image) {
 			klog.V(2).Infof("Rate limiting start requests to %d per iteration", MaxBootPerIteration)
 			break
 		}
+		if newNode.Spec.Placement.SpreadAcrossZones &&
+			newNode.Spec.Placement.AvailabilityZone == "" {
+			c.assignSpreadZone(newNode)
+		}
+		if c.Config.SpreadSubnets && newNode.Spec.Placement.SubnetID == "" {
+			c.assignSpreadSubnet(newNode)
+		}
+		// the finalizer keeps the node record around until its cloud
+		// resources are confirmed released
+		newNode.AddFinalizer(api.CloudResourcesFinalizer)
 		newNode, err := c.NodeRegistry.CreateNode(newNode)
 		if err != nil {
 			klog.Errorf("Error creating node in registry: %v", err)
 			continue
 		}
+		c.launchWaitGroup.Add(1)
 		go c.startSingleNode(newNode, image, metadata)
 	}
 }
 
+// assignSpreadSubnet implements a topology spread across subnets:
+// the next cell goes to the least-loaded eligible subnet so a busy
+// controller doesn't exhaust one subnet's addresses while the rest
+// of the VPC sits idle. Any subnet whose cell count is within the
+// configured maxSkew of the emptiest one is a candidate, and among
+// candidates the one with the most free addresses wins. When no
+// eligible subnet is found the node's placement is left alone and
+// the launch falls back to the configured subnet.
+func (c *NodeController) assignSpreadSubnet(node *api.Node) {
+	if c.CloudClient.GetAttributes().Provider != cloud.ProviderAWS {
+		return
+	}
+	subnets := c.CloudStatus.GetAvailableSubnets(
+		node.Spec.InstanceType, node.Spec.Spot, node.Spec.Resources.PrivateIPOnly)
+	if az := node.Spec.Placement.AvailabilityZone; az != "" {
+		eligible := subnets[:0]
+		for _, sn := range subnets {
+			if sn.AZ == az {
+				eligible = append(eligible, sn)
+			}
+		}
+		subnets = eligible
+	}
+	if len(subnets) == 0 {
+		return
+	}
+	counts := c.subnetCellCounts()
+	minCount := counts[subnets[0].ID]
+	for _, sn := range subnets[1:] {
+		if counts[sn.ID] < minCount {
+			minCount = counts[sn.ID]
+		}
+	}
+	maxSkew := c.Config.SubnetSpreadMaxSkew
+	if maxSkew < 1 {
+		maxSkew = 1
+	}
+	best := -1
+	for i := range subnets {
+		if counts[subnets[i].ID] >= minCount+maxSkew {
+			continue
+		}
+		if best < 0 ||
+			subnets[i].AvailableAddresses > subnets[best].AvailableAddresses ||
+			(subnets[i].AvailableAddresses == subnets[best].AvailableAddresses &&
+				counts[subnets[i].ID] < counts[subnets[best].ID]) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return
+	}
+	node.Spec.Placement.SubnetID = subnets[best].ID
+	klog.V(2).Infof("spreading node %s into subnet %s (%d cells, %d free addresses)",
+		node.Name, subnets[best].ID, counts[subnets[best].ID],
+		subnets[best].AvailableAddresses)
+}
+
+// assignSpreadZone implements a topology spread across availability
+// zones: the next cell goes to the candidate zone with the fewest
+// live cells, so consecutive dispatches round-robin through the
+// zones. Candidates are the placement's availabilityZones when set,
+// otherwise every zone in the region; zones the cloud reports as out
+// of capacity for the instance type are skipped. When no candidate
+// zone is usable the placement is left alone and the cloud picks the
+// zone.
+func (c *NodeController) assignSpreadZone(node *api.Node) {
+	zones := node.Spec.Placement.AvailabilityZones
+	if len(zones) == 0 {
+		var err error
+		zones, err = c.CloudClient.GetAvailabilityZones()
+		if err != nil {
+			klog.Warningf("Error listing availability zones for node %s: %v",
+				node.Name, err)
+			return
+		}
+	}
+	counts := c.zoneCellCounts()
+	best := ""
+	for _, az := range zones {
+		if c.CloudStatus.IsUnavailableZone(
+			node.Spec.InstanceType, node.Spec.Spot,
+			node.Spec.Resources.PrivateIPOnly, az) {
+			continue
+		}
+		if best == "" || counts[az] < counts[best] {
+			best = az
+		}
+	}
+	if best == "" {
+		return
+	}
+	node.Spec.Placement.AvailabilityZone = best
+	klog.V(2).Infof("spreading node %s into availability zone %s (%d cells)",
+		node.Name, best, counts[best])
+}
+
+// zoneCellCounts returns how many live cells are in each availability
+// zone.
+func (c *NodeController) zoneCellCounts() map[string]int {
+	counts := make(map[string]int)
+	nodes, err := c.NodeRegistry.ListNodes(func(n *api.Node) bool {
+		return n.Status.Phase != api.NodeTerminated &&
+			n.Spec.Placement.AvailabilityZone != ""
+	})
+	if err != nil {
+		klog.Warningf("Error listing nodes for zone spread: %v", err)
+		return counts
+	}
+	for _, n := range nodes.Items {
+		counts[n.Spec.Placement.AvailabilityZone]++
+	}
+	return counts
+}
+
+// subnetCellCounts returns how many live cells are in each subnet.
+func (c *NodeController) subnetCellCounts() map[string]int {
+	counts := make(map[string]int)
+	nodes, err := c.NodeRegistry.ListNodes(func(n *api.Node) bool {
+		return n.Status.Phase != api.NodeTerminated && n.Status.SubnetID != ""
+	})
+	if err != nil {
+		klog.Warningf("Error listing nodes for subnet spread: %v", err)
+		return counts
+	}
+	for _, n := range nodes.Items {
+		counts[n.Status.SubnetID]++
+	}
+	return counts
+}
+
+// isCapacityError reports whether a node start failed because the
+// cloud couldn't provide the instance, as opposed to a bad request or
+// credentials problem that an on-demand retry would hit as well.
+func isCapacityError(err error) bool {
+	switch err.(type) {
+	case *cloud.NoCapacityError, *cloud.UnsupportedInstanceError:
+		return true
+	}
+	return false
+}
+
 func (c *NodeController) handleStartNodeError(node *api.Node, err error, isSpot bool) {
 	switch err := err.(type) {
 	case *cloud.NoCapacityError:
@@ -278,6 +658,27 @@ func (c *NodeController) handleStartNodeError(node *api.Node, err error, isSpot
 	}
 }
 
+// emitSpotLaunchFailure makes spot capacity problems visible to the
+// user. A pod that requires spot instances can sit failing or
+// retrying for a long time and, without this, the reason only shows
+// up in our logs, indistinguishable from any other launch failure.
+// Only capacity and price problems are reported, other errors aren't
+// specific to spot.
+func (c *NodeController) emitSpotLaunchFailure(node *api.Node, err error) {
+	switch err.(type) {
+	case *cloud.NoCapacityError, *cloud.UnsupportedInstanceError:
+	default:
+		return
+	}
+	az := node.Spec.Placement.AvailabilityZone
+	if az == "" {
+		az = "any availability zone"
+	}
+	c.Events.Emit(events.StartSpotFailed, "node-controller", node,
+		"Warning: could not get a spot instance of type %s in %s, there is no spot capacity available or the max price is too low: %v",
+		node.Spec.InstanceType, az, err)
+}
+
 func (c *NodeController) startSingleNode(node *api.Node, image cloud.Image, cloudInitData string) error {
 	var (
 		startResult *cloud.StartNodeResult
@@ -285,11 +686,32 @@ func (c *NodeController) startSingleNode(node *api.Node, image cloud.Image, clou
 	)
 	if node.Spec.Spot {
 		startResult, err = c.CloudClient.StartSpotNode(node, image, cloudInitData)
+		if err != nil && node.Spec.SpotFallback && isCapacityError(err) {
+			// the pod behind this node prefers spot but doesn't
+			// require it, so record the shortage and boot an
+			// on-demand instance instead
+			c.handleStartNodeError(node, err, true)
+			c.emitSpotLaunchFailure(node, err)
+			klog.V(2).Infof("No spot capacity for node %s, falling back to an on-demand instance: %v",
+				node.Name, err)
+			node.Spec.Spot = false
+			_, err = c.NodeRegistry.AtomicUpdate(node.Name, func(in *api.Node) error {
+				in.Spec.Spot = false
+				return nil
+			})
+			if err == nil {
+				startResult, err = c.CloudClient.StartNode(node, image, cloudInitData)
+			}
+		}
 	} else {
 		startResult, err = c.CloudClient.StartNode(node, image, cloudInitData)
 	}
 	if err != nil {
+		c.launchWaitGroup.Done()
 		c.handleStartNodeError(node, err, false)
+		if node.Spec.Spot {
+			c.emitSpotLaunchFailure(node, err)
+		}
 		klog.Errorf("Error in node start: %v", err)
 		_, regError := c.NodeRegistry.PurgeNode(node)
 		if regError != nil {
@@ -299,26 +721,94 @@ func (c *NodeController) startSingleNode(node *api.Node, image cloud.Image, clou
 		return util.WrapError(err, "Error starting node")
 	}
 	node.Status.InstanceID = startResult.InstanceID
+	node.Status.SubnetID = startResult.SubnetID
 	node.Spec.Placement.AvailabilityZone = startResult.AvailabilityZone
+	if startResult.SubnetID != "" {
+		c.CloudStatus.ClaimSubnetAddress(startResult.SubnetID)
+	}
+	node.Status.Phase = api.NodeCreated
+	_, _ = c.NodeRegistry.UpdateStatus(node)
+	c.Events.Emit(events.NodeCreated, "node-created", node, "")
+	// The instance ID is in the registry now so a restart can adopt
+	// or reap the instance; shutdown doesn't wait for the boot.
+	c.launchWaitGroup.Done()
 	return c.finishNodeStart(node)
 }
 
 func (c *NodeController) finishNodeStart(node *api.Node) error {
-	node.Status.Phase = api.NodeCreated
-	_, _ = c.NodeRegistry.UpdateStatus(node)
-	c.Events.Emit(events.NodeCreated, "node-created", node, "")
 	// todo: we know the instance is running, we could just do
 	// a describe instance here...
 	addresses, err := c.CloudClient.WaitForRunning(node)
 	if err != nil {
 		klog.V(2).Infof("Unhealthy wait for running, terminating node: %s", node.Name)
+		c.Events.Emit(events.NodeBootTimeout, "node-controller", node,
+			"instance %s never reached running: %v", node.Status.InstanceID, err)
 		_ = c.stopSingleNode(node)
 		return util.WrapError(err, "Error waiting for node to be running")
 	}
+	runningAt := time.Now().UTC()
 	c.Events.Emit(events.NodeRunning, "node-controller", node, "")
 	node.Status.Addresses = addresses
 	_, _ = c.NodeRegistry.UpdateStatus(node)
-	return c.waitForAvailableOrTerminate(node, BootTimeout)
+	err = c.waitForAvailableOrTerminate(node, BootTimeout)
+	if err != nil {
+		return err
+	}
+	c.recordLaunchLatency(node, runningAt, time.Now().UTC())
+	return nil
+}
+
+// launchLatencyBuckets are the histogram bucket upper bounds used
+// for node launch timings.
+var launchLatencyBuckets = []time.Duration{
+	30 * time.Second,
+	60 * time.Second,
+	90 * time.Second,
+	2 * time.Minute,
+	3 * time.Minute,
+	5 * time.Minute,
+	10 * time.Minute,
+}
+
+func launchLatencyKey(node *api.Node, phase string) string {
+	lifecycle := "on-demand"
+	if node.Spec.Spot {
+		lifecycle = "spot"
+	}
+	return fmt.Sprintf("%s/%s/%s/%s",
+		node.Spec.InstanceType, node.Spec.Placement.AvailabilityZone, lifecycle, phase)
+}
+
+func (c *NodeController) observeLaunchLatency(node *api.Node, phase string, d time.Duration) {
+	key := launchLatencyKey(node, phase)
+	c.launchLatencyLock.Lock()
+	if c.launchLatencies == nil {
+		c.launchLatencies = make(map[string]*stats.Histogram)
+	}
+	hist := c.launchLatencies[key]
+	if hist == nil {
+		hist = stats.NewHistogram(launchLatencyBuckets)
+		c.launchLatencies[key] = hist
+	}
+	c.launchLatencyLock.Unlock()
+	hist.Observe(d)
+}
+
+// recordLaunchLatency records how long a fresh launch spent waiting
+// for the cloud to run the instance and for the cell agent to come
+// up. The timings feed the per instance type/AZ/lifecycle histograms
+// in the dump endpoint and the total lands on the node's status.
+func (c *NodeController) recordLaunchLatency(node *api.Node, runningAt, readyAt time.Time) {
+	dispatchedAt := node.CreationTimestamp.Time
+	c.observeLaunchLatency(node, "dispatch-to-running", runningAt.Sub(dispatchedAt))
+	c.observeLaunchLatency(node, "running-to-ready", readyAt.Sub(runningAt))
+	total := readyAt.Sub(dispatchedAt)
+	c.observeLaunchLatency(node, "total", total)
+	node.Status.LaunchLatencySeconds = total.Seconds()
+	_, err := c.NodeRegistry.UpdateStatus(node)
+	if err != nil {
+		klog.Warningf("Could not save launch latency on node %s: %v", node.Name, err)
+	}
 }
 
 func (c *NodeController) stopSingleNode(node *api.Node) error {
@@ -334,8 +824,26 @@ func (c *NodeController) stopSingleNode(node *api.Node) error {
 	}
 	c.NodeClientFactory.DeleteClient(node.Status.Addresses)
 	go func(n *api.Node) {
-		_ = c.CloudClient.StopInstance(n.Status.InstanceID)
-		_, err := c.NodeRegistry.PurgeNode(node)
+		if n.Status.InstanceID != "" {
+			err := c.CloudClient.StopInstance(n.Status.InstanceID)
+			if err != nil && n.HasFinalizer(api.CloudResourcesFinalizer) {
+				// we couldn't confirm the instance is gone, keep the
+				// node record (and its finalizer) around so the
+				// reaper retries the stop on its next pass
+				klog.Errorf("Error stopping instance %s of node %s, will retry: %v",
+					n.Status.InstanceID, n.Name, err)
+				return
+			}
+		}
+		n, err := c.NodeRegistry.AtomicUpdate(n.Name, func(in *api.Node) error {
+			in.RemoveFinalizer(api.CloudResourcesFinalizer)
+			return nil
+		})
+		if err != nil {
+			klog.Errorf("Could not remove finalizer from node %s: %v", node.Name, err)
+			return
+		}
+		_, err = c.NodeRegistry.PurgeNode(n)
 		if err != nil {
 			klog.Errorf("Could not mark node %s as terminated: %v", n.Name, err)
 		}
@@ -481,9 +989,11 @@ func (c *NodeController) markUnhealthyNodes(allNodes *api.NodeList, LastHeartbea
 			continue
 		}
 		if now.Sub(last) < HealthyTimeout {
+			c.updateReadyCondition(node, last, true)
 			continue
 		}
 		klog.Warningf("No heartbeats from node %s. Set to terminate.", node.Name)
+		c.updateReadyCondition(node, last, false)
 		node, err := c.NodeRegistry.MarkForTermination(node)
 		if err != nil {
 			klog.Errorf("Error marking node %s for termination", node.Name)
@@ -492,6 +1002,26 @@ func (c *NodeController) markUnhealthyNodes(allNodes *api.NodeList, LastHeartbea
 	return nil
 }
 
+// updateReadyCondition keeps the node's Ready condition in sync with
+// the heartbeat loop, only writing to the registry when the status
+// flips.
+func (c *NodeController) updateReadyCondition(node *api.Node, lastHeartbeat time.Time, healthy bool) {
+	status := api.ConditionTrue
+	reason := "Heartbeat"
+	message := ""
+	if !healthy {
+		status = api.ConditionFalse
+		reason = "HeartbeatTimeout"
+		message = fmt.Sprintf("no heartbeat since %s", lastHeartbeat.Format(time.RFC3339))
+	}
+	if node.Status.SetCondition(api.NodeReady, status, reason, message) {
+		if _, err := c.NodeRegistry.UpdateStatus(node); err != nil {
+			klog.Errorf("Error updating ready condition on node %s: %v",
+				node.Name, err)
+		}
+	}
+}
+
 // go through and remove any heartbeat records for nodes
 // that no longer exist.
 func pruneHeartbeats(allNodes *api.NodeList, lastHeartbeat map[string]time.Time) {
@@ -528,10 +1058,28 @@ func (c *NodeController) waitForAvailableOrTerminate(node *api.Node, timeout tim
 	}
 	klog.V(2).Infof("Waiting for available on node %s", node.Name)
 	client := c.NodeClientFactory.GetClient(node.Status.Addresses)
-	err := waitForHealthy(node, client, timeout)
+	err := waitForHealthy(node, client, timeout, func() bool {
+		return c.launchAborted(node.Name)
+	})
+	if err == ErrLaunchAborted {
+		klog.V(2).Infof("Aborting launch of node %s, node was marked for termination mid-boot", node.Name)
+		c.Events.Emit(events.NodeLaunchAborted, "node-controller", node,
+			"aborted launch of instance %s, node was marked for termination before the agent came up", node.Status.InstanceID)
+		n, gerr := c.NodeRegistry.GetNode(node.Name)
+		if gerr == nil &&
+			n.Status.Phase != api.NodeTerminating &&
+			n.Status.Phase != api.NodeTerminated {
+			// The reaper would get to it eventually, stop the
+			// instance now instead of letting the boot finish.
+			_ = c.stopSingleNode(n)
+		}
+		return err
+	}
 	if err != nil {
 		klog.Errorf("Error in node start: node unresponsive for %s seconds", timeout)
 		klog.V(2).Infof("Terminating node: %s", node.Name)
+		c.Events.Emit(events.NodeBootTimeout, "node-controller", node,
+			"instance %s running but agent not healthy after %s: %v", node.Status.InstanceID, timeout, err)
 		_ = c.stopSingleNode(node)
 		return util.WrapError(err, "Error waiting for healthy node")
 	}
@@ -546,21 +1094,44 @@ func (c *NodeController) waitForAvailableOrTerminate(node *api.Node, timeout tim
 	return nil
 }
 
-func waitForHealthy(node *api.Node, client nodeclient.NodeClient, timeout time.Duration) error {
-	success := false
+// ErrLaunchAborted is returned from a boot wait when the booting node
+// was marked for termination before its agent became healthy, e.g.
+// because the pod the node was launched for was deleted mid-boot.
+var ErrLaunchAborted = errors.New("node launch aborted: node was marked for termination")
+
+// launchAborted reports whether a booting node has been marked for
+// termination behind our back, which happens when the pod the node
+// was created for goes away while the instance is still booting.
+func (c *NodeController) launchAborted(name string) bool {
+	n, err := c.NodeRegistry.GetNode(name)
+	if err != nil {
+		return false
+	}
+	return n.Spec.Terminate ||
+		n.Status.Phase == api.NodeTerminating ||
+		n.Status.Phase == api.NodeTerminated
+}
+
+func waitForHealthy(node *api.Node, client nodeclient.NodeClient, timeout time.Duration, aborted func() bool) error {
+	// Keep the last healthcheck error around: a connection error
+	// means the agent never came up while an application-level reply
+	// means the cell was at least partway through booting.
+	var lastErr error
 	giveUp := time.Now().UTC().Add(timeout)
 	for time.Now().UTC().Before(giveUp) {
-		err := client.Healthcheck()
-		if err == nil {
-			success = true
-			break
+		if aborted != nil && aborted() {
+			return ErrLaunchAborted
+		}
+		lastErr = client.Healthcheck()
+		if lastErr == nil {
+			return nil
 		}
 		time.Sleep(HealthcheckPause)
 	}
-	if !success {
-		return fmt.Errorf("Not Healthy")
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no healthcheck completed before the timeout")
 	}
-	return nil
+	return util.WrapError(lastErr, "Not Healthy")
 }
 
 func filterNodes(allNodes *api.NodeList, pred func(*api.Node) bool) []*api.Node {
@@ -574,7 +1145,7 @@ func filterNodes(allNodes *api.NodeList, pred func(*api.Node) bool) []*api.Node
 }
 
 // Goes through and stops nodes that we have asked to be terminated
-func (c NodeController) reaperLoop(quit <-chan struct{}, wg *sync.WaitGroup) {
+func (c *NodeController) reaperLoop(quit <-chan struct{}, wg *sync.WaitGroup) {
 	wg.Add(1)
 	defer wg.Done()
 
@@ -764,6 +1335,48 @@ func (c *NodeController) saveNodeLogs(node *api.Node) {
 	}
 }
 
+// recycleNode tries to return a used node to the available pool
+// instead of terminating it, so back-to-back pods with the same
+// shape skip the instance boot. The node is only reused when
+// recycling is enabled, the node hasn't been asked to terminate, the
+// instance is younger than the configured bound and the agent still
+// answers healthchecks after the previous pod's units, volumes and
+// deployed files have been wiped with an empty spec.
+func (c *NodeController) recycleNode(node *api.Node) bool {
+	if !c.Config.RecycleNodes || node.Spec.Terminate {
+		return false
+	}
+	age := time.Since(node.CreationTimestamp.Time)
+	if age > c.Config.RecycleNodeMaxAge {
+		klog.V(2).Infof("Node %s is %s old, past the recycling bound, terminating",
+			node.Name, age.Round(time.Second))
+		return false
+	}
+	if len(node.Status.Addresses) == 0 {
+		return false
+	}
+	client := c.NodeClientFactory.GetClient(node.Status.Addresses)
+	if err := client.UpdateUnits(api.PodParameters{}); err != nil {
+		klog.Warningf("Error resetting node %s for reuse, terminating: %v",
+			node.Name, err)
+		return false
+	}
+	if err := client.Healthcheck(); err != nil {
+		klog.Warningf("Node %s failed its recycling healthcheck, terminating: %v",
+			node.Name, err)
+		return false
+	}
+	node.Status.Phase = api.NodeAvailable
+	if _, err := c.NodeRegistry.UpdateStatus(node); err != nil {
+		klog.Errorf("Error returning recycled node %s to the pool: %v",
+			node.Name, err)
+		return false
+	}
+	klog.V(2).Infof("Recycled node %s back into the pool", node.Name)
+	c.Events.Emit(events.NodeRecycled, "node-controller", node, "")
+	return true
+}
+
 func (c *NodeController) cleanUsedNode(name string) error {
 	node, err := c.NodeRegistry.GetNode(name)
 	if err != nil {
@@ -789,6 +1402,9 @@ func (c *NodeController) cleanUsedNode(name string) error {
 		klog.Errorf(err.Error())
 	}
 	c.saveNodeLogs(node)
+	if c.recycleNode(node) {
+		return nil
+	}
 	// We've decided to skip cleaning and just terminate.  if you
 	// decide to remove the node-cleaning phase entirely then please
 	// make sure to double check and make sure that any nodes returned
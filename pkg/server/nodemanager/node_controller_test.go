@@ -25,6 +25,7 @@ import (
 	"time"
 
 	"github.com/elotl/kip/pkg/api"
+	"github.com/elotl/kip/pkg/api/annotations"
 	"github.com/elotl/kip/pkg/certs"
 	"github.com/elotl/kip/pkg/nodeclient"
 	"github.com/elotl/kip/pkg/server/cloud"
@@ -60,7 +61,10 @@ func FakeLister() ([]cloud.CloudInstance, error) {
 }
 
 func StartReturnsOK(node *api.Node, image cloud.Image, metadata string) (*cloud.StartNodeResult, error) {
-	result := &cloud.StartNodeResult{"instID", "us-east-1a"}
+	result := &cloud.StartNodeResult{
+		InstanceID:       "instID",
+		AvailabilityZone: "us-east-1a",
+	}
 	return result, nil
 }
 
@@ -246,6 +250,104 @@ func TestStartNodeFails(t *testing.T) {
 	assert.Equal(t, api.NodeTerminated, nodes.Items[0].Status.Phase)
 }
 
+func TestStartSpotNodeFallsBack(t *testing.T) {
+	t.Parallel()
+	HealthyTimeout = 3000 * time.Millisecond
+	HealthcheckPause = 100 * time.Millisecond
+	ctl, closer := MakeNodeController()
+	defer closer()
+	spotCalls := 0
+	client := cloud.NewMockClient()
+	client.SpotStarter = func(node *api.Node, image cloud.Image, metadata string) (*cloud.StartNodeResult, error) {
+		spotCalls++
+		return nil, &cloud.NoCapacityError{OriginalError: "no spot capacity"}
+	}
+	client.Starter = StartReturnsOK
+	client.Waiter = ReturnAddresses
+	ctl.CloudClient = client
+	node := api.GetFakeNode()
+	node.Spec.Spot = true
+	node.Spec.SpotFallback = true
+	ctl.startNodes([]*api.Node{node}, cloud.Image{})
+	time.Sleep(1 * time.Second)
+	assert.Equal(t, 1, spotCalls)
+	nodes, err := ctl.NodeRegistry.ListAllNodes(registry.MatchAllNodes)
+	assert.Nil(t, err)
+	assert.Len(t, nodes.Items, 1)
+	assert.Equal(t, api.NodeAvailable, nodes.Items[0].Status.Phase)
+	// the fallback flipped the node to on-demand
+	assert.False(t, nodes.Items[0].Spec.Spot)
+}
+
+func TestStartSpotNodeNoFallbackOnFatalError(t *testing.T) {
+	t.Parallel()
+	HealthyTimeout = 500 * time.Millisecond
+	HealthcheckPause = 100 * time.Millisecond
+	ctl, closer := MakeNodeController()
+	defer closer()
+	onDemandCalls := 0
+	client := cloud.NewMockClient()
+	client.SpotStarter = StartFails
+	client.Starter = func(node *api.Node, image cloud.Image, metadata string) (*cloud.StartNodeResult, error) {
+		onDemandCalls++
+		return StartReturnsOK(node, image, metadata)
+	}
+	client.Waiter = ReturnAddresses
+	ctl.CloudClient = client
+	node := api.GetFakeNode()
+	node.Spec.Spot = true
+	node.Spec.SpotFallback = true
+	ctl.startNodes([]*api.Node{node}, cloud.Image{})
+	time.Sleep(1 * time.Second)
+	// a non-capacity error must not be masked by an on-demand retry
+	assert.Equal(t, 0, onDemandCalls)
+	nodes, err := ctl.NodeRegistry.ListAllNodes(registry.MatchAllNodes)
+	assert.Nil(t, err)
+	assert.Len(t, nodes.Items, 1)
+	assert.Equal(t, api.NodeTerminated, nodes.Items[0].Status.Phase)
+}
+
+func TestDrainLaunchesOnQuit(t *testing.T) {
+	t.Parallel()
+	ctl, closer := MakeNodeController()
+	defer closer()
+	release := make(chan struct{})
+	ctl.CloudClient = &cloud.MockCloudClient{
+		Starter: func(node *api.Node, image cloud.Image, metadata string) (*cloud.StartNodeResult, error) {
+			<-release
+			return StartReturnsOK(node, image, metadata)
+		},
+		Stopper: ReturnNil,
+		Waiter:  ReturnAddresses,
+	}
+	quit := make(chan struct{})
+	wg := &sync.WaitGroup{}
+	go ctl.drainLaunchesOnQuit(quit, wg)
+	ctl.startNodes([]*api.Node{api.GetFakeNode()}, cloud.Image{})
+	time.Sleep(50 * time.Millisecond)
+	close(quit)
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("shutdown finished while a launch was still in flight")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("shutdown did not finish after the launch was recorded")
+	}
+	nodes, err := ctl.NodeRegistry.ListAllNodes(registry.MatchAllNodes)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(nodes.Items))
+	assert.Equal(t, "instID", nodes.Items[0].Status.InstanceID)
+}
+
 func OKResponse(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("pong"))
 }
@@ -401,6 +503,45 @@ func TestCleanUsedNode(t *testing.T) {
 	assert.Equal(t, eventCleanedNode.Status.BoundPodName, boundPod)
 }
 
+func TestRecycleUsedNode(t *testing.T) {
+	t.Parallel()
+	ctl, closer := MakeNodeController()
+	defer closer()
+	ctl.Config.RecycleNodes = true
+	ctl.Config.RecycleNodeMaxAge = time.Hour
+	recycled := false
+	ctl.Events.RegisterHandlerFunc(events.NodeRecycled, func(e events.Event) error {
+		recycled = true
+		return nil
+	})
+	n := api.GetFakeNode()
+	n, _ = ctl.NodeRegistry.CreateNode(n)
+	n.Status.Phase = api.NodeAvailable
+	n.Status.Addresses = api.NewNetworkAddresses("1.2.3.4", "")
+	n, _ = ctl.NodeRegistry.UpdateStatus(n)
+	n.Status.Phase = api.NodeClaimed
+	n.Status.BoundPodName = "testpod"
+	n, _ = ctl.NodeRegistry.UpdateStatus(n)
+	err := ctl.cleanUsedNode(n.Name)
+	assert.Nil(t, err)
+	time.Sleep(1 * time.Second)
+	// The node goes back into the pool instead of being purged.
+	n, err = ctl.NodeRegistry.GetNode(n.Name)
+	assert.Nil(t, err)
+	assert.Equal(t, api.NodeAvailable, n.Status.Phase)
+	assert.Equal(t, "", n.Status.BoundPodName)
+	assert.True(t, recycled)
+	// A node past the age bound is terminated as before.
+	ctl.Config.RecycleNodeMaxAge = 0
+	n.Status.Phase = api.NodeClaimed
+	n, _ = ctl.NodeRegistry.UpdateStatus(n)
+	err = ctl.cleanUsedNode(n.Name)
+	assert.Nil(t, err)
+	time.Sleep(1 * time.Second)
+	_, err = ctl.NodeRegistry.GetNode(n.Name)
+	assert.NotNil(t, err)
+}
+
 func TestSendOutHeartbeats(t *testing.T) {
 	t.Parallel()
 	ctl, closer := MakeNodeController()
@@ -689,3 +830,130 @@ func TestDoPoolsCalculationComputeFails(t *testing.T) {
 	_, err := ctl.doPoolsCalculation()
 	assert.Error(t, err)
 }
+
+func TestAssignSpreadSubnet(t *testing.T) {
+	t.Parallel()
+	ctl, closer := MakeNodeController()
+	defer closer()
+	// Put a cell in sub-1111 so it's more loaded than sub-3333.
+	n := makeTestOndemandNode(t, ctl, api.NodeAvailable)
+	n.Status.SubnetID = "sub-1111"
+	_, err := ctl.NodeRegistry.UpdateStatus(n)
+	assert.NoError(t, err)
+
+	// The least-loaded eligible public subnet wins.
+	node := api.GetFakeNode()
+	node.Spec.InstanceType = defaultInstanceType
+	ctl.assignSpreadSubnet(node)
+	assert.Equal(t, "sub-3333", node.Spec.Placement.SubnetID)
+
+	// Private-only pods are limited to private subnets.
+	node = api.GetFakeNode()
+	node.Spec.InstanceType = defaultInstanceType
+	node.Spec.Resources.PrivateIPOnly = true
+	ctl.assignSpreadSubnet(node)
+	assert.Equal(t, "sub-2222", node.Spec.Placement.SubnetID)
+
+	// An explicit AZ restricts the spread to that zone's subnets.
+	node = api.GetFakeNode()
+	node.Spec.InstanceType = defaultInstanceType
+	node.Spec.Placement.AvailabilityZone = "us-east-1a"
+	ctl.assignSpreadSubnet(node)
+	assert.Equal(t, "sub-1111", node.Spec.Placement.SubnetID)
+}
+
+func TestAssignSpreadZone(t *testing.T) {
+	t.Parallel()
+	ctl, closer := MakeNodeController()
+	defer closer()
+	mockClient := ctl.CloudClient.(*cloud.MockCloudClient)
+	mockClient.AZGetter = func() ([]string, error) {
+		return []string{"us-east-1a", "us-east-1b", "us-east-1c"}, nil
+	}
+
+	// The emptiest usable zone wins. us-east-1b only has a private
+	// subnet so public nodes can't go there.
+	node := api.GetFakeNode()
+	node.Spec.InstanceType = defaultInstanceType
+	ctl.assignSpreadZone(node)
+	assert.Equal(t, "us-east-1a", node.Spec.Placement.AvailabilityZone)
+
+	// With a cell in us-east-1a, the next dispatch round-robins to
+	// us-east-1c.
+	n := api.GetFakeNode()
+	n.Spec.BootImage = defaultBootImageID
+	n.Spec.InstanceType = defaultInstanceType
+	n.Spec.Placement.AvailabilityZone = "us-east-1a"
+	n, err := ctl.NodeRegistry.CreateNode(n)
+	assert.NoError(t, err)
+	n.Status.Phase = api.NodeAvailable
+	_, err = ctl.NodeRegistry.UpdateStatus(n)
+	assert.NoError(t, err)
+	node = api.GetFakeNode()
+	node.Spec.InstanceType = defaultInstanceType
+	ctl.assignSpreadZone(node)
+	assert.Equal(t, "us-east-1c", node.Spec.Placement.AvailabilityZone)
+
+	// An explicit candidate list restricts the spread.
+	node = api.GetFakeNode()
+	node.Spec.InstanceType = defaultInstanceType
+	node.Spec.Placement.AvailabilityZones = []string{"us-east-1a"}
+	ctl.assignSpreadZone(node)
+	assert.Equal(t, "us-east-1a", node.Spec.Placement.AvailabilityZone)
+
+	// An exhausted zone is skipped even when it's the emptiest.
+	ctl.CloudStatus.AddUnavailableZone(defaultInstanceType, false, "us-east-1c")
+	node = api.GetFakeNode()
+	node.Spec.InstanceType = defaultInstanceType
+	ctl.assignSpreadZone(node)
+	assert.Equal(t, "us-east-1a", node.Spec.Placement.AvailabilityZone)
+}
+
+func TestInMaintenanceWindow(t *testing.T) {
+	t.Parallel()
+	at := func(hhmm string) time.Time {
+		ts, err := time.Parse("15:04", hhmm)
+		assert.NoError(t, err)
+		return ts
+	}
+	tests := []struct {
+		now    string
+		start  string
+		end    string
+		inside bool
+	}{
+		{"12:00", "", "", true},
+		{"12:00", "10:00", "14:00", true},
+		{"09:59", "10:00", "14:00", false},
+		{"14:00", "10:00", "14:00", false},
+		// window wrapping midnight
+		{"23:30", "22:00", "04:00", true},
+		{"03:59", "22:00", "04:00", true},
+		{"12:00", "22:00", "04:00", false},
+	}
+	for _, tc := range tests {
+		assert.Equal(t, tc.inside,
+			inMaintenanceWindow(at(tc.now), tc.start, tc.end),
+			"now %s window %s-%s", tc.now, tc.start, tc.end)
+	}
+}
+
+func TestPodWantsImageUpdate(t *testing.T) {
+	t.Parallel()
+	ctl := &NodeController{}
+	ctl.Config.ReplaceNamespaces = []string{"kube-system"}
+	pod := api.GetFakePod()
+	pod.Namespace = "default"
+	assert.False(t, ctl.podWantsImageUpdate(pod))
+	pod.Namespace = "kube-system"
+	assert.True(t, ctl.podWantsImageUpdate(pod))
+	pod.Annotations = map[string]string{
+		annotations.PodReplaceOnImageUpdate: "false",
+	}
+	assert.False(t, ctl.podWantsImageUpdate(pod))
+	pod.Namespace = "default"
+	pod.Annotations[annotations.PodReplaceOnImageUpdate] = "true"
+	assert.True(t, ctl.podWantsImageUpdate(pod))
+	pod.Annotations[annotations.PodReplaceOnImageUpdate] = "junk"
+	assert.False(t, ctl.podWantsImageUpdate(pod))
+}
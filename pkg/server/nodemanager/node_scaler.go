@@ -17,10 +17,13 @@ limitations under the License.
 package nodemanager
 
 import (
+	"reflect"
 	"time"
 
 	"github.com/elotl/kip/pkg/api"
+	"github.com/elotl/kip/pkg/labels"
 	"github.com/elotl/kip/pkg/server/cloud"
+	"github.com/elotl/kip/pkg/server/events"
 	"github.com/elotl/kip/pkg/util"
 	"k8s.io/klog"
 )
@@ -29,21 +32,35 @@ type StatusUpdater interface {
 	UpdateStatus(*api.Node) (*api.Node, error)
 }
 
+// PodPreemptor terminates a running pod in order to free up its node
+// for a higher priority pod.
+type PodPreemptor interface {
+	MarkPodForTermination(pod *api.Pod) (*api.Pod, error)
+}
+
 type BindingNodeScaler struct {
-	nodeRegistry      StatusUpdater
-	standbyNodes      []StandbyNodeSpec
-	cloudStatus       cloud.StatusKeeper
-	defaultVolumeSize string
-	fixedSizeVolume   bool
+	nodeRegistry            StatusUpdater
+	standbyNodes            []StandbyNodeSpec
+	cloudStatus             cloud.StatusKeeper
+	defaultVolumeSize       string
+	fixedSizeVolume         bool
+	podPreemptor            PodPreemptor
+	events                  *events.EventSystem
+	enablePreemption        bool
+	instanceMetadataOptions api.InstanceMetadataOptions
 }
 
-func NewBindingNodeScaler(nodeReg StatusUpdater, standbyNodes []StandbyNodeSpec, cloudStatus cloud.StatusKeeper, defaultVolumeSize string, fixedSizeVolume bool) *BindingNodeScaler {
+func NewBindingNodeScaler(nodeReg StatusUpdater, standbyNodes []StandbyNodeSpec, cloudStatus cloud.StatusKeeper, defaultVolumeSize string, fixedSizeVolume bool, podPreemptor PodPreemptor, eventSystem *events.EventSystem, enablePreemption bool, instanceMetadataOptions api.InstanceMetadataOptions) *BindingNodeScaler {
 	return &BindingNodeScaler{
-		nodeRegistry:      nodeReg,
-		standbyNodes:      standbyNodes,
-		cloudStatus:       cloudStatus,
-		defaultVolumeSize: defaultVolumeSize,
-		fixedSizeVolume:   fixedSizeVolume,
+		nodeRegistry:            nodeReg,
+		standbyNodes:            standbyNodes,
+		cloudStatus:             cloudStatus,
+		defaultVolumeSize:       defaultVolumeSize,
+		fixedSizeVolume:         fixedSizeVolume,
+		podPreemptor:            podPreemptor,
+		events:                  eventSystem,
+		enablePreemption:        enablePreemption,
+		instanceMetadataOptions: instanceMetadataOptions,
 	}
 }
 
@@ -51,9 +68,16 @@ func NewBindingNodeScaler(nodeReg StatusUpdater, standbyNodes []StandbyNodeSpec,
 // but if we're spotPreferred and we have unavailability, we allow the
 // pod to match to a non-spot node.
 func (s *BindingNodeScaler) spotMatches(pod *api.Pod, node *api.Node) bool {
-	if (pod.Spec.Spot.Policy == api.SpotNever && !node.Spec.Spot) ||
-		(pod.Spec.Spot.Policy == api.SpotAlways && node.Spec.Spot) {
-		return true
+	switch pod.Spec.Spot.Policy {
+	case api.SpotNever:
+		return !node.Spec.Spot
+	case api.SpotAlways:
+		return node.Spec.Spot
+	case api.SpotPreferred:
+		if node.Spec.Spot {
+			return true
+		}
+		return s.cloudStatus.IsUnavailableZone(pod.Spec.InstanceType, true, pod.Spec.Resources.PrivateIPOnly, pod.Spec.Placement.AvailabilityZone)
 	}
 	return false
 }
@@ -61,16 +85,68 @@ func (s *BindingNodeScaler) spotMatches(pod *api.Pod, node *api.Node) bool {
 // a pod with no specified placement can match any node
 // a pod with a specified placement can only match nodes with that placement
 func placementMatches(pod *api.Pod, node *api.Node) bool {
-	return pod.Spec.Placement.AvailabilityZone == "" ||
-		pod.Spec.Placement.AvailabilityZone == node.Spec.Placement.AvailabilityZone
+	if pod.Spec.Placement.AvailabilityZone != "" &&
+		pod.Spec.Placement.AvailabilityZone != node.Spec.Placement.AvailabilityZone {
+		return false
+	}
+	if pod.Spec.Placement.SubnetID != "" &&
+		pod.Spec.Placement.SubnetID != node.Spec.Placement.SubnetID {
+		return false
+	}
+	if len(pod.Spec.Placement.AvailabilityZones) > 0 {
+		found := false
+		for _, az := range pod.Spec.Placement.AvailabilityZones {
+			if az == node.Spec.Placement.AvailabilityZone {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if !labels.Equals(labels.Set(pod.Spec.Placement.NodeSelector),
+		labels.Set(node.Spec.Placement.NodeSelector)) {
+		return false
+	}
+	return pod.Spec.Placement.PlacementGroup == "" ||
+		pod.Spec.Placement.PlacementGroup == node.Spec.Placement.PlacementGroup
+}
+
+// a secondary interface can only be attached at launch, so a pod
+// requesting one can only match nodes launched with the exact same
+// interface configuration (and vice versa)
+func secondaryInterfaceMatches(pod *api.Pod, node *api.Node) bool {
+	podIface := pod.Spec.SecondaryNetworkInterface
+	nodeIface := node.Spec.SecondaryNetworkInterface
+	if podIface == nil || nodeIface == nil {
+		return podIface == nil && nodeIface == nil
+	}
+	return reflect.DeepEqual(podIface, nodeIface)
+}
+
+// a node that reported resource pressure while running its last pod
+// shouldn't get new pods until the pressure clears
+func nodeHasPressure(node *api.Node) bool {
+	for _, cond := range node.Status.Conditions {
+		switch cond.Type {
+		case api.NodeMemoryPressure, api.NodeDiskPressure, api.NodePIDPressure:
+			if cond.Status == api.ConditionTrue {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 func (s *BindingNodeScaler) podMatchesNode(pod *api.Pod, node *api.Node) bool {
 	return node.Spec.InstanceType == pod.Spec.InstanceType &&
 		node.Spec.Resources.PrivateIPOnly == pod.Spec.Resources.PrivateIPOnly &&
 		node.Spec.Resources.GPU == pod.Spec.Resources.GPU &&
+		!nodeHasPressure(node) &&
 		s.spotMatches(pod, node) &&
 		placementMatches(pod, node) &&
+		secondaryInterfaceMatches(pod, node) &&
 		s.diskMatches(pod, node)
 }
 
@@ -87,12 +163,21 @@ func (s *BindingNodeScaler) diskMatches(pod *api.Pod, node *api.Node) bool {
 
 func (s *BindingNodeScaler) createNodeForPod(pod *api.Pod) *api.Node {
 	isSpotPod := false
+	spotFallback := false
 	if pod.Spec.Spot.Policy == api.SpotAlways {
 		// don't create pods if spot is unavailable
 		if s.cloudStatus.IsUnavailableZone(pod.Spec.InstanceType, true, pod.Spec.Resources.PrivateIPOnly, pod.Spec.Placement.AvailabilityZone) {
 			return nil
 		}
 		isSpotPod = true
+	} else if pod.Spec.Spot.Policy == api.SpotPreferred {
+		// try spot while it looks available, otherwise go straight to
+		// on-demand; the node controller falls back at launch time if
+		// the spot request still gets rejected
+		if !s.cloudStatus.IsUnavailableZone(pod.Spec.InstanceType, true, pod.Spec.Resources.PrivateIPOnly, pod.Spec.Placement.AvailabilityZone) {
+			isSpotPod = true
+			spotFallback = true
+		}
 	}
 
 	if s.cloudStatus.IsUnavailableZone(pod.Spec.InstanceType, isSpotPod, pod.Spec.Resources.PrivateIPOnly, pod.Spec.Placement.AvailabilityZone) {
@@ -103,6 +188,7 @@ func (s *BindingNodeScaler) createNodeForPod(pod *api.Pod) *api.Node {
 	node.Spec.InstanceType = pod.Spec.InstanceType
 	node.Spec.BootImage = BootImage.ID
 	node.Spec.Spot = isSpotPod
+	node.Spec.SpotFallback = spotFallback
 	node.Spec.Resources = pod.Spec.Resources
 	// If we can resize, keep things simple and never enlarge the disk
 	// until dispatch (just launch with the default size), otherwise,
@@ -113,6 +199,8 @@ func (s *BindingNodeScaler) createNodeForPod(pod *api.Pod) *api.Node {
 		node.Spec.Resources.VolumeSize = pod.Spec.Resources.VolumeSize
 	}
 	node.Spec.Placement = pod.Spec.Placement
+	node.Spec.SecondaryNetworkInterface = pod.Spec.SecondaryNetworkInterface.DeepCopy()
+	node.Spec.InstanceMetadataOptions = s.instanceMetadataOptions
 	node.Status.BoundPodName = pod.Name
 	return node
 }
@@ -123,6 +211,7 @@ func (s *BindingNodeScaler) createNodeForStandbySpec(spec *StandbyNodeSpec) *api
 	node.Spec.BootImage = BootImage.ID
 	node.Spec.Spot = spec.Spot
 	node.Spec.Resources.VolumeSize = s.defaultVolumeSize
+	node.Spec.InstanceMetadataOptions = s.instanceMetadataOptions
 	return node
 }
 
@@ -132,6 +221,59 @@ func (s *BindingNodeScaler) nodeMatchesStandbySpec(node *api.Node, spec *Standby
 		node.Spec.Resources.VolumeSize == s.defaultVolumeSize
 }
 
+// preemptForPod tries to free up capacity for a pod that couldn't get
+// a node by terminating a running pod with a lower priority whose
+// node can be reused for the waiting pod. The preempted pod goes
+// through the normal pod termination path so its units get stopped
+// cleanly, its node is returned to the pool and, once cleaned, can be
+// matched to the preempting pod in a later scaling iteration.
+func (s *BindingNodeScaler) preemptForPod(pod *api.Pod, pods []*api.Pod) {
+	victim := s.findPreemptionVictim(pod, pods)
+	if victim == nil {
+		return
+	}
+	klog.V(2).Infof("Preempting pod %s (priority %d) to make room for pod %s (priority %d)",
+		victim.Name, victim.Spec.Priority, pod.Name, pod.Spec.Priority)
+	if s.events != nil {
+		s.events.Emit(events.PodPreempted, "node-scaler", victim,
+			"Pod %s was preempted by higher priority pod %s", victim.Name, pod.Name)
+		s.events.Emit(events.PodPreempted, "node-scaler", pod,
+			"Pod %s is preempting lower priority pod %s", pod.Name, victim.Name)
+	}
+	if _, err := s.podPreemptor.MarkPodForTermination(victim); err != nil {
+		klog.Errorf("Error marking preempted pod %s for termination: %s",
+			victim.Name, err)
+	}
+}
+
+// The victim's node was created from the victim's pod spec so we
+// compare pod specs to decide whether the waiting pod could reuse the
+// victim's node. Of all running, lower priority pods that match, pick
+// the one with the lowest priority.
+func (s *BindingNodeScaler) findPreemptionVictim(pod *api.Pod, pods []*api.Pod) *api.Pod {
+	var victim *api.Pod
+	for _, p := range pods {
+		if p.Status.Phase != api.PodRunning ||
+			p.Spec.Priority >= pod.Spec.Priority {
+			continue
+		}
+		if p.Spec.InstanceType != pod.Spec.InstanceType ||
+			p.Spec.Resources.PrivateIPOnly != pod.Spec.Resources.PrivateIPOnly ||
+			p.Spec.Resources.GPU != pod.Spec.Resources.GPU ||
+			(pod.Spec.Spot.Policy == api.SpotAlways) != (p.Spec.Spot.Policy == api.SpotAlways) {
+			continue
+		}
+		if pod.Spec.Placement.AvailabilityZone != "" &&
+			pod.Spec.Placement.AvailabilityZone != p.Spec.Placement.AvailabilityZone {
+			continue
+		}
+		if victim == nil || p.Spec.Priority < victim.Spec.Priority {
+			victim = p
+		}
+	}
+	return victim
+}
+
 // A brief summary of how we figure out what nodes need to be started
 // and what nodes need to be shut down:
 //
@@ -239,6 +381,8 @@ func (s *BindingNodeScaler) Compute(nodes []*api.Node, pods []*api.Pod) ([]*api.
 		if newNode != nil {
 			podNodeBinding[pod.Name] = newNode.Name
 			newNodes = append(newNodes, newNode)
+		} else if s.enablePreemption && pod.Spec.Priority > 0 {
+			s.preemptForPod(pod, pods)
 		}
 	}
 	// for all nodes that remain, match them to the buffered pool spec.
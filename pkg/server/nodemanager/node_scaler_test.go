@@ -53,6 +53,9 @@ func TestSpotMatches(t *testing.T) {
 		{false, api.SpotNever, true, false},
 		{true, api.SpotAlways, true, false},
 		{false, api.SpotAlways, false, false},
+		{true, api.SpotPreferred, true, false},
+		{false, api.SpotPreferred, false, false},
+		{true, api.SpotPreferred, false, true},
 	}
 	for i, tc := range tests {
 		pod := api.GetFakePod()
@@ -87,6 +90,10 @@ func TestPodMatchesNode(t *testing.T) {
 	p2 = *pod
 	p2.Spec.Spot.Policy = api.SpotAlways
 	assert.False(t, ns.podMatchesNode(&p2, node))
+	node.Status.SetCondition(api.NodeDiskPressure, api.ConditionTrue, "DiskUsage", "")
+	assert.False(t, ns.podMatchesNode(pod, node))
+	node.Status.SetCondition(api.NodeDiskPressure, api.ConditionFalse, "DiskUsage", "")
+	assert.True(t, ns.podMatchesNode(pod, node))
 }
 
 func TestCreateNodeForPodUnavailable(t *testing.T) {
@@ -116,6 +123,25 @@ func TestCreateNodeForPodUnavailable(t *testing.T) {
 	assert.Nil(t, node)
 }
 
+func TestCreateNodeForPodSpotPreferred(t *testing.T) {
+	cloudStatus, _ := cloud.NewLinkedAZSubnetStatus(cloud.NewMockClient())
+	ns := BindingNodeScaler{cloudStatus: cloudStatus, defaultVolumeSize: "5G"}
+
+	// while spot looks available, boot spot with fallback enabled
+	pod := api.GetFakePod()
+	pod.Spec.Spot.Policy = api.SpotPreferred
+	node := ns.createNodeForPod(pod)
+	assert.True(t, node.Spec.Spot)
+	assert.True(t, node.Spec.SpotFallback)
+
+	// with no spot capacity, go straight to on-demand
+	ns.cloudStatus.AddUnavailableInstance(pod.Spec.InstanceType, true)
+	node = ns.createNodeForPod(pod)
+	assert.NotNil(t, node)
+	assert.False(t, node.Spec.Spot)
+	assert.False(t, node.Spec.SpotFallback)
+}
+
 func TestCreateNodeForPodVolumeSize(t *testing.T) {
 	cloudStatus, _ := cloud.NewLinkedAZSubnetStatus(cloud.NewMockClient())
 	defaultVolumeSize := "5G"
@@ -147,6 +173,31 @@ func TestPlacementMatches(t *testing.T) {
 	assert.True(t, placementMatches(pod, node))
 	pod.Spec.Placement.AvailabilityZone = "us-west-1a"
 	assert.False(t, placementMatches(pod, node))
+	pod.Spec.Placement.AvailabilityZone = ""
+	pod.Spec.Placement.PlacementGroup = "hpc-group"
+	assert.False(t, placementMatches(pod, node))
+	node.Spec.Placement.PlacementGroup = "hpc-group"
+	assert.True(t, placementMatches(pod, node))
+}
+
+func TestSecondaryInterfaceMatches(t *testing.T) {
+	node := api.GetFakeNode()
+	pod := api.GetFakePod()
+	assert.True(t, secondaryInterfaceMatches(pod, node))
+	pod.Spec.SecondaryNetworkInterface = &api.SecondaryNetworkInterfaceSpec{
+		SubnetID: "subnet-1234",
+	}
+	assert.False(t, secondaryInterfaceMatches(pod, node))
+	node.Spec.SecondaryNetworkInterface = &api.SecondaryNetworkInterfaceSpec{
+		SubnetID: "subnet-1234",
+	}
+	assert.True(t, secondaryInterfaceMatches(pod, node))
+	node.Spec.SecondaryNetworkInterface.SecurityGroupIDs = []string{"sg-1234"}
+	assert.False(t, secondaryInterfaceMatches(pod, node))
+	pod.Spec.SecondaryNetworkInterface.SecurityGroupIDs = []string{"sg-1234"}
+	assert.True(t, secondaryInterfaceMatches(pod, node))
+	pod.Spec.SecondaryNetworkInterface = nil
+	assert.False(t, secondaryInterfaceMatches(pod, node))
 }
 
 func TestCreateNodeForStandbySpec(t *testing.T) {
@@ -191,6 +242,42 @@ func TestNodeMatchesStandbySpec(t *testing.T) {
 	assert.False(t, ns.nodeMatchesStandbySpec(&n3, sb))
 }
 
+func TestFindPreemptionVictim(t *testing.T) {
+	ns := &BindingNodeScaler{enablePreemption: true}
+	pod := api.GetFakePod()
+	pod.Spec.Priority = 100
+
+	lowPrio := api.GetFakePod()
+	lowPrio.Spec.InstanceType = pod.Spec.InstanceType
+	lowPrio.Status.Phase = api.PodRunning
+	lowPrio.Spec.Priority = 10
+	evenLower := api.GetFakePod()
+	evenLower.Spec.InstanceType = pod.Spec.InstanceType
+	evenLower.Status.Phase = api.PodRunning
+	evenLower.Spec.Priority = 1
+	higherPrio := api.GetFakePod()
+	higherPrio.Spec.InstanceType = pod.Spec.InstanceType
+	higherPrio.Status.Phase = api.PodRunning
+	higherPrio.Spec.Priority = 200
+	notRunning := api.GetFakePod()
+	notRunning.Spec.InstanceType = pod.Spec.InstanceType
+	notRunning.Status.Phase = api.PodWaiting
+	wrongInstance := api.GetFakePod()
+	wrongInstance.Spec.InstanceType = "p3.xlarge"
+	wrongInstance.Status.Phase = api.PodRunning
+
+	pods := []*api.Pod{higherPrio, lowPrio, evenLower, notRunning, wrongInstance}
+	victim := ns.findPreemptionVictim(pod, pods)
+	if assert.NotNil(t, victim) {
+		assert.Equal(t, evenLower.Name, victim.Name)
+	}
+
+	// no victim if nothing has a lower priority
+	pod.Spec.Priority = 0
+	victim = ns.findPreemptionVictim(pod, []*api.Pod{higherPrio, lowPrio})
+	assert.Nil(t, victim)
+}
+
 func makeNodeScaler() (*BindingNodeScaler, func()) {
 	nodeRegistry, closer := registry.SetupTestNodeRegistry()
 	cloudStatus, _ := cloud.NewLinkedAZSubnetStatus(cloud.NewMockClient())
@@ -23,6 +23,7 @@ type NodeStatusController struct {
 	daemonEndpointPort     int32
 	kubeletCapacity        corev1.ResourceList
 	kubeletLabels          map[string]string
+	kubeletTaints          []corev1.Taint
 	cidrs                  []string
 	node                   *corev1.Node
 	cloudClient            cloud.CloudClient
@@ -37,6 +38,7 @@ func NewNodeStatusController(
 	daemonEndpointPort int32,
 	capacity corev1.ResourceList,
 	labels map[string]string,
+	taints []corev1.Taint,
 ) *NodeStatusController {
 	return &NodeStatusController{
 		nodeReady:              false,
@@ -48,6 +50,7 @@ func NewNodeStatusController(
 		daemonEndpointPort:     daemonEndpointPort,
 		kubeletCapacity:        capacity,
 		kubeletLabels:          labels,
+		kubeletTaints:          taints,
 		ping:                   make(chan interface{}),
 	}
 }
@@ -133,6 +136,7 @@ func (n *NodeStatusController) Dump() []byte {
 		DaemonEndpointPort int32
 		KubeletCapacity    corev1.ResourceList
 		KubeletLabels      map[string]string
+		KubeletTaints      []corev1.Taint
 		CIDRs              []string
 		NodeStatus         corev1.NodeStatus
 	}{
@@ -142,6 +146,7 @@ func (n *NodeStatusController) Dump() []byte {
 		DaemonEndpointPort: n.daemonEndpointPort,
 		KubeletCapacity:    n.kubeletCapacity,
 		KubeletLabels:      n.kubeletLabels,
+		KubeletTaints:      n.kubeletTaints,
 		CIDRs:              n.cidrs,
 		NodeStatus:         nodeStatus,
 		LoopTimerCount:     n.controlLoopTimer.Count,
@@ -182,11 +187,25 @@ func (n *NodeStatusController) UpdateNode(node *corev1.Node) {
 	for k, v := range n.kubeletLabels {
 		node.Labels[k] = v
 	}
+	for _, taint := range n.kubeletTaints {
+		if !taintPresent(node.Spec.Taints, taint) {
+			node.Spec.Taints = append(node.Spec.Taints, taint)
+		}
+	}
 	node.Status = n.GetNodeStatus()
 	// Save node metadata and spec.
 	n.node = node.DeepCopy()
 }
 
+func taintPresent(taints []corev1.Taint, taint corev1.Taint) bool {
+	for i := range taints {
+		if taints[i].MatchTaint(&taint) {
+			return true
+		}
+	}
+	return false
+}
+
 func (n *NodeStatusController) GetNodeStatus() corev1.NodeStatus {
 	return corev1.NodeStatus{
 		Addresses:       n.nodeAddresses(),
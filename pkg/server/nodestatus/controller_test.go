@@ -99,3 +99,24 @@ func TestGetNodeStatus(t *testing.T) {
 		}
 	}
 }
+
+//func (n *NodeStatusController) UpdateNode(node *corev1.Node)
+func TestUpdateNodeTaints(t *testing.T) {
+	ctrl := &NodeStatusController{
+		cidrs: []string{"10.0.0.0/16"},
+		kubeletTaints: []corev1.Taint{
+			{
+				Key:    "kip.elotl.co/cell",
+				Value:  "true",
+				Effect: corev1.TaintEffectNoSchedule,
+			},
+		},
+	}
+	node := &corev1.Node{}
+	ctrl.UpdateNode(node)
+	assert.Len(t, node.Spec.Taints, 1)
+	assert.Equal(t, "kip.elotl.co/cell", node.Spec.Taints[0].Key)
+	// Taints already present on the node are not duplicated.
+	ctrl.UpdateNode(node)
+	assert.Len(t, node.Spec.Taints, 1)
+}
@@ -17,11 +17,14 @@ limitations under the License.
 package server
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"net"
 	"net/url"
+	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -50,6 +53,11 @@ const (
 	PodControllerCleanPeriod    = 20 * time.Second
 	PodControllerControlPeriod  = 5 * time.Second
 	PodControllerFullSyncPeriod = 31 * time.Second
+	// Directory on the node where itzo keeps each unit's rootfs.
+	itzoUnitsDir = "/tmp/itzo/units"
+	// Cap matches the kubernetes limit on container termination
+	// messages.
+	maxTerminationMessageBytes = 4096
 )
 
 var lastWrongPod map[string]string
@@ -64,7 +72,7 @@ type PodController struct {
 	podRegistry            *registry.PodRegistry
 	logRegistry            *registry.LogRegistry
 	metricsRegistry        *registry.MetricsRegistry
-	nodeLister             registry.NodeLister
+	nodeLister             registry.NodeStatusUpdater
 	resourceManager        *manager.ResourceManager
 	nodeDispenser          *nodemanager.NodeDispenser
 	nodeClientFactory      nodeclient.ItzoClientFactoryer
@@ -80,6 +88,45 @@ type PodController struct {
 	statusInterval         time.Duration
 	healthChecker          *healthcheck.HealthCheckController
 	defaultIAMPermissions  string
+	schedulingTimeout      time.Duration
+	// instanceTagLabels restricts which pod labels are propagated as
+	// cloud instance tags, from cells.instanceTagLabels. Empty
+	// propagates every label that isn't kubernetes-internal.
+	instanceTagLabels []string
+	// resyncInstanceTags re-applies label tags to running pods'
+	// instances during the full sync loop so label edits made after
+	// launch show up in the cloud.
+	resyncInstanceTags bool
+	// defaultImagePullSecret is the "namespace/name" of a
+	// docker-registry secret applied to every pod, from
+	// cells.defaultImagePullSecret.
+	defaultImagePullSecret   string
+	defaultRegistryCredsLock sync.Mutex
+	defaultRegistryCreds     map[string]api.RegistryCredentials
+	defaultRegistryCredsTime time.Time
+	// registryMirrors maps registry hosts to mirror hosts, from
+	// cells.registryMirrors. Unit images from a mirrored registry are
+	// rewritten to the mirror in the spec sent to the cell.
+	registryMirrors map[string]string
+	// maxVolumeDeploySize caps the uncompressed payload of a single
+	// deployed volume in bytes, from cells.maxVolumeDeploySize. Zero
+	// allows any size.
+	maxVolumeDeploySize int64
+	// volumeDeploys caches content hashes of deployed volumes so
+	// reconciles only re-ship volumes whose content changed.
+	volumeDeploys *volumeDeployCache
+	// postStartRuns remembers which units had their PostStart hook
+	// run, so a hook fires once per unit start.
+	postStartRuns *postStartTracker
+	// grpcProbeFailures counts consecutive gRPC liveness probe
+	// failures per unit so a single flake doesn't kill a pod.
+	grpcProbeFailures *probeFailureTracker
+	// dispatchCancels maps a dispatching pod's name to a function
+	// canceling its in-flight deploys, so a delete arriving during
+	// dispatch doesn't keep deploying to a cell that's about to be
+	// torn down.
+	dispatchCancels     map[string]context.CancelFunc
+	dispatchCancelsLock sync.Mutex
 }
 
 type FullPodStatus struct {
@@ -96,10 +143,33 @@ type FullPodStatus struct {
 func (c *PodController) Start(quit <-chan struct{}, wg *sync.WaitGroup) {
 	c.registerEventHandlers()
 	c.failDispatchingPods()
+	c.logDefaultImagePullSecret()
 	c.healthChecker.Start()
 	go c.ControlLoop(quit, wg)
 }
 
+// logDefaultImagePullSecret resolves the cluster-wide image pull
+// secret once at startup so a broken secret shows up in the logs
+// right away instead of on the first pod launch.
+func (c *PodController) logDefaultImagePullSecret() {
+	if c.defaultImagePullSecret == "" {
+		return
+	}
+	creds, err := c.defaultRegistryCredentials()
+	if err != nil {
+		klog.Errorf("Could not resolve default image pull secret %s: %v",
+			c.defaultImagePullSecret, err)
+		return
+	}
+	registries := make([]string, 0, len(creds))
+	for server := range creds {
+		registries = append(registries, server)
+	}
+	sort.Strings(registries)
+	klog.Infof("Default image pull secret %s covers registries: %s",
+		c.defaultImagePullSecret, strings.Join(registries, ", "))
+}
+
 func (c *PodController) registerEventHandlers() {
 	// Creates a fast dispatch for pods
 	c.events.RegisterHandlerFunc(events.PodCreated, c.podCreated)
@@ -129,6 +199,10 @@ func (c *PodController) podUpdated(e events.Event) error {
 
 func (c *PodController) podDeleted(e events.Event) error {
 	pod := e.Object.(*api.Pod)
+	c.cancelDispatch(pod.Name)
+	c.volumeDeploys.forgetPod(pod.Name)
+	c.postStartRuns.forgetPod(pod.Name)
+	c.grpcProbeFailures.forgetPod(pod.Name)
 	if pod.Status.BoundNodeName != "" {
 		c.terminateBoundPod(pod)
 	} else {
@@ -191,6 +265,7 @@ func (c *PodController) ControlLoop(quit <-chan struct{}, wg *sync.WaitGroup) {
 			c.controlLoopTimer.StartLoop()
 			c.ControlPods()
 			c.terminateHealthCheckFailedPods()
+			c.terminateDeadlineExceededPods()
 			c.controlLoopTimer.EndLoop()
 		case <-statusTicker.C:
 			c.checkRunningPodStatus()
@@ -263,7 +338,8 @@ func parseDockerConfigServer(serverURL string) (string, error) {
 }
 
 const (
-	dockerConfigJSONKey = ".dockerconfigjson"
+	dockerConfigJSONKey       = ".dockerconfigjson"
+	defaultImagePullSecretTTL = 1 * time.Minute
 )
 
 func parseDockerConfigCreds(dockerJSON []byte) (map[string]api.RegistryCredentials, error) {
@@ -328,8 +404,52 @@ func parseDockerConfigCreds(dockerJSON []byte) (map[string]api.RegistryCredentia
 	return creds, nil
 }
 
+// defaultRegistryCredentials resolves the docker config secret named
+// by cells.defaultImagePullSecret. The parsed credentials are cached
+// for a short time so we don't hit the api server on every pod
+// launch.
+func (c *PodController) defaultRegistryCredentials() (map[string]api.RegistryCredentials, error) {
+	if c.defaultImagePullSecret == "" {
+		return nil, nil
+	}
+	c.defaultRegistryCredsLock.Lock()
+	defer c.defaultRegistryCredsLock.Unlock()
+	if c.defaultRegistryCreds != nil &&
+		time.Since(c.defaultRegistryCredsTime) < defaultImagePullSecretTTL {
+		return c.defaultRegistryCreds, nil
+	}
+	parts := strings.SplitN(c.defaultImagePullSecret, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("default image pull secret %q must be specified as namespace/name", c.defaultImagePullSecret)
+	}
+	s, err := c.resourceManager.GetSecret(parts[1], parts[0])
+	if err != nil {
+		return nil, util.WrapError(err, "could not get default image pull secret %s from api server", c.defaultImagePullSecret)
+	}
+	dockerJSON, ok := s.Data[dockerConfigJSONKey]
+	if !ok {
+		return nil, fmt.Errorf("default image pull secret %s has no %s key", c.defaultImagePullSecret, dockerConfigJSONKey)
+	}
+	creds, err := parseDockerConfigCreds(dockerJSON)
+	if err != nil {
+		return nil, err
+	}
+	c.defaultRegistryCreds = creds
+	c.defaultRegistryCredsTime = time.Now()
+	return creds, nil
+}
+
 func (c *PodController) loadRegistryCredentials(pod *api.Pod) (map[string]api.RegistryCredentials, error) {
 	allCreds := make(map[string]api.RegistryCredentials)
+	// Start from the cluster-wide credentials, per-pod secrets below
+	// overwrite them for any registry both cover.
+	defaultCreds, err := c.defaultRegistryCredentials()
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range defaultCreds {
+		allCreds[k] = v
+	}
 	for _, secretName := range pod.Spec.ImagePullSecrets {
 		s, err := c.resourceManager.GetSecret(secretName, pod.Namespace)
 		if err != nil {
@@ -395,6 +515,54 @@ func (c *PodController) loadRegistryCredentials(pod *api.Pod) (map[string]api.Re
 	return allCreds, nil
 }
 
+// rewriteImageToMirror returns image pointed at the configured mirror
+// for its registry, or the unchanged reference when no mirror matches.
+// Bare Docker Hub references match the "docker.io" key and get the
+// implicit library/ prefix the hub resolves for official images.
+func rewriteImageToMirror(image string, mirrors map[string]string) string {
+	server, _, err := util.ParseImageSpec(image)
+	if err != nil {
+		return image
+	}
+	if server == "" {
+		mirror, ok := mirrors["docker.io"]
+		if !ok {
+			return image
+		}
+		if !strings.Contains(image, "/") {
+			image = "library/" + image
+		}
+		return mirror + "/" + image
+	}
+	mirror, ok := mirrors[server]
+	if !ok {
+		return image
+	}
+	return mirror + strings.TrimPrefix(image, server)
+}
+
+// applyRegistryMirrors rewrites unit images whose registry has a
+// configured mirror so the cell pulls through the mirror. The unit
+// lists are copied first: the stored pod spec keeps the original
+// reference and unit statuses keep reporting it. Credentials are
+// unaffected since the cell picks them by the registry host in the
+// reference it pulls, so a mirror authenticates through whichever
+// pull secret covers the mirror's host.
+func applyRegistryMirrors(spec api.PodSpec, mirrors map[string]string) api.PodSpec {
+	if len(mirrors) == 0 {
+		return spec
+	}
+	spec.Units = append([]api.Unit(nil), spec.Units...)
+	for i := range spec.Units {
+		spec.Units[i].Image = rewriteImageToMirror(spec.Units[i].Image, mirrors)
+	}
+	spec.InitUnits = append([]api.Unit(nil), spec.InitUnits...)
+	for i := range spec.InitUnits {
+		spec.InitUnits[i].Image = rewriteImageToMirror(spec.InitUnits[i].Image, mirrors)
+	}
+	return spec
+}
+
 func (c *PodController) resizeVolume(node *api.Node, pod *api.Pod, client nodeclient.NodeClient) error {
 	size, err := resource.ParseQuantity(pod.Spec.Resources.VolumeSize)
 	if err != nil {
@@ -433,9 +601,22 @@ func (c *PodController) updatePodUnits(pod *api.Pod) error {
 		return util.WrapError(err,
 			"unable to sync pod %s: generating hostname: %v", pod.Name, err)
 	}
+	spec, err := resolveUnitEnvFiles(pod.Namespace, pod.Spec, c.resourceManager)
+	if err != nil {
+		return util.WrapError(err, "Unable to sync pod %s", pod.Name)
+	}
+	spec = applyPodDefaultEnv(spec)
+	spec, err = resolveKeyRefEnv(pod.Namespace, spec, c.resourceManager)
+	if err != nil {
+		return util.WrapError(err, "Unable to sync pod %s", pod.Name)
+	}
+	spec = resolveFieldRefEnv(spec, pod, c.kubernetesNodeName,
+		api.GetPodIP(node.Status.Addresses))
+	spec = applyRegistryMirrors(spec, c.registryMirrors)
+	spec = applyHostPathReadOnlyHints(spec)
 	podParams := api.PodParameters{
 		Credentials: podCreds,
-		Spec:        util.ExpandCommandAndArgs(pod.Spec),
+		Spec:        util.WrapShellCommands(util.ExpandCommandAndArgs(spec)),
 		PodName:     pod.Name,
 		NodeName:    c.kubernetesNodeName,
 		PodIP:       api.GetPodIP(node.Status.Addresses),
@@ -450,9 +631,63 @@ func isBurstableMachine(machine string) bool {
 		strings.HasPrefix(machineType, "t3"))
 }
 
+func (c *PodController) registerDispatchCancel(podName string, cancel context.CancelFunc) {
+	c.dispatchCancelsLock.Lock()
+	defer c.dispatchCancelsLock.Unlock()
+	if c.dispatchCancels == nil {
+		c.dispatchCancels = make(map[string]context.CancelFunc)
+	}
+	c.dispatchCancels[podName] = cancel
+}
+
+func (c *PodController) unregisterDispatchCancel(podName string) {
+	c.dispatchCancelsLock.Lock()
+	defer c.dispatchCancelsLock.Unlock()
+	delete(c.dispatchCancels, podName)
+}
+
+// cancelDispatch aborts any deploys in flight for a pod that's being
+// deleted mid-dispatch. It's a no-op for pods that aren't dispatching.
+func (c *PodController) cancelDispatch(podName string) {
+	c.dispatchCancelsLock.Lock()
+	cancel := c.dispatchCancels[podName]
+	c.dispatchCancelsLock.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
+// abortDispatch reports whether the pod's launch was canceled, i.e.
+// the pod was deleted while we were still dispatching it. The caller
+// is expected to bail out of any remaining launch steps; teardown of
+// the pod and its node happens through the normal delete path.
+func (c *PodController) abortDispatch(ctx context.Context, pod *api.Pod) bool {
+	if ctx.Err() == nil {
+		return false
+	}
+	klog.V(2).Infof("Canceled dispatching pod %s, pod is being torn down", pod.Name)
+	c.events.Emit(events.PodLaunchAborted, "pod-controller", pod,
+		"aborted launch of pod %s, pod was deleted while dispatching", pod.Name)
+	return true
+}
+
 func (c *PodController) dispatchPodToNode(pod *api.Pod, node *api.Node) {
 	klog.V(2).Infof("Dispatching pod %s to node %s", pod.Name, node.Name)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c.registerDispatchCancel(pod.Name, cancel)
+	defer c.unregisterDispatchCancel(pod.Name)
+	if c.abortDispatch(ctx, pod) {
+		return
+	}
 	client := c.nodeClientFactory.GetClient(node.Status.Addresses)
+	if err := validateHostPathVolumes(pod, client); err != nil {
+		msg := fmt.Sprintf("Error dispatching pod to node, host path validation failed for pod %s: %v", pod.Name, err)
+		klog.Errorln(msg)
+		c.events.Emit(events.PodHostPathMismatch, "pod-controller", pod, msg)
+		c.markFailedPod(pod, true, msg)
+		return
+	}
 	resizableVolume := !c.cloudClient.GetAttributes().FixedSizeVolume
 	if resizableVolume && pod.Spec.Resources.VolumeSize != "" {
 		err := c.resizeVolume(node, pod, client)
@@ -476,9 +711,28 @@ func (c *PodController) dispatchPodToNode(pod *api.Pod, node *api.Node) {
 		}
 	}
 
-	securityGroupsStr := pod.Annotations[annotations.PodSecurityGroups]
-	if len(securityGroupsStr) != 0 {
-		err := c.attachSecurityGroupsToNode(node, securityGroupsStr)
+	// Check for cancellation before each step that mutates cloud
+	// resources so deleting a dispatching pod doesn't leave us
+	// attaching groups, volumes or routes to a dying node.
+	if c.abortDispatch(ctx, pod) {
+		return
+	}
+	securityGroups := []string{}
+	if securityGroupsStr := pod.Annotations[annotations.PodSecurityGroups]; len(securityGroupsStr) != 0 {
+		securityGroups = strings.Split(securityGroupsStr, ",")
+	}
+	if len(pod.Spec.SourceRanges) != 0 {
+		sg, err := c.ensurePodSourceRangesGroup(pod)
+		if err != nil {
+			msg := fmt.Sprintf("Error dispatching pod to node, could not create source range security group for pod %s: %s", pod.Name, err)
+			klog.Errorln(msg)
+			c.markFailedPod(pod, true, msg)
+			return
+		}
+		securityGroups = append(securityGroups, sg.ID)
+	}
+	if len(securityGroups) != 0 {
+		err := c.attachSecurityGroupsToNode(node, securityGroups)
 		if err != nil {
 			msg := fmt.Sprintf("Error dispatching pod to node, could not attach security groups to pod %s: %s", pod.Name, err)
 			klog.Errorln(msg)
@@ -503,9 +757,40 @@ func (c *PodController) dispatchPodToNode(pod *api.Pod, node *api.Node) {
 		}
 	}
 
+	if c.abortDispatch(ctx, pod) {
+		return
+	}
+	for _, vol := range pod.Spec.Volumes {
+		if vol.CloudVolume == nil {
+			continue
+		}
+		err := c.cloudClient.AttachVolume(node, vol.CloudVolume.VolumeID)
+		if err != nil {
+			msg := fmt.Sprintf("Error dispatching pod to node, could not attach volume %s to pod %s: %s", vol.CloudVolume.VolumeID, pod.Name, err)
+			klog.Errorln(msg)
+			c.markFailedPod(pod, true, msg)
+			return
+		}
+	}
+
+	if c.abortDispatch(ctx, pod) {
+		return
+	}
 	cidr := pod.Annotations[annotations.PodCloudRoute]
+	if len(cidr) != 0 && api.IsHostNetwork(&pod.Spec) {
+		klog.V(2).Infof("pod %s uses host networking, not adding route %s",
+			pod.Name, cidr)
+		cidr = ""
+	}
 	if len(cidr) != 0 {
 		cidrs := strings.Fields(cidr)
+		if err := c.cloudClient.EnsureRouteTableCapacity(len(cidrs)); err != nil {
+			msg := fmt.Sprintf("Error dispatching pod to node, no route table capacity for pod %s: %s", pod.Name, err)
+			klog.Errorln(msg)
+			c.events.Emit(events.RouteTableFull, "pod-controller", pod, msg)
+			c.markFailedPod(pod, true, msg)
+			return
+		}
 		err := c.addCloudRoute(node, cidrs)
 		if err != nil {
 			msg := fmt.Sprintf("Error dispatching pod to node, could not add route %s to pod %s: %s", cidrs, pod.Name, err)
@@ -521,32 +806,55 @@ func (c *PodController) dispatchPodToNode(pod *api.Pod, node *api.Node) {
 	/// as a goroutine cause we don't care when it finishes
 	go c.TagNodeWithPodLabels(pod, node)
 
-	err := deployPodVolumes(pod, node, c.resourceManager, c.nodeClientFactory)
+	err := deployPodVolumes(ctx, pod, node, c.resourceManager, c.nodeClientFactory, c.maxVolumeDeploySize, c.volumeDeploys)
 	if err != nil {
+		if c.abortDispatch(ctx, pod) {
+			return
+		}
 		msg := fmt.Sprintf("Error deploying volumes to node for pod %s: %v", pod.Name, err)
 		klog.Errorln(msg)
 		c.markFailedPod(pod, true, msg)
 		return
 	}
 
-	err = deployResolvconf(pod, node, c.dnsConfigurer, c.nodeClientFactory)
+	err = deployResolvconf(ctx, pod, node, c.dnsConfigurer, c.nodeClientFactory)
 	if err != nil {
+		if c.abortDispatch(ctx, pod) {
+			return
+		}
 		msg := fmt.Sprintf("Error deploying resolv.conf to node for pod %s: %v", pod.Name, err)
 		klog.Errorln(msg)
 		c.markFailedPod(pod, true, msg)
 		return
 	}
 
-	err = deployEtcHosts(pod, node, c.dnsConfigurer, c.nodeClientFactory)
+	err = deployEtcHosts(ctx, pod, node, c.dnsConfigurer, c.nodeClientFactory)
 	if err != nil {
+		if c.abortDispatch(ctx, pod) {
+			return
+		}
 		msg := fmt.Sprintf("Error deploying /etc/hosts to node for pod %s: %v", pod.Name, err)
 		klog.Errorln(msg)
 		c.markFailedPod(pod, true, msg)
 		return
 	}
 
-	err = deployNetworkAgentToken(c.networkAgentKubeConfig, pod, node, c.nodeClientFactory)
+	err = deployTrustedCerts(ctx, pod, node, c.nodeClientFactory)
+	if err != nil {
+		if c.abortDispatch(ctx, pod) {
+			return
+		}
+		msg := fmt.Sprintf("Error deploying trusted CA bundle to node for pod %s: %v", pod.Name, err)
+		klog.Errorln(msg)
+		c.markFailedPod(pod, true, msg)
+		return
+	}
+
+	err = deployNetworkAgentToken(ctx, c.networkAgentKubeConfig, pod, node, c.nodeClientFactory)
 	if err != nil {
+		if c.abortDispatch(ctx, pod) {
+			return
+		}
 		msg := fmt.Sprintf(
 			"deploying network agent kubeconfig for %q: %v", pod.Name, err)
 		klog.Error(msg)
@@ -590,25 +898,53 @@ func (c *PodController) addCloudRoute(node *api.Node, cidrs []string) error {
 		}
 		if err := c.cloudClient.AddRoute(cidr, instanceID); err != nil {
 			// We don't remove any existing routes in the route table, so
-			// adding one will fail if there's an existing route for the same
-			// CIDR (but different instance as the next hop). The previous
-			// route should have been cleaned up when its instance terminated,
-			// but there might be race conditions. The garbage collector should
-			// clean the route entry up eventually.
+			// adding one fails with a cloud.RouteConflictError if there's an
+			// existing route for the same CIDR (but different instance as
+			// the next hop). The previous route should have been cleaned up
+			// when its instance terminated, but there might be race
+			// conditions. The garbage collector should clean the route entry
+			// up eventually.
 			return err
 		}
 	}
 	return nil
 }
 
-func (c *PodController) attachSecurityGroupsToNode(node *api.Node, securityGroupsStr string) error {
-	securityGroups := strings.Split(securityGroupsStr, ",")
+func (c *PodController) attachSecurityGroupsToNode(node *api.Node, securityGroups []string) error {
 	if len(securityGroups) == 0 {
 		return nil
 	}
 	return c.cloudClient.AttachSecurityGroups(node, securityGroups)
 }
 
+// ensurePodSourceRangesGroup creates (or updates, if the pod is being
+// relaunched) a security group opening the host ports the pod's units
+// expose to the CIDRs in spec.sourceRanges. The group is attached to
+// the pod's instance alongside the cell security group, which only
+// admits traffic from inside the VPC.
+func (c *PodController) ensurePodSourceRangesGroup(pod *api.Pod) (*cloud.SecurityGroup, error) {
+	ports := make([]cloud.InstancePort, 0, len(pod.Spec.Units))
+	for _, unit := range pod.Spec.Units {
+		for _, port := range unit.Ports {
+			if port.HostPort == 0 {
+				continue
+			}
+			protocol := port.Protocol
+			if protocol == "" {
+				protocol = api.ProtocolTCP
+			}
+			ports = append(ports, cloud.InstancePort{
+				Name:          port.Name,
+				Protocol:      protocol,
+				Port:          int(port.HostPort),
+				PortRangeSize: 1,
+			})
+		}
+	}
+	sgName := util.CreateSecurityGroupName(c.controllerID, pod.Name)
+	return c.cloudClient.EnsureSecurityGroup(sgName, ports, pod.Spec.SourceRanges)
+}
+
 func (c *PodController) SyncRunningPods() {
 	podList, err := c.podRegistry.ListPods(func(p *api.Pod) bool {
 		return p.Spec.Phase == api.PodRunning &&
@@ -624,19 +960,63 @@ func (c *PodController) SyncRunningPods() {
 			if err != nil {
 				klog.Error(err)
 			}
+			// Re-deploy configmap and secret volumes whose content
+			// changed since the last deploy; the hash cache keeps
+			// unchanged volumes from being re-shipped every sync.
+			if node, err := c.nodeLister.GetNode(p.Status.BoundNodeName); err == nil {
+				err = deployPodVolumes(context.Background(), p, node,
+					c.resourceManager, c.nodeClientFactory,
+					c.maxVolumeDeploySize, c.volumeDeploys)
+				if err != nil {
+					klog.Errorf("Error syncing volumes for pod %s: %v", p.Name, err)
+				}
+			}
+			if c.resyncInstanceTags && p.Status.BoundInstanceID != "" {
+				err = c.cloudClient.AddInstanceTags(
+					p.Status.BoundInstanceID, c.podCloudTags(p))
+				if err != nil {
+					klog.Errorf("Error re-syncing instance tags for pod %s: %v", p.Name, err)
+				}
+			}
 		}(pod)
 	}
 }
 
-func (c *PodController) TagNodeWithPodLabels(pod *api.Pod, node *api.Node) {
+// podCloudTags builds the tag map applied to a pod's instance: the
+// pod's labels minus kubernetes-internal ones, restricted to
+// instanceTagLabels when that's configured, plus our own name and
+// namespace tags. Labels that would collide with a reserved Kip tag
+// key are dropped.
+func (c *PodController) podCloudTags(pod *api.Pod) map[string]string {
 	cloudLabels := util.FilterKeysWithPrefix(pod.Labels, util.InternalLabelPrefixes)
+	if len(c.instanceTagLabels) > 0 {
+		for k := range cloudLabels {
+			if !util.StringInSlice(k, c.instanceTagLabels) {
+				delete(cloudLabels, k)
+			}
+		}
+	}
+	reservedTagKeys := []string{
+		cloud.ControllerTagKey,
+		cloud.NameTagKey,
+		cloud.NamespaceTagKey,
+		cloud.NametagTagKey,
+		cloud.PodNameTagKey,
+	}
+	for _, k := range reservedTagKeys {
+		delete(cloudLabels, k)
+	}
 	podName := util.GetNameFromString(pod.Name)
 	podNamespace := util.GetNamespaceFromString(pod.Name)
 	cloudLabels[cloud.PodNameTagKey] = util.CreateBoundNodeNameTag(c.nametag, podName)
 	if podNamespace != "" {
 		cloudLabels[cloud.NamespaceTagKey] = podNamespace
 	}
-	err := c.cloudClient.AddInstanceTags(node.Status.InstanceID, cloudLabels)
+	return cloudLabels
+}
+
+func (c *PodController) TagNodeWithPodLabels(pod *api.Pod, node *api.Node) {
+	err := c.cloudClient.AddInstanceTags(node.Status.InstanceID, c.podCloudTags(pod))
 	if err != nil {
 		klog.Errorln("Error tagging node", node.Name, err)
 	}
@@ -696,8 +1076,63 @@ func (c *PodController) handlePodStatusReply(reply FullPodStatus) {
 		}
 	}
 
+	if pod.Status.Phase == api.PodRunning {
+		c.runPostStartHooks(pod, reply.UnitStatuses)
+		c.checkGRPCProbes(pod)
+	}
+
 	if len(reply.ResourceUsage) > 0 {
 		c.metricsRegistry.Insert(pod.Name, api.Now(), reply.ResourceUsage)
+		c.updateNodeConditions(pod, reply.ResourceUsage)
+	}
+}
+
+const (
+	memoryPressureThreshold = 90.0 // percent
+	diskPressureThreshold   = 90.0 // percent
+)
+
+func pressureStatus(usage, threshold float64) api.ConditionStatus {
+	if usage >= threshold {
+		return api.ConditionTrue
+	}
+	return api.ConditionFalse
+}
+
+// updateNodeConditions turns the resource usage the cell agent
+// reported into pressure conditions on the bound node. The node
+// scaler uses those to keep new pods off a stressed node if it gets
+// recycled into the standby pool.
+func (c *PodController) updateNodeConditions(pod *api.Pod, usage api.ResourceMetrics) {
+	if pod.Status.BoundNodeName == "" {
+		return
+	}
+	node, err := c.nodeLister.GetNode(pod.Status.BoundNodeName)
+	if err != nil {
+		klog.Errorf("Error getting node %s for condition update: %v",
+			pod.Status.BoundNodeName, err)
+		return
+	}
+	changed := false
+	if memory, ok := usage["memory"]; ok {
+		changed = node.Status.SetCondition(
+			api.NodeMemoryPressure,
+			pressureStatus(memory, memoryPressureThreshold),
+			"MemoryUsage",
+			fmt.Sprintf("memory usage %.1f%%", memory)) || changed
+	}
+	if disk, ok := usage["disk"]; ok {
+		changed = node.Status.SetCondition(
+			api.NodeDiskPressure,
+			pressureStatus(disk, diskPressureThreshold),
+			"DiskUsage",
+			fmt.Sprintf("disk usage %.1f%%", disk)) || changed
+	}
+	if changed {
+		if _, err := c.nodeLister.UpdateStatus(node); err != nil {
+			klog.Errorf("Error updating conditions on node %s: %v",
+				node.Name, err)
+		}
 	}
 }
 
@@ -824,7 +1259,7 @@ func (c *PodController) setPodDispatchingParams(pod *api.Pod, node *api.Node) (*
 	// mode, in which case the pod will share the main IP address of the
 	// instance.
 	podIP := api.GetPodIP(node.Status.Addresses)
-	if api.IsHostNetwork(pod.Spec.SecurityContext) {
+	if api.IsHostNetwork(&pod.Spec) {
 		podIP = api.GetPrivateIP(node.Status.Addresses)
 	}
 	pod.Status.Addresses = api.NewNetworkAddresses(podIP, "")
@@ -846,7 +1281,103 @@ func (c *PodController) setPodDispatchingParams(pod *api.Pod, node *api.Node) (*
 	return pod, err
 }
 
+// constrainPodToVolumeAZ pins a pod that references pre-existing
+// cloud volumes to the availability zone those volumes live in, since
+// a volume can only be attached to instances in its own zone.
+func (c *PodController) constrainPodToVolumeAZ(pod *api.Pod) error {
+	changed := false
+	for _, vol := range pod.Spec.Volumes {
+		if vol.CloudVolume == nil {
+			continue
+		}
+		volumeID := vol.CloudVolume.VolumeID
+		az, err := c.cloudClient.GetVolumeAvailabilityZone(volumeID)
+		if err != nil {
+			return util.WrapError(
+				err, "getting availability zone of volume %s", volumeID)
+		}
+		if pod.Spec.Placement.AvailabilityZone == az {
+			continue
+		}
+		if pod.Spec.Placement.AvailabilityZone != "" {
+			return fmt.Errorf(
+				"volume %s is in availability zone %s but pod placement requires %s",
+				volumeID, az, pod.Spec.Placement.AvailabilityZone)
+		}
+		pod.Spec.Placement.AvailabilityZone = az
+		changed = true
+	}
+	if changed {
+		klog.V(2).Infof("pinning pod %s to availability zone %s for its cloud volumes",
+			pod.Name, pod.Spec.Placement.AvailabilityZone)
+		_, err := c.podRegistry.UpdatePodSpecAndLabels(pod)
+		if err != nil {
+			return util.WrapError(
+				err, "updating pod %s placement", pod.Name)
+		}
+	}
+	return nil
+}
+
+// constrainPodToSubnet resolves a pod's subnetSelector into a concrete
+// subnet before scheduling. Among the matching subnets the one with the
+// most free addresses wins. A selector that matches no subnet in the
+// VPC is a scheduling failure.
+func (c *PodController) constrainPodToSubnet(pod *api.Pod) error {
+	sel := pod.Spec.Placement.SubnetSelector
+	if sel == nil || pod.Spec.Placement.SubnetID != "" {
+		return nil
+	}
+	subnets, err := c.cloudClient.GetSubnets()
+	if err != nil {
+		return util.WrapError(
+			err, "listing subnets for the subnetSelector of pod %s", pod.Name)
+	}
+	best := -1
+	for i := range subnets {
+		if az := pod.Spec.Placement.AvailabilityZone; az != "" && subnets[i].AZ != az {
+			continue
+		}
+		matches, err := cloud.SubnetMatchesSelector(subnets[i], sel)
+		if err != nil {
+			return util.WrapError(err, "invalid subnetSelector on pod %s", pod.Name)
+		}
+		if !matches {
+			continue
+		}
+		if best < 0 || subnets[i].AvailableAddresses > subnets[best].AvailableAddresses {
+			best = i
+		}
+	}
+	if best < 0 {
+		return fmt.Errorf("no subnet in the VPC matches the pod's subnetSelector")
+	}
+	pod.Spec.Placement.SubnetID = subnets[best].ID
+	if pod.Spec.Placement.AvailabilityZone == "" {
+		pod.Spec.Placement.AvailabilityZone = subnets[best].AZ
+	}
+	klog.V(2).Infof("pinning pod %s to subnet %s for its subnetSelector",
+		pod.Name, subnets[best].ID)
+	_, err = c.podRegistry.UpdatePodSpecAndLabels(pod)
+	if err != nil {
+		return util.WrapError(err, "updating pod %s placement", pod.Name)
+	}
+	return nil
+}
+
 func (c *PodController) schedulePod(pod *api.Pod) {
+	if err := c.constrainPodToVolumeAZ(pod); err != nil {
+		msg := fmt.Sprintf("Error scheduling pod %s: %v", pod.Name, err)
+		klog.Errorf("%s", msg)
+		c.markFailedPod(pod, false, msg)
+		return
+	}
+	if err := c.constrainPodToSubnet(pod); err != nil {
+		msg := fmt.Sprintf("Error scheduling pod %s: %v", pod.Name, err)
+		klog.Errorf("%s", msg)
+		c.markFailedPod(pod, false, msg)
+		return
+	}
 	// Get a free node from the nodeDispenser
 	// which gets nodes from the node_controller. The
 	// request has the pod name so that the node_controller
@@ -861,36 +1392,108 @@ func (c *PodController) schedulePod(pod *api.Pod) {
 		c.nodeDispenser.ReturnNode(nodeReply.Node.Name, true)
 		return
 	}
+	c.emitPodScheduledEvent(pod, nodeReply.Node)
 	go c.dispatchPodToNode(pod, nodeReply.Node)
 }
 
+// emitPodScheduledEvent records why a pod landed on its cell: the
+// chosen instance type, availability zone, subnet and spot flag. The
+// message is key=value formatted so the placement decision can be
+// parsed back out of the event stream.
+func (c *PodController) emitPodScheduledEvent(pod *api.Pod, node *api.Node) {
+	reason := "matched-resources"
+	if pod.Spec.InstanceType != "" {
+		reason = "requested-instance-type"
+	}
+	if pod.Spec.Spot.Policy == api.SpotAlways && !node.Spec.Spot {
+		reason += ",spot-fallback"
+	}
+	if pod.Spec.Placement.AvailabilityZone != "" {
+		reason += ",az-constrained"
+	}
+	msg := fmt.Sprintf("instanceType=%s az=%s subnetID=%s spot=%t reason=%s",
+		node.Spec.InstanceType,
+		node.Spec.Placement.AvailabilityZone,
+		node.Status.SubnetID,
+		node.Spec.Spot,
+		reason)
+	c.events.Emit(events.PodScheduled, "pod-controller", pod, msg)
+}
+
 func (c *PodController) terminateUnboundPod(pod *api.Pod) {
 	c.podRegistry.TerminatePod(pod, api.PodTerminated, "Terminating unbound pod")
 }
 
 func (c *PodController) terminateBoundPod(pod *api.Pod) {
+	c.runPreStopHooks(pod)
 	c.savePodLogs(pod)
 	c.podRegistry.TerminatePod(pod, api.PodTerminated, "Terminating bound pod")
+	go c.releaseNode(pod, pod.Status.BoundNodeName, pod.Status.BoundInstanceID)
+}
 
-	go func() {
-		// Return node.
-		klog.V(2).Infof("returning node %s for pod %s",
-			pod.Status.BoundNodeName, pod.Name)
-		c.nodeDispenser.ReturnNode(pod.Status.BoundNodeName, false)
-		// Remove any cloud routes created for this pod.
-		instanceID := pod.Status.BoundInstanceID
-		routes := pod.Annotations[annotations.PodCloudRoute]
-		if instanceID != "" && len(routes) > 0 {
-			klog.V(2).Infof("removing route %s for pod %s", routes, pod.Name)
-			for _, cidr := range strings.Fields(routes) {
-				err := c.cloudClient.RemoveRoute(cidr, instanceID)
-				if err != nil {
-					klog.Warningf("removing cidr %s for pod %s (%s): %v",
-						cidr, pod.Name, instanceID, err)
-				}
+// releaseNode returns the pod's node to the node dispenser and removes
+// any cloud routes that were created for the pod.
+func (c *PodController) releaseNode(pod *api.Pod, nodeName, instanceID string) {
+	klog.V(2).Infof("returning node %s for pod %s", nodeName, pod.Name)
+	c.nodeDispenser.ReturnNode(nodeName, false)
+	routes := pod.Annotations[annotations.PodCloudRoute]
+	if instanceID != "" && len(routes) > 0 {
+		klog.V(2).Infof("removing route %s for pod %s", routes, pod.Name)
+		for _, cidr := range strings.Fields(routes) {
+			err := c.cloudClient.RemoveRoute(cidr, instanceID)
+			if err != nil {
+				klog.Warningf("removing cidr %s for pod %s (%s): %v",
+					cidr, pod.Name, instanceID, err)
 			}
 		}
-	}()
+	}
+}
+
+// suspendUnboundPod parks a pod that doesn't have a cell in the
+// Suspended phase. There's no instance to stop, only the status
+// changes.
+func (c *PodController) suspendUnboundPod(pod *api.Pod) {
+	pod.Status.Phase = api.PodSuspended
+	msg := fmt.Sprintf("Pod %s suspended before getting a cell", pod.Name)
+	c.events.Emit(events.PodSuspended, "pod-controller", pod, msg)
+	if _, err := c.podRegistry.UpdatePodStatus(pod, "Pod suspended"); err != nil {
+		klog.Errorf("Error updating suspended pod %s status: %v", pod.Name, err)
+	}
+}
+
+// suspendBoundPod stops the cell backing a pod while keeping the pod
+// object and any persistent volumes around so the pod can be resumed
+// later. Everything stored on the cell itself is lost, so the pod's
+// logs are saved and the unit states are rolled into their last
+// termination state before the cell is released.
+func (c *PodController) suspendBoundPod(pod *api.Pod) {
+	c.savePodLogs(pod)
+	boundNodeName := pod.Status.BoundNodeName
+	boundInstanceID := pod.Status.BoundInstanceID
+	cleanSuspendedPodStatus(pod)
+	msg := fmt.Sprintf("Pod %s suspended, releasing cell %s",
+		pod.Name, boundNodeName)
+	c.events.Emit(events.PodSuspended, "pod-controller", pod, msg)
+	if _, err := c.podRegistry.UpdatePodStatus(pod, "Pod suspended"); err != nil {
+		klog.Errorf("Error updating suspended pod %s status: %v", pod.Name, err)
+		return
+	}
+	go c.releaseNode(pod, boundNodeName, boundInstanceID)
+}
+
+// resumePod relaunches a suspended pod. Its status goes back to
+// Waiting and the regular dispatch path gets it a fresh cell,
+// reattaching any persistent volumes the pod references.
+func (c *PodController) resumePod(pod *api.Pod) {
+	msg := fmt.Sprintf("Pod %s resuming from suspension", pod.Name)
+	c.events.Emit(events.PodResumed, "pod-controller", pod, msg)
+	pod.Status.Phase = api.PodWaiting
+	pod, err := c.podRegistry.UpdatePodStatus(pod, "Pod resuming")
+	if err != nil {
+		klog.Errorf("Error updating resuming pod %s status: %v", pod.Name, err)
+		return
+	}
+	c.schedulePod(pod)
 }
 
 func (c *PodController) queryPodStatus(pod *api.Pod) FullPodStatus {
@@ -933,9 +1536,53 @@ func (c *PodController) queryPodStatus(pod *api.Pod) FullPodStatus {
 		ResourceUsage:    replyStatuses.ResourceUsage,
 		Error:            nil,
 	}
+	resolveTerminationMessages(client, pod.Spec.Units, reply.UnitStatuses)
+	resolveTerminationMessages(client, pod.Spec.InitUnits, reply.InitUnitStatuses)
 	return reply
 }
 
+// resolveTerminationMessages fills in Terminated.Message for units that
+// exited without reporting one. The unit's termination message file is
+// read from the node; with FallbackToLogsOnError the tail of the
+// unit's logs is used when that file is empty and the unit failed.
+func resolveTerminationMessages(client nodeclient.NodeClient, units []api.Unit, statuses []api.UnitStatus) {
+	for i := range statuses {
+		for j := range units {
+			if units[j].Name == statuses[i].Name {
+				resolveTerminationMessage(client, &units[j], &statuses[i])
+				break
+			}
+		}
+	}
+}
+
+func resolveTerminationMessage(client nodeclient.NodeClient, unit *api.Unit, status *api.UnitStatus) {
+	term := status.State.Terminated
+	if term == nil || term.Message != "" {
+		return
+	}
+	path := unit.TerminationMessagePath
+	if path == "" {
+		path = api.TerminationMessagePathDefault
+	}
+	nodePath := filepath.Join(itzoUnitsDir, unit.Name, "ROOTFS", path)
+	data, err := client.GetFile(nodePath, 0, maxTerminationMessageBytes)
+	if err != nil {
+		klog.V(4).Infof("reading termination message file %s for unit %s: %v",
+			path, unit.Name, err)
+	}
+	if len(data) == 0 &&
+		unit.TerminationMessagePolicy == api.TerminationMessageFallbackToLogsOnError &&
+		term.ExitCode != 0 {
+		data, err = client.GetLogs(unit.Name, 0, maxTerminationMessageBytes)
+		if err != nil {
+			klog.Warningf("getting logs for termination message of unit %s: %v",
+				unit.Name, err)
+		}
+	}
+	term.Message = string(data)
+}
+
 func filterUnitStatuses(units []api.Unit, statusmap map[string]api.UnitStatus) []api.UnitStatus {
 	// Not sure if we should do this but I'm going to filter out
 	// statuses for units that aren't in our spec and add un-acked
@@ -1042,6 +1689,72 @@ func (c *PodController) handlePodSucceeded(pod *api.Pod) {
 	//c.deleteFinishedPod(pod)
 }
 
+// podSchedulingTimedOut reports whether a waiting pod has exceeded its
+// scheduling timeout. The timeout is measured from pod creation so it
+// covers all dispatch attempts, including AZ retries and instance type
+// fallbacks. A pod-level timeout overrides the controller default and
+// no timeout at all (the default) keeps today's wait-forever behavior.
+func (c *PodController) podSchedulingTimedOut(pod *api.Pod) bool {
+	timeout := c.schedulingTimeout
+	if pod.Spec.SchedulingTimeoutSeconds > 0 {
+		timeout = time.Duration(pod.Spec.SchedulingTimeoutSeconds) * time.Second
+	}
+	if timeout <= 0 {
+		return false
+	}
+	return time.Since(pod.CreationTimestamp.Time) > timeout
+}
+
+func (c *PodController) failSchedulingTimedOutPod(pod *api.Pod) {
+	waited := time.Since(pod.CreationTimestamp.Time).Round(time.Second)
+	msg := fmt.Sprintf(
+		"pod %s could not be scheduled within its scheduling timeout (waited %v): no cell with capacity was available",
+		pod.Name, waited)
+	klog.Warningf("%s", msg)
+	c.events.Emit(events.PodScheduleTimeout, "pod-controller", pod, msg)
+	c.podRegistry.TerminatePod(pod, api.PodFailed, msg)
+}
+
+// podDeadlineExceeded reports whether a running pod has been active
+// past its activeDeadlineSeconds. The deadline is measured from the
+// first time the pod started running, restarts don't reset it.
+func podDeadlineExceeded(pod *api.Pod) bool {
+	if pod.Spec.ActiveDeadlineSeconds == nil ||
+		pod.Status.StartTime == nil {
+		return false
+	}
+	deadline := time.Duration(*pod.Spec.ActiveDeadlineSeconds) * time.Second
+	return time.Since(pod.Status.StartTime.Time) > deadline
+}
+
+// terminateDeadlineExceededPods fails running pods that have been
+// active past their activeDeadlineSeconds. The pod is failed
+// terminally, bypassing restart policy: a batch job that blew its
+// wall-clock budget shouldn't be started over.
+func (c *PodController) terminateDeadlineExceededPods() {
+	podList, err := c.podRegistry.ListPods(func(p *api.Pod) bool {
+		return p.Status.Phase == api.PodRunning && podDeadlineExceeded(p)
+	})
+	if err != nil {
+		klog.Errorf("Error listing pods for active deadline check: %v", err)
+		return
+	}
+	for _, pod := range podList.Items {
+		msg := fmt.Sprintf(
+			"DeadlineExceeded: pod %s was active longer than its deadline of %ds",
+			pod.Name, *pod.Spec.ActiveDeadlineSeconds)
+		klog.Warningf("%s", msg)
+		c.events.Emit(events.PodDeadlineExceeded, "pod-controller", pod, msg)
+		c.savePodLogs(pod)
+		if err := c.podRegistry.TerminatePod(pod, api.PodFailed, msg); err != nil {
+			klog.Errorf("Error failing deadline exceeded pod %s: %v",
+				pod.Name, err)
+			continue
+		}
+		go c.releaseNode(pod, pod.Status.BoundNodeName, pod.Status.BoundInstanceID)
+	}
+}
+
 func podNeedsControlling(p *api.Pod) bool {
 	return p.Spec.Phase != p.Status.Phase
 }
@@ -1066,21 +1779,41 @@ func (c *PodController) ControlPods() {
 			// if terminated, we don't care
 			switch pod.Status.Phase {
 			case api.PodWaiting:
+				if c.podSchedulingTimedOut(pod) {
+					c.failSchedulingTimedOutPod(pod)
+					continue
+				}
 				c.schedulePod(pod)
 			case api.PodDispatching:
 				klog.Warningf("Previously dispatching pod %s is not finished dispatching", pod.Name)
 			case api.PodRunning:
 				klog.Warningf("Pod %s is already in desired state, no control necessary", pod.Name)
 			case api.PodFailed:
-				remedyFailedPod(pod, c.podRegistry)
+				remedyFailedPod(pod, c.podRegistry, c.events)
 			case api.PodSucceeded:
 				c.handlePodSucceeded(pod)
+			case api.PodSuspended:
+				c.resumePod(pod)
 			case api.PodTerminated:
 				// We've likely set this pod as dead after too many failures
 				klog.Warningf("pod %s is terminated but speced to be running", pod.Name)
 			default:
 				klog.Errorf("Unknown pod phase: %s", pod.Status.Phase)
 			}
+		case api.PodSuspended:
+			// if waiting, there's no cell yet, just park the pod
+			// if dispatching or running, stop the cell but keep the pod
+			// if failed or succeeded, the pod already finished, leave it
+			// for the user to resume or delete
+			switch pod.Status.Phase {
+			case api.PodWaiting:
+				c.suspendUnboundPod(pod)
+			case api.PodDispatching, api.PodRunning:
+				c.suspendBoundPod(pod)
+			case api.PodFailed, api.PodSucceeded, api.PodTerminated:
+				klog.Warningf("pod %s is %s, nothing to suspend",
+					pod.Name, pod.Status.Phase)
+			}
 		case api.PodTerminated:
 			// if waiting, just mark it as terminated
 			// if dispatching, log that we will try to terminate it soon
@@ -1092,7 +1825,7 @@ func (c *PodController) ControlPods() {
 			// if failed or succeeded, move to Terminated
 			//
 			switch pod.Status.Phase {
-			case api.PodWaiting, api.PodFailed, api.PodSucceeded:
+			case api.PodWaiting, api.PodSuspended, api.PodFailed, api.PodSucceeded:
 				c.terminateUnboundPod(pod)
 			case api.PodDispatching, api.PodRunning:
 				c.terminateBoundPod(pod)
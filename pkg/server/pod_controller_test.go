@@ -30,9 +30,11 @@ import (
 	"github.com/elotl/kip/pkg/server/healthcheck"
 	"github.com/elotl/kip/pkg/server/nodemanager"
 	"github.com/elotl/kip/pkg/server/registry"
+	"github.com/elotl/kip/pkg/util"
 	"github.com/elotl/kip/pkg/util/k8s/eventrecorder"
 	"github.com/kubernetes/kubernetes/pkg/kubelet/network/dns"
 	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 func createPodController(c nodeclient.ItzoClientFactoryer) (*PodController, func()) {
@@ -60,6 +62,9 @@ func createPodController(c nodeclient.ItzoClientFactoryer) (*PodController, func
 		events:            events.NewEventSystem(quit, wg),
 		cloudClient:       cloud.NewMockClient(),
 		healthChecker:     healthChecker,
+		volumeDeploys:     newVolumeDeployCache(),
+		postStartRuns:     newPostStartTracker(),
+		grpcProbeFailures: newProbeFailureTracker(),
 	}
 	controller.dnsConfigurer = dns.NewConfigurer(
 		eventrecorder.NewLoggingEventRecorder(5),
@@ -103,6 +108,76 @@ func TestDispatchPodToNodeHappy(t *testing.T) {
 	}
 }
 
+func TestDispatchPodSourceRanges(t *testing.T) {
+	t.Parallel()
+	client := nodeclient.NewMockItzoClientFactory()
+	ctl, closer := createPodController(client)
+	defer closer()
+	mockCloud := ctl.cloudClient.(*cloud.MockCloudClient)
+	var ensuredName string
+	var ensuredPorts []cloud.InstancePort
+	var ensuredRanges []string
+	mockCloud.SecurityGroupEnsurer = func(sgName string, ports []cloud.InstancePort, sourceRanges []string) (*cloud.SecurityGroup, error) {
+		ensuredName = sgName
+		ensuredPorts = ports
+		ensuredRanges = sourceRanges
+		sg := cloud.NewSecurityGroup("sg-1234", sgName, ports, sourceRanges)
+		return &sg, nil
+	}
+	pod := api.GetFakePod()
+	pod.Spec.SourceRanges = []string{"192.0.2.0/24"}
+	pod.Spec.Units[0].Ports = []api.ContainerPort{
+		{Name: "http", Protocol: api.ProtocolTCP, HostPort: 8080, ContainerPort: 8080},
+		{Name: "internal", ContainerPort: 9090},
+	}
+	pod, err := ctl.podRegistry.CreatePod(pod)
+	assert.NoError(t, err)
+	pod.Status.Phase = api.PodDispatching
+	ctl.podRegistry.UpdatePodStatus(pod, "")
+	node := bindPodToANode(t, pod, ctl)
+	ctl.dispatchPodToNode(pod, node)
+	assert.Equal(t, api.PodRunning, pod.Status.Phase)
+	assert.Equal(t, util.CreateSecurityGroupName(ctl.controllerID, pod.Name), ensuredName)
+	// only ports with a host port are opened up
+	expectedPorts := []cloud.InstancePort{
+		{Name: "http", Protocol: api.ProtocolTCP, Port: 8080, PortRangeSize: 1},
+	}
+	assert.Equal(t, expectedPorts, ensuredPorts)
+	assert.Equal(t, pod.Spec.SourceRanges, ensuredRanges)
+}
+
+func TestPodCloudTags(t *testing.T) {
+	t.Parallel()
+	pod := api.GetFakePod()
+	pod.Name = "kube-system_mypod"
+	pod.Labels = map[string]string{
+		"app":                    "web",
+		"team":                   "infra",
+		"kubernetes.io/role":     "worker",
+		cloud.ControllerTagKey:   "spoofed",
+		"io.kubernetes.pod.name": "mypod",
+	}
+	// no allowlist: everything but internal and reserved keys
+	ctl := &PodController{nametag: "nametag"}
+	tags := ctl.podCloudTags(pod)
+	expected := map[string]string{
+		"app":                 "web",
+		"team":                "infra",
+		cloud.PodNameTagKey:   util.CreateBoundNodeNameTag("nametag", "mypod"),
+		cloud.NamespaceTagKey: "kube-system",
+	}
+	assert.Equal(t, expected, tags)
+	// an allowlist restricts which labels become tags
+	ctl.instanceTagLabels = []string{"team"}
+	tags = ctl.podCloudTags(pod)
+	expected = map[string]string{
+		"team":                "infra",
+		cloud.PodNameTagKey:   util.CreateBoundNodeNameTag("nametag", "mypod"),
+		cloud.NamespaceTagKey: "kube-system",
+	}
+	assert.Equal(t, expected, tags)
+}
+
 func schedulePodHelper(t *testing.T, ctl *PodController, pod *api.Pod) {
 	go func() {
 		node := api.GetFakeNode()
@@ -114,6 +189,48 @@ func schedulePodHelper(t *testing.T, ctl *PodController, pod *api.Pod) {
 	ctl.schedulePod(pod)
 }
 
+func TestConstrainPodToVolumeAZ(t *testing.T) {
+	t.Parallel()
+	client := nodeclient.NewMockItzoClientFactory()
+	ctl, closer := createPodController(client)
+	defer closer()
+	mockCloud := ctl.cloudClient.(*cloud.MockCloudClient)
+	mockCloud.VolumeAZGetter = func(volumeID string) (string, error) {
+		return "us-east-1b", nil
+	}
+
+	// Pods without cloud volumes are left alone.
+	pod := api.GetFakePod()
+	pod, err := ctl.podRegistry.CreatePod(pod)
+	assert.NoError(t, err)
+	err = ctl.constrainPodToVolumeAZ(pod)
+	assert.NoError(t, err)
+	assert.Equal(t, "", pod.Spec.Placement.AvailabilityZone)
+
+	// A pod referencing a cloud volume gets pinned to the volume's AZ.
+	pod.Spec.Volumes = []api.Volume{
+		{
+			Name: "data",
+			VolumeSource: api.VolumeSource{
+				CloudVolume: &api.CloudVolumeSource{
+					VolumeID: "vol-123",
+				},
+			},
+		},
+	}
+	err = ctl.constrainPodToVolumeAZ(pod)
+	assert.NoError(t, err)
+	assert.Equal(t, "us-east-1b", pod.Spec.Placement.AvailabilityZone)
+	p, err := ctl.podRegistry.GetPod(pod.Name)
+	assert.NoError(t, err)
+	assert.Equal(t, "us-east-1b", p.Spec.Placement.AvailabilityZone)
+
+	// A placement conflicting with the volume's AZ is an error.
+	pod.Spec.Placement.AvailabilityZone = "us-east-1a"
+	err = ctl.constrainPodToVolumeAZ(pod)
+	assert.Error(t, err)
+}
+
 func TestCheckClaimedNodesSimple(t *testing.T) {
 	t.Parallel()
 	client := nodeclient.NewMockItzoClientFactory()
@@ -172,6 +289,7 @@ func MakeUnitFailed(name string) api.UnitStatus {
 
 type podPhaseInput struct {
 	restartPolicy api.RestartPolicy
+	specUnits     []api.Unit
 	units         []api.UnitStatus
 	phase         api.PodPhase
 	isValid       bool
@@ -365,7 +483,7 @@ func TestFailingToStartPod(t *testing.T) {
 	// Go through and test a pod that continually fails to launch
 	for i := 0; i <= allowedStartFailures; i++ {
 		ctl.markFailedPod(p, true, "")
-		remedyFailedPod(p, ctl.podRegistry)
+		remedyFailedPod(p, ctl.podRegistry, ctl.events)
 		p, err := ctl.podRegistry.GetPod(p.Name)
 		if i == allowedStartFailures {
 			assert.Equal(t, store.ErrKeyNotFound, err)
@@ -382,6 +500,56 @@ func TestFailingToStartPod(t *testing.T) {
 	}
 }
 
+func TestSuspendAndResumePod(t *testing.T) {
+	t.Parallel()
+	client := nodeclient.NewMockItzoClientFactory()
+	ctl, closer := createPodController(client)
+	defer closer()
+	p := api.GetFakePod()
+	p.Spec.Phase = api.PodRunning
+	p.Status.Phase = api.PodRunning
+	p, err := ctl.podRegistry.CreatePod(p)
+	assert.NoError(t, err)
+	node := api.GetFakeNode()
+	nodeReg := ctl.nodeLister.(*registry.NodeRegistry)
+	node, err = nodeReg.CreateNode(node)
+	assert.NoError(t, err)
+	p.Status.BoundNodeName = node.Name
+	p.Status.BoundInstanceID = node.Status.InstanceID
+	p, err = ctl.podRegistry.UpdatePodStatus(p, "")
+	assert.NoError(t, err)
+
+	// Suspending releases the pod's node but keeps the pod object.
+	p.Spec.Phase = api.PodSuspended
+	p, err = ctl.podRegistry.UpdatePodSpecAndLabels(p)
+	assert.NoError(t, err)
+	ctl.ControlPods()
+	p, err = ctl.podRegistry.GetPod(p.Name)
+	assert.NoError(t, err)
+	assert.Equal(t, api.PodSuspended, p.Status.Phase)
+	assert.Equal(t, "", p.Status.BoundNodeName)
+	select {
+	case ret := <-ctl.nodeDispenser.NodeReturnChan:
+		assert.Equal(t, node.Name, ret.NodeName)
+		assert.False(t, ret.Unused)
+	case <-time.After(3 * time.Second):
+		t.Fatal("suspended pod's node was not returned")
+	}
+
+	// Resuming sends the pod back through the regular dispatch path.
+	p.Spec.Phase = api.PodRunning
+	p, err = ctl.podRegistry.UpdatePodSpecAndLabels(p)
+	assert.NoError(t, err)
+	go func() {
+		newNode := api.GetFakeNode()
+		nodeReg.CreateNode(newNode)
+		req := <-ctl.nodeDispenser.NodeRequestChan
+		req.ReplyChan <- nodemanager.NodeReply{Node: newNode}
+	}()
+	ctl.ControlPods()
+	waitForPodInState(t, ctl, p.Name, api.PodRunning)
+}
+
 func FailStatus() (*api.PodStatusReply, error) {
 	return nil, fmt.Errorf("Status failed")
 }
@@ -471,6 +639,216 @@ func bindPodToANode(t *testing.T, pod *api.Pod, ctl *PodController) *api.Node {
 	return node
 }
 
+func TestPodSchedulingTimedOut(t *testing.T) {
+	t.Parallel()
+	oldPod := api.GetFakePod()
+	oldPod.CreationTimestamp = api.Time{Time: time.Now().Add(-10 * time.Minute)}
+	newPod := api.GetFakePod()
+	newPod.CreationTimestamp = api.Now()
+	tests := []struct {
+		name              string
+		pod               *api.Pod
+		podTimeoutSeconds int64
+		controllerTimeout time.Duration
+		expected          bool
+	}{
+		{
+			name:     "no timeout configured waits forever",
+			pod:      oldPod,
+			expected: false,
+		},
+		{
+			name:              "controller default timeout expired",
+			pod:               oldPod,
+			controllerTimeout: 5 * time.Minute,
+			expected:          true,
+		},
+		{
+			name:              "pod timeout overrides controller default",
+			pod:               oldPod,
+			podTimeoutSeconds: 3600,
+			controllerTimeout: 5 * time.Minute,
+			expected:          false,
+		},
+		{
+			name:              "pod timeout expired",
+			pod:               oldPod,
+			podTimeoutSeconds: 60,
+			expected:          true,
+		},
+		{
+			name:              "young pod is not timed out",
+			pod:               newPod,
+			controllerTimeout: 5 * time.Minute,
+			expected:          false,
+		},
+	}
+	for _, tc := range tests {
+		ctl := &PodController{schedulingTimeout: tc.controllerTimeout}
+		tc.pod.Spec.SchedulingTimeoutSeconds = tc.podTimeoutSeconds
+		assert.Equal(t, tc.expected, ctl.podSchedulingTimedOut(tc.pod), tc.name)
+	}
+}
+
+func TestPodDeadlineExceeded(t *testing.T) {
+	t.Parallel()
+	deadline := int64(60)
+	longDeadline := int64(3600)
+	started := api.Time{Time: time.Now().Add(-10 * time.Minute)}
+	justStarted := api.Now()
+	tests := []struct {
+		name     string
+		deadline *int64
+		started  *api.Time
+		expected bool
+	}{
+		{
+			name:     "no deadline runs forever",
+			started:  &started,
+			expected: false,
+		},
+		{
+			name:     "pod that never ran has no deadline",
+			deadline: &deadline,
+			expected: false,
+		},
+		{
+			name:     "deadline exceeded",
+			deadline: &deadline,
+			started:  &started,
+			expected: true,
+		},
+		{
+			name:     "deadline not reached",
+			deadline: &longDeadline,
+			started:  &justStarted,
+			expected: false,
+		},
+	}
+	for _, tc := range tests {
+		pod := api.GetFakePod()
+		pod.Spec.ActiveDeadlineSeconds = tc.deadline
+		pod.Status.StartTime = tc.started
+		assert.Equal(t, tc.expected, podDeadlineExceeded(pod), tc.name)
+	}
+}
+
+func TestTerminateDeadlineExceededPods(t *testing.T) {
+	t.Parallel()
+	client := nodeclient.NewMockItzoClientFactory()
+	ctl, closer := createPodController(client)
+	defer closer()
+	// A pod whose unit has been sleeping well past its 60s deadline.
+	pod := api.GetFakePod()
+	deadline := int64(60)
+	pod.Spec.ActiveDeadlineSeconds = &deadline
+	pod.Spec.Phase = api.PodRunning
+	pod.Status.Phase = api.PodRunning
+	started := api.Time{Time: time.Now().Add(-10 * time.Minute)}
+	pod.Status.StartTime = &started
+	pod, err := ctl.podRegistry.CreatePod(pod)
+	assert.NoError(t, err)
+	bindPodToANode(t, pod, ctl)
+
+	ctl.terminateDeadlineExceededPods()
+
+	// The pod is failed terminally and cleaned up, it doesn't come
+	// back on a new cell.
+	_, err = ctl.podRegistry.GetPod(pod.Name)
+	assert.Equal(t, store.ErrKeyNotFound, err)
+}
+
+func TestUpdatePodUnitsSendsSupplementalGroups(t *testing.T) {
+	t.Parallel()
+	client := nodeclient.NewMockItzoClientFactory()
+	var sentParams api.PodParameters
+	client.Update = func(pp api.PodParameters) error {
+		sentParams = pp
+		return nil
+	}
+	ctl, closer := createPodController(client)
+	defer closer()
+	pod := api.GetFakePod()
+	pod.Spec.SecurityContext = &api.PodSecurityContext{
+		SupplementalGroups: []int64{2000, 3000},
+	}
+	pod, err := ctl.podRegistry.CreatePod(pod)
+	assert.NoError(t, err)
+	bindPodToANode(t, pod, ctl)
+	err = ctl.updatePodUnits(pod)
+	assert.NoError(t, err)
+	if assert.NotNil(t, sentParams.Spec.SecurityContext) {
+		assert.Equal(t,
+			[]int64{2000, 3000},
+			sentParams.Spec.SecurityContext.SupplementalGroups)
+	}
+}
+
+func TestUpdatePodUnitsSendsStopSignal(t *testing.T) {
+	t.Parallel()
+	client := nodeclient.NewMockItzoClientFactory()
+	var sentParams api.PodParameters
+	client.Update = func(pp api.PodParameters) error {
+		sentParams = pp
+		return nil
+	}
+	ctl, closer := createPodController(client)
+	defer closer()
+	pod := api.GetFakePod()
+	pod.Spec.Units[0].StopSignal = "SIGQUIT"
+	pod, err := ctl.podRegistry.CreatePod(pod)
+	assert.NoError(t, err)
+	bindPodToANode(t, pod, ctl)
+	err = ctl.updatePodUnits(pod)
+	assert.NoError(t, err)
+	if assert.Len(t, sentParams.Spec.Units, len(pod.Spec.Units)) {
+		assert.Equal(t, "SIGQUIT", sentParams.Spec.Units[0].StopSignal)
+	}
+}
+
+func TestUpdatePodUnitsSendsUlimits(t *testing.T) {
+	t.Parallel()
+	client := nodeclient.NewMockItzoClientFactory()
+	var sentParams api.PodParameters
+	client.Update = func(pp api.PodParameters) error {
+		sentParams = pp
+		return nil
+	}
+	ctl, closer := createPodController(client)
+	defer closer()
+	pod := api.GetFakePod()
+	ulimits := []api.Ulimit{{Name: "nofile", Soft: 1024, Hard: 65536}}
+	pod.Spec.Units[0].Ulimits = ulimits
+	pod, err := ctl.podRegistry.CreatePod(pod)
+	assert.NoError(t, err)
+	bindPodToANode(t, pod, ctl)
+	err = ctl.updatePodUnits(pod)
+	assert.NoError(t, err)
+	if assert.Len(t, sentParams.Spec.Units, len(pod.Spec.Units)) {
+		assert.Equal(t, ulimits, sentParams.Spec.Units[0].Ulimits)
+	}
+}
+
+func TestUpdatePodUnitsSendsShmSize(t *testing.T) {
+	t.Parallel()
+	client := nodeclient.NewMockItzoClientFactory()
+	var sentParams api.PodParameters
+	client.Update = func(pp api.PodParameters) error {
+		sentParams = pp
+		return nil
+	}
+	ctl, closer := createPodController(client)
+	defer closer()
+	pod := api.GetFakePod()
+	pod.Spec.ShmSize = "1Gi"
+	pod, err := ctl.podRegistry.CreatePod(pod)
+	assert.NoError(t, err)
+	bindPodToANode(t, pod, ctl)
+	err = ctl.updatePodUnits(pod)
+	assert.NoError(t, err)
+	assert.Equal(t, "1Gi", sentParams.Spec.ShmSize)
+}
+
 func TestSetPodDispatchingParams(t *testing.T) {
 	client := nodeclient.NewMockItzoClientFactory()
 	ctl, closer := createPodController(client)
@@ -573,3 +951,170 @@ func TestParseDockerConfigCreds(t *testing.T) {
 		assert.Equal(t, tc.password, regCreds.Password)
 	}
 }
+
+func TestResolveTerminationMessage(t *testing.T) {
+	client := nodeclient.NewMockItzoClientFactory()
+	fileContent := ""
+	client.File = func(path string, lines, bytes int) ([]byte, error) {
+		return []byte(fileContent), nil
+	}
+	client.Logs = func(unit string, lines, bytes int) ([]byte, error) {
+		return []byte("tail of logs"), nil
+	}
+	tests := []struct {
+		name        string
+		unit        api.Unit
+		status      api.UnitStatus
+		fileContent string
+		expected    string
+	}{
+		{
+			name:        "message read from file",
+			unit:        api.Unit{Name: "foo"},
+			status:      terminatedUnitStatus("foo", 1, ""),
+			fileContent: "wrote my own",
+			expected:    "wrote my own",
+		},
+		{
+			name: "fallback to logs on error",
+			unit: api.Unit{
+				Name:                     "foo",
+				TerminationMessagePolicy: api.TerminationMessageFallbackToLogsOnError,
+			},
+			status:   terminatedUnitStatus("foo", 1, ""),
+			expected: "tail of logs",
+		},
+		{
+			name: "no fallback on clean exit",
+			unit: api.Unit{
+				Name:                     "foo",
+				TerminationMessagePolicy: api.TerminationMessageFallbackToLogsOnError,
+			},
+			status:   terminatedUnitStatus("foo", 0, ""),
+			expected: "",
+		},
+		{
+			name:     "existing message is kept",
+			unit:     api.Unit{Name: "foo"},
+			status:   terminatedUnitStatus("foo", 1, "from the node"),
+			expected: "from the node",
+		},
+	}
+	for _, tc := range tests {
+		fileContent = tc.fileContent
+		resolveTerminationMessages(
+			client, []api.Unit{tc.unit}, []api.UnitStatus{tc.status})
+		assert.Equal(t, tc.expected, tc.status.State.Terminated.Message, tc.name)
+	}
+}
+
+func terminatedUnitStatus(name string, exitCode int32, msg string) api.UnitStatus {
+	return api.UnitStatus{
+		Name: name,
+		State: api.UnitState{
+			Terminated: &api.UnitStateTerminated{
+				ExitCode: exitCode,
+				Message:  msg,
+			},
+		},
+	}
+}
+
+func TestApplyRegistryMirrors(t *testing.T) {
+	mirrors := map[string]string{
+		"docker.io": "mirror.internal:5000",
+		"gcr.io":    "gcr-cache.internal",
+	}
+	tests := []struct {
+		name     string
+		image    string
+		expected string
+	}{
+		{
+			name:     "official docker hub image",
+			image:    "redis:5.0",
+			expected: "mirror.internal:5000/library/redis:5.0",
+		},
+		{
+			name:     "docker hub user image",
+			image:    "elotl/kip:latest",
+			expected: "mirror.internal:5000/elotl/kip:latest",
+		},
+		{
+			name:     "mirrored registry",
+			image:    "gcr.io/proj/app:v1",
+			expected: "gcr-cache.internal/proj/app:v1",
+		},
+		{
+			name:     "unmirrored registry",
+			image:    "quay.io/org/app:v1",
+			expected: "quay.io/org/app:v1",
+		},
+	}
+	for _, tc := range tests {
+		assert.Equal(t, tc.expected, rewriteImageToMirror(tc.image, mirrors), tc.name)
+	}
+
+	// The pod's own units keep the original reference, only the copy
+	// sent to the cell is rewritten.
+	spec := api.PodSpec{
+		Units:     []api.Unit{{Name: "u", Image: "redis:5.0"}},
+		InitUnits: []api.Unit{{Name: "i", Image: "gcr.io/proj/init:v1"}},
+	}
+	rewritten := applyRegistryMirrors(spec, mirrors)
+	assert.Equal(t, "mirror.internal:5000/library/redis:5.0", rewritten.Units[0].Image)
+	assert.Equal(t, "gcr-cache.internal/proj/init:v1", rewritten.InitUnits[0].Image)
+	assert.Equal(t, "redis:5.0", spec.Units[0].Image)
+	assert.Equal(t, "gcr.io/proj/init:v1", spec.InitUnits[0].Image)
+
+	// No mirrors configured is a no-op.
+	assert.Equal(t, spec, applyRegistryMirrors(spec, nil))
+}
+
+func TestPreStopHookRunsOnPodDelete(t *testing.T) {
+	t.Parallel()
+	client := nodeclient.NewMockItzoClientFactory()
+	var mu sync.Mutex
+	var ranCommands [][]string
+	client.Run = func(cmd api.RunCmdParams) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		ranCommands = append(ranCommands, cmd.Command)
+		return "", nil
+	}
+	ctl, closer := createPodController(client)
+	defer closer()
+	pod := api.GetFakePod()
+	pod.Spec.Units[0].Lifecycle = &api.Lifecycle{
+		PreStop: &api.Handler{
+			Exec: &api.ExecAction{Command: []string{"/bin/cleanup", "now"}},
+		},
+	}
+	pod, err := ctl.podRegistry.CreatePod(pod)
+	assert.NoError(t, err)
+	pod.Status.Phase = api.PodRunning
+	ctl.podRegistry.UpdatePodStatus(pod, "")
+	bindPodToANode(t, pod, ctl)
+	ctl.terminateBoundPod(pod)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, [][]string{{"/bin/cleanup", "now"}}, ranCommands)
+}
+
+func TestResolveHandlerPort(t *testing.T) {
+	t.Parallel()
+	unit := &api.Unit{
+		Name: "web",
+		Ports: []api.ContainerPort{
+			{Name: "metrics", ContainerPort: 9102},
+		},
+	}
+	port, err := resolveHandlerPort(unit, intstr.FromInt(8080))
+	assert.NoError(t, err)
+	assert.Equal(t, 8080, port)
+	port, err = resolveHandlerPort(unit, intstr.FromString("metrics"))
+	assert.NoError(t, err)
+	assert.Equal(t, 9102, port)
+	_, err = resolveHandlerPort(unit, intstr.FromString("no-such-port"))
+	assert.Error(t, err)
+}
@@ -19,8 +19,10 @@ package server
 import (
 	"fmt"
 	"reflect"
+	"strconv"
 
 	"github.com/elotl/kip/pkg/api"
+	"github.com/elotl/kip/pkg/api/annotations"
 	"github.com/elotl/kip/pkg/server/events"
 	"github.com/elotl/kip/pkg/server/registry"
 	"k8s.io/klog"
@@ -38,6 +40,12 @@ const (
 // // going on.
 func setPodRunning(pod *api.Pod, nodeName string, podRegistry *registry.PodRegistry, eventSystem *events.EventSystem) error {
 	pod.Status.Phase = api.PodRunning
+	if pod.Status.StartTime == nil {
+		// Only set on the first run, the active deadline is measured
+		// from here across restarts.
+		now := api.Now()
+		pod.Status.StartTime = &now
+	}
 	pod.Status.InitUnitStatuses = make([]api.UnitStatus, len(pod.Spec.InitUnits))
 	for i := range pod.Spec.InitUnits {
 		pod.Status.InitUnitStatuses[i] = api.UnitStatus{
@@ -64,7 +72,21 @@ func setPodRunning(pod *api.Pod, nodeName string, podRegistry *registry.PodRegis
 	return err
 }
 
-func computePodPhase(policy api.RestartPolicy, unitstatus []api.UnitStatus, podName string) (phase api.PodPhase, failMsg string) {
+// unitRestartPolicy returns the restart policy in effect for the named
+// unit: the unit's own policy if it has one, the pod's otherwise.
+func unitRestartPolicy(podPolicy api.RestartPolicy, units []api.Unit, name string) api.RestartPolicy {
+	for i := range units {
+		if units[i].Name == name {
+			if units[i].RestartPolicy != nil {
+				return *units[i].RestartPolicy
+			}
+			break
+		}
+	}
+	return podPolicy
+}
+
+func computePodPhase(policy api.RestartPolicy, units []api.Unit, unitstatus []api.UnitStatus, podName string) (phase api.PodPhase, failMsg string) {
 	// Note: we need to treat the "created" unit state the same way as the
 	// "running" state. Itzo will set the status of units to "created" right
 	// after creating them, and only to "running" once the application is
@@ -85,54 +107,99 @@ func computePodPhase(policy api.RestartPolicy, unitstatus []api.UnitStatus, podN
 		}
 	}
 
-	switch policy {
-	case api.RestartPolicyAlways:
-		// Itzo should restart everything
-		// Pods that are waiting to be restarted will be in a terminated state
-		phase = api.PodRunning
-	case api.RestartPolicyNever:
-		// If all units have succeeded, pod has succeeded.
-		// If at least one unit is waiting or still running, pod is running
-		phase = api.PodSucceeded
-		for _, us := range unitstatus {
-			if us.State.Waiting != nil || us.State.Running != nil {
-				failMsg = ""
-				phase = api.PodRunning
-				break
-			}
-			if us.State.Terminated != nil && us.State.Terminated.ExitCode != 0 {
-				failMsg = fmt.Sprintf("Unit %s terminated with non-zero exit code %d", us.Name, us.State.Terminated.ExitCode)
-				phase = api.PodFailed
-			}
+	// Each unit is judged by the restart policy in effect for it,
+	// which can be overridden per unit. The pod only completes once
+	// every non-restarting unit has terminated: it succeeds when they
+	// all exited 0 and fails when one of them exited non-zero after
+	// exhausting its restarts.
+	anyRunning := false
+	anyFailed := false
+	for _, us := range unitstatus {
+		unitPolicy := unitRestartPolicy(policy, units, us.Name)
+		if unitPolicy == api.RestartPolicyAlways {
+			// Itzo restarts the unit no matter how it exits, it never
+			// reaches a terminal state.
+			anyRunning = true
+			continue
 		}
-	case api.RestartPolicyOnFailure:
-		// If all units have succeeded, pod has succeeded.
-		// If at least one unit is still running, pod is running.
-		// Failed is not a valid status with this restart policy.
-		phase = api.PodSucceeded
-		for _, us := range unitstatus {
-			if us.State.Running != nil ||
-				us.State.Waiting != nil ||
-				(us.State.Terminated != nil && us.State.Terminated.ExitCode != 0) {
-				failMsg = ""
-				phase = api.PodRunning
-				break
-			}
+		terminated := us.State.Terminated
+		if terminated == nil {
+			// The unit is waiting, running or its state hasn't been
+			// reported yet. Either way it hasn't completed, so the
+			// pod can't have.
+			anyRunning = true
+			continue
+		}
+		if terminated.ExitCode == 0 {
+			continue
 		}
+		if unitPolicy == api.RestartPolicyOnFailure {
+			// Failed units get restarted, so failure is not a
+			// terminal state with this policy.
+			anyRunning = true
+		} else {
+			failMsg = fmt.Sprintf("Unit %s terminated with non-zero exit code %d", us.Name, terminated.ExitCode)
+			anyFailed = true
+		}
+	}
+	if anyRunning {
+		return api.PodRunning, ""
+	}
+	if anyFailed {
+		return api.PodFailed, failMsg
 	}
-	return phase, failMsg
+	return api.PodSucceeded, ""
+}
+
+// podMaxStartFailures returns the number of start failures the pod is
+// allowed before we give up on it, either from the pod's
+// max-start-failures annotation or the controller default.
+func podMaxStartFailures(pod *api.Pod) int {
+	val, ok := pod.Annotations[annotations.PodMaxStartFailures]
+	if !ok {
+		return allowedStartFailures
+	}
+	maxFailures, err := strconv.Atoi(val)
+	if err != nil || maxFailures < 0 {
+		klog.Warningf("annotation %s on pod %s has invalid value %q, using default of %d",
+			annotations.PodMaxStartFailures, pod.Name, val, allowedStartFailures)
+		return allowedStartFailures
+	}
+	return maxFailures
 }
 
 func podShouldBeRestarted(pod *api.Pod) bool {
-	return pod.Status.StartFailures <= allowedStartFailures &&
+	return pod.Status.StartFailures <= podMaxStartFailures(pod) &&
 		pod.Spec.RestartPolicy != api.RestartPolicyNever
 }
 
+// podStartFailureMessage digs the reason for the most recent start
+// failure out of the pod's unit statuses so we can tell the user why
+// we gave up on the pod.
+func podStartFailureMessage(pod *api.Pod) string {
+	allStatuses := [][]api.UnitStatus{
+		pod.Status.InitUnitStatuses,
+		pod.Status.UnitStatuses,
+	}
+	for _, statuses := range allStatuses {
+		for _, us := range statuses {
+			if us.State.Waiting != nil && us.State.Waiting.StartFailure {
+				return fmt.Sprintf("unit %s: %s", us.Name, us.State.Waiting.Reason)
+			}
+			if us.LastTerminationState.Waiting != nil && us.LastTerminationState.Waiting.StartFailure {
+				return fmt.Sprintf("unit %s: %s", us.Name, us.LastTerminationState.Waiting.Reason)
+			}
+		}
+	}
+	return "too many start failures"
+}
+
 // Here we create a new status and populate UnitStatuses.LastTerminationState
 // with the last unit status
 func cleanFailedPodStatus(pod *api.Pod) {
 	newStatus := api.PodStatus{
 		Phase:            api.PodWaiting,
+		StartTime:        pod.Status.StartTime,
 		StartFailures:    pod.Status.StartFailures,
 		InitUnitStatuses: pod.Status.InitUnitStatuses,
 		UnitStatuses:     pod.Status.UnitStatuses,
@@ -148,7 +215,16 @@ func cleanFailedPodStatus(pod *api.Pod) {
 	pod.Status = newStatus
 }
 
-func remedyFailedPod(pod *api.Pod, podRegistry *registry.PodRegistry) {
+// cleanSuspendedPodStatus gives a suspended pod the same cleanup a
+// failed pod gets before relaunch (unit states rolled into
+// LastTerminationState, cell binding cleared) but parks the pod in
+// Suspended instead of sending it back to Waiting.
+func cleanSuspendedPodStatus(pod *api.Pod) {
+	cleanFailedPodStatus(pod)
+	pod.Status.Phase = api.PodSuspended
+}
+
+func remedyFailedPod(pod *api.Pod, podRegistry *registry.PodRegistry, eventSystem *events.EventSystem) {
 	if podShouldBeRestarted(pod) {
 		msg := fmt.Sprintf("Pod %s is being restarted on a new cell", pod.Name)
 		if pod.Status.StartFailures > 0 {
@@ -158,7 +234,10 @@ func remedyFailedPod(pod *api.Pod, podRegistry *registry.PodRegistry) {
 		cleanFailedPodStatus(pod)
 		podRegistry.UpdatePodStatus(pod, msg)
 	} else {
-		klog.Errorf("pod %s has failed to start %d times. Not trying again, pod has failed", pod.Name, pod.Status.StartFailures)
+		failMsg := podStartFailureMessage(pod)
+		klog.Errorf("pod %s has failed to start %d times. Not trying again, pod has failed: %s", pod.Name, pod.Status.StartFailures, failMsg)
+		eventSystem.Emit(events.PodExceededStartFailures, "pod-controller", pod,
+			"Pod %s failed to start %d times, giving up: %s", pod.Name, pod.Status.StartFailures, failMsg)
 		podRegistry.TerminatePod(pod, api.PodFailed,
 			"Pod failed: too many start failures")
 	}
@@ -166,12 +245,12 @@ func remedyFailedPod(pod *api.Pod, podRegistry *registry.PodRegistry) {
 
 func updatePodWithStatus(pod *api.Pod, reply FullPodStatus) (changed, startFailure bool, failMsg string) {
 	policy := pod.Spec.RestartPolicy
-	podPhase, unitFailMsg := computePodPhase(policy, reply.UnitStatuses, pod.Name)
+	podPhase, unitFailMsg := computePodPhase(policy, pod.Spec.Units, reply.UnitStatuses, pod.Name)
 
 	if policy == api.RestartPolicyAlways {
 		policy = api.RestartPolicyOnFailure
 	}
-	initPodPhase, initUnitFailMsg := computePodPhase(policy, reply.InitUnitStatuses, pod.Name)
+	initPodPhase, initUnitFailMsg := computePodPhase(policy, pod.Spec.InitUnits, reply.InitUnitStatuses, pod.Name)
 	failMsg = initUnitFailMsg + unitFailMsg
 	if initPodPhase == api.PodFailed {
 		podPhase = api.PodFailed
@@ -18,19 +18,23 @@ package server
 
 import (
 	"fmt"
+	"sync"
 	"testing"
 
 	"github.com/docker/libkv/store"
 	"github.com/elotl/kip/pkg/api"
+	"github.com/elotl/kip/pkg/api/annotations"
+	"github.com/elotl/kip/pkg/server/events"
 	"github.com/elotl/kip/pkg/server/registry"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestRemedyFailedPod(t *testing.T) {
 	tests := []struct {
-		startFails    int
-		restartPolicy api.RestartPolicy
-		expectedPhase api.PodPhase
+		startFails       int
+		maxStartFailures string
+		restartPolicy    api.RestartPolicy
+		expectedPhase    api.PodPhase
 	}{
 		{
 			startFails:    1,
@@ -52,17 +56,44 @@ func TestRemedyFailedPod(t *testing.T) {
 			restartPolicy: api.RestartPolicyNever,
 			expectedPhase: api.PodFailed,
 		},
+		{
+			startFails:       allowedStartFailures + 1,
+			maxStartFailures: "5",
+			restartPolicy:    api.RestartPolicyAlways,
+			expectedPhase:    api.PodWaiting,
+		},
+		{
+			startFails:       1,
+			maxStartFailures: "0",
+			restartPolicy:    api.RestartPolicyAlways,
+			expectedPhase:    api.PodFailed,
+		},
+		{
+			startFails:       allowedStartFailures,
+			maxStartFailures: "not-a-number",
+			restartPolicy:    api.RestartPolicyAlways,
+			expectedPhase:    api.PodWaiting,
+		},
 	}
 	podReg, closer := registry.SetupTestPodRegistry()
 	defer closer()
+	quit := make(chan struct{})
+	wg := &sync.WaitGroup{}
+	defer close(quit)
+	eventSystem := events.NewEventSystem(quit, wg)
 	for i, tc := range tests {
 		pod := api.GetFakePod()
 		pod.Status.Phase = api.PodFailed
 		pod.Spec.RestartPolicy = tc.restartPolicy
 		pod.Status.StartFailures = tc.startFails
+		if tc.maxStartFailures != "" {
+			pod.Annotations = map[string]string{
+				annotations.PodMaxStartFailures: tc.maxStartFailures,
+			}
+		}
 		_, err := podReg.CreatePod(pod)
 		assert.NoError(t, err)
-		remedyFailedPod(pod, podReg)
+		remedyFailedPod(pod, podReg, eventSystem)
 		p, err := podReg.GetPod(pod.Name)
 		if tc.expectedPhase == api.PodFailed {
 			assert.Equal(t, store.ErrKeyNotFound, err)
@@ -77,6 +108,11 @@ func TestRemedyFailedPod(t *testing.T) {
 
 func TestComputePodPhase(t *testing.T) {
 	t.Parallel()
+	never := api.RestartPolicyNever
+	runOnceHelper := []api.Unit{
+		{Name: "main"},
+		{Name: "helper", RestartPolicy: &never},
+	}
 	inputs := []podPhaseInput{
 		// RestartPolicyAlways
 		podPhaseInput{
@@ -168,6 +204,26 @@ func TestComputePodPhase(t *testing.T) {
 			},
 			phase: api.PodRunning,
 		},
+		// One non-zero exit fails the pod once nothing else is
+		// running, no matter how the other units did.
+		podPhaseInput{
+			restartPolicy: api.RestartPolicyNever,
+			units: []api.UnitStatus{
+				MakeUnitSucceeded("foo"),
+				MakeUnitFailed("bar"),
+			},
+			phase: api.PodFailed,
+		},
+		// A unit whose state hasn't been reported yet keeps the pod
+		// running, even when every other unit already succeeded.
+		podPhaseInput{
+			restartPolicy: api.RestartPolicyNever,
+			units: []api.UnitStatus{
+				MakeUnitSucceeded("foo"),
+				api.UnitStatus{Name: "bar"},
+			},
+			phase: api.PodRunning,
+		},
 		// RestartPolicyOnFailure
 		podPhaseInput{
 			restartPolicy: api.RestartPolicyOnFailure,
@@ -248,10 +304,48 @@ func TestComputePodPhase(t *testing.T) {
 			},
 			phase: api.PodSucceeded,
 		},
+		// Per-unit restart policy overrides: a restarting main unit
+		// with a run-once helper.
+		podPhaseInput{
+			restartPolicy: api.RestartPolicyOnFailure,
+			specUnits:     runOnceHelper,
+			units: []api.UnitStatus{
+				MakeUnitRunning("main"),
+				MakeUnitSucceeded("helper"),
+			},
+			phase: api.PodRunning,
+		},
+		podPhaseInput{
+			restartPolicy: api.RestartPolicyOnFailure,
+			specUnits:     runOnceHelper,
+			units: []api.UnitStatus{
+				MakeUnitFailed("main"),
+				MakeUnitSucceeded("helper"),
+			},
+			phase: api.PodRunning,
+		},
+		podPhaseInput{
+			restartPolicy: api.RestartPolicyOnFailure,
+			specUnits:     runOnceHelper,
+			units: []api.UnitStatus{
+				MakeUnitSucceeded("main"),
+				MakeUnitFailed("helper"),
+			},
+			phase: api.PodFailed,
+		},
+		podPhaseInput{
+			restartPolicy: api.RestartPolicyOnFailure,
+			specUnits:     runOnceHelper,
+			units: []api.UnitStatus{
+				MakeUnitSucceeded("main"),
+				MakeUnitSucceeded("helper"),
+			},
+			phase: api.PodSucceeded,
+		},
 	}
 	for i, inp := range inputs {
 		msg := fmt.Sprintf("test %d", i)
-		phase, failMsg := computePodPhase(inp.restartPolicy, inp.units, "testpod")
+		phase, failMsg := computePodPhase(inp.restartPolicy, inp.specUnits, inp.units, "testpod")
 		if inp.phase == api.PodFailed {
 			assert.NotEmpty(t, failMsg, msg)
 		} else {
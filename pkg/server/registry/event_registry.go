@@ -78,7 +78,9 @@ func NewEventRegistry(kvstore etcd.Storer, codec api.MilpaCodec, es *events.Even
 	return reg
 }
 
-func (reg *EventRegistry) Handle(e events.Event) error {
+// NewEventFromSystemEvent converts an internal event-system event
+// into an api Event record referencing the involved object.
+func NewEventFromSystemEvent(e events.Event) *api.Event {
 	obj := e.Object
 	value := reflect.ValueOf(obj).Elem()
 	// Event.Object is always a first-class Milpa object, i.e. a struct type
@@ -95,6 +97,11 @@ func (reg *EventRegistry) Handle(e events.Event) error {
 	ev.Status = string(e.Status)
 	ev.Source = e.Source
 	ev.Message = e.Message
+	return ev
+}
+
+func (reg *EventRegistry) Handle(e events.Event) error {
+	ev := NewEventFromSystemEvent(e)
 	_, err := reg.CreateEvent(ev)
 	if err != nil {
 		klog.Errorf("Error creating event %v in storage: %v", ev, err)
@@ -215,6 +215,11 @@ func (reg *NodeRegistry) listNodes(nodePath string, filter func(*api.Node) bool)
 }
 
 func (reg *NodeRegistry) PurgeNode(node *api.Node) (*api.Node, error) {
+	if len(node.Finalizers) > 0 {
+		return nil, fmt.Errorf(
+			"Node %s still has finalizers %v, refusing to purge it",
+			node.Name, node.Finalizers)
+	}
 	klog.V(2).Infof("Purging node %v", node)
 	reg.eventSystem.Emit(events.NodePurged, "node-registry", node)
 
@@ -78,6 +78,25 @@ func TestNodeDoubleCreate(t *testing.T) {
 // 	}
 // }
 
+func TestPurgeNodeWithFinalizer(t *testing.T) {
+	nodeRegistry, closer := SetupTestNodeRegistry()
+	defer closer()
+
+	n := api.GetFakeNode()
+	n.AddFinalizer(api.CloudResourcesFinalizer)
+	n, err := nodeRegistry.CreateNode(n)
+	assert.NoError(t, err)
+	_, err = nodeRegistry.PurgeNode(n)
+	assert.Error(t, err)
+	n, err = nodeRegistry.AtomicUpdate(n.Name, func(in *api.Node) error {
+		in.RemoveFinalizer(api.CloudResourcesFinalizer)
+		return nil
+	})
+	assert.NoError(t, err)
+	_, err = nodeRegistry.PurgeNode(n)
+	assert.NoError(t, err)
+}
+
 func TestListNodes(t *testing.T) {
 	nodeRegistry, closer := SetupTestNodeRegistry()
 	defer closer()
@@ -47,6 +47,13 @@ type PodRegistry struct {
 	Codec             api.MilpaCodec
 	eventSystem       *events.EventSystem
 	statefulValidator *validation.StatefulValidator
+	// namespaceDefaultResources holds the per-namespace ResourceSpec
+	// applied to pods that don't size themselves, keyed by namespace.
+	namespaceDefaultResources map[string]api.ResourceSpec
+	// namespaceDefaultInstanceType holds per-namespace overrides of
+	// the controller's default instance type for pods that don't size
+	// themselves, keyed by namespace.
+	namespaceDefaultInstanceType map[string]string
 }
 
 func makePodKey(id string) string {
@@ -57,10 +64,10 @@ func makeDeletedPodKey(id string) string {
 	return PodTrashPath + "/" + id
 }
 
-func NewPodRegistry(kvstore etcd.Storer, codec api.MilpaCodec, es *events.EventSystem, sv *validation.StatefulValidator) *PodRegistry {
+func NewPodRegistry(kvstore etcd.Storer, codec api.MilpaCodec, es *events.EventSystem, sv *validation.StatefulValidator, namespaceDefaultResources map[string]api.ResourceSpec, namespaceDefaultInstanceType map[string]string) *PodRegistry {
 	// empty directories create problems and pain the butt errors
 	// lets avoid them
-	reg := &PodRegistry{kvstore, codec, es, sv}
+	reg := &PodRegistry{kvstore, codec, es, sv, namespaceDefaultResources, namespaceDefaultInstanceType}
 	reg.Put(PodDirectoryPlaceholder, []byte("."), &store.WriteOptions{IsDir: true})
 	reg.Put(PodTrashDirectoryPlaceholder, []byte("."), &store.WriteOptions{IsDir: true})
 	return reg
@@ -125,9 +132,11 @@ func (reg *PodRegistry) Delete(name string) (api.MilpaObject, error) {
 		msg := fmt.Sprintf("Could not delete pod %s", name)
 		return nil, util.WrapError(err, msg)
 	}
-	// allow the user to totally delete a terminated pod
+	// allow the user to totally delete a terminated pod once nothing
+	// holds a finalizer on it
 	if api.IsTerminalPodPhase(pod.Spec.Phase) &&
-		api.IsTerminalPodPhase(pod.Status.Phase) {
+		api.IsTerminalPodPhase(pod.Status.Phase) &&
+		len(pod.Finalizers) == 0 {
 		err := reg.Storer.Delete(makePodKey(pod.Name))
 		if err != nil {
 			return nil, util.WrapError(err,
@@ -139,16 +148,102 @@ func (reg *PodRegistry) Delete(name string) (api.MilpaObject, error) {
 	}
 }
 
+// applyNamespaceDefaultResources fills in the configured default
+// ResourceSpec for the pod's namespace when the pod specifies
+// neither resources nor an instance type. Pods that size themselves
+// in any way are left alone, so anything the pod sets always wins
+// over the namespace default.
+func (reg *PodRegistry) applyNamespaceDefaultResources(p *api.Pod) {
+	if len(reg.namespaceDefaultResources) == 0 {
+		return
+	}
+	rs := p.Spec.Resources
+	if p.Spec.InstanceType != "" ||
+		rs.CPU != "" || rs.Memory != "" || rs.GPU != "" || rs.GPUMemory != "" {
+		return
+	}
+	defaults, ok := reg.namespaceDefaultResources[p.Namespace]
+	if !ok {
+		return
+	}
+	klog.V(3).Infof("Applying namespace %s default resources to pod %s",
+		p.Namespace, p.Name)
+	// Only the sizing fields come from the default; knobs like
+	// privateIPOnly stay whatever the pod set.
+	rs.CPU = defaults.CPU
+	rs.Memory = defaults.Memory
+	rs.GPU = defaults.GPU
+	rs.GPUMemory = defaults.GPUMemory
+	if defaults.DedicatedCPU {
+		rs.DedicatedCPU = true
+	}
+	if rs.VolumeSize == "" {
+		rs.VolumeSize = defaults.VolumeSize
+	}
+	p.Spec.Resources = rs
+}
+
+// podSizedByDefault reports whether the pod will be sized by the
+// controller's default instance type: it specifies no instance type
+// and nothing in its ResourceSpec that picks an instance.
+func podSizedByDefault(p *api.Pod) bool {
+	rs := p.Spec.Resources
+	return p.Spec.InstanceType == "" &&
+		rs.CPU == "" && rs.Memory == "" && rs.GPU == "" && rs.GPUMemory == "" &&
+		!rs.InstanceStoreRoot
+}
+
+// applyUnitResourceRequests sizes a pod from its units' resource
+// requests when the pod sets neither an instance type nor any sizing
+// in its own ResourceSpec. Per-unit requests are the most specific
+// sizing available, so they win over the namespace defaults applied
+// below.
+func applyUnitResourceRequests(p *api.Pod) {
+	rs := p.Spec.Resources
+	if p.Spec.InstanceType != "" ||
+		rs.CPU != "" || rs.Memory != "" || rs.GPU != "" || rs.GPUMemory != "" {
+		return
+	}
+	agg := api.AggregateUnitResources(&p.Spec)
+	p.Spec.Resources.CPU = agg.CPU
+	p.Spec.Resources.Memory = agg.Memory
+	p.Spec.Resources.GPU = agg.GPU
+}
+
+// applyNamespaceDefaultInstanceType overrides the default instance
+// type for pods in namespaces that configure their own default.
+func (reg *PodRegistry) applyNamespaceDefaultInstanceType(p *api.Pod) {
+	instanceType, ok := reg.namespaceDefaultInstanceType[p.Namespace]
+	if !ok {
+		return
+	}
+	klog.V(3).Infof("Applying namespace %s default instance type %s to pod %s",
+		p.Namespace, instanceType, p.Name)
+	p.Spec.InstanceType = instanceType
+}
+
 // I see this kinda like validation.  I'm not sure of a better
 // place to put it.  Basically, it makes sure that the pod is
 // in proper shape before it's created
 func (reg *PodRegistry) preCreatePod(p *api.Pod) (*api.Pod, error) {
+	applyUnitResourceRequests(p)
+	reg.applyNamespaceDefaultResources(p)
+	usedDefault := podSizedByDefault(p)
+	if usedDefault {
+		reg.applyNamespaceDefaultInstanceType(p)
+	}
+	api.NormalizeResourceSpec(&p.Spec.Resources)
 	instanceType, sustainedCPU, err := instanceselector.ResourcesToInstanceType(&p.Spec)
 	if err != nil {
 		return nil, util.WrapError(err, "Could not create pod %s, failure to convert resources to instance type", p.Name)
 	}
 	p.Spec.InstanceType = instanceType
 	p.Spec.Resources.SustainedCPU = sustainedCPU
+	if usedDefault {
+		reg.eventSystem.Emit(events.PodDefaultInstanceType, "pod-registry", p,
+			"pod %s specifies neither resources nor an instance type, defaulting to instance type %s",
+			p.Name, instanceType)
+	}
 	return p, nil
 }
 
@@ -286,21 +381,28 @@ func validStatusPhaseChange(old, new api.PodPhase) bool {
 	switch old {
 	case api.PodWaiting:
 		switch new {
-		case api.PodDispatching, api.PodTerminated:
+		case api.PodDispatching, api.PodSuspended, api.PodTerminated:
 			return true
 		default:
 			return false
 		}
 	case api.PodDispatching:
 		switch new {
-		case api.PodRunning, api.PodFailed, api.PodTerminated:
+		case api.PodRunning, api.PodSuspended, api.PodFailed, api.PodTerminated:
 			return true
 		default:
 			return false
 		}
 	case api.PodRunning:
 		switch new {
-		case api.PodFailed, api.PodTerminated, api.PodSucceeded:
+		case api.PodSuspended, api.PodFailed, api.PodTerminated, api.PodSucceeded:
+			return true
+		default:
+			return false
+		}
+	case api.PodSuspended:
+		switch new {
+		case api.PodWaiting, api.PodTerminated:
 			return true
 		default:
 			return false
@@ -352,6 +454,11 @@ func (reg *PodRegistry) UpdatePodStatus(p *api.Pod, reason string) (*api.Pod, er
 }
 
 func (reg *PodRegistry) TerminatePod(pod *api.Pod, phase api.PodPhase, msg string) error {
+	if len(pod.Finalizers) > 0 {
+		return fmt.Errorf(
+			"Pod %s still has finalizers %v, refusing to remove it from the registry",
+			pod.Name, pod.Finalizers)
+	}
 	reg.eventSystem.Emit(events.PodTerminated, "pod-registry", pod, msg)
 	return reg.Storer.Delete(makePodKey(pod.Name))
 }
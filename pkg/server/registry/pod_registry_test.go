@@ -290,3 +290,71 @@ func TestAtomicUpdateWithModifyFailure(t *testing.T) {
 		t.Errorf("pod should have terminated phase, has: %s", finalPod.Status.Phase)
 	}
 }
+
+func TestApplyNamespaceDefaultResources(t *testing.T) {
+	reg := &PodRegistry{
+		namespaceDefaultResources: map[string]api.ResourceSpec{
+			"batch": {CPU: "2", Memory: "4Gi"},
+		},
+	}
+	// A resource-less pod in the namespace gets the default.
+	p := api.GetFakePod()
+	p.Namespace = "batch"
+	p.Spec.InstanceType = ""
+	p.Spec.Resources = api.ResourceSpec{}
+	reg.applyNamespaceDefaultResources(p)
+	assert.Equal(t, "2", p.Spec.Resources.CPU)
+	assert.Equal(t, "4Gi", p.Spec.Resources.Memory)
+	// Pods that size themselves win over the default.
+	p = api.GetFakePod()
+	p.Namespace = "batch"
+	p.Spec.InstanceType = ""
+	p.Spec.Resources = api.ResourceSpec{CPU: "1", Memory: "1Gi"}
+	reg.applyNamespaceDefaultResources(p)
+	assert.Equal(t, "1", p.Spec.Resources.CPU)
+	assert.Equal(t, "1Gi", p.Spec.Resources.Memory)
+	// So do pods with an explicit instance type.
+	p = api.GetFakePod()
+	p.Namespace = "batch"
+	p.Spec.InstanceType = "t2.nano"
+	p.Spec.Resources = api.ResourceSpec{}
+	reg.applyNamespaceDefaultResources(p)
+	assert.Equal(t, "", p.Spec.Resources.CPU)
+	// Namespaces without a default are untouched.
+	p = api.GetFakePod()
+	p.Namespace = "other"
+	p.Spec.InstanceType = ""
+	p.Spec.Resources = api.ResourceSpec{}
+	reg.applyNamespaceDefaultResources(p)
+	assert.Equal(t, "", p.Spec.Resources.CPU)
+}
+
+func TestApplyNamespaceDefaultInstanceType(t *testing.T) {
+	reg := &PodRegistry{
+		namespaceDefaultInstanceType: map[string]string{
+			"batch": "c5.large",
+		},
+	}
+	// A pod without sizing in the namespace gets the override.
+	p := api.GetFakePod()
+	p.Namespace = "batch"
+	p.Spec.InstanceType = ""
+	p.Spec.Resources = api.ResourceSpec{}
+	assert.True(t, podSizedByDefault(p))
+	reg.applyNamespaceDefaultInstanceType(p)
+	assert.Equal(t, "c5.large", p.Spec.InstanceType)
+	// Pods that size themselves are not sized by the default.
+	p = api.GetFakePod()
+	p.Spec.InstanceType = ""
+	p.Spec.Resources = api.ResourceSpec{CPU: "1", Memory: "1Gi"}
+	assert.False(t, podSizedByDefault(p))
+	p = api.GetFakePod()
+	assert.False(t, podSizedByDefault(p))
+	// Namespaces without an override are untouched.
+	p = api.GetFakePod()
+	p.Namespace = "other"
+	p.Spec.InstanceType = ""
+	p.Spec.Resources = api.ResourceSpec{}
+	reg.applyNamespaceDefaultInstanceType(p)
+	assert.Equal(t, "", p.Spec.InstanceType)
+}
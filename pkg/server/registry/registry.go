@@ -48,6 +48,14 @@ type NodeLister interface {
 	ListNodes(func(*api.Node) bool) (*api.NodeList, error)
 }
 
+// NodeStatusUpdater is a NodeLister that can also persist changes to
+// a node's status, e.g. health conditions observed while a pod runs
+// on it.
+type NodeStatusUpdater interface {
+	NodeLister
+	UpdateStatus(*api.Node) (*api.Node, error)
+}
+
 var (
 	ErrAlreadyExists = errors.New("Object already exists")
 	trashTTL         = 60 * time.Second
@@ -79,7 +79,7 @@ func makeRegistryComponents() (*events.EventSystem, etcd.Storer, func()) {
 func makeFakeStatefulValidator() *validation.StatefulValidator {
 	cs, _ := cloud.NewLinkedAZSubnetStatus(cloud.NewMockClient())
 	vpcCIDRs := []string{"172.20.0.0/16"}
-	sv := validation.NewStatefulValidator(cs, cloud.ProviderAWS, vpcCIDRs)
+	sv := validation.NewStatefulValidator(cs, cloud.ProviderAWS, vpcCIDRs, nil, false)
 	return sv
 }
 
@@ -93,7 +93,7 @@ func SetupTestPodRegistry() (*PodRegistry, func()) {
 	instanceselector.Setup("aws", "us-east-1", "", "t2.nano")
 	es, KVStore, closer := makeRegistryComponents()
 	sv := makeFakeStatefulValidator()
-	podRegistry := NewPodRegistry(KVStore, api.VersioningCodec{}, es, sv)
+	podRegistry := NewPodRegistry(KVStore, api.VersioningCodec{}, es, sv, nil, nil)
 	return podRegistry, closer
 }
 
@@ -18,9 +18,11 @@ package server
 
 import (
 	"fmt"
+	"io/ioutil"
 	"net"
 	"os"
 	"sort"
+	"strconv"
 	"sync"
 	"time"
 
@@ -51,6 +53,7 @@ import (
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/client-go/rest"
 	restclient "k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -94,6 +97,8 @@ type InstanceProvider struct {
 	startTime         time.Time
 	podNotifier       func(*v1.Pod)
 	portManager       *portmanager.PortManager
+	eventSystem       *events.EventSystem
+	kubeletTaints     []v1.Taint
 }
 
 func validateWriteToEtcd(client *etcd.SimpleEtcd) error {
@@ -179,6 +184,44 @@ func ensureRegionUnchanged(etcdClient *etcd.SimpleEtcd, region string) error {
 	return nil
 }
 
+// cellConfigWithDefaults merges provider-level settings that travel
+// to the agent via the cell config file into the cells' config map.
+// Explicit entries in cellConfig win so users can keep overriding
+// agent settings directly.
+func cellConfigWithDefaults(cells CellsConfig) map[string]string {
+	defaults := make(map[string]string)
+	if cells.ImagePullConcurrency > 0 {
+		defaults["imagePullConcurrency"] = strconv.Itoa(cells.ImagePullConcurrency)
+	}
+	if cells.UnitLogMaxSize > 0 {
+		defaults["unitLogMaxSize"] = strconv.Itoa(cells.UnitLogMaxSize)
+	}
+	if cells.UnitLogMaxFiles > 0 {
+		defaults["unitLogMaxFiles"] = strconv.Itoa(cells.UnitLogMaxFiles)
+	}
+	if len(defaults) == 0 {
+		return cells.CellConfig
+	}
+	merged := make(map[string]string, len(cells.CellConfig)+len(defaults))
+	for k, v := range defaults {
+		merged[k] = v
+	}
+	for k, v := range cells.CellConfig {
+		merged[k] = v
+	}
+	return merged
+}
+
+// recycleNodeMaxAge returns the configured bound on how old an
+// instance may be and still be reused for another pod, defaulting to
+// an hour.
+func recycleNodeMaxAge(cells CellsConfig) time.Duration {
+	if cells.RecycleNodeMaxAgeSeconds > 0 {
+		return time.Duration(cells.RecycleNodeMaxAgeSeconds) * time.Second
+	}
+	return time.Hour
+}
+
 // InstanceProvider should implement node.PodLifecycleHandler
 func NewInstanceProvider(configFilePath, nodeName, internalIP, clusterDNS, clusterDomain string, daemonEndpointPort int32, debugServer bool, rm *manager.ResourceManager, kubeConfig, networkAgentKubeConfig *clientcmdapi.Config, systemQuit <-chan struct{}) (*InstanceProvider, error) {
 	systemWG := &sync.WaitGroup{}
@@ -251,6 +294,8 @@ func NewInstanceProvider(configFilePath, nodeName, internalIP, clusterDNS, clust
 		cloudStatus,
 		cloudClient.GetAttributes().Provider,
 		cloudClient.GetVPCCIDRs(),
+		serverConfigFile.Cells.SupportedRuntimeClasses,
+		serverConfigFile.Cells.ForbidPublicSourceRanges,
 	)
 
 	klog.V(5).Infof("setting up instance selector")
@@ -262,6 +307,12 @@ func NewInstanceProvider(configFilePath, nodeName, internalIP, clusterDNS, clust
 	if err != nil {
 		return nil, fmt.Errorf("error setting up instance selector %s", err)
 	}
+	bounds := serverConfigFile.Cells.InstanceSizeBounds
+	err = instanceselector.SetSizeBounds(
+		bounds.MinCPU, bounds.MinMemory, bounds.MaxCPU, bounds.MaxMemory)
+	if err != nil {
+		return nil, fmt.Errorf("error setting instance size bounds: %s", err)
+	}
 
 	// Ugly: need to do validation of this field after we have setup
 	// the instanceselector
@@ -270,13 +321,21 @@ func NewInstanceProvider(configFilePath, nodeName, internalIP, clusterDNS, clust
 	if len(errs) > 0 {
 		return nil, fmt.Errorf("error validating provider.yaml: %v", errs.ToAggregate())
 	}
+	for ns, instanceType := range serverConfigFile.Cells.NamespaceDefaultInstanceType {
+		errs = validation.ValidateInstanceType(instanceType, field.NewPath("cells.namespaceDefaultInstanceType").Key(ns))
+		if len(errs) > 0 {
+			return nil, fmt.Errorf("error validating provider.yaml: %v", errs.ToAggregate())
+		}
+	}
 
 	klog.V(5).Infof("setting up events")
 	eventSystem := events.NewEventSystem(systemQuit, systemWG)
 
 	klog.V(5).Infof("setting up registry")
 	podRegistry := registry.NewPodRegistry(
-		etcdClient, api.VersioningCodec{}, eventSystem, statefulValidator)
+		etcdClient, api.VersioningCodec{}, eventSystem, statefulValidator,
+		serverConfigFile.Cells.NamespaceDefaultResources,
+		serverConfigFile.Cells.NamespaceDefaultInstanceType)
 	nodeRegistry := registry.NewNodeRegistry(
 		etcdClient, api.VersioningCodec{}, eventSystem)
 	eventRegistry := registry.NewEventRegistry(
@@ -324,6 +383,25 @@ func NewInstanceProvider(configFilePath, nodeName, internalIP, clusterDNS, clust
 		)
 	}
 
+	if serverConfigFile.Cells.TrustedCAFile != "" {
+		klog.V(5).Infof("loading trusted CA bundle")
+		caBundle, err := ioutil.ReadFile(serverConfigFile.Cells.TrustedCAFile)
+		if err != nil {
+			return nil, util.WrapError(err, "reading cells.trustedCAFile")
+		}
+		setTrustedCABundle(caBundle, serverConfigFile.Cells.ReplaceImageCABundle)
+	}
+	setDNSDefaults(serverConfigFile.Cells.DefaultDNSNdots, serverConfigFile.Cells.MaxDNSSearchDomains)
+	setDefaultDNSPolicy(api.DNSPolicy(serverConfigFile.Cells.DefaultDNSPolicy))
+
+	maxVolumeDeploySize := int64(0)
+	if s := serverConfigFile.Cells.MaxVolumeDeploySize; s != "" {
+		// The quantity was validated with the rest of the config file.
+		if q, err := resource.ParseQuantity(s); err == nil {
+			maxVolumeDeploySize = q.Value()
+		}
+	}
+
 	klog.V(5).Infof("creating pod controller")
 	podController := &PodController{
 		podRegistry:            podRegistry,
@@ -343,6 +421,15 @@ func NewInstanceProvider(configFilePath, nodeName, internalIP, clusterDNS, clust
 		statusInterval:         time.Duration(serverConfigFile.Cells.StatusInterval) * time.Second,
 		healthChecker:          healthChecker,
 		defaultIAMPermissions:  serverConfigFile.Cells.DefaultIAMPermissions,
+		schedulingTimeout:      time.Duration(serverConfigFile.Cells.SchedulingTimeoutSeconds) * time.Second,
+		defaultImagePullSecret: serverConfigFile.Cells.DefaultImagePullSecret,
+		registryMirrors:        serverConfigFile.Cells.RegistryMirrors,
+		instanceTagLabels:      serverConfigFile.Cells.InstanceTagLabels,
+		resyncInstanceTags:     serverConfigFile.Cells.ResyncInstanceTags,
+		maxVolumeDeploySize:    maxVolumeDeploySize,
+		volumeDeploys:          newVolumeDeployCache(),
+		postStartRuns:          newPostStartTracker(),
+		grpcProbeFailures:      newProbeFailureTracker(),
 	}
 
 	klog.V(5).Infof("creating image ID cache")
@@ -355,15 +442,29 @@ func NewInstanceProvider(configFilePath, nodeName, internalIP, clusterDNS, clust
 	}
 	fixedSizeVolume := cloudClient.GetAttributes().FixedSizeVolume
 
+	if serverConfigFile.Cells.BootTimeoutSeconds > 0 {
+		nodemanager.BootTimeout = time.Duration(serverConfigFile.Cells.BootTimeoutSeconds) * time.Second
+	}
+
 	klog.V(5).Infof("creating node controller")
 	nodeController := &nodemanager.NodeController{
 		Config: nodemanager.NodeControllerConfig{
-			PoolInterval:      7 * time.Second,
-			HeartbeatInterval: 10 * time.Second,
-			ReaperInterval:    10 * time.Second,
-			ItzoVersion:       serverConfigFile.Cells.Itzo.Version,
-			ItzoURL:           serverConfigFile.Cells.Itzo.URL,
-			CellConfig:        serverConfigFile.Cells.CellConfig,
+			PoolInterval:         7 * time.Second,
+			HeartbeatInterval:    10 * time.Second,
+			ReaperInterval:       10 * time.Second,
+			ItzoVersion:          serverConfigFile.Cells.Itzo.Version,
+			ItzoURL:              serverConfigFile.Cells.Itzo.URL,
+			ItzoSHA256:           serverConfigFile.Cells.Itzo.SHA256,
+			CellConfig:           cellConfigWithDefaults(serverConfigFile.Cells),
+			RecycleNodes:         serverConfigFile.Cells.RecycleNodes,
+			RecycleNodeMaxAge:    recycleNodeMaxAge(serverConfigFile.Cells),
+			SpreadSubnets:        serverConfigFile.Cells.SubnetSpread.Enabled,
+			SubnetSpreadMaxSkew:  serverConfigFile.Cells.SubnetSpread.MaxSkew,
+			ReplaceOutdatedCells: serverConfigFile.Cells.BootImageRollout.Enabled,
+			ReplaceMaxInFlight:   serverConfigFile.Cells.BootImageRollout.MaxInFlight,
+			ReplaceWindowStart:   serverConfigFile.Cells.BootImageRollout.WindowStart,
+			ReplaceWindowEnd:     serverConfigFile.Cells.BootImageRollout.WindowEnd,
+			ReplaceNamespaces:    serverConfigFile.Cells.BootImageRollout.Namespaces,
 		},
 		NodeRegistry:  nodeRegistry,
 		LogRegistry:   logRegistry,
@@ -375,6 +476,10 @@ func NewInstanceProvider(configFilePath, nodeName, internalIP, clusterDNS, clust
 			cloudStatus,
 			serverConfigFile.Cells.DefaultVolumeSize,
 			fixedSizeVolume,
+			podRegistry,
+			eventSystem,
+			serverConfigFile.Cells.EnablePodPreemption,
+			serverConfigFile.Cells.InstanceMetadataOptions,
 		),
 		CloudClient:        cloudClient,
 		NodeClientFactory:  itzoClientFactory,
@@ -396,6 +501,8 @@ func NewInstanceProvider(configFilePath, nodeName, internalIP, clusterDNS, clust
 		nodeRegistry: nodeRegistry,
 		cloudClient:  cloudClient,
 		controllerID: controllerID,
+		nametag:      nametag,
+		events:       eventSystem,
 	}
 
 	klog.V(5).Infof("creating metrics controller")
@@ -434,6 +541,7 @@ func NewInstanceProvider(configFilePath, nodeName, internalIP, clusterDNS, clust
 		daemonEndpointPort,
 		serverConfigFile.Kubelet.Capacity,
 		serverConfigFile.Kubelet.Labels,
+		serverConfigFile.Kubelet.Taints,
 	)
 
 	controllers := map[string]Controller{
@@ -465,6 +573,8 @@ func NewInstanceProvider(configFilePath, nodeName, internalIP, clusterDNS, clust
 		internalIP:        internalIP,
 		startTime:         time.Now(),
 		portManager:       portManager,
+		eventSystem:       eventSystem,
+		kubeletTaints:     serverConfigFile.Kubelet.Taints,
 	}
 
 	klog.V(5).Infof("registering internal event handlers")
@@ -511,6 +621,7 @@ func (p *InstanceProvider) setupDebugServer() error {
 	}
 	grpcServer := grpc.NewServer()
 	clientapi.RegisterKipServer(grpcServer, p)
+	clientapi.RegisterKipWatchServer(grpcServer, p)
 	go func() {
 		err := grpcServer.Serve(lis)
 		if err != nil {
@@ -641,11 +752,40 @@ func (p *InstanceProvider) getMetricsRegistry() *registry.MetricsRegistry {
 	return reg.(*registry.MetricsRegistry)
 }
 
+// checkPodTolerations rejects pods that do not tolerate the taints
+// configured on the virtual node. The scheduler filters those pods out
+// for us, but a pod with a hardcoded nodeName skips scheduling entirely
+// and would otherwise land here unchecked.
+func (p *InstanceProvider) checkPodTolerations(pod *v1.Pod) error {
+	for i := range p.kubeletTaints {
+		taint := &p.kubeletTaints[i]
+		if taint.Effect == v1.TaintEffectPreferNoSchedule {
+			continue
+		}
+		tolerated := false
+		for j := range pod.Spec.Tolerations {
+			if pod.Spec.Tolerations[j].ToleratesTaint(taint) {
+				tolerated = true
+				break
+			}
+		}
+		if !tolerated {
+			return fmt.Errorf("pod %s/%s does not tolerate the virtual node taint %s=%s:%s",
+				pod.Namespace, pod.Name, taint.Key, taint.Value, taint.Effect)
+		}
+	}
+	return nil
+}
+
 func (p *InstanceProvider) CreatePod(ctx context.Context, pod *v1.Pod) error {
 	ctx, span := trace.StartSpan(ctx, "CreatePod")
 	defer span.End()
 	ctx = addAttributes(ctx, span, namespaceKey, pod.Namespace, nameKey, pod.Name)
 	klog.V(5).Infof("CreatePod %q", pod.Name)
+	if err := p.checkPodTolerations(pod); err != nil {
+		klog.Errorf("CreatePod %q: %v", pod.Name, err)
+		return err
+	}
 	milpaPod, err := k8sToMilpaPod(pod)
 	if err != nil {
 		klog.Errorf("CreatePod %q: %v", pod.Name, err)
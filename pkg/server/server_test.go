@@ -79,6 +79,38 @@ func TestEnsureRegionUnchanged(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestCellConfigWithDefaults(t *testing.T) {
+	t.Parallel()
+	// no provider-level settings leaves the map untouched
+	cfg := map[string]string{"foo": "bar"}
+	assert.Equal(t, cfg, cellConfigWithDefaults(CellsConfig{CellConfig: cfg}))
+	// the settings are merged without mutating the original map
+	cells := CellsConfig{
+		CellConfig:           cfg,
+		ImagePullConcurrency: 3,
+		UnitLogMaxSize:       10485760,
+		UnitLogMaxFiles:      5,
+	}
+	merged := cellConfigWithDefaults(cells)
+	assert.Equal(t, map[string]string{
+		"foo":                  "bar",
+		"imagePullConcurrency": "3",
+		"unitLogMaxSize":       "10485760",
+		"unitLogMaxFiles":      "5",
+	}, merged)
+	assert.Equal(t, map[string]string{"foo": "bar"}, cfg)
+	// an explicit cellConfig entry wins
+	cells = CellsConfig{
+		CellConfig:     map[string]string{"unitLogMaxSize": "1024"},
+		UnitLogMaxSize: 2048,
+	}
+	merged = cellConfigWithDefaults(cells)
+	assert.Equal(t, map[string]string{"unitLogMaxSize": "1024"}, merged)
+	// a nil map works too
+	merged = cellConfigWithDefaults(CellsConfig{ImagePullConcurrency: 2})
+	assert.Equal(t, map[string]string{"imagePullConcurrency": "2"}, merged)
+}
+
 //func getPortMappings(containers []v1.Container) []v1.ContainerPort
 func TestGetPortMappings(t *testing.T) {
 	testCases := []struct {
@@ -150,3 +182,34 @@ func TestGetPortMappings(t *testing.T) {
 		assert.Equal(t, tc.portMappings, pms)
 	}
 }
+
+func TestCheckPodTolerations(t *testing.T) {
+	taints := []v1.Taint{
+		{
+			Key:    "kip.elotl.co/cell",
+			Value:  "true",
+			Effect: v1.TaintEffectNoSchedule,
+		},
+	}
+	p := &InstanceProvider{kubeletTaints: taints}
+	pod := &v1.Pod{}
+	pod.Namespace = "default"
+	pod.Name = "testpod"
+	err := p.checkPodTolerations(pod)
+	assert.Error(t, err)
+	pod.Spec.Tolerations = []v1.Toleration{
+		{
+			Key:      "kip.elotl.co/cell",
+			Operator: v1.TolerationOpExists,
+			Effect:   v1.TaintEffectNoSchedule,
+		},
+	}
+	assert.NoError(t, p.checkPodTolerations(pod))
+	// PreferNoSchedule taints never block a pod.
+	p.kubeletTaints[0].Effect = v1.TaintEffectPreferNoSchedule
+	pod.Spec.Tolerations = nil
+	assert.NoError(t, p.checkPodTolerations(pod))
+	// No configured taints, nothing to check.
+	p.kubeletTaints = nil
+	assert.NoError(t, p.checkPodTolerations(pod))
+}
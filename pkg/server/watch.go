@@ -0,0 +1,149 @@
+/*
+Copyright 2020 Elotl Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/elotl/kip/pkg/api"
+	"github.com/elotl/kip/pkg/clientapi"
+	"github.com/elotl/kip/pkg/server/events"
+	"github.com/elotl/kip/pkg/server/registry"
+	"github.com/elotl/kip/pkg/util"
+)
+
+// eventWatchBufferSize bounds how many undelivered events a single
+// watch connection may queue up before the connection is cut off.
+const eventWatchBufferSize = 1000
+
+// eventWatcher buffers events for one watch connection.  It is
+// registered with the event system for the lifetime of the connection
+// and unregistered when the connection goes away.
+type eventWatcher struct {
+	eventChan    chan events.Event
+	overflow     chan struct{}
+	overflowOnce sync.Once
+}
+
+func newEventWatcher() *eventWatcher {
+	return &eventWatcher{
+		eventChan: make(chan events.Event, eventWatchBufferSize),
+		overflow:  make(chan struct{}),
+	}
+}
+
+// Handle never blocks the event system: when the consumer has fallen
+// eventWatchBufferSize events behind, the watch is flagged for
+// termination instead.
+func (w *eventWatcher) Handle(e events.Event) error {
+	select {
+	case w.eventChan <- e:
+	default:
+		w.overflowOnce.Do(func() { close(w.overflow) })
+	}
+	return nil
+}
+
+func sendWatchEvent(ev *api.Event, stream clientapi.KipWatch_WatchEventsServer) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return util.WrapError(err, "Error serializing event")
+	}
+	return stream.Send(&clientapi.StreamMsg{Data: body})
+}
+
+// replayEvents sends the retained events created after since, oldest
+// first.
+func (s InstanceProvider) replayEvents(since time.Time, stream clientapi.KipWatch_WatchEventsServer) error {
+	reg, exists := s.Registries["Event"]
+	if !exists {
+		return fmt.Errorf("Fatal error: can't find event registry in storage")
+	}
+	eventRegistry := reg.(*registry.EventRegistry)
+	eventList, err := eventRegistry.ListEvents(func(e *api.Event) bool {
+		return e.CreationTimestamp.After(api.Time{Time: since})
+	})
+	if err != nil {
+		return util.WrapError(err, "Could not list events for watch replay")
+	}
+	for _, ev := range eventList.Items {
+		if err := sendWatchEvent(ev, stream); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WatchEvents streams events to the client as they are emitted.  A
+// non-empty since timestamp (RFC3339) first replays the retained
+// events created after that time so clients can resume a dropped
+// watch; an event emitted while the replay runs can show up twice,
+// consumers should dedupe on the event name.  A resume point past the
+// retention window and a consumer that falls too far behind both get
+// a clear error on the stream instead of silently missing events.
+func (s InstanceProvider) WatchEvents(request *clientapi.WatchEventsRequest, stream clientapi.KipWatch_WatchEventsServer) error {
+	watcher := newEventWatcher()
+	s.eventSystem.RegisterHandler(events.AllEvents, watcher)
+	defer s.eventSystem.UnregisterHandler(events.AllEvents, watcher)
+	if since := string(request.GetSince()); since != "" {
+		sinceTime, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			return fmt.Errorf("Could not parse since timestamp %q, expected an RFC3339 time", since)
+		}
+		if time.Since(sinceTime) > registry.DefaultTTL {
+			msg := clientapi.StreamMsg{
+				Error: []byte(fmt.Sprintf("watch resume point %s is too old: events are only retained for %s, older events have been dropped", since, registry.DefaultTTL)),
+			}
+			if err := stream.Send(&msg); err != nil {
+				return err
+			}
+		}
+		if err := s.replayEvents(sinceTime, stream); err != nil {
+			return err
+		}
+	}
+	for {
+		select {
+		case e := <-watcher.eventChan:
+			if err := sendWatchEvent(registry.NewEventFromSystemEvent(e), stream); err != nil {
+				return err
+			}
+		case <-watcher.overflow:
+			msg := clientapi.StreamMsg{
+				Error: []byte(fmt.Sprintf("watch fell more than %d events behind and events have been dropped: re-list and restart the watch", eventWatchBufferSize)),
+			}
+			_ = stream.Send(&msg)
+			return fmt.Errorf("event watch consumer too slow, closing watch")
+		case <-stream.Context().Done():
+			return nil
+		case <-s.SystemQuit:
+			return nil
+		case <-time.After(5 * time.Second):
+			// Same trick as StreamLogs: periodically send an empty
+			// message so we notice the client has gone away during
+			// quiet periods instead of holding the goroutine open.
+			msg := clientapi.StreamMsg{}
+			if err := stream.Send(&msg); err != nil {
+				return err
+			}
+		}
+	}
+	// NOTREACHED
+}
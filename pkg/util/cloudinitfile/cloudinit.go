@@ -35,6 +35,7 @@ type CloudConfig struct {
 	Hostname          string          `yaml:"hostname,omitempty"`
 	Users             []User          `yaml:"users,omitempty"`
 	RunCmd            []string        `yaml:"runcmd,omitempty"`
+	BootCmd           []string        `yaml:"bootcmd,omitempty"`
 	// this one is legacy, can be removed when no more kip controllers use it
 	MilpaFiles []CloudInitFile `yaml:"milpa_files,omitempty"`
 	// Todo: add additional parameters supported by traditional cloud-init
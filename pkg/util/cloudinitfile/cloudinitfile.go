@@ -20,12 +20,17 @@ limitations under the License.
 package cloudinitfile
 
 import (
+	"bytes"
+	"compress/gzip"
 	"fmt"
 	"io/ioutil"
 	"regexp"
+	"sort"
+	"strings"
 
 	"github.com/elotl/kip/pkg/util"
 	"github.com/go-yaml/yaml"
+	"k8s.io/klog"
 )
 
 const semverRegexFmt string = `v?([0-9]+)(\.[0-9]+)(\.[0-9]+)?` +
@@ -33,18 +38,29 @@ const semverRegexFmt string = `v?([0-9]+)(\.[0-9]+)(\.[0-9]+)?` +
 	`(\+([0-9A-Za-z\-]+(\.[0-9A-Za-z\-]+)*))?`
 
 var (
-	itzoDir          = "/tmp/itzo"
-	ItzoVersionPath  = itzoDir + "/itzo_version"
-	ItzoURLPath      = itzoDir + "/itzo_url"
-	CellConfigPath   = itzoDir + "/cell_config.yaml"
-	cloudInitHeader  = []byte("#cloud-config\n")
-	maxCloudInitSize = 16000
-	semverRegex      = regexp.MustCompile("^" + semverRegexFmt + "$")
+	itzoDir         = "/tmp/itzo"
+	ItzoVersionPath = itzoDir + "/itzo_version"
+	ItzoURLPath     = itzoDir + "/itzo_url"
+	ItzoSHA256Path  = itzoDir + "/itzo_sha256"
+	CellConfigPath  = itzoDir + "/cell_config.yaml"
+	cloudInitHeader = []byte("#cloud-config\n")
+	redactedContent = "<redacted>"
+	semverRegex     = regexp.MustCompile("^" + semverRegexFmt + "$")
 )
 
 type File struct {
-	userData CloudConfig
-	kipFiles map[string]CloudInitFile
+	userData    CloudConfig
+	kipFiles    map[string]CloudInitFile
+	secretPaths map[string]bool
+	// maxSize caps the document Contents returns; zero means
+	// unlimited.
+	maxSize int
+	// gzipOverLimit compresses an oversized document instead of
+	// failing outright.
+	gzipOverLimit bool
+	// runCmds are appended after the user's own runcmd entries so
+	// kip never clobbers them.
+	runCmds []string
 }
 
 func New(path string) (*File, error) {
@@ -57,14 +73,24 @@ func New(path string) (*File, error) {
 		}
 	}
 	f := &File{
-		userData: userData,
-		kipFiles: make(map[string]CloudInitFile),
+		userData:    userData,
+		kipFiles:    make(map[string]CloudInitFile),
+		secretPaths: make(map[string]bool),
 	}
 	return f, nil
 }
 
 func (f *File) ResetInstanceData() {
 	f.kipFiles = make(map[string]CloudInitFile)
+	f.secretPaths = make(map[string]bool)
+	f.runCmds = nil
+}
+
+// AddRunCmd appends a command to the merged document's runcmd
+// section. Commands from the user's cloud-init file come first and are
+// never overwritten.
+func (f *File) AddRunCmd(cmds []string) {
+	f.runCmds = append(f.runCmds, cmds...)
 }
 
 func (f *File) AddKipFile(content, path, permissions string) {
@@ -76,6 +102,17 @@ func (f *File) AddKipFile(content, path, permissions string) {
 	}
 }
 
+// AddKipSecretFile adds a file whose content must not show up when
+// the merged cloud-init document is read back for debugging, e.g.
+// private keys.
+func (f *File) AddKipSecretFile(content, path, permissions string) {
+	f.AddKipFile(content, path, permissions)
+	if f.secretPaths == nil {
+		f.secretPaths = make(map[string]bool)
+	}
+	f.secretPaths[path] = true
+}
+
 func loadUserCloudConfig(path string) (ucc CloudConfig, err error) {
 	contents, err := ioutil.ReadFile(path)
 	if err != nil {
@@ -99,6 +136,10 @@ func (f *File) AddItzoVersion(version string) {
 	f.AddKipFile(version, ItzoVersionPath, "0444")
 }
 
+// AddItzoURL points the boot process at the location to download the
+// cell agent from. Any URL the instance can reach works, including a
+// fully-private internal mirror, the controller never fetches it
+// itself.
 func (f *File) AddItzoURL(url string) {
 	if url == "" {
 		return
@@ -106,33 +147,171 @@ func (f *File) AddItzoURL(url string) {
 	f.AddKipFile(url, ItzoURLPath, "0444")
 }
 
+// AddItzoSHA256 injects the expected sha256 checksum of the agent
+// binary so the boot process can verify the download before running
+// it. A corrupted or tampered-with download then fails the boot
+// instead of silently producing a broken node.
+func (f *File) AddItzoSHA256(sum string) {
+	if sum == "" {
+		return
+	}
+	f.AddKipFile(strings.ToLower(sum), ItzoSHA256Path, "0444")
+}
+
+// CellConfig is the typed form of the config file sent to the cell
+// agent. The yaml tags are the keys the agent parses; anything else
+// in a user's cellConfig map is a typo the agent would silently
+// ignore, so unknown keys are rejected at controller startup.
+type CellConfig struct {
+	ImageCacheEndpoint   string `yaml:"imageCacheEndpoint,omitempty"`
+	ImageCacheMountDir   string `yaml:"imageCacheMountDir,omitempty"`
+	ImageCacheMountOpts  string `yaml:"imageCacheMountOpts,omitempty"`
+	ImagePullConcurrency string `yaml:"imagePullConcurrency,omitempty"`
+	UnitLogMaxSize       string `yaml:"unitLogMaxSize,omitempty"`
+	UnitLogMaxFiles      string `yaml:"unitLogMaxFiles,omitempty"`
+}
+
+// CellConfigKeys lists the cell config keys the agent understands.
+var CellConfigKeys = []string{
+	"imageCacheEndpoint",
+	"imageCacheMountDir",
+	"imageCacheMountOpts",
+	"imagePullConcurrency",
+	"unitLogMaxSize",
+	"unitLogMaxFiles",
+}
+
+// UnknownCellConfigKeys returns the keys in cfg that aren't in
+// CellConfigKeys, sorted for stable error messages.
+func UnknownCellConfigKeys(cfg map[string]string) []string {
+	unknown := []string{}
+	for key := range cfg {
+		if !util.StringInSlice(key, CellConfigKeys) {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+func cellConfigFromMap(cfg map[string]string) CellConfig {
+	var cc CellConfig
+	for key, value := range cfg {
+		switch key {
+		case "imageCacheEndpoint":
+			cc.ImageCacheEndpoint = value
+		case "imageCacheMountDir":
+			cc.ImageCacheMountDir = value
+		case "imageCacheMountOpts":
+			cc.ImageCacheMountOpts = value
+		case "imagePullConcurrency":
+			cc.ImagePullConcurrency = value
+		case "unitLogMaxSize":
+			cc.UnitLogMaxSize = value
+		case "unitLogMaxFiles":
+			cc.UnitLogMaxFiles = value
+		default:
+			klog.Warningf("dropping unknown cell config key %q", key)
+		}
+	}
+	return cc
+}
+
 func (f *File) AddCellConfig(cfg map[string]string) {
 	if len(cfg) == 0 {
 		return
 	}
-	buf, err := yaml.Marshal(cfg)
+	buf, err := yaml.Marshal(cellConfigFromMap(cfg))
 	if err != nil {
 		return
 	}
 	f.AddKipFile(string(buf), CellConfigPath, "0444")
 }
 
+// SetSizeLimit caps the size of the document Contents returns, in
+// bytes. The cap comes from the cloud provider's user-data API limit,
+// which differs between providers. Zero or negative means unlimited.
+func (f *File) SetSizeLimit(n int) {
+	f.maxSize = n
+}
+
+// SetGzipOverLimit makes Contents gzip the merged document when it
+// exceeds the size limit instead of failing outright. cloud-init on
+// the instance transparently decompresses gzipped user-data, so the
+// compressed form is still a valid #cloud-config document to the
+// cell.
+func (f *File) SetGzipOverLimit(enabled bool) {
+	f.gzipOverLimit = enabled
+}
+
 func (f *File) Contents() ([]byte, error) {
+	data, err := f.contents(false)
+	if err != nil {
+		return nil, err
+	}
+	return f.fitToSizeLimit(data)
+}
+
+// fitToSizeLimit checks the merged document against the configured
+// size limit so oversized user-data is caught here with a clear error
+// instead of a launch API rejection. With gzipOverLimit set an
+// oversized document is compressed first and only rejected when the
+// compressed form is still over the limit.
+func (f *File) fitToSizeLimit(data []byte) ([]byte, error) {
+	if f.maxSize <= 0 || len(data) <= f.maxSize {
+		return data, nil
+	}
+	if !f.gzipOverLimit {
+		return nil, fmt.Errorf(
+			"cloud-init document is %d bytes, over the %d byte limit",
+			len(data), f.maxSize)
+	}
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	if _, err := gw.Write(data); err != nil {
+		return nil, util.WrapError(err, "Error compressing cloud-init data")
+	}
+	if err := gw.Close(); err != nil {
+		return nil, util.WrapError(err, "Error compressing cloud-init data")
+	}
+	if buf.Len() > f.maxSize {
+		return nil, fmt.Errorf(
+			"cloud-init document is %d bytes (%d gzipped), over the %d byte limit",
+			len(data), buf.Len(), f.maxSize)
+	}
+	return buf.Bytes(), nil
+}
+
+// RedactedContents returns the same merged cloud-init document as
+// Contents but with the content of secret files blanked out, so
+// operators can inspect exactly what was sent to the cloud launch API
+// without exposing key material.
+func (f *File) RedactedContents() ([]byte, error) {
+	return f.contents(true)
+}
+
+func (f *File) contents(redactSecrets bool) ([]byte, error) {
 	mergedConfig := f.userData
 	mergedFiles := make([]CloudInitFile, 0, len(f.userData.WriteFiles)+len(f.kipFiles))
 	mergedFiles = append(mergedFiles, f.userData.WriteFiles...)
-	for _, wf := range f.kipFiles {
+	for path, wf := range f.kipFiles {
+		if redactSecrets && f.secretPaths[path] {
+			wf.Content = redactedContent
+		}
 		mergedFiles = append(mergedFiles, wf)
 	}
 	mergedConfig.WriteFiles = mergedFiles
+	if len(f.runCmds) > 0 {
+		merged := make([]string, 0, len(f.userData.RunCmd)+len(f.runCmds))
+		merged = append(merged, f.userData.RunCmd...)
+		merged = append(merged, f.runCmds...)
+		mergedConfig.RunCmd = merged
+	}
 	mergedContent, err := yaml.Marshal(mergedConfig)
 	if err != nil {
 		return nil, err
 	}
 	cloudInitContent := cloudInitHeader
 	cloudInitContent = append(cloudInitContent, mergedContent...)
-	if len(cloudInitContent) > maxCloudInitSize {
-		return nil, fmt.Errorf("Cloud init data length is over 16K")
-	}
 	return cloudInitContent, nil
 }
@@ -17,9 +17,14 @@ limitations under the License.
 package cloudinitfile
 
 import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
 	"fmt"
 	"io/ioutil"
+	"math/rand"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/go-yaml/yaml"
@@ -92,6 +97,31 @@ func TestWriteContent(t *testing.T) {
 	assert.Equal(t, expected, string(cloudInitContent))
 }
 
+func TestRedactedContents(t *testing.T) {
+	cif, err := New("")
+	assert.NoError(t, err)
+	cif.AddKipSecretFile("super secret key material", "/tmp/itzo/server.key", "0600")
+	expected := string(cloudInitHeader) + fmt.Sprintf(`write_files:
+- content: %s
+  owner: root
+  path: /tmp/itzo/server.key
+  permissions: "0600"
+`, redactedContent)
+	cloudInitContent, err := cif.RedactedContents()
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(cloudInitContent))
+	// the unredacted contents are untouched
+	cloudInitContent, err = cif.Contents()
+	assert.NoError(t, err)
+	assert.Contains(t, string(cloudInitContent), "super secret key material")
+
+	cif.ResetInstanceData()
+	cif.AddKipFile("not a secret", "/tmp/itzo/itzo_url", "0444")
+	cloudInitContent, err = cif.RedactedContents()
+	assert.NoError(t, err)
+	assert.Contains(t, string(cloudInitContent), "not a secret")
+}
+
 func TestAddItzoFuncs(t *testing.T) {
 	cif, err := New("")
 	assert.NoError(t, err)
@@ -157,4 +187,161 @@ func TestAddItzoFuncs(t *testing.T) {
 	cloudInitContent, err = cif.Contents()
 	assert.NoError(t, err)
 	assert.Equal(t, expected, string(cloudInitContent))
+
+	cif.ResetInstanceData()
+	cif.AddItzoSHA256("")
+	cloudInitContent, err = cif.Contents()
+	assert.NoError(t, err)
+	expected = string(cloudInitHeader) + "{}\n"
+	assert.Equal(t, expected, string(cloudInitContent))
+
+	sumString := strings.Repeat("AB12", 16)
+	cif.AddItzoSHA256(sumString)
+	expected = string(cloudInitHeader) + fmt.Sprintf(`write_files:
+- content: %s
+  owner: root
+  path: %s
+  permissions: "0444"
+`, strings.ToLower(sumString), ItzoSHA256Path)
+	cloudInitContent, err = cif.Contents()
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(cloudInitContent))
+}
+
+func TestAddCellConfig(t *testing.T) {
+	f, err := New("")
+	assert.NoError(t, err)
+	f.AddCellConfig(map[string]string{
+		"imageCacheEndpoint": "10.20.0.2:/data",
+		"unitLogMaxFiles":    "3",
+	})
+	contents, err := f.Contents()
+	assert.NoError(t, err)
+	assert.Contains(t, string(contents), "imageCacheEndpoint: 10.20.0.2:/data")
+	assert.Contains(t, string(contents), "unitLogMaxFiles: \"3\"")
+	// A typoed key never reaches the cell.
+	f, err = New("")
+	assert.NoError(t, err)
+	f.AddCellConfig(map[string]string{
+		"imageCacheEndpont": "10.20.0.2:/data",
+	})
+	contents, err = f.Contents()
+	assert.NoError(t, err)
+	assert.NotContains(t, string(contents), "imageCacheEndpont")
+}
+
+func TestUnknownCellConfigKeys(t *testing.T) {
+	unknown := UnknownCellConfigKeys(map[string]string{
+		"imageCacheEndpoint":  "10.20.0.2:/data",
+		"imageCacheMountDir":  "/var/cache/images",
+		"imageCacheMountOpts": "-o ro",
+	})
+	assert.Len(t, unknown, 0)
+	unknown = UnknownCellConfigKeys(map[string]string{
+		"imageCacheEndpoint": "10.20.0.2:/data",
+		"zzz":                "1",
+		"aaa":                "2",
+	})
+	assert.Equal(t, []string{"aaa", "zzz"}, unknown)
+}
+
+func TestContentsSizeLimit(t *testing.T) {
+	f, err := New("")
+	assert.NoError(t, err)
+	f.AddKipFile(strings.Repeat("itzo config data\n", 50), "/tmp/itzo/config", "0444")
+	plain, err := f.Contents()
+	assert.NoError(t, err)
+
+	// A limit exactly at the document size leaves it untouched.
+	f.SetSizeLimit(len(plain))
+	out, err := f.Contents()
+	assert.NoError(t, err)
+	assert.Equal(t, plain, out)
+
+	// One byte under the limit without gzip is an error reporting
+	// actual vs allowed size.
+	f.SetSizeLimit(len(plain) - 1)
+	_, err = f.Contents()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), fmt.Sprintf("%d bytes", len(plain)))
+		assert.Contains(t, err.Error(), fmt.Sprintf("%d byte limit", len(plain)-1))
+	}
+
+	// With gzip enabled the compressible document fits again and
+	// decompresses back to the original.
+	f.SetGzipOverLimit(true)
+	out, err = f.Contents()
+	assert.NoError(t, err)
+	assert.True(t, len(out) < len(plain))
+	gzr, err := gzip.NewReader(bytes.NewReader(out))
+	assert.NoError(t, err)
+	decompressed, err := ioutil.ReadAll(gzr)
+	assert.NoError(t, err)
+	assert.Equal(t, plain, decompressed)
+
+	// Incompressible content over the limit fails even gzipped, with
+	// both sizes in the error.
+	random := make([]byte, 4096)
+	rand.Read(random)
+	f.AddKipFile(base64.StdEncoding.EncodeToString(random), "/tmp/itzo/blob", "0444")
+	f.SetSizeLimit(100)
+	_, err = f.Contents()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "gzipped")
+		assert.Contains(t, err.Error(), "100 byte limit")
+	}
+
+	// No limit means no check.
+	f.SetSizeLimit(0)
+	_, err = f.Contents()
+	assert.NoError(t, err)
+
+	// The redacted debugging view is never compressed.
+	f.SetSizeLimit(100)
+	redacted, err := f.RedactedContents()
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(redacted), "#cloud-config"))
+}
+
+func TestMergeUserCommands(t *testing.T) {
+	data := `
+runcmd:
+- systemctl restart chronyd
+- touch /tmp/user-was-here
+bootcmd:
+- modprobe overlay
+`
+	path, closer := ciTmpFile(t, data)
+	defer closer()
+	f, err := New(path)
+	assert.NoError(t, err)
+	f.AddKipFile("v1.0.0", ItzoVersionPath, "0444")
+	f.AddRunCmd([]string{"echo kip-ready"})
+
+	contents, err := f.Contents()
+	assert.NoError(t, err)
+	merged, err := loadCloudConfigFromString(string(contents))
+	assert.NoError(t, err)
+	// The user's sections survive the merge and kip's commands come
+	// after the user's.
+	assert.Equal(t, []string{"modprobe overlay"}, merged.BootCmd)
+	assert.Equal(t, []string{
+		"systemctl restart chronyd",
+		"touch /tmp/user-was-here",
+		"echo kip-ready",
+	}, merged.RunCmd)
+	assert.Len(t, merged.WriteFiles, 1)
+
+	// Resetting instance data drops kip's commands but not the
+	// user's.
+	f.ResetInstanceData()
+	contents, err = f.Contents()
+	assert.NoError(t, err)
+	merged, err = loadCloudConfigFromString(string(contents))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"systemctl restart chronyd",
+		"touch /tmp/user-was-here",
+	}, merged.RunCmd)
+	assert.Equal(t, []string{"modprobe overlay"}, merged.BootCmd)
 }
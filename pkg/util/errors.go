@@ -22,9 +22,31 @@ import (
 	"k8s.io/klog"
 )
 
+// ErrorCategory classifies an error so that callers can decide how to
+// handle it (e.g. retry transient errors, fail fast on invalid input)
+// without resorting to parsing error messages.
+type ErrorCategory string
+
+const (
+	// CategoryUnknown is the category of errors that haven't been
+	// classified.
+	CategoryUnknown ErrorCategory = ""
+	// CategoryNotFound marks errors caused by a referenced object
+	// that doesn't exist.
+	CategoryNotFound ErrorCategory = "NotFound"
+	// CategoryTransient marks errors that are likely to go away if
+	// the operation is retried (network hiccups, eventual consistency
+	// in cloud APIs, etc.).
+	CategoryTransient ErrorCategory = "Transient"
+	// CategoryInvalid marks errors caused by bad input, retrying the
+	// operation won't help.
+	CategoryInvalid ErrorCategory = "Invalid"
+)
+
 type WrappedError struct {
-	msg   string
-	cause error
+	msg      string
+	cause    error
+	category ErrorCategory
 }
 
 func (e WrappedError) Error() string {
@@ -35,6 +57,10 @@ func (e WrappedError) Cause() error {
 	return e.cause
 }
 
+func (e WrappedError) Category() ErrorCategory {
+	return e.category
+}
+
 func WrapError(err error, format string, args ...interface{}) error {
 	s := fmt.Sprintf(format, args...)
 	var msg string
@@ -58,3 +84,43 @@ func WrapError(err error, format string, args ...interface{}) error {
 		}
 	}
 }
+
+// WrapErrorWithCategory works like WrapError but also tags the error
+// with a category. If the wrapped error already carries a category,
+// that one wins: the code closest to the failure knows best.
+func WrapErrorWithCategory(category ErrorCategory, err error, format string, args ...interface{}) error {
+	we := WrapError(err, format, args...).(WrappedError)
+	if we.category == CategoryUnknown {
+		we.category = category
+	}
+	return we
+}
+
+// GetErrorCategory returns the category of err, following wrapped
+// causes until it finds a classified error. Unclassified errors (and
+// nil) return CategoryUnknown.
+func GetErrorCategory(err error) ErrorCategory {
+	for err != nil {
+		we, ok := err.(WrappedError)
+		if !ok {
+			break
+		}
+		if we.category != CategoryUnknown {
+			return we.category
+		}
+		err = we.cause
+	}
+	return CategoryUnknown
+}
+
+func IsNotFoundError(err error) bool {
+	return GetErrorCategory(err) == CategoryNotFound
+}
+
+func IsTransientError(err error) bool {
+	return GetErrorCategory(err) == CategoryTransient
+}
+
+func IsInvalidError(err error) bool {
+	return GetErrorCategory(err) == CategoryInvalid
+}
@@ -34,3 +34,27 @@ func TestWrapError(t *testing.T) {
 	we2 := WrapError(e, "")
 	assert.Equal(t, "1", we2.Error())
 }
+
+func TestWrapErrorWithCategory(t *testing.T) {
+	e := fmt.Errorf("boom")
+	we := WrapErrorWithCategory(CategoryTransient, e, "we")
+	assert.Equal(t, "we: boom", we.Error())
+	assert.Equal(t, CategoryTransient, GetErrorCategory(we))
+	assert.True(t, IsTransientError(we))
+	assert.False(t, IsNotFoundError(we))
+
+	// The category survives further wrapping with plain WrapError.
+	outer := WrapError(we, "outer")
+	assert.Equal(t, "outer: we: boom", outer.Error())
+	assert.Equal(t, CategoryTransient, GetErrorCategory(outer))
+
+	// The innermost category wins, the code closest to the failure
+	// knows best.
+	rewrapped := WrapErrorWithCategory(CategoryInvalid, we, "rewrapped")
+	assert.Equal(t, CategoryTransient, GetErrorCategory(rewrapped))
+
+	// Unclassified errors have no category.
+	assert.Equal(t, CategoryUnknown, GetErrorCategory(e))
+	assert.Equal(t, CategoryUnknown, GetErrorCategory(WrapError(e, "plain")))
+	assert.Equal(t, CategoryUnknown, GetErrorCategory(nil))
+}
@@ -17,6 +17,8 @@ limitations under the License.
 package util
 
 import (
+	"strings"
+
 	"github.com/elotl/kip/pkg/api"
 	"k8s.io/kubernetes/third_party/forked/golang/expansion"
 )
@@ -29,23 +31,54 @@ func EnvVarsToMap(envs []api.EnvVar) map[string]string {
 	return result
 }
 
+// ExpandCommandAndArgs implements kubernetes-style $(VAR) expansion
+// of unit commands and args against the unit's env. $$ escapes a
+// literal $ and references that don't resolve are left as written.
+// The expansion runs after env files and pod-level defaults have been
+// merged into the unit's env, so anything visible to the unit's
+// process can be referenced.
 func ExpandCommandAndArgs(spec api.PodSpec) api.PodSpec {
-	for i, unit := range spec.Units {
+	expandUnits(spec.InitUnits)
+	expandUnits(spec.Units)
+	return spec
+}
+
+func expandUnits(units []api.Unit) {
+	for i, unit := range units {
+		if unit.Shell {
+			// Shell units get their variables expanded by the shell
+			// at runtime, don't expand them here.
+			continue
+		}
 		mapping := expansion.MappingFuncFor(EnvVarsToMap(unit.Env))
 		if len(unit.Command) != 0 {
 			command := make([]string, len(unit.Command))
 			for j, cmd := range unit.Command {
 				command[j] = expansion.Expand(cmd, mapping)
 			}
-			spec.Units[i].Command = command
+			units[i].Command = command
 		}
 		if len(unit.Args) != 0 {
 			args := make([]string, len(unit.Args))
 			for j, arg := range unit.Args {
 				args[j] = expansion.Expand(arg, mapping)
 			}
-			spec.Units[i].Args = args
+			units[i].Args = args
+		}
+	}
+}
+
+// WrapShellCommands rewrites units that have Shell set so their
+// command runs through /bin/sh -c. The command string and any args are
+// joined with spaces into a single shell command line.
+func WrapShellCommands(spec api.PodSpec) api.PodSpec {
+	for i, unit := range spec.Units {
+		if !unit.Shell || len(unit.Command) == 0 {
+			continue
 		}
+		cmdline := strings.Join(append(unit.Command, unit.Args...), " ")
+		spec.Units[i].Command = []string{"/bin/sh", "-c", cmdline}
+		spec.Units[i].Args = nil
 	}
 	return spec
 }
@@ -0,0 +1,100 @@
+/*
+Copyright 2020 Elotl Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"testing"
+
+	"github.com/elotl/kip/pkg/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandCommandAndArgs(t *testing.T) {
+	spec := api.PodSpec{
+		Units: []api.Unit{
+			{
+				Name:    "expanded",
+				Command: []string{"echo", "$(FOO)"},
+				Args:    []string{"$(FOO)-suffix"},
+				Env:     []api.EnvVar{{Name: "FOO", Value: "bar"}},
+			},
+			{
+				Name:    "shell-skipped",
+				Command: []string{"echo $(FOO)"},
+				Env:     []api.EnvVar{{Name: "FOO", Value: "bar"}},
+				Shell:   true,
+			},
+		},
+	}
+	spec = ExpandCommandAndArgs(spec)
+	assert.Equal(t, []string{"echo", "bar"}, spec.Units[0].Command)
+	assert.Equal(t, []string{"bar-suffix"}, spec.Units[0].Args)
+	// Shell units are expanded by the shell at runtime.
+	assert.Equal(t, []string{"echo $(FOO)"}, spec.Units[1].Command)
+}
+
+func TestExpandCommandAndArgsEscaping(t *testing.T) {
+	spec := api.PodSpec{
+		InitUnits: []api.Unit{
+			{
+				Name:    "init",
+				Command: []string{"sh-like", "$(FOO)"},
+				Env:     []api.EnvVar{{Name: "FOO", Value: "bar"}},
+			},
+		},
+		Units: []api.Unit{
+			{
+				Name: "escapes",
+				Command: []string{
+					"$$(FOO)",      // $$ escapes the reference
+					"$$",           // a lone escaped dollar
+					"$(NO_SUCH)",   // unresolved references stay literal
+					"$(FOO)$(FOO)", // adjacent references both expand
+				},
+				Env: []api.EnvVar{{Name: "FOO", Value: "bar"}},
+			},
+		},
+	}
+	spec = ExpandCommandAndArgs(spec)
+	assert.Equal(t, []string{"sh-like", "bar"}, spec.InitUnits[0].Command)
+	assert.Equal(t,
+		[]string{"$(FOO)", "$", "$(NO_SUCH)", "barbar"},
+		spec.Units[0].Command)
+}
+
+func TestWrapShellCommands(t *testing.T) {
+	spec := api.PodSpec{
+		Units: []api.Unit{
+			{
+				Name:    "direct",
+				Command: []string{"echo", "hello"},
+			},
+			{
+				Name:    "shell",
+				Command: []string{"echo $FOO | grep bar"},
+				Args:    []string{"extra"},
+				Shell:   true,
+			},
+		},
+	}
+	spec = WrapShellCommands(spec)
+	assert.Equal(t, []string{"echo", "hello"}, spec.Units[0].Command)
+	assert.Equal(t,
+		[]string{"/bin/sh", "-c", "echo $FOO | grep bar extra"},
+		spec.Units[1].Command)
+	assert.Nil(t, spec.Units[1].Args)
+}
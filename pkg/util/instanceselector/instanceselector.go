@@ -43,6 +43,12 @@ type InstanceData struct {
 	CPU               float32        `json:"cpu"`
 	Burstable         bool           `json:"burstable"`
 	Baseline          float32        `json:"baseline"`
+	// Memory of a single GPU accelerator in GiB. Not all instance
+	// data files carry this, see gpuMemoryGiB for the fallbacks.
+	GPUMemory float32 `json:"gpuMemory,omitempty"`
+	// Network performance class, e.g. "Up to 10 Gigabit". Not all
+	// instance data files carry this.
+	NetworkPerformance string `json:"networkPerformance,omitempty"`
 }
 
 // CustomInstanceData holds instance type information for custom sized
@@ -69,6 +75,69 @@ type instanceSelector struct {
 	// eventually need to make the GPU spec vary as well
 	memorySpecParser          func(resource.Quantity) float32
 	containerInstanceSelector func(*api.ResourceSpec) (int64, int64, error)
+	// Tells us whether an instance type comes with local
+	// instance-store (ephemeral NVMe) disks. Pods that request an
+	// instance-store backed emptyDir can only run on those types.
+	instanceStoreSupported func(instanceType string) bool
+	sizeBounds             sizeBounds
+}
+
+// sizeBounds floors and caps the instance types autosizing may pick,
+// a cost guardrail against mis-specified pods landing on huge
+// instances. Zero values leave the corresponding dimension
+// unbounded. Memory is in the cloud's native unit, same as
+// InstanceData.Memory.
+type sizeBounds struct {
+	minCPU    float32
+	minMemory float32
+	maxCPU    float32
+	maxMemory float32
+}
+
+func (b sizeBounds) active() bool {
+	return b != sizeBounds{}
+}
+
+func (b sizeBounds) allows(inst InstanceData) bool {
+	if b.minCPU > 0 && inst.CPU < b.minCPU {
+		return false
+	}
+	if b.maxCPU > 0 && inst.CPU > b.maxCPU {
+		return false
+	}
+	if b.minMemory > 0 && inst.Memory < b.minMemory {
+		return false
+	}
+	if b.maxMemory > 0 && inst.Memory > b.maxMemory {
+		return false
+	}
+	return true
+}
+
+// SetSizeBounds constrains the instance types autosizing may pick to
+// ones with at least the min and at most the max vCPUs and memory.
+// Empty strings leave a dimension unbounded. Pods with an explicit
+// instanceType are unaffected. Must be called after Setup.
+func SetSizeBounds(minCPU, minMemory, maxCPU, maxMemory string) error {
+	if selector == nil {
+		return fmt.Errorf("instanceselector has not been initialized")
+	}
+	var bounds sizeBounds
+	var err error
+	if bounds.minCPU, err = parseCPUSpec(minCPU); err != nil {
+		return util.WrapError(err, "Error parsing minimum CPU bound")
+	}
+	if bounds.maxCPU, err = parseCPUSpec(maxCPU); err != nil {
+		return util.WrapError(err, "Error parsing maximum CPU bound")
+	}
+	if bounds.minMemory, err = selector.parseMemorySpec(minMemory); err != nil {
+		return util.WrapError(err, "Error parsing minimum memory bound")
+	}
+	if bounds.maxMemory, err = selector.parseMemorySpec(maxMemory); err != nil {
+		return util.WrapError(err, "Error parsing maximum memory bound")
+	}
+	selector.sizeBounds = bounds
+	return nil
 }
 
 var selector *instanceSelector
@@ -94,6 +163,7 @@ func Setup(cloud, region, zone, defaultInstanceType string) error {
 				return util.ToGiBFloat32(&q)
 			},
 			containerInstanceSelector: FargateInstanceSelector,
+			instanceStoreSupported:    awsInstanceStoreSupported,
 		}
 	case "azure":
 		data, err := getSelectorData(azureInstanceJson, region)
@@ -109,6 +179,7 @@ func Setup(cloud, region, zone, defaultInstanceType string) error {
 				return util.ToGiBFloat32(&q)
 			},
 			containerInstanceSelector: AzureContainenrInstanceSelector,
+			instanceStoreSupported:    azureInstanceStoreSupported,
 		}
 	case "gce":
 		data, err := getSelectorData(gceInstanceJson, zone)
@@ -129,6 +200,12 @@ func Setup(cloud, region, zone, defaultInstanceType string) error {
 				return util.ToGiBFloat32(&q)
 			},
 			containerInstanceSelector: GCEContainenrInstanceSelector,
+			// GCE local SSDs are attached to an instance at launch
+			// instead of coming bundled with the instance type. We
+			// don't support attaching them yet.
+			instanceStoreSupported: func(instanceType string) bool {
+				return false
+			},
 		}
 		klog.Infof("custom instances in %s: %+v", zone, customData)
 	default:
@@ -187,6 +264,98 @@ func parseGPUSpec(gpuSpec string) (int, string, error) {
 	return count, typ, nil
 }
 
+// Per-accelerator memory in GiB for the GPU models that show up in
+// the instance data files' supportedGPUTypes.
+var gpuTypeMemoryGiB = map[string]float32{
+	"nvidia-tesla-k80":      12,
+	"nvidia-tesla-p4":       8,
+	"nvidia-tesla-p4-vws":   8,
+	"nvidia-tesla-p100":     16,
+	"nvidia-tesla-p100-vws": 16,
+	"nvidia-tesla-t4":       16,
+	"nvidia-tesla-t4-vws":   16,
+	"nvidia-tesla-v100":     16,
+}
+
+// Per-accelerator memory in GiB for AWS GPU instance families, whose
+// data files carry neither gpuMemory nor supported GPU types.
+var awsGPUFamilyMemoryGiB = map[string]float32{
+	"g2": 4,
+	"g3": 8,
+	"p2": 12,
+	"p3": 16,
+}
+
+// Accelerator model offered by each AWS GPU instance family. The AWS
+// data files don't carry supported GPU types, so a requested GPU type
+// is mapped to instance families instead.
+var awsGPUFamilyTypes = map[string]string{
+	"g2":   "nvidia-grid-k520",
+	"g3":   "nvidia-tesla-m60",
+	"g3s":  "nvidia-tesla-m60",
+	"g4dn": "nvidia-tesla-t4",
+	"p2":   "nvidia-tesla-k80",
+	"p3":   "nvidia-tesla-v100",
+	"p3dn": "nvidia-tesla-v100",
+	"p4d":  "nvidia-a100",
+}
+
+// instanceOffersGPUType reports whether the instance type offers at
+// least count accelerators of the requested model. GCE instance data
+// lists the supported GPU types directly; for AWS the accelerator is
+// derived from the instance family.
+func instanceOffersGPUType(inst InstanceData, gpuType string, count int) bool {
+	if len(inst.SupportedGPUTypes) > 0 {
+		return inst.SupportedGPUTypes[gpuType] >= count
+	}
+	family := strings.SplitN(inst.InstanceType, ".", 2)[0]
+	return awsGPUFamilyTypes[family] == gpuType && inst.GPU >= count
+}
+
+// availableGPUTypes lists the accelerator models offered by the
+// instance types in the selector's region, for the error shown when a
+// requested GPU type isn't available.
+func (instSel *instanceSelector) availableGPUTypes() []string {
+	types := sets.NewString()
+	for _, inst := range instSel.instanceData {
+		for typ := range inst.SupportedGPUTypes {
+			types.Insert(typ)
+		}
+		if inst.GPU > 0 {
+			family := strings.SplitN(inst.InstanceType, ".", 2)[0]
+			if typ, ok := awsGPUFamilyTypes[family]; ok {
+				types.Insert(typ)
+			}
+		}
+	}
+	return types.List()
+}
+
+// gpuMemoryGiB is the per-accelerator memory of an instance type in
+// GiB, or 0 if we don't know it. The instance data's gpuMemory wins
+// when present. Otherwise we fall back to the known memory of the
+// requested GPU type or, without a type constraint, the largest
+// accelerator offered on the instance type.
+func gpuMemoryGiB(inst InstanceData, gpuType string) float32 {
+	if inst.GPUMemory > 0 {
+		return inst.GPUMemory
+	}
+	if gpuType != "" {
+		return gpuTypeMemoryGiB[gpuType]
+	}
+	var most float32
+	for typ := range inst.SupportedGPUTypes {
+		if mem := gpuTypeMemoryGiB[typ]; mem > most {
+			most = mem
+		}
+	}
+	if most == 0.0 {
+		parts := strings.SplitN(inst.InstanceType, ".", 2)
+		most = awsGPUFamilyMemoryGiB[parts[0]]
+	}
+	return most
+}
+
 func parseCPUSpec(cpuSpec string) (float32, error) {
 	if cpuSpec == "" {
 		return 0.0, nil
@@ -317,7 +486,7 @@ func toInstanceData(data []CustomInstanceData, memoryRequirement, cpuRequirement
 // the t2.Unlimited option from AWS. For T2 instances, we try to
 // figure out what percentage of a CPU a user will likely use and
 // use that to compute t2.Unlimited cost.
-func (instSel *instanceSelector) getInstanceFromResources(rs api.ResourceSpec, instanceTypeGlob string) (string, bool) {
+func (instSel *instanceSelector) getInstanceFromResources(rs api.ResourceSpec, instanceTypeGlob string, needsInstanceStore, needsHugePages bool) (string, bool) {
 	memoryRequirement, err := instSel.parseMemorySpec(rs.Memory)
 	if err != nil {
 		klog.Errorf("Error parsing memory spec: %s", err)
@@ -330,6 +499,16 @@ func (instSel *instanceSelector) getInstanceFromResources(rs api.ResourceSpec, i
 	if err != nil {
 		klog.Errorf("Error parsing GPU spec: %s", err)
 	}
+	if rs.GPUType != "" {
+		gpuTypeRequirements = strings.ToLower(rs.GPUType)
+	}
+	if gpuTypeRequirements != "" && gpuCountRequirements == 0 {
+		gpuCountRequirements = 1
+	}
+	gpuMemoryRequirement, err := instSel.parseMemorySpec(rs.GPUMemory)
+	if err != nil {
+		klog.Errorf("Error parsing GPU memory spec: %s", err)
+	}
 
 	matches := filterInstanceData(instSel.instanceData, func(inst InstanceData) bool {
 		return !IsUnsupportedInstance(inst.InstanceType)
@@ -350,13 +529,36 @@ func (instSel *instanceSelector) getInstanceFromResources(rs api.ResourceSpec, i
 		return glob.Glob(instanceTypeGlob, inst.InstanceType)
 	})
 
+	// Controller-level size bounds
+	matches = filterInstanceData(matches, func(inst InstanceData) bool {
+		return instSel.sizeBounds.allows(inst)
+	})
+
+	// Instance store
+	if needsInstanceStore {
+		matches = filterInstanceData(matches, func(inst InstanceData) bool {
+			return instSel.instanceStoreSupported(inst.InstanceType)
+		})
+	}
+
+	// Hugepages
+	if needsHugePages {
+		matches = filterInstanceData(matches, func(inst InstanceData) bool {
+			return !inst.Burstable
+		})
+	}
+
 	// GPU
 	matches = filterInstanceData(matches, func(inst InstanceData) bool {
 		if gpuTypeRequirements == "" {
-			return inst.GPU >= gpuCountRequirements
+			if inst.GPU < gpuCountRequirements {
+				return false
+			}
+		} else if !instanceOffersGPUType(inst, gpuTypeRequirements, gpuCountRequirements) {
+			return false
 		}
-		available := inst.SupportedGPUTypes[gpuTypeRequirements]
-		return available >= gpuCountRequirements
+		return gpuMemoryRequirement == 0.0 ||
+			gpuMemoryGiB(inst, gpuTypeRequirements) >= gpuMemoryRequirement
 	})
 
 	// CPU
@@ -401,11 +603,61 @@ func (instSel *instanceSelector) getInstanceFromResources(rs api.ResourceSpec, i
 	return cheapestInstance, cheapestIsSustained
 }
 
+// AWS instance families that come with instance-store volumes but
+// whose name doesn't follow the trailing "d" convention (e.g. c5d,
+// m5d, r5d, z1d).
+var awsInstanceStoreFamilies = sets.NewString(
+	"i2", "i3", "i3en", "d2", "d3", "d3en", "h1", "f1", "x1", "x1e", "p3dn",
+)
+
+func awsInstanceStoreSupported(instanceType string) bool {
+	parts := strings.SplitN(instanceType, ".", 2)
+	family := parts[0]
+	return strings.HasSuffix(family, "d") ||
+		awsInstanceStoreFamilies.Has(family)
+}
+
+func azureInstanceStoreSupported(instanceType string) bool {
+	// The Lsv2 series is the only one with directly mapped local NVMe
+	// disks.
+	return strings.HasPrefix(instanceType, "Standard_L")
+}
+
+// podNeedsInstanceStore is true if the pod asks for an
+// instance-store backed root disk or any of its emptyDir volumes
+// asks to be backed by a local instance-store disk.
+func podNeedsInstanceStore(ps *api.PodSpec) bool {
+	if ps.Resources.InstanceStoreRoot {
+		return true
+	}
+	for _, vol := range ps.Volumes {
+		if vol.EmptyDir != nil &&
+			vol.EmptyDir.Medium == api.StorageMediumInstanceStore {
+			return true
+		}
+	}
+	return false
+}
+
+// podNeedsHugePages is true if any of the pod's emptyDir volumes asks
+// to be backed by hugepages.
+func podNeedsHugePages(ps *api.PodSpec) bool {
+	for _, vol := range ps.Volumes {
+		if vol.EmptyDir != nil &&
+			vol.EmptyDir.Medium == api.StorageMediumHugePages {
+			return true
+		}
+	}
+	return false
+}
+
 func noResourceSpecified(ps *api.PodSpec) bool {
 	return ps.InstanceType == "" &&
 		ps.Resources.CPU == "" &&
 		ps.Resources.Memory == "" &&
-		ps.Resources.GPU == ""
+		ps.Resources.GPU == "" &&
+		ps.Resources.GPUType == "" &&
+		ps.Resources.GPUMemory == ""
 }
 
 // Used by validation code in Kip
@@ -419,15 +671,65 @@ func IsUnsupportedInstance(instanceType string) bool {
 		selector.unsupportedInstances.Has(instanceType)
 }
 
+// Catalog returns the instance type data the selector knows about for
+// the configured region, along with whether each type has local
+// instance-store disks.
+func Catalog() ([]InstanceData, error) {
+	if selector == nil {
+		msg := "fatal: instanceselector has not been initialized"
+		klog.Errorf(msg)
+		return nil, fmt.Errorf(msg)
+	}
+	return selector.instanceData, nil
+}
+
+// SupportsInstanceStore reports whether an instance type comes with
+// local instance-store disks.
+func SupportsInstanceStore(instanceType string) bool {
+	if selector == nil || selector.instanceStoreSupported == nil {
+		return false
+	}
+	return selector.instanceStoreSupported(instanceType)
+}
+
+// SupportsHugePages reports whether an instance type can reserve
+// hugepages for a hugetlbfs backed emptyDir. The shared-core
+// burstable types don't advertise hugepage support; instance types we
+// have no data for (e.g. custom instances) are assumed to support
+// them.
+func SupportsHugePages(instanceType string) bool {
+	if selector == nil {
+		return false
+	}
+	for _, inst := range selector.instanceData {
+		if inst.InstanceType == instanceType {
+			return !inst.Burstable
+		}
+	}
+	return true
+}
+
 func instanceTypeSpecified(instanceType string) bool {
 	return instanceType != "" && !strings.ContainsRune(instanceType, '*')
 }
 
 func ResourcesToInstanceType(ps *api.PodSpec) (string, *bool, error) {
 	if ps.Resources.ContainerInstance != nil && *ps.Resources.ContainerInstance {
+		if podNeedsHugePages(ps) {
+			msg := "container instances cannot back an emptyDir with hugepages, use a regular cell instead"
+			return "", nil, fmt.Errorf(msg)
+		}
 		return api.ContainerInstanceType, nil, nil
 	}
 	if instanceTypeSpecified(ps.InstanceType) {
+		if podNeedsInstanceStore(ps) && !SupportsInstanceStore(ps.InstanceType) {
+			msg := fmt.Sprintf("instance type %s has no local instance-store disks but the pod asks for instance-store backed storage", ps.InstanceType)
+			return "", nil, fmt.Errorf(msg)
+		}
+		if podNeedsHugePages(ps) && !SupportsHugePages(ps.InstanceType) {
+			msg := fmt.Sprintf("instance type %s does not advertise hugepage support but the pod asks for a hugepages backed emptyDir", ps.InstanceType)
+			return "", nil, fmt.Errorf(msg)
+		}
 		var sustainedCPU *bool
 		if ps.Resources.SustainedCPU != nil {
 			sustainedCPU = ps.Resources.SustainedCPU
@@ -439,12 +741,32 @@ func ResourcesToInstanceType(ps *api.PodSpec) (string, *bool, error) {
 		klog.Errorf(msg)
 		return "", nil, fmt.Errorf(msg)
 	}
-	if ps.InstanceType == "" && noResourceSpecified(ps) {
+	needsInstanceStore := podNeedsInstanceStore(ps)
+	needsHugePages := podNeedsHugePages(ps)
+	if ps.InstanceType == "" && noResourceSpecified(ps) &&
+		!needsInstanceStore && !needsHugePages {
 		return selector.defaultInstanceType, nil, nil
 	}
 
-	instanceType, needsSustainedCPU := selector.getInstanceFromResources(ps.Resources, ps.InstanceType)
+	instanceType, needsSustainedCPU := selector.getInstanceFromResources(ps.Resources, ps.InstanceType, needsInstanceStore, needsHugePages)
 	if instanceType == "" {
+		if needsInstanceStore {
+			msg := "could not find an instance type with instance-store volumes satisfying the pod's resource constraints in this region. Note that data on instance-store backed disks is lost when the instance stops"
+			return "", nil, fmt.Errorf(msg)
+		}
+		if needsHugePages {
+			msg := "could not find an instance type with hugepage support satisfying the pod's resource constraints in this region"
+			return "", nil, fmt.Errorf(msg)
+		}
+		if ps.Resources.GPUType != "" {
+			msg := fmt.Sprintf("no instance type in this region offers GPU type %s and satisfies the pod's resource constraints, available GPU types: %s",
+				ps.Resources.GPUType, strings.Join(selector.availableGPUTypes(), ", "))
+			return "", nil, fmt.Errorf(msg)
+		}
+		if selector.sizeBounds.active() {
+			msg := "could not find an instance type satisfying the pod's resource constraints within the controller's configured instance size bounds. Either shrink the pod's resource requests or relax the bounds"
+			return "", nil, fmt.Errorf(msg)
+		}
 		msg := "could not compute instance type from Spec.Resources. It's likely that the Pod.Spec.Resources specify an instance that doesnt exist in the cloud"
 		return "", nil, fmt.Errorf(msg)
 	}
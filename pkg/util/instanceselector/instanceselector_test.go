@@ -70,6 +70,37 @@ func TestGCEDefaultGPUInstance(t *testing.T) {
 	assert.Equal(t, "n1-standard-1", inst)
 }
 
+func TestAWSGPUMemoryInstance(t *testing.T) {
+	defaultInstanceType := "t2.nano"
+	_ = Setup("aws", "us-east-1", "", defaultInstanceType)
+	ps := api.PodSpec{}
+	ps.Resources.GPU = "1"
+	ps.Resources.GPUMemory = "16Gi"
+	// p2 accelerators only have 12GiB, the cheapest 16GiB one is a p3
+	inst, _, err := ResourcesToInstanceType(&ps)
+	assert.NoError(t, err)
+	assert.Equal(t, "p3.2xlarge", inst)
+	ps.Resources.GPUMemory = "100Gi"
+	_, _, err = ResourcesToInstanceType(&ps)
+	assert.NotNil(t, err)
+}
+
+func TestGCEGPUMemoryInstance(t *testing.T) {
+	err := Setup("gce", "us-west-1", "us-west1-a", "f1-micro")
+	assert.NoError(t, err)
+	ps := api.PodSpec{}
+	ps.Resources.GPU = "1 nvidia-tesla-p100"
+	ps.Resources.GPUMemory = "16Gi"
+	ps.Resources.Memory = "3.75Gi"
+	inst, _, err := ResourcesToInstanceType(&ps)
+	assert.NoError(t, err)
+	assert.Equal(t, "n1-standard-1", inst)
+	// the k80 only has 12GiB per accelerator
+	ps.Resources.GPU = "1 nvidia-tesla-k80"
+	_, _, err = ResourcesToInstanceType(&ps)
+	assert.NotNil(t, err)
+}
+
 func TestGCESpecificGPUInstance(t *testing.T) {
 	err := Setup("gce", "us-west-1", "us-west1-a", "f1-micro")
 	assert.NoError(t, err)
@@ -81,6 +112,77 @@ func TestGCESpecificGPUInstance(t *testing.T) {
 	assert.Equal(t, "n1-standard-1", inst)
 }
 
+func TestInstanceOffersGPUType(t *testing.T) {
+	catalog := []InstanceData{
+		{
+			InstanceType: "fake-gce-type",
+			GPU:          4,
+			SupportedGPUTypes: map[string]int{
+				"nvidia-tesla-t4":   4,
+				"nvidia-tesla-p100": 1,
+			},
+		},
+		{
+			InstanceType: "p3.2xlarge",
+			GPU:          1,
+		},
+		{
+			InstanceType: "m5.large",
+		},
+	}
+	testCases := []struct {
+		instanceType string
+		gpuType      string
+		count        int
+		offers       bool
+	}{
+		{"fake-gce-type", "nvidia-tesla-t4", 2, true},
+		{"fake-gce-type", "nvidia-tesla-p100", 2, false},
+		{"fake-gce-type", "nvidia-tesla-k80", 1, false},
+		// AWS types don't list supported GPU types, the accelerator
+		// comes from the instance family.
+		{"p3.2xlarge", "nvidia-tesla-v100", 1, true},
+		{"p3.2xlarge", "nvidia-tesla-v100", 2, false},
+		{"p3.2xlarge", "nvidia-tesla-t4", 1, false},
+		{"m5.large", "nvidia-tesla-t4", 1, false},
+	}
+	byType := make(map[string]InstanceData)
+	for _, inst := range catalog {
+		byType[inst.InstanceType] = inst
+	}
+	for i, tc := range testCases {
+		offers := instanceOffersGPUType(byType[tc.instanceType], tc.gpuType, tc.count)
+		assert.Equal(t, tc.offers, offers, "test case %d", i)
+	}
+}
+
+func TestAWSGPUTypeInstance(t *testing.T) {
+	_ = Setup("aws", "us-east-1", "", "t2.nano")
+	ps := api.PodSpec{}
+	ps.Resources.GPUType = "nvidia-tesla-v100"
+	inst, _, err := ResourcesToInstanceType(&ps)
+	assert.NoError(t, err)
+	assert.Equal(t, "p3.2xlarge", inst)
+	// An accelerator no instance family in the region offers.
+	ps.Resources.GPUType = "nvidia-a100"
+	_, _, err = ResourcesToInstanceType(&ps)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "available GPU types")
+		assert.Contains(t, err.Error(), "nvidia-tesla-v100")
+	}
+}
+
+func TestGCEGPUTypeInstance(t *testing.T) {
+	err := Setup("gce", "us-west-1", "us-west1-a", "f1-micro")
+	assert.NoError(t, err)
+	ps := api.PodSpec{}
+	ps.Resources.GPUType = "nvidia-tesla-p100"
+	ps.Resources.Memory = "3.75Gi"
+	inst, _, err := ResourcesToInstanceType(&ps)
+	assert.NoError(t, err)
+	assert.Equal(t, "n1-standard-1", inst)
+}
+
 func TestHasInstanceType(t *testing.T) {
 	_ = Setup("aws", "us-east-1", "", "t2.nano")
 	ps := api.PodSpec{}
@@ -124,6 +226,118 @@ func TestNoMatch(t *testing.T) {
 	assert.NotNil(t, err)
 }
 
+func TestSizeBounds(t *testing.T) {
+	_ = Setup("aws", "us-east-1", "", "t2.nano")
+	// The floor pushes a tiny pod onto a bigger instance.
+	err := SetSizeBounds("2", "4Gi", "", "")
+	assert.NoError(t, err)
+	ps := api.PodSpec{}
+	ps.Resources.CPU = "0.5"
+	ps.Resources.Memory = "0.5Gi"
+	inst, _, err := ResourcesToInstanceType(&ps)
+	assert.NoError(t, err)
+	assert.NotEqual(t, "t3.nano", inst)
+	// The cap fails a pod that can only be satisfied above it.
+	err = SetSizeBounds("", "", "2", "4Gi")
+	assert.NoError(t, err)
+	ps.Resources.CPU = "16"
+	ps.Resources.Memory = "32Gi"
+	_, _, err = ResourcesToInstanceType(&ps)
+	assert.NotNil(t, err)
+	// A pod that fits within the cap still schedules.
+	ps.Resources.CPU = "1"
+	ps.Resources.Memory = "1Gi"
+	_, _, err = ResourcesToInstanceType(&ps)
+	assert.NoError(t, err)
+	// Malformed bounds are rejected.
+	assert.Error(t, SetSizeBounds("notacpu", "", "", ""))
+}
+
+func TestInstanceStoreEmptyDir(t *testing.T) {
+	_ = Setup("aws", "us-east-1", "", "t2.nano")
+	ps := api.PodSpec{}
+	ps.Volumes = []api.Volume{
+		{
+			Name: "scratch",
+			VolumeSource: api.VolumeSource{
+				EmptyDir: &api.EmptyDir{
+					Medium: api.StorageMediumInstanceStore,
+				},
+			},
+		},
+	}
+	inst, _, err := ResourcesToInstanceType(&ps)
+	assert.NoError(t, err)
+	assert.True(t, awsInstanceStoreSupported(inst))
+	// GCE doesn't bundle local SSDs with instance types so selection
+	// should fail with a clear error.
+	err = Setup("gce", "us-west-1", "us-west1-a", "f1-micro")
+	assert.NoError(t, err)
+	_, _, err = ResourcesToInstanceType(&ps)
+	assert.NotNil(t, err)
+}
+
+func TestInstanceStoreRoot(t *testing.T) {
+	_ = Setup("aws", "us-east-1", "", "t2.nano")
+	ps := api.PodSpec{}
+	ps.Resources.InstanceStoreRoot = true
+	inst, _, err := ResourcesToInstanceType(&ps)
+	assert.NoError(t, err)
+	assert.True(t, awsInstanceStoreSupported(inst))
+	// An explicit instance type without instance-store disks can't
+	// back an instance-store root.
+	ps.InstanceType = "t2.nano"
+	_, _, err = ResourcesToInstanceType(&ps)
+	assert.NotNil(t, err)
+	ps.InstanceType = "i3.xlarge"
+	inst, _, err = ResourcesToInstanceType(&ps)
+	assert.NoError(t, err)
+	assert.Equal(t, "i3.xlarge", inst)
+}
+
+func TestHugePagesEmptyDir(t *testing.T) {
+	_ = Setup("aws", "us-east-1", "", "t2.nano")
+	ps := api.PodSpec{}
+	ps.Volumes = []api.Volume{
+		{
+			Name: "hugetlb",
+			VolumeSource: api.VolumeSource{
+				EmptyDir: &api.EmptyDir{
+					Medium:    api.StorageMediumHugePages,
+					SizeLimit: 1 << 30,
+				},
+			},
+		},
+	}
+	// Autosizing skips the burstable types that don't advertise
+	// hugepage support.
+	inst, _, err := ResourcesToInstanceType(&ps)
+	assert.NoError(t, err)
+	assert.True(t, SupportsHugePages(inst))
+	// An explicit burstable instance type is rejected.
+	ps.InstanceType = "t2.nano"
+	_, _, err = ResourcesToInstanceType(&ps)
+	assert.NotNil(t, err)
+	ps.InstanceType = "c5.large"
+	inst, _, err = ResourcesToInstanceType(&ps)
+	assert.NoError(t, err)
+	assert.Equal(t, "c5.large", inst)
+	// Container instances can't reserve hugepages.
+	ps.InstanceType = ""
+	containerInstance := true
+	ps.Resources.ContainerInstance = &containerInstance
+	_, _, err = ResourcesToInstanceType(&ps)
+	assert.NotNil(t, err)
+}
+
+func TestAWSInstanceStoreSupported(t *testing.T) {
+	assert.True(t, awsInstanceStoreSupported("c5d.large"))
+	assert.True(t, awsInstanceStoreSupported("i3.xlarge"))
+	assert.True(t, awsInstanceStoreSupported("z1d.large"))
+	assert.False(t, awsInstanceStoreSupported("c5.large"))
+	assert.False(t, awsInstanceStoreSupported("t2.nano"))
+}
+
 type instanceTypeSpec struct {
 	Resources        api.ResourceSpec
 	instanceTypeGlob string
@@ -135,7 +349,7 @@ func runInstanceTypeTests(t *testing.T, testCases []instanceTypeSpec) {
 	for i, tc := range testCases {
 		msg := fmt.Sprintf("Test %d: instanceSpec: %#v, glob: %s",
 			i, tc.Resources, tc.instanceTypeGlob)
-		it, sus := selector.getInstanceFromResources(tc.Resources, tc.instanceTypeGlob)
+		it, sus := selector.getInstanceFromResources(tc.Resources, tc.instanceTypeGlob, false, false)
 		assert.Equal(t, tc.instanceType, it, msg)
 		assert.Equal(t, tc.sustainedCPU, sus, msg)
 	}
@@ -206,7 +420,7 @@ func TestAWSResourcesToInstanceType(t *testing.T) {
 	runInstanceTypeTests(t, testCases)
 }
 
-//func cheapestCustomInstanceSizeForCPUAndMemory(cid CustomInstanceData, memoryRequirement, cpuRequirement float32) (float32, float32, float32)
+// func cheapestCustomInstanceSizeForCPUAndMemory(cid CustomInstanceData, memoryRequirement, cpuRequirement float32) (float32, float32, float32)
 func TestCheapestCustomInstanceSizeForCPUAndMemory(t *testing.T) {
 	testCases := []struct {
 		Data   CustomInstanceData
@@ -60,3 +60,50 @@ func (t *LoopTimer) EndLoop() {
 	t.Average = time.Duration((int64(t.Average)*t.Count + int64(t.LastLoop)) / (t.Count + 1))
 	t.Count += 1
 }
+
+// Histogram counts duration observations into fixed buckets. Bounds
+// holds the inclusive upper bound of each bucket; observations above
+// the last bound land in a final overflow bucket, so Counts always
+// has one more entry than Bounds.
+type Histogram struct {
+	Bounds []time.Duration
+	Counts []int64
+	Count  int64
+	Sum    time.Duration
+	sync.Mutex
+}
+
+func NewHistogram(bounds []time.Duration) *Histogram {
+	return &Histogram{
+		Bounds: bounds,
+		Counts: make([]int64, len(bounds)+1),
+	}
+}
+
+func (h *Histogram) Observe(d time.Duration) {
+	h.Lock()
+	defer h.Unlock()
+
+	i := 0
+	for ; i < len(h.Bounds); i++ {
+		if d <= h.Bounds[i] {
+			break
+		}
+	}
+	h.Counts[i] += 1
+	h.Count += 1
+	h.Sum += d
+}
+
+func (h *Histogram) Copy() *Histogram {
+	h.Lock()
+	defer h.Unlock()
+	counts := make([]int64, len(h.Counts))
+	copy(counts, h.Counts)
+	return &Histogram{
+		Bounds: h.Bounds,
+		Counts: counts,
+		Count:  h.Count,
+		Sum:    h.Sum,
+	}
+}
@@ -0,0 +1,55 @@
+/*
+Copyright 2020 Elotl Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistogramObserve(t *testing.T) {
+	h := NewHistogram([]time.Duration{
+		30 * time.Second,
+		60 * time.Second,
+		2 * time.Minute,
+	})
+	timings := []time.Duration{
+		10 * time.Second,  // first bucket
+		30 * time.Second,  // first bucket, bounds are inclusive
+		45 * time.Second,  // second bucket
+		90 * time.Second,  // third bucket
+		10 * time.Minute,  // overflow
+		500 * time.Second, // overflow
+	}
+	for _, d := range timings {
+		h.Observe(d)
+	}
+	assert.Equal(t, []int64{2, 1, 1, 2}, h.Counts)
+	assert.Equal(t, int64(6), h.Count)
+	expectedSum := 10*time.Second + 30*time.Second + 45*time.Second +
+		90*time.Second + 10*time.Minute + 500*time.Second
+	assert.Equal(t, expectedSum, h.Sum)
+
+	// Copies are snapshots, later observations don't affect them.
+	snapshot := h.Copy()
+	h.Observe(1 * time.Second)
+	assert.Equal(t, int64(6), snapshot.Count)
+	assert.Equal(t, int64(7), h.Count)
+	assert.Equal(t, []int64{3, 1, 1, 2}, h.Counts)
+}